@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/portnyagin/practicum_project/internal/app"
+	"os"
+)
+
+func main() {
+	os.Exit(app.Bootstrap())
+}
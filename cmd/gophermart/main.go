@@ -1,7 +1,13 @@
 package main
 
-import "github.com/portnyagin/practicum_project/internal/app"
+import (
+	"github.com/portnyagin/practicum_project/internal/app"
+	"os"
+)
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(app.Migrate())
+	}
 	app.Start()
 }
@@ -7,17 +7,18 @@ require (
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible // indirect
 	github.com/gbrlsnchs/jwt/v3 v3.0.1 // indirect
 	github.com/go-chi/chi/v5 v5.0.5
-	github.com/go-chi/jwtauth/v5 v5.0.2 // indirect
+	github.com/go-chi/jwtauth/v5 v5.0.2
 	github.com/golang/mock v1.6.0
 	github.com/jackc/pgconn v1.10.0
-	github.com/jackc/pgerrcode v0.0.0-20201024163028-a0d42d470451 // indirect
+	github.com/jackc/pgerrcode v0.0.0-20201024163028-a0d42d470451
 	github.com/jackc/pgx/v4 v4.13.0
+	github.com/lestrrat-go/jwx v1.2.6
 	github.com/magefile/mage v1.11.0 // indirect
 	github.com/spf13/pflag v1.0.5
 	github.com/stretchr/testify v1.7.0
 	go.uber.org/atomic v1.9.0 // indirect
 	go.uber.org/multierr v1.7.0 // indirect
 	go.uber.org/zap v1.19.1
-	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519 // indirect
+	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519
 	golang.org/x/net v0.0.0-20210405180319-a5a99cb37ef4
 )
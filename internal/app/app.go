@@ -4,11 +4,11 @@ import (
 	"context"
 	"fmt"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	cfg "github.com/portnyagin/practicum_project/internal/app/config"
 	"github.com/portnyagin/practicum_project/internal/app/handler"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
 	"github.com/portnyagin/practicum_project/internal/app/infrastructure/client"
-	"github.com/portnyagin/practicum_project/internal/app/infrastructure/postgres"
-	"github.com/portnyagin/practicum_project/internal/app/repository"
 	"github.com/portnyagin/practicum_project/internal/app/service"
 	"go.uber.org/zap"
 	"log"
@@ -29,63 +29,104 @@ func Start() {
 		logger.Fatal("can't init configuration", zap.Error(err))
 	}
 
-	postgresHandlerTx, err := postgres.NewPostgresqlHandlerTX(context.Background(), config.DatabaseDSN, logger)
-	if err != nil {
-		logger.Fatal("can't create postgres handler", zap.Error(err))
+	if config.MetricsAddress != "" {
+		go serveMetrics(config.MetricsAddress, logger)
 	}
-
-	if config.Reinit {
-		err = repository.ClearDatabase(context.Background(), postgresHandlerTx)
-		if err != nil {
-			logger.Fatal("can't clear database structure", zap.Error(err))
-			return
-		}
+	repos, err := newRepositories(context.Background(), config, logger)
+	if err != nil {
+		logger.Fatal("can't init storage", zap.Error(err))
+		return
 	}
-	err = repository.InitDatabase(context.Background(), postgresHandlerTx)
+	router, accrualService, err := newRouter(config, repos, logger)
 	if err != nil {
-		logger.Fatal("can't init database structure", zap.Error(err))
+		logger.Fatal("can't init service", zap.Error(err))
 		return
 	}
 
-	userRepository, err := repository.NewUserRepository(postgresHandlerTx, logger)
+	go accrualService.StartProcessJob(1)
+	err = http.ListenAndServe(config.ServerAddress, router)
 	if err != nil {
-		logger.Fatal("can't init user repopsitory", zap.Error(err))
-		return
+		fmt.Println("can't start service")
+		fmt.Println(err)
 	}
+}
 
-	orderRepository, err := repository.NewOrderRepository(postgresHandlerTx, logger)
+// newRouter создает сервисы и обработчики поверх репозиториев хранилища
+func newRouter(config *cfg.AppConfig, repos *repositories, logger *infrastructure.Logger) (*chi.Mux, *service.AccrualService, error) {
+	passwordHasher, passwordPolicy, err := newPasswordRules(config.PasswordConfig)
 	if err != nil {
-		logger.Fatal("can't init order repopsitory", zap.Error(err))
-		return
+		return nil, nil, err
+	}
+	authService := service.NewAuthService(repos.user, repos.session, logger, passwordHasher, passwordPolicy)
+	orderService := service.NewOrderService(repos.order, logger, config.ValidateOrderNum)
+	transferLimits := service.TransferLimits{
+		MinAmount:  config.TransferMinAmount,
+		MaxAmount:  config.TransferMaxAmount,
+		DailyLimit: config.TransferDailyLimit,
 	}
-	balanceRepository, err := repository.NewBalanceRepository(postgresHandlerTx, logger)
+	withdrawalPolicy := service.NewWithdrawalPolicy(repos.balance, service.WithdrawalRules{
+		MinAmount:     config.WithdrawMinAmount,
+		MaxAmount:     config.WithdrawMaxAmount,
+		DailyLimit:    config.WithdrawDailyLimit,
+		MonthlyLimit:  config.WithdrawMonthlyLimit,
+		LargeAccrual:  config.WithdrawLargeAccrual,
+		CoolingPeriod: config.WithdrawCoolingPeriod,
+	}, logger)
+	balanceService := service.NewBalanceService(repos.balance, repos.user, logger, transferLimits, withdrawalPolicy, config.WithdrawAllowPartial)
+	auth, err := newAuth(config, logger)
 	if err != nil {
-		logger.Fatal("can't init balance repopsitory", zap.Error(err))
-		return
+		return nil, nil, fmt.Errorf("can't init token signing keys: %w", err)
 	}
-
-	authService := service.NewAuthService(userRepository, logger)
-	orderService := service.NewOrderService(orderRepository, logger, config.ValidateOrderNum)
-	balanceService := service.NewBalanceService(balanceRepository, logger)
-	auth := handler.NewAuth("secret")
-	authHandler := handler.NewAuthHandler(authService, auth, logger)
+	auth.SetAccessTokenTTL(config.AccessTokenTTL)
+	cookieSettings, err := newCookieSettings(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't init cookie settings: %w", err)
+	}
+	auth.SetCookieSettings(cookieSettings)
+	sessionService := service.NewSessionService(repos.session, repos.user, repos.tx, logger, config.RefreshTokenTTL)
+	securityAudit := service.NewSecurityAuditLog(repos.securityEvent, logger)
+	loginThrottle := service.NewLoginThrottle(repos.loginAttempt, securityAudit, service.LoginThrottleRules{
+		MaxFailures:   config.LoginMaxFailures,
+		IPMaxFailures: config.LoginIPMaxFailures,
+		Lockout:       config.LoginLockout,
+		FailureWindow: config.LoginFailureWindow,
+		DelayBase:     config.LoginDelayBase,
+		DelayMax:      config.LoginDelayMax,
+	}, logger)
+	authHandler := handler.NewAuthHandler(authService, sessionService, loginThrottle, auth, logger, config.TokenInBody)
+	apiKeyService := service.NewAPIKeyService(repos.apiKey, logger)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService, auth, logger)
 	orderHandler := handler.NewOrderHandler(orderService, auth, logger)
 	balanceHandler := handler.NewBalanceHandler(balanceService, auth, logger)
+	ledgerService := service.NewLedgerService(repos.ledger, repos.balance, logger)
+	adjustmentService := service.NewAdjustmentService(repos.adjustment, repos.balance, repos.user, logger, config.AdjustmentApprovalThreshold)
+	adminHandler := handler.NewAdminHandler(ledgerService, adjustmentService, authService, securityAudit, auth, logger)
 	router := chi.NewRouter()
+	// идентификатор запроса попадает в журнал запросов и в трассировку запросов к базе
+	router.Use(middleware.RequestID)
 
 	accrualClient := client.NewAccrualClient(config.AccrualServiceAddress, logger)
-	gophermartClient := client.NewGophermartClient(config.ServerAddress, logger)
-	accrualService := service.NewAccrualService(orderRepository, balanceRepository, accrualClient, gophermartClient, logger, config.EnableAccrual)
+	accrualService := service.NewAccrualService(repos.order, repos.balance, repos.tx, accrualClient, logger, config.EnableAccrual)
 	accrualHandler := handler.NewAccrualHandler(accrualService, logger)
 
-	publicRoutes(router, authHandler, accrualHandler, postgresHandlerTx, logger)
-	protectedOrderRoutes(router, auth.GetJWTAuth(), postgresHandlerTx, orderHandler, logger)
-	protectedBalanceRoutes(router, auth.GetJWTAuth(), postgresHandlerTx, balanceHandler, logger)
-
-	go accrualService.StartProcessJob(1)
-	err = http.ListenAndServe(config.ServerAddress, router)
-	if err != nil {
-		fmt.Println("can't start service")
-		fmt.Println(err)
+	publicRoutes(router, authHandler, config.TrustProxyHeaders, repos.tx, securityAudit, logger)
+	protectedAuthRoutes(router, auth, sessionService, config.CSRFProtection, repos.tx, authHandler, securityAudit, logger)
+	protectedOrderRoutes(router, auth, apiKeyService, sessionService, config.CSRFProtection, repos.tx, orderHandler, logger)
+	protectedBalanceRoutes(router, auth, apiKeyService, sessionService, config.CSRFProtection, repos.tx, balanceHandler, securityAudit, logger)
+	protectedAPIKeyRoutes(router, auth, sessionService, config.CSRFProtection, repos.tx, apiKeyHandler, securityAudit, logger)
+	adminRoutes(router, auth, sessionService, config.CSRFProtection, repos.tx, adminHandler, accrualHandler, securityAudit, logger)
+	if config.OIDCIssuer != "" {
+		oidcClient := client.NewOIDCClient(client.OIDCConfig{
+			Issuer:       config.OIDCIssuer,
+			ClientID:     config.OIDCClientID,
+			ClientSecret: config.OIDCClientSecret,
+			RedirectURL:  config.OIDCRedirectURL,
+			Scopes:       config.OIDCScopes,
+		}, logger)
+		oidcService := service.NewOIDCService(oidcClient, repos.user, repos.identity, logger)
+		oidcHandler := handler.NewOIDCHandler(oidcService, sessionService, auth, logger, config.OIDCPostLoginRedirect, config.TokenInBody)
+		oidcRoutes(router, oidcHandler, repos.tx, securityAudit, logger)
 	}
+
+	return router, accrualService, nil
 }
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/caarlos0/env/v6"
+	cfg "github.com/portnyagin/practicum_project/internal/app/config"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// memoryConfig - настройки сервиса на хранилище в памяти для тестов
+func memoryConfig(t *testing.T) *cfg.AppConfig {
+	config := cfg.NewConfig()
+	require.NoError(t, env.Parse(config))
+	config.Storage = cfg.StorageMemory
+	config.EnableAccrual = false
+	config.CSRFProtection = false
+	config.JWTSecret = "secret"
+	config.BcryptCost = 4
+	return config
+}
+
+func newTestServer(t *testing.T, config *cfg.AppConfig) (*httptest.Server, *repositories) {
+	logger, _ := zap.NewDevelopment()
+	repos, err := newRepositories(context.Background(), config, logger)
+	require.NoError(t, err)
+	router, _, err := newRouter(config, repos, logger)
+	require.NoError(t, err)
+	return httptest.NewServer(router), repos
+}
+
+// TestApp_MemoryStorage - сервис целиком на хранилище в памяти, без базы данных
+func TestApp_MemoryStorage(t *testing.T) {
+	server, repos := newTestServer(t, memoryConfig(t))
+	defer server.Close()
+
+	request := func(method string, path string, contentType string, body string, token string) *http.Response {
+		req, err := http.NewRequest(method, server.URL+path, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return res
+	}
+
+	res := request(http.MethodPost, "/api/user/register", "application/json", `{"login":"user","password":"correct horse battery"}`, "")
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	token := res.Header.Get("Authorization")
+	require.NotEmpty(t, token)
+
+	res = request(http.MethodPost, "/api/user/register", "application/json", `{"login":"user","password":"another horse battery"}`, "")
+	res.Body.Close()
+	assert.Equal(t, http.StatusConflict, res.StatusCode, "login is taken")
+
+	res = request(http.MethodPost, "/api/user/orders", "text/plain", "79927398713", token)
+	res.Body.Close()
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+	res = request(http.MethodPost, "/api/user/orders", "text/plain", "79927398713", token)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode, "order is already uploaded by the user")
+
+	res = request(http.MethodGet, "/api/user/orders", "", "", token)
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, string(body), `"number":"79927398713"`)
+
+	res = request(http.MethodGet, "/api/user/balance", "", "", token)
+	body, err = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, string(body), `"current":0`)
+
+	user, err := repos.user.GetUserByLogin(context.Background(), "user")
+	require.NoError(t, err)
+	account, err := repos.balance.GetAccount(context.Background(), user.ID)
+	require.NoError(t, err)
+	for _, amount := range []float32{100, 50} {
+		require.NoError(t, repos.balance.CreateOperation(context.Background(), &model.Operation{
+			AccountID:     account.ID,
+			OrderNum:      "79927398713",
+			OperationType: model.OperationCredit,
+			Amount:        amount,
+			ProcessedAt:   time.Now(),
+		}))
+	}
+	res = request(http.MethodGet, "/api/user/balance/statement/export", "", "", token)
+	body, err = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var statement []map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &statement))
+	assert.Len(t, statement, 2)
+}
+
+// TestApp_RefreshTokenReuse - отзыв сессии при повторном предъявлении refresh-токена сохраняется, хотя клиент получает 401
+func TestApp_RefreshTokenReuse(t *testing.T) {
+	config := memoryConfig(t)
+	config.TokenInBody = true
+	server, _ := newTestServer(t, config)
+	defer server.Close()
+
+	post := func(path string, body string) (int, dto.Token) {
+		res, err := http.Post(server.URL+path, "application/json", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var token dto.Token
+		if res.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&token))
+		}
+		return res.StatusCode, token
+	}
+	refresh := func(refreshToken string) (int, dto.Token) {
+		return post("/api/user/token/refresh", `{"refresh_token":"`+refreshToken+`"}`)
+	}
+
+	status, registered := post("/api/user/register", `{"login":"user","password":"correct horse battery"}`)
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, registered.RefreshToken)
+
+	status, refreshed := refresh(registered.RefreshToken)
+	require.Equal(t, http.StatusOK, status)
+
+	status, _ = refresh(registered.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, status, "replaced refresh token is reused")
+	status, _ = refresh(refreshed.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, status, "session is revoked after reuse")
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	cfg "github.com/portnyagin/practicum_project/internal/app/config"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure/postgres"
+	"github.com/portnyagin/practicum_project/internal/app/repository"
+	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
+	"github.com/portnyagin/practicum_project/internal/app/service"
+	"go.uber.org/zap"
+	"log"
+	"os"
+)
+
+// Audit проверяет целостность учета и печатает отчет в stdout.
+// Возвращает код завершения: 0 - расхождений нет или все они исправлены, 1 - найдены расхождения, а с Fix - остались
+// нарушения, которые исправление не затрагивает, 2 - ошибка
+func Audit() int {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatalf("can't initialize zap logger: %v", err)
+	}
+	defer logger.Sync()
+
+	config := cfg.NewAuditConfig()
+	if err = config.Init(); err != nil {
+		logger.Error("can't init configuration", zap.Error(err))
+		return 2
+	}
+	ctx := context.Background()
+	postgresHandlerTx, err := postgres.NewPostgresqlHandlerTX(ctx, config.DatabaseDSN, logger)
+	if err != nil {
+		logger.Error("can't create postgres handler", zap.Error(err))
+		return 2
+	}
+	defer postgresHandlerTx.Close()
+
+	ledgerRepository, err := repository.NewLedgerRepository(postgresHandlerTx, logger)
+	if err != nil {
+		logger.Error("can't init ledger repopsitory", zap.Error(err))
+		return 2
+	}
+	balanceRepository, err := repository.NewBalanceRepository(postgresHandlerTx, logger)
+	if err != nil {
+		logger.Error("can't init balance repopsitory", zap.Error(err))
+		return 2
+	}
+	ledgerService := service.NewLedgerService(ledgerRepository, balanceRepository, logger)
+
+	tx, err := postgresHandlerTx.NewTx(ctx)
+	if err != nil {
+		logger.Error("can't start transaction", zap.Error(err))
+		return 2
+	}
+	ctx = context.WithValue(ctx, basedbhandler.TransactionKey("tx"), tx)
+	report, err := ledgerService.Check(ctx, config.Fix)
+	if err != nil {
+		logger.Error("ledger audit failed", zap.Error(err))
+		if err = postgresHandlerTx.Rollback(ctx); err != nil {
+			logger.Error("can't rollback transaction", zap.Error(err))
+		}
+		return 2
+	}
+	if config.Fix {
+		err = postgresHandlerTx.Commit(ctx)
+	} else {
+		err = postgresHandlerTx.Rollback(ctx)
+	}
+	if err != nil {
+		logger.Error("can't finish transaction", zap.Error(err))
+		return 2
+	}
+
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		logger.Error("can't serialize report", zap.Error(err))
+		return 2
+	}
+	fmt.Fprintln(os.Stdout, string(out))
+	return auditExitCode(report, config.Fix)
+}
+
+// auditExitCode - код завершения проверки: после исправления важны только нарушения, которые оно не затрагивает
+func auditExitCode(report *dto.LedgerReport, fix bool) int {
+	if fix && !report.IsRepairable() || !fix && !report.IsConsistent() {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,29 @@
+package app
+
+import (
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_auditExitCode(t *testing.T) {
+	mismatch := []dto.AccountMismatch{{AccountID: 1}}
+	orphan := []dto.LedgerOperation{{ID: 1}}
+	tests := []struct {
+		name   string
+		report dto.LedgerReport
+		fix    bool
+		want   int
+	}{
+		{name: "auditExitCode. Case #1. Consistent", report: dto.LedgerReport{}, want: 0},
+		{name: "auditExitCode. Case #2. Mismatch found", report: dto.LedgerReport{AccountMismatches: mismatch}, want: 1},
+		{name: "auditExitCode. Case #3. Mismatch fixed", report: dto.LedgerReport{AccountMismatches: mismatch}, fix: true, want: 0},
+		{name: "auditExitCode. Case #4. Orphan operation remains after fix", report: dto.LedgerReport{AccountMismatches: mismatch, OrphanOperations: orphan}, fix: true, want: 1},
+		{name: "auditExitCode. Case #5. Order without credit remains after fix", report: dto.LedgerReport{OrdersWithoutCredit: []dto.LedgerOrder{{ID: 1}}}, fix: true, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, auditExitCode(&tt.report, tt.fix))
+		})
+	}
+}
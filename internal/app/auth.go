@@ -0,0 +1,79 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	cfg "github.com/portnyagin/practicum_project/internal/app/config"
+	"github.com/portnyagin/practicum_project/internal/app/handler"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/service"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+)
+
+func newAuth(config *cfg.AppConfig, logger *infrastructure.Logger) (*handler.Auth, error) {
+	if config.JWTKeysFile != "" {
+		keys, err := handler.LoadKeySet(config.JWTKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("token signing keys loaded", zap.String("file", config.JWTKeysFile), zap.Int("keys", keys.Len()))
+		return handler.NewAuthWithKeys(keys, config.JWTSigningKeyID)
+	}
+	if config.JWTSecret != "" {
+		return handler.NewAuth(config.JWTSecret), nil
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	logger.Warn("token signing key is not configured, random secret is used. Tokens will be invalid after restart")
+	return handler.NewAuth(hex.EncodeToString(b)), nil
+}
+
+func newCookieSettings(config *cfg.AppConfig) (handler.CookieSettings, error) {
+	settings := handler.CookieSettings{
+		Path:     config.CookiePath,
+		Domain:   config.CookieDomain,
+		Secure:   config.CookieSecure,
+		HttpOnly: config.CookieHTTPOnly,
+	}
+	switch strings.ToLower(config.CookieSameSite) {
+	case "lax":
+		settings.SameSite = http.SameSiteLaxMode
+	case "strict":
+		settings.SameSite = http.SameSiteStrictMode
+	case "none":
+		// браузеры принимают SameSite=None только вместе с Secure
+		if !settings.Secure {
+			return settings, fmt.Errorf("SameSite=None requires secure cookie")
+		}
+		settings.SameSite = http.SameSiteNoneMode
+	default:
+		return settings, fmt.Errorf("unknown SameSite mode %s", config.CookieSameSite)
+	}
+	return settings, nil
+}
+
+func newPasswordRules(config cfg.PasswordConfig) (*service.PasswordHasher, *service.PasswordPolicy, error) {
+	hasher, err := service.NewPasswordHasher(service.PasswordHashing{
+		Algorithm:     config.PasswordHash,
+		BcryptCost:    config.BcryptCost,
+		Argon2Time:    config.Argon2Time,
+		Argon2Memory:  config.Argon2Memory,
+		Argon2Threads: config.Argon2Threads,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't init password hashing: %w", err)
+	}
+	var banned []string
+	if config.PasswordBannedFile != "" {
+		banned, err = service.LoadBannedPasswords(config.PasswordBannedFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("can't load banned passwords: %w", err)
+		}
+	}
+	return hasher, service.NewPasswordPolicy(config.PasswordMinLength, banned), nil
+}
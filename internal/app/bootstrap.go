@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	cfg "github.com/portnyagin/practicum_project/internal/app/config"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure/postgres"
+	"github.com/portnyagin/practicum_project/internal/app/repository"
+	"github.com/portnyagin/practicum_project/internal/app/service"
+	"go.uber.org/zap"
+	"log"
+	"os"
+)
+
+// Bootstrap назначает роль администратора пользователю, при отсутствии пользователя создает его.
+// Возвращает код завершения: 0 - успешно, 2 - ошибка
+func Bootstrap() int {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatalf("can't initialize zap logger: %v", err)
+	}
+	defer logger.Sync()
+
+	config := cfg.NewBootstrapConfig()
+	if err = config.Init(); err != nil {
+		logger.Error("can't init configuration", zap.Error(err))
+		return 2
+	}
+	ctx := context.Background()
+	postgresHandlerTx, err := postgres.NewPostgresqlHandlerTX(ctx, config.DatabaseDSN, logger)
+	if err != nil {
+		logger.Error("can't create postgres handler", zap.Error(err))
+		return 2
+	}
+	defer postgresHandlerTx.Close()
+	if err = repository.InitDatabase(ctx, postgresHandlerTx, logger); err != nil {
+		logger.Error("can't init database structure", zap.Error(err))
+		return 2
+	}
+
+	userRepository, err := repository.NewUserRepository(postgresHandlerTx, logger)
+	if err != nil {
+		logger.Error("can't init user repopsitory", zap.Error(err))
+		return 2
+	}
+	sessionRepository, err := repository.NewSessionRepository(postgresHandlerTx, logger)
+	if err != nil {
+		logger.Error("can't init session repopsitory", zap.Error(err))
+		return 2
+	}
+	passwordHasher, passwordPolicy, err := newPasswordRules(config.PasswordConfig)
+	if err != nil {
+		logger.Error("can't init password rules", zap.Error(err))
+		return 2
+	}
+	authService := service.NewAuthService(userRepository, sessionRepository, logger, passwordHasher, passwordPolicy)
+
+	var created bool
+	err = postgresHandlerTx.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		var err error
+		created, err = authService.BootstrapAdmin(ctx, &dto.User{Login: config.AdminLogin, Pass: config.AdminPassword})
+		return err
+	})
+	if err != nil {
+		logger.Error("can't bootstrap administrator", zap.Error(err))
+		return 2
+	}
+	if created {
+		fmt.Fprintf(os.Stdout, "user %s created with role admin\n", config.AdminLogin)
+	} else {
+		fmt.Fprintf(os.Stdout, "role admin granted to user %s\n", config.AdminLogin)
+	}
+	return 0
+}
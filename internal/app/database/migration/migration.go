@@ -0,0 +1,301 @@
+package migration
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
+	"go.uber.org/zap"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockKey - ключ advisory lock, которым экземпляры сервиса исключают одновременное выполнение миграций
+const lockKey = 7120041
+
+const lockMigrations = "select pg_advisory_xact_lock($1)"
+
+// миграция может долго ждать блокировку и долго выполняться, таймауты сервиса к ней не применяются
+const disableTimeouts = "set local statement_timeout = 0; set local lock_timeout = 0;"
+
+const createMigrationsTable = "create table if not exists schema_migrations (\n" +
+	"version numeric primary key,\n" +
+	"name varchar not null,\n" +
+	"applied_at timestamp with time zone not null\n" +
+	");"
+
+const getAppliedMigrations = "select version, name, applied_at from schema_migrations order by version"
+
+const insertMigration = "INSERT INTO schema_migrations (version, name, applied_at) VALUES($1, $2, $3);"
+
+const deleteMigration = "DELETE FROM schema_migrations WHERE version=$1;"
+
+// имя файла миграции: <версия>_<название>.up.sql или <версия>_<название>.down.sql
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Unknown - миграция применена к базе, но отсутствует в сборке (база обновлена более новой версией сервиса)
+	Unknown bool
+}
+
+// Migrations возвращает миграции, встроенные в бинарный файл
+func Migrations() ([]Migration, error) {
+	dir, err := fs.Sub(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+	return Load(dir)
+}
+
+// Load читает миграции из корня fsys и упорядочивает их по версии. У каждой версии должны быть оба файла, up и down
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		parts := fileName.FindStringSubmatch(e.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("migration: unexpected file name %s", e.Name())
+		}
+		version, err := strconv.Atoi(parts[1])
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration: bad version in file name %s", e.Name())
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = m
+		}
+		if m.Name != parts[2] {
+			return nil, fmt.Errorf("migration: version %d has different names %s and %s", version, m.Name, parts[2])
+		}
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		if parts[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+	var res []Migration
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration: version %d must have both up and down scripts", m.Version)
+		}
+		res = append(res, *m)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+	return res, nil
+}
+
+type appliedMigration struct {
+	name      string
+	appliedAt time.Time
+}
+
+// Migrator применяет и откатывает миграции. Каждая миграция выполняется в отдельной транзакции под advisory lock,
+// поэтому одновременно запущенные экземпляры сервиса применяют миграции по очереди, и каждую - только один раз
+type Migrator struct {
+	h          basedbhandler.TransactionalDBHandler
+	migrations []Migration
+	log        *infrastructure.Logger
+}
+
+func NewMigrator(h basedbhandler.TransactionalDBHandler, migrations []Migration, log *infrastructure.Logger) *Migrator {
+	var target Migrator
+	target.h = h
+	target.migrations = migrations
+	target.log = log
+	return &target
+}
+
+// Latest - последняя версия среди миграций сборки
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up применяет еще не примененные миграции до версии target включительно, target = 0 - до последней.
+// Возвращает число примененных миграций
+func (m *Migrator) Up(ctx context.Context, target int) (int, error) {
+	if target == 0 {
+		target = m.Latest()
+	}
+	if target > m.Latest() {
+		return 0, fmt.Errorf("migration: version %d not found, latest is %d", target, m.Latest())
+	}
+	count := 0
+	for {
+		done, err := m.step(ctx, func(ctx context.Context, applied map[int]appliedMigration) (bool, error) {
+			for _, mg := range m.migrations {
+				if mg.Version > target {
+					break
+				}
+				if _, ok := applied[mg.Version]; ok {
+					continue
+				}
+				m.log.Info("Migrator: applying migration", zap.Int("version", mg.Version), zap.String("name", mg.Name))
+				if err := m.h.Execute(ctx, mg.Up); err != nil {
+					return false, fmt.Errorf("migration %d_%s up: %w", mg.Version, mg.Name, err)
+				}
+				if err := m.h.Execute(ctx, insertMigration, mg.Version, mg.Name, time.Now()); err != nil {
+					return false, err
+				}
+				return false, nil
+			}
+			return true, nil
+		})
+		if err != nil || done {
+			return count, err
+		}
+		count++
+	}
+}
+
+// Down откатывает примененные миграции с версией больше target, начиная с последней. target = 0 - откат всех миграций.
+// Возвращает число откаченных миграций
+func (m *Migrator) Down(ctx context.Context, target int) (int, error) {
+	if target < 0 {
+		return 0, fmt.Errorf("migration: bad target version %d", target)
+	}
+	known := make(map[int]bool)
+	for _, mg := range m.migrations {
+		known[mg.Version] = true
+	}
+	count := 0
+	for {
+		done, err := m.step(ctx, func(ctx context.Context, applied map[int]appliedMigration) (bool, error) {
+			for version := range applied {
+				if version > target && !known[version] {
+					return false, fmt.Errorf("migration: version %d is applied but unknown to this build, can't roll it back", version)
+				}
+			}
+			for i := len(m.migrations) - 1; i >= 0; i-- {
+				mg := m.migrations[i]
+				if mg.Version <= target {
+					break
+				}
+				if _, ok := applied[mg.Version]; !ok {
+					continue
+				}
+				m.log.Info("Migrator: rolling back migration", zap.Int("version", mg.Version), zap.String("name", mg.Name))
+				if err := m.h.Execute(ctx, mg.Down); err != nil {
+					return false, fmt.Errorf("migration %d_%s down: %w", mg.Version, mg.Name, err)
+				}
+				if err := m.h.Execute(ctx, deleteMigration, mg.Version); err != nil {
+					return false, err
+				}
+				return false, nil
+			}
+			return true, nil
+		})
+		if err != nil || done {
+			return count, err
+		}
+		count++
+	}
+}
+
+// Status возвращает состояние всех миграций сборки и примененных к базе, упорядоченное по версии
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var res []Status
+	_, err := m.step(ctx, func(ctx context.Context, applied map[int]appliedMigration) (bool, error) {
+		for _, mg := range m.migrations {
+			s := Status{Version: mg.Version, Name: mg.Name}
+			if a, ok := applied[mg.Version]; ok {
+				s.Applied = true
+				s.AppliedAt = a.appliedAt
+				delete(applied, mg.Version)
+			}
+			res = append(res, s)
+		}
+		for version, a := range applied {
+			res = append(res, Status{Version: version, Name: a.name, Applied: true, AppliedAt: a.appliedAt, Unknown: true})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+	return res, nil
+}
+
+// step выполняет fn в транзакции под блокировкой миграций. fn получает примененные версии и возвращает true,
+// если делать больше нечего. При ошибке транзакция откатывается
+func (m *Migrator) step(ctx context.Context, fn func(ctx context.Context, applied map[int]appliedMigration) (bool, error)) (bool, error) {
+	tx, err := m.h.NewTx(ctx)
+	if err != nil {
+		return false, err
+	}
+	ctx = context.WithValue(ctx, basedbhandler.TransactionKey("tx"), tx)
+	done, err := m.stepTx(ctx, fn)
+	if err != nil {
+		if e := m.h.Rollback(ctx); e != nil {
+			m.log.Error("Migrator: can't rollback transaction", zap.Error(e))
+		}
+		return false, err
+	}
+	return done, m.h.Commit(ctx)
+}
+
+func (m *Migrator) stepTx(ctx context.Context, fn func(ctx context.Context, applied map[int]appliedMigration) (bool, error)) (bool, error) {
+	if err := m.h.Execute(ctx, disableTimeouts); err != nil {
+		return false, err
+	}
+	if err := m.h.Execute(ctx, lockMigrations, lockKey); err != nil {
+		return false, err
+	}
+	if err := m.h.Execute(ctx, createMigrationsTable); err != nil {
+		return false, err
+	}
+	rows, err := m.h.Query(ctx, getAppliedMigrations)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var (
+			version int
+			a       appliedMigration
+		)
+		if err = rows.Scan(&version, &a.name, &a.appliedAt); err != nil {
+			return false, err
+		}
+		applied[version] = a
+	}
+	// миграции выполняются в той же транзакции, соединение должно быть свободно
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return false, err
+	}
+	return fn(ctx, applied)
+}
@@ -0,0 +1,257 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// fakeDB хранит таблицу schema_migrations в памяти и запоминает выполненные скрипты миграций
+type fakeDB struct {
+	applied   map[int]string
+	pending   map[int]string
+	scripts   []string
+	failOn    string
+	locks     int
+	commits   int
+	rollbacks int
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{applied: make(map[int]string)}
+}
+
+func (db *fakeDB) NewTx(ctx context.Context) (pgx.Tx, error) {
+	db.pending = make(map[int]string)
+	for k, v := range db.applied {
+		db.pending[k] = v
+	}
+	return nil, nil
+}
+
+func (db *fakeDB) Commit(ctx context.Context) error {
+	db.applied = db.pending
+	db.commits++
+	return nil
+}
+
+func (db *fakeDB) Rollback(ctx context.Context) error {
+	db.rollbacks++
+	return nil
+}
+
+func (db *fakeDB) Execute(ctx context.Context, statement string, args ...interface{}) error {
+	switch statement {
+	case lockMigrations:
+		db.locks++
+	case createMigrationsTable, disableTimeouts:
+	case insertMigration:
+		db.pending[args[0].(int)] = args[1].(string)
+	case deleteMigration:
+		delete(db.pending, args[0].(int))
+	default:
+		if statement == db.failOn {
+			return errors.New("syntax error")
+		}
+		db.scripts = append(db.scripts, statement)
+	}
+	return nil
+}
+
+func (db *fakeDB) ExecuteBatch(ctx context.Context, statement string, args [][]interface{}) error {
+	return nil
+}
+
+func (db *fakeDB) Query(ctx context.Context, statement string, args ...interface{}) (basedbhandler.Rows, error) {
+	rows := &fakeRows{i: -1}
+	for version, name := range db.pending {
+		rows.versions = append(rows.versions, version)
+		rows.names = append(rows.names, name)
+	}
+	return rows, nil
+}
+
+func (db *fakeDB) QueryRow(ctx context.Context, statement string, args ...interface{}) (basedbhandler.Row, error) {
+	return nil, errors.New("not implemented")
+}
+
+type fakeRows struct {
+	versions []int
+	names    []string
+	i        int
+}
+
+func (r *fakeRows) Next() bool {
+	r.i++
+	return r.i < len(r.versions)
+}
+
+func (r *fakeRows) Err() error {
+	return nil
+}
+
+func (r *fakeRows) Close() {
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"version", "name", "applied_at"}
+}
+
+func (r *fakeRows) Scan(dest ...interface{}) error {
+	*dest[0].(*int) = r.versions[r.i]
+	*dest[1].(*string) = r.names[r.i]
+	*dest[2].(*time.Time) = time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	return nil
+}
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "init", Up: "create 1", Down: "drop 1"},
+	{Version: 2, Name: "orders", Up: "create 2", Down: "drop 2"},
+	{Version: 3, Name: "index", Up: "create 3", Down: "drop 3"},
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []Migration
+		wantErr bool
+	}{
+		{
+			name: "Load. Case #1. Ordered by version",
+			files: fstest.MapFS{
+				"0010_index.up.sql":   {Data: []byte("create index")},
+				"0010_index.down.sql": {Data: []byte("drop index")},
+				"0002_init.up.sql":    {Data: []byte("create table")},
+				"0002_init.down.sql":  {Data: []byte("drop table")},
+			},
+			want: []Migration{
+				{Version: 2, Name: "init", Up: "create table", Down: "drop table"},
+				{Version: 10, Name: "index", Up: "create index", Down: "drop index"},
+			},
+		},
+		{
+			name: "Load. Case #2. No down script",
+			files: fstest.MapFS{
+				"0001_init.up.sql": {Data: []byte("create table")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Load. Case #3. Same version with different names",
+			files: fstest.MapFS{
+				"0001_init.up.sql":    {Data: []byte("create table")},
+				"0001_other.down.sql": {Data: []byte("drop table")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Load. Case #4. Bad file name",
+			files: fstest.MapFS{
+				"init.sql": {Data: []byte("create table")},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(tt.files)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	assert.Equal(t, 1, migrations[0].Version)
+}
+
+func TestMigrator_Up(t *testing.T) {
+	log, _ := zap.NewDevelopment()
+	ctx := context.Background()
+	db := newFakeDB()
+	db.applied[1] = "init"
+	target := NewMigrator(db, testMigrations, log)
+
+	count, err := target.Up(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []string{"create 2"}, db.scripts)
+
+	count, err = target.Up(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []string{"create 2", "create 3"}, db.scripts)
+	assert.Equal(t, map[int]string{1: "init", 2: "orders", 3: "index"}, db.applied)
+	assert.Equal(t, db.commits, db.locks, "each step must take the lock")
+
+	count, err = target.Up(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	_, err = target.Up(ctx, 4)
+	assert.Error(t, err)
+}
+
+func TestMigrator_UpFailure(t *testing.T) {
+	log, _ := zap.NewDevelopment()
+	db := newFakeDB()
+	db.failOn = "create 2"
+	target := NewMigrator(db, testMigrations, log)
+
+	count, err := target.Up(context.Background(), 0)
+	assert.Error(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 1, db.rollbacks)
+	assert.Equal(t, map[int]string{1: "init"}, db.applied)
+}
+
+func TestMigrator_Down(t *testing.T) {
+	log, _ := zap.NewDevelopment()
+	ctx := context.Background()
+	db := newFakeDB()
+	db.applied = map[int]string{1: "init", 2: "orders", 3: "index"}
+	target := NewMigrator(db, testMigrations, log)
+
+	count, err := target.Down(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []string{"drop 3", "drop 2"}, db.scripts)
+	assert.Equal(t, map[int]string{1: "init"}, db.applied)
+
+	db.applied[7] = "future"
+	_, err = target.Down(ctx, 0)
+	assert.Error(t, err, "unknown migration can't be rolled back")
+	assert.Equal(t, map[int]string{1: "init", 7: "future"}, db.applied)
+}
+
+func TestMigrator_Status(t *testing.T) {
+	log, _ := zap.NewDevelopment()
+	db := newFakeDB()
+	db.applied = map[int]string{1: "init", 7: "future"}
+	target := NewMigrator(db, testMigrations, log)
+
+	status, err := target.Status(context.Background())
+	require.NoError(t, err)
+	appliedAt := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []Status{
+		{Version: 1, Name: "init", Applied: true, AppliedAt: appliedAt},
+		{Version: 2, Name: "orders"},
+		{Version: 3, Name: "index"},
+		{Version: 7, Name: "future", Applied: true, AppliedAt: appliedAt, Unknown: true},
+	}, status)
+}
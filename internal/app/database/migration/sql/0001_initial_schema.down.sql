@@ -0,0 +1,13 @@
+drop table if exists security_events cascade;
+drop sequence if exists seq_security_event;
+drop function if exists security_events_append_only();
+drop table if exists login_attempts cascade;
+drop table if exists sessions cascade;
+drop table if exists adjustments cascade;
+drop table if exists operations cascade;
+drop table if exists orders cascade;
+drop table if exists accounts cascade;
+drop table if exists api_keys cascade;
+drop table if exists user_identities cascade;
+drop table if exists user_roles cascade;
+drop table if exists users cascade;
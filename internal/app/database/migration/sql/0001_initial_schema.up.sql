@@ -0,0 +1,143 @@
+-- Структура базы на момент перехода на миграции. Все объекты создаются с if not exists,
+-- чтобы миграция применялась и к базам, созданным прежним InitDatabase
+
+create table if not exists users (
+    id numeric primary key,
+    login varchar not null,
+    pass varchar not null,
+    active numeric not null default 1
+);
+create sequence if not exists seq_user increment by 1 no minvalue no maxvalue start with 1 cache 10 owned by users.id;
+create unique index if not exists user_login_idx on users (login);
+
+create table if not exists user_roles (
+    user_id numeric not null,
+    role varchar not null,
+    primary key (user_id, role)
+);
+
+create table if not exists user_identities (
+    issuer varchar not null,
+    subject varchar not null,
+    user_id numeric not null,
+    email varchar not null,
+    created_at timestamp with time zone not null,
+    primary key (issuer, subject)
+);
+create index if not exists user_identity_user_id_idx on user_identities (user_id);
+
+create table if not exists api_keys (
+    id varchar primary key,
+    user_id numeric not null,
+    name varchar not null,
+    key_hash varchar not null,
+    scopes varchar not null,
+    created_at timestamp with time zone not null,
+    revoked_at timestamp with time zone
+);
+create index if not exists api_key_user_id_idx on api_keys (user_id);
+
+create table if not exists accounts (
+    id numeric primary key,
+    user_id numeric not null,
+    balance numeric not null default 0,
+    debit numeric not null default 0,
+    credit numeric not null default 0
+);
+create sequence if not exists seq_account increment by 1 no minvalue no maxvalue start with 1 cache 10 owned by accounts.id;
+create unique index if not exists account_user_id_idx on accounts (user_id);
+
+create table if not exists orders (
+    id numeric primary key,
+    user_id numeric not null,
+    num varchar not null,
+    status varchar not null,
+    upload_at timestamp with time zone not null,
+    updated_at timestamp with time zone
+);
+create sequence if not exists seq_order increment by 1 no minvalue no maxvalue start with 1 cache 10 owned by orders.id;
+create index if not exists order_user_id_idx on orders (user_id, status);
+create unique index if not exists order_num_idx on orders (num);
+
+create table if not exists operations (
+    id numeric primary key,
+    account_id numeric not null,
+    order_id numeric not null,
+    order_num varchar not null,
+    operation_type varchar not null,
+    amount numeric not null,
+    processed_at timestamp with time zone not null
+);
+create sequence if not exists seq_operation increment by 1 no minvalue no maxvalue start with 1 cache 10 owned by operations.id;
+create index if not exists operation_account_id_idx on operations (account_id);
+create index if not exists operation_order_id_idx on operations (order_id);
+alter table operations add column if not exists transfer_ref varchar;
+create index if not exists operation_transfer_ref_idx on operations (transfer_ref);
+alter table operations add column if not exists reason varchar;
+alter table operations add column if not exists order_part numeric;
+create unique index if not exists operation_withdrawal_order_part_idx on operations (order_num, order_part)
+    where operation_type = 'DEBIT' and order_part is not null;
+alter table operations add column if not exists actor varchar;
+
+create table if not exists adjustments (
+    id numeric primary key,
+    user_id numeric not null,
+    amount numeric not null,
+    reason_code varchar not null,
+    comment varchar not null,
+    requested_by varchar not null,
+    approved_by varchar,
+    status varchar not null,
+    operation_id numeric,
+    created_at timestamp with time zone not null,
+    decided_at timestamp with time zone
+);
+create sequence if not exists seq_adjustment increment by 1 no minvalue no maxvalue start with 1 cache 10 owned by adjustments.id;
+create index if not exists adjustment_status_idx on adjustments (status);
+
+create table if not exists sessions (
+    id varchar primary key,
+    user_id numeric not null,
+    refresh_hash varchar not null,
+    previous_hash varchar,
+    created_at timestamp with time zone not null,
+    refreshed_at timestamp with time zone not null,
+    expires_at timestamp with time zone not null,
+    revoked_at timestamp with time zone
+);
+create unique index if not exists session_refresh_hash_idx on sessions (refresh_hash);
+create index if not exists session_previous_hash_idx on sessions (previous_hash);
+create index if not exists session_user_id_idx on sessions (user_id);
+
+create table if not exists login_attempts (
+    key varchar primary key,
+    failures numeric not null,
+    last_failure_at timestamp with time zone not null,
+    locked_until timestamp with time zone
+);
+
+-- журнал безопасности только пополняется: изменение и удаление записей запрещены триггером
+create sequence if not exists seq_security_event increment by 1 no minvalue no maxvalue start with 1 cache 10;
+create table if not exists security_events (
+    id numeric primary key default nextval('seq_security_event'),
+    action varchar not null,
+    result varchar not null,
+    user_id numeric,
+    login varchar not null,
+    target varchar not null,
+    ip varchar not null,
+    user_agent varchar not null,
+    details varchar not null,
+    created_at timestamp with time zone not null
+);
+create index if not exists security_event_created_at_idx on security_events (created_at);
+create index if not exists security_event_user_id_idx on security_events (user_id, created_at);
+create index if not exists security_event_action_idx on security_events (action, created_at);
+create or replace function security_events_append_only() returns trigger as $$
+begin
+    raise exception 'security_events is append-only';
+end;
+$$ language plpgsql;
+drop trigger if exists security_events_append_only on security_events;
+create trigger security_events_append_only before update or delete on security_events
+    for each row execute procedure security_events_append_only();
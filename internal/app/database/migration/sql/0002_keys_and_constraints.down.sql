@@ -0,0 +1,64 @@
+alter table accounts drop constraint if exists account_balance_check;
+alter table adjustments drop constraint if exists adjustment_status_check;
+alter table operations drop constraint if exists operation_amount_check;
+alter table operations drop constraint if exists operation_type_check;
+alter table orders drop constraint if exists order_status_check;
+
+alter table user_identities drop constraint if exists user_identity_user_id_fk;
+alter table api_keys drop constraint if exists api_key_user_id_fk;
+alter table user_roles drop constraint if exists user_role_user_id_fk;
+alter table sessions drop constraint if exists session_user_id_fk;
+alter table adjustments drop constraint if exists adjustment_operation_id_fk;
+alter table adjustments drop constraint if exists adjustment_user_id_fk;
+alter table operations drop constraint if exists operation_order_id_fk;
+alter table operations drop constraint if exists operation_account_id_fk;
+alter table orders drop constraint if exists order_user_id_fk;
+alter table accounts drop constraint if exists account_user_id_fk;
+
+alter table security_events alter column user_id type numeric;
+
+update operations set order_id = 0 where order_id is null;
+
+alter table user_identities alter column user_id type numeric;
+alter table api_keys alter column user_id type numeric;
+alter table user_roles alter column user_id type numeric;
+alter table sessions alter column user_id type numeric;
+alter table adjustments alter column operation_id type numeric;
+alter table adjustments alter column user_id type numeric;
+alter table operations alter column order_id set not null;
+alter table operations alter column order_id type numeric;
+alter table operations alter column account_id type numeric;
+alter table orders alter column user_id type numeric;
+alter table accounts alter column user_id type numeric;
+
+alter table security_events alter column id drop identity if exists;
+alter table security_events alter column id type numeric;
+create sequence if not exists seq_security_event increment by 1 no minvalue no maxvalue start with 1 cache 10;
+select setval('seq_security_event', (select coalesce(max(id), 0) + 1 from security_events), false);
+alter table security_events alter column id set default nextval('seq_security_event');
+
+alter table adjustments alter column id drop identity if exists;
+alter table adjustments alter column id type numeric;
+create sequence if not exists seq_adjustment increment by 1 no minvalue no maxvalue start with 1 cache 10 owned by adjustments.id;
+select setval('seq_adjustment', (select coalesce(max(id), 0) + 1 from adjustments), false);
+
+alter table operations alter column id drop identity if exists;
+alter table operations alter column id type numeric;
+create sequence if not exists seq_operation increment by 1 no minvalue no maxvalue start with 1 cache 10 owned by operations.id;
+select setval('seq_operation', (select coalesce(max(id), 0) + 1 from operations), false);
+
+-- до миграции заказы и операции нумеровались общей последовательностью seq_order
+alter table orders alter column id drop identity if exists;
+alter table orders alter column id type numeric;
+create sequence if not exists seq_order increment by 1 no minvalue no maxvalue start with 1 cache 10 owned by orders.id;
+select setval('seq_order', greatest((select coalesce(max(id), 0) from orders), (select coalesce(max(id), 0) from operations)) + 1, false);
+
+alter table accounts alter column id drop identity if exists;
+alter table accounts alter column id type numeric;
+create sequence if not exists seq_account increment by 1 no minvalue no maxvalue start with 1 cache 10 owned by accounts.id;
+select setval('seq_account', (select coalesce(max(id), 0) + 1 from accounts), false);
+
+alter table users alter column id drop identity if exists;
+alter table users alter column id type numeric;
+create sequence if not exists seq_user increment by 1 no minvalue no maxvalue start with 1 cache 10 owned by users.id;
+select setval('seq_user', (select coalesce(max(id), 0) + 1 from users), false);
@@ -0,0 +1,75 @@
+-- Ключи bigint с identity вместо numeric с отдельными последовательностями. Существующие идентификаторы сохраняются,
+-- identity продолжает нумерацию с максимального значения. Перед миграцией стоит проверить базу gophermart-audit:
+-- операции без счета или заказа не дадут создать внешние ключи
+
+alter table users alter column id type bigint;
+alter table users alter column id add generated by default as identity;
+select setval(pg_get_serial_sequence('users', 'id'), (select coalesce(max(id), 0) + 1 from users), false);
+drop sequence if exists seq_user;
+
+alter table accounts alter column id type bigint;
+alter table accounts alter column id add generated by default as identity;
+select setval(pg_get_serial_sequence('accounts', 'id'), (select coalesce(max(id), 0) + 1 from accounts), false);
+drop sequence if exists seq_account;
+
+alter table orders alter column id type bigint;
+alter table orders alter column id add generated by default as identity;
+select setval(pg_get_serial_sequence('orders', 'id'), (select coalesce(max(id), 0) + 1 from orders), false);
+drop sequence if exists seq_order;
+
+alter table operations alter column id type bigint;
+alter table operations alter column id add generated by default as identity;
+select setval(pg_get_serial_sequence('operations', 'id'), (select coalesce(max(id), 0) + 1 from operations), false);
+drop sequence if exists seq_operation;
+
+alter table adjustments alter column id type bigint;
+alter table adjustments alter column id add generated by default as identity;
+select setval(pg_get_serial_sequence('adjustments', 'id'), (select coalesce(max(id), 0) + 1 from adjustments), false);
+drop sequence if exists seq_adjustment;
+
+alter table security_events alter column id drop default;
+alter table security_events alter column id type bigint;
+alter table security_events alter column id add generated by default as identity;
+select setval(pg_get_serial_sequence('security_events', 'id'), (select coalesce(max(id), 0) + 1 from security_events), false);
+drop sequence if exists seq_security_event;
+
+alter table accounts alter column user_id type bigint;
+alter table orders alter column user_id type bigint;
+alter table operations alter column account_id type bigint;
+alter table operations alter column order_id type bigint;
+alter table operations alter column order_id drop not null;
+alter table adjustments alter column user_id type bigint;
+alter table adjustments alter column operation_id type bigint;
+alter table sessions alter column user_id type bigint;
+alter table user_roles alter column user_id type bigint;
+alter table api_keys alter column user_id type bigint;
+alter table user_identities alter column user_id type bigint;
+
+-- списания, переводы и корректировки не связаны с заказом: вместо 0 хранится null
+update operations set order_id = null where order_id = 0;
+
+-- журнал безопасности не ссылается на пользователей: он должен переживать любые изменения в остальных таблицах
+alter table security_events alter column user_id type bigint;
+
+alter table accounts add constraint account_user_id_fk foreign key (user_id) references users (id);
+alter table orders add constraint order_user_id_fk foreign key (user_id) references users (id);
+alter table operations add constraint operation_account_id_fk foreign key (account_id) references accounts (id);
+alter table operations add constraint operation_order_id_fk foreign key (order_id) references orders (id);
+alter table adjustments add constraint adjustment_user_id_fk foreign key (user_id) references users (id);
+alter table adjustments add constraint adjustment_operation_id_fk foreign key (operation_id) references operations (id);
+alter table sessions add constraint session_user_id_fk foreign key (user_id) references users (id);
+alter table user_roles add constraint user_role_user_id_fk foreign key (user_id) references users (id);
+alter table api_keys add constraint api_key_user_id_fk foreign key (user_id) references users (id);
+alter table user_identities add constraint user_identity_user_id_fk foreign key (user_id) references users (id);
+
+alter table orders add constraint order_status_check
+    check (status in ('NEW', 'REGISTERED', 'INVALID', 'PROCESSING', 'PROCESSED'));
+alter table operations add constraint operation_type_check
+    check (operation_type in ('DEBIT', 'CREDIT', 'ADJUSTMENT'));
+-- начисления и списания положительны, знак корректировки задает направление
+alter table operations add constraint operation_amount_check
+    check ((operation_type = 'ADJUSTMENT' and amount <> 0) or (operation_type <> 'ADJUSTMENT' and amount >= 0));
+alter table adjustments add constraint adjustment_status_check
+    check (status in ('PENDING', 'APPLIED', 'REJECTED'));
+alter table accounts add constraint account_balance_check
+    check (balance >= 0 and debit >= 0 and credit >= 0);
@@ -0,0 +1 @@
+alter table operations drop column if exists approved_by;
@@ -0,0 +1,6 @@
+-- Корректировка с подтверждением: в операции учета указывается и автор (actor), и подтвердивший администратор
+alter table operations add column if not exists approved_by varchar;
+update operations op set approved_by = adj.approved_by
+from adjustments adj
+where adj.operation_id = op.id
+and adj.approved_by is not null;
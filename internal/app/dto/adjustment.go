@@ -0,0 +1,23 @@
+package dto
+
+import "time"
+
+type AdjustmentRequest struct {
+	Login      string  `json:"login"`
+	Amount     float32 `json:"sum"`
+	ReasonCode string  `json:"reason_code"`
+	Comment    string  `json:"comment"`
+}
+
+type Adjustment struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	Amount      float32    `json:"sum"`
+	ReasonCode  string     `json:"reason_code"`
+	Comment     string     `json:"comment"`
+	RequestedBy string     `json:"requested_by"`
+	ApprovedBy  string     `json:"approved_by,omitempty"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DecidedAt   *time.Time `json:"decided_at,omitempty"`
+}
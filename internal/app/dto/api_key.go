@@ -0,0 +1,26 @@
+package dto
+
+import "time"
+
+type APIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// APIKey - описание ключа. Key заполняется только при создании: повторно получить ключ нельзя
+type APIKey struct {
+	ID        string     `json:"id"`
+	Key       string     `json:"key,omitempty"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// APIKeyPrincipal - владелец и права ключа, предъявленного в запросе
+type APIKeyPrincipal struct {
+	KeyID  string
+	UserID int
+	Login  string
+	Scopes []string
+}
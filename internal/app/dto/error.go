@@ -2,10 +2,14 @@ package dto
 
 import (
 	"errors"
+	"strings"
+	"time"
 )
 
 type Error struct {
-	Msg string `json:"msg"`
+	Msg        string      `json:"msg"`
+	Code       string      `json:"code,omitempty"`
+	Violations []Violation `json:"violations,omitempty"`
 }
 
 var ErrDuplicateKey = errors.New("duplicate key")
@@ -17,7 +21,87 @@ var ErrOrderRegistered = errors.New("order registered early")
 var ErrOrderRegisteredByAnotherUser = errors.New("order registered early by another user")
 
 var ErrNotEnoughFunds = errors.New("not enougth founds")
+var ErrWithdrawalDuplicate = errors.New("order already paid with points")
 var ErrBadOrderNum = errors.New("bad order num")
 
 var ErrTooManyRequest = errors.New("too many request to remote service")
 var ErrRemoteServiceError = errors.New("remote service error")
+
+var ErrRecipientNotFound = errors.New("recipient not found")
+var ErrTransferToSelf = errors.New("transfer to self")
+var ErrTransferLimit = errors.New("transfer limit exceeded")
+
+var ErrWithdrawalRejected = errors.New("withdrawal rejected by policy")
+
+const (
+	PolicyAmountTooSmall       = "AMOUNT_TOO_SMALL"
+	PolicyAmountTooLarge       = "AMOUNT_TOO_LARGE"
+	PolicyDailyLimitExceeded   = "DAILY_LIMIT_EXCEEDED"
+	PolicyMonthlyLimitExceeded = "MONTHLY_LIMIT_EXCEEDED"
+	PolicyCoolingPeriodActive  = "COOLING_PERIOD_ACTIVE"
+)
+
+// PolicyError - отказ в операции по одному из правил. errors.Is(err, ErrWithdrawalRejected) == true
+type PolicyError struct {
+	Code string
+}
+
+func (e *PolicyError) Error() string {
+	return ErrWithdrawalRejected.Error() + ": " + e.Code
+}
+
+func (e *PolicyError) Unwrap() error {
+	return ErrWithdrawalRejected
+}
+
+var ErrAdjustmentNotPending = errors.New("adjustment is not pending")
+var ErrSelfApproval = errors.New("adjustment can't be approved by its requester")
+
+var ErrSessionExpired = errors.New("session expired or revoked")
+var ErrWrongPassword = errors.New("wrong password")
+var ErrUnknownRole = errors.New("unknown role")
+
+const (
+	FieldRequired       = "REQUIRED"
+	PasswordTooShort    = "PASSWORD_TOO_SHORT"
+	PasswordBanned      = "PASSWORD_BANNED"
+	PasswordEqualsLogin = "PASSWORD_EQUALS_LOGIN"
+	UnknownScope        = "UNKNOWN_SCOPE"
+)
+
+type Violation struct {
+	Field string `json:"field"`
+	Code  string `json:"code"`
+}
+
+// ValidationError - ошибки проверки полей запроса. errors.Is(err, ErrBadParam) == true
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	codes := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		codes = append(codes, v.Field+":"+v.Code)
+	}
+	return ErrBadParam.Error() + ": " + strings.Join(codes, ", ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrBadParam
+}
+
+var ErrTooManyAttempts = errors.New("too many login attempts")
+
+// LockoutError - вход временно запрещен. errors.Is(err, ErrTooManyAttempts) == true
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return ErrTooManyAttempts.Error() + ", retry after " + e.RetryAfter.String()
+}
+
+func (e *LockoutError) Unwrap() error {
+	return ErrTooManyAttempts
+}
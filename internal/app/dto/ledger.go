@@ -0,0 +1,56 @@
+package dto
+
+import "time"
+
+type LedgerReport struct {
+	CheckedAt           time.Time          `json:"checked_at"`
+	AccountsChecked     int                `json:"accounts_checked"`
+	AccountMismatches   []AccountMismatch  `json:"account_mismatches"`
+	OrphanOperations    []LedgerOperation  `json:"orphan_operations"`
+	OrdersWithoutCredit []LedgerOrder      `json:"orders_without_credit"`
+	Corrections         []LedgerCorrection `json:"corrections,omitempty"`
+}
+
+type AccountMismatch struct {
+	AccountID        int     `json:"account_id"`
+	UserID           int     `json:"user_id"`
+	Balance          float32 `json:"balance"`
+	Debit            float32 `json:"debit"`
+	Credit           float32 `json:"credit"`
+	LedgerDebit      float32 `json:"ledger_debit"`
+	LedgerCredit     float32 `json:"ledger_credit"`
+	LedgerAdjustment float32 `json:"ledger_adjustment"`
+}
+
+type LedgerOperation struct {
+	ID            int       `json:"id"`
+	AccountID     int       `json:"account_id"`
+	OrderID       int       `json:"order_id"`
+	OrderNum      string    `json:"order"`
+	OperationType string    `json:"operation_type"`
+	Amount        float32   `json:"sum"`
+	ProcessedAt   time.Time `json:"processed_at"`
+}
+
+type LedgerOrder struct {
+	ID     int    `json:"id"`
+	UserID int    `json:"user_id"`
+	Num    string `json:"number"`
+	Status string `json:"status"`
+}
+
+type LedgerCorrection struct {
+	AccountID     int     `json:"account_id"`
+	OperationType string  `json:"operation_type,omitempty"`
+	Amount        float32 `json:"sum"`
+}
+
+func (r *LedgerReport) IsConsistent() bool {
+	return len(r.AccountMismatches) == 0 && len(r.OrphanOperations) == 0 && len(r.OrdersWithoutCredit) == 0
+}
+
+// IsRepairable - все найденные нарушения исправляются корректирующими операциями. Операции-сироты и заказы
+// без начисления исправление не затрагивает, они остаются после него
+func (r *LedgerReport) IsRepairable() bool {
+	return len(r.OrphanOperations) == 0 && len(r.OrdersWithoutCredit) == 0
+}
@@ -0,0 +1,20 @@
+package dto
+
+// OIDCAuthRequest - параметры начатого входа через OpenID Connect. State, Nonce и Verifier (PKCE) сохраняются
+// на стороне клиента до возврата от провайдера
+type OIDCAuthRequest struct {
+	URL      string
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+// OIDCIdentity - проверенные claims id_token провайдера
+type OIDCIdentity struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Nonce             string
+}
@@ -0,0 +1,57 @@
+package dto
+
+import "time"
+
+// Действия журнала безопасности
+const (
+	AuditRegister          = "REGISTER"
+	AuditLogin             = "LOGIN"
+	AuditLoginOIDC         = "LOGIN_OIDC"
+	AuditLoginLockout      = "LOGIN_LOCKOUT"
+	AuditLogout            = "LOGOUT"
+	AuditPasswordChange    = "PASSWORD_CHANGE"
+	AuditDeactivate        = "DEACTIVATE"
+	AuditWithdraw          = "WITHDRAW"
+	AuditTransfer          = "TRANSFER"
+	AuditAPIKeyCreate      = "API_KEY_CREATE"
+	AuditAPIKeyRevoke      = "API_KEY_REVOKE"
+	AuditLedgerFix         = "ADMIN_LEDGER_FIX"
+	AuditAdjustmentCreate  = "ADMIN_ADJUSTMENT_CREATE"
+	AuditAdjustmentApprove = "ADMIN_ADJUSTMENT_APPROVE"
+	AuditAdjustmentReject  = "ADMIN_ADJUSTMENT_REJECT"
+	AuditUserDeactivate    = "ADMIN_USER_DEACTIVATE"
+	AuditRoleGrant         = "ADMIN_ROLE_GRANT"
+	AuditRoleRevoke        = "ADMIN_ROLE_REVOKE"
+	AuditAccrualProcess    = "ADMIN_ACCRUAL_PROCESS"
+)
+
+// Результат действия
+const (
+	AuditSuccess = "SUCCESS"
+	AuditFailure = "FAILURE"
+)
+
+// SecurityEvent - запись журнала безопасности. UserID и Login - кто выполнил действие (для неудачного входа - введенный логин),
+// Target - над чем выполнено действие
+type SecurityEvent struct {
+	ID        int64     `json:"id"`
+	Action    string    `json:"action"`
+	Result    string    `json:"result"`
+	UserID    int       `json:"user_id,omitempty"`
+	Login     string    `json:"login,omitempty"`
+	Target    string    `json:"target,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Details   string    `json:"details,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SecurityEventFilter - условия выборки журнала. Пустые поля не ограничивают выборку, To не включается
+type SecurityEventFilter struct {
+	UserID int
+	Login  string
+	Action string
+	From   time.Time
+	To     time.Time
+	Limit  int
+}
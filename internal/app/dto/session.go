@@ -0,0 +1,23 @@
+package dto
+
+import "time"
+
+// Session - выданная пользователю сессия. RefreshToken заполняется только при создании и обновлении сессии
+type Session struct {
+	ID           string
+	UserID       int
+	Login        string
+	Roles        []string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
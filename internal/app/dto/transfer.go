@@ -0,0 +1,18 @@
+package dto
+
+import "time"
+
+type Transfer struct {
+	Recipient string  `json:"recipient"`
+	Amount    float32 `json:"sum"`
+}
+
+type StatementEntry struct {
+	OperationType string    `json:"operation_type"`
+	Amount        float32   `json:"sum"`
+	OrderNum      string    `json:"order,omitempty"`
+	TransferRef   string    `json:"transfer_ref,omitempty"`
+	Counterparty  string    `json:"counterparty,omitempty"`
+	Reason        string    `json:"reason,omitempty"`
+	ProcessedAt   time.Time `json:"processed_at"`
+}
@@ -5,3 +5,13 @@ type User struct {
 	Login string `json:"login"`
 	Pass  string `json:"password"`
 }
+
+type ChangePasswordRequest struct {
+	OldPass string `json:"old_password"`
+	NewPass string `json:"new_password"`
+}
+
+// DeactivateRequest - пользователь подтверждает удаление учетной записи паролем
+type DeactivateRequest struct {
+	Pass string `json:"password"`
+}
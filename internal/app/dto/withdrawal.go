@@ -7,6 +7,8 @@ type Withdrawal struct {
 	Amount      float32   `json:"sum"`
 	Status      string    `json:"status"`
 	ProcessedAt time.Time `json:"processed_at"`
+	Part        int       `json:"part,omitempty"`
+	OrderTotal  float32   `json:"order_total,omitempty"`
 }
 
 type Withdraw struct {
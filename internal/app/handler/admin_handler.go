@@ -0,0 +1,528 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//go:generate mockgen -destination=mocks/mock_ledger_service.go -package=mocks . LedgerService
+type LedgerService interface {
+	Check(ctx context.Context, fix bool) (*dto.LedgerReport, error)
+}
+
+//go:generate mockgen -destination=mocks/mock_adjustment_service.go -package=mocks . AdjustmentService
+type AdjustmentService interface {
+	Create(ctx context.Context, req *dto.AdjustmentRequest, admin string) (*dto.Adjustment, error)
+	Approve(ctx context.Context, adjustmentID int, admin string) (*dto.Adjustment, error)
+	Reject(ctx context.Context, adjustmentID int, admin string) (*dto.Adjustment, error)
+	GetList(ctx context.Context, status string) ([]dto.Adjustment, error)
+}
+
+//go:generate mockgen -destination=mocks/mock_user_admin_service.go -package=mocks . UserAdminService
+type UserAdminService interface {
+	DeactivateByLogin(ctx context.Context, login string) error
+	GrantRole(ctx context.Context, login string, role string) error
+	RevokeRole(ctx context.Context, login string, role string) error
+}
+
+//go:generate mockgen -destination=mocks/mock_security_event_service.go -package=mocks . SecurityEventService
+type SecurityEventService interface {
+	Find(ctx context.Context, filter *dto.SecurityEventFilter) ([]dto.SecurityEvent, error)
+	Export(ctx context.Context, filter *dto.SecurityEventFilter, fn func(event *dto.SecurityEvent) error) error
+}
+
+type AdminHandler struct {
+	ledgerService     LedgerService
+	adjustmentService AdjustmentService
+	userService       UserAdminService
+	eventService      SecurityEventService
+	auth              *Auth
+	log               *infrastructure.Logger
+}
+
+func NewAdminHandler(ls LedgerService, as AdjustmentService, us UserAdminService, es SecurityEventService, auth *Auth, l *infrastructure.Logger) *AdminHandler {
+	var target AdminHandler
+	target.ledgerService = ls
+	target.adjustmentService = as
+	target.userService = us
+	target.eventService = es
+	target.auth = auth
+	target.log = l
+	return &target
+}
+
+/*
+200 — проверка выполнена, отчет в теле ответа;
+401 — пользователь не авторизован;
+403 — недостаточно прав;
+500 — внутренняя ошибка сервера.
+*/
+func (h *AdminHandler) LedgerAudit(w http.ResponseWriter, r *http.Request) {
+	h.ledgerAudit(w, r, false)
+}
+
+/*
+200 — проверка выполнена, расхождения исправлены корректирующими операциями;
+401 — пользователь не авторизован;
+403 — недостаточно прав;
+500 — внутренняя ошибка сервера.
+*/
+func (h *AdminHandler) LedgerFix(w http.ResponseWriter, r *http.Request) {
+	h.ledgerAudit(w, r, true)
+}
+
+func (h *AdminHandler) ledgerAudit(w http.ResponseWriter, r *http.Request, fix bool) {
+	ctx := r.Context()
+	_, login, err := h.auth.GetFromContext(ctx)
+	if err != nil {
+		h.log.Error("AdminHandler:can't get params from the token", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	h.log.Info("Ledger audit requested", zap.String("admin", login), zap.Bool("fix", fix))
+	report, err := h.ledgerService.Check(ctx, fix)
+	if err != nil {
+		h.log.Error("AdminHandler:ledger audit error", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	responseBody, err := json.Marshal(report)
+	if err != nil {
+		h.log.Error("AdminHandler: can't serialize response", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if err = WriteResponse(w, http.StatusOK, responseBody); err != nil {
+		h.log.Error("AdminHandler: can't write response", zap.Error(err))
+	}
+}
+
+/*
+201 — корректировка применена;
+202 — корректировка ожидает подтверждения вторым администратором;
+400 — неверный формат запроса;
+401 — пользователь не авторизован;
+402 — на счету недостаточно средств для списания;
+403 — недостаточно прав;
+404 — пользователь не найден;
+500 — внутренняя ошибка сервера.
+*/
+func (h *AdminHandler) CreateAdjustment(w http.ResponseWriter, r *http.Request) {
+	b, err := getRequestBody(r)
+	if err != nil {
+		h.log.Error("AdminHandler:can't get request body", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	var req dto.AdjustmentRequest
+	if len(b) == 0 || json.Unmarshal(b, &req) != nil {
+		h.log.Info("AdminHandler:bad adjustment request body")
+		if err = WriteResponse(w, http.StatusBadRequest, ErrMessage("Неверный формат запроса")); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	ctx := r.Context()
+	_, admin, err := h.auth.GetFromContext(ctx)
+	if err != nil {
+		h.log.Error("AdminHandler:can't get params from the token", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	auditTarget(ctx, fmt.Sprintf("login=%s sum=%v reason=%s", req.Login, req.Amount, req.ReasonCode))
+	adjustment, err := h.adjustmentService.Create(ctx, &req, admin)
+	if err != nil {
+		h.writeAdjustmentError(w, err)
+		return
+	}
+	statusCode := http.StatusCreated
+	if adjustment.Status == "PENDING" {
+		statusCode = http.StatusAccepted
+	}
+	h.writeAdjustment(w, statusCode, adjustment)
+}
+
+/*
+200 — корректировка подтверждена и применена;
+401 — пользователь не авторизован;
+402 — на счету недостаточно средств для списания;
+403 — недостаточно прав или попытка подтвердить собственную корректировку;
+404 — корректировка не найдена;
+409 — корректировка уже обработана;
+500 — внутренняя ошибка сервера.
+*/
+func (h *AdminHandler) ApproveAdjustment(w http.ResponseWriter, r *http.Request) {
+	h.decideAdjustment(w, r, h.adjustmentService.Approve)
+}
+
+/*
+200 — корректировка отклонена;
+401 — пользователь не авторизован;
+403 — недостаточно прав;
+404 — корректировка не найдена;
+409 — корректировка уже обработана;
+500 — внутренняя ошибка сервера.
+*/
+func (h *AdminHandler) RejectAdjustment(w http.ResponseWriter, r *http.Request) {
+	h.decideAdjustment(w, r, h.adjustmentService.Reject)
+}
+
+/*
+200 — успешная обработка запроса;
+204 — нет данных для ответа;
+401 — пользователь не авторизован;
+403 — недостаточно прав;
+500 — внутренняя ошибка сервера.
+*/
+func (h *AdminHandler) GetAdjustmentList(w http.ResponseWriter, r *http.Request) {
+	res, err := h.adjustmentService.GetList(r.Context(), r.URL.Query().Get("status"))
+	if err != nil {
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if len(res) == 0 {
+		if err = WriteResponse(w, http.StatusNoContent, nil); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	responseBody, err := json.Marshal(res)
+	if err != nil {
+		h.log.Error("AdminHandler: can't serialize response", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if err = WriteResponse(w, http.StatusOK, responseBody); err != nil {
+		h.log.Error("AdminHandler: can't write response", zap.Error(err))
+	}
+}
+
+/*
+200 — учетная запись деактивирована, все сессии пользователя завершены;
+401 — пользователь не авторизован;
+403 — недостаточно прав;
+404 — пользователь не найден или уже деактивирован;
+500 — внутренняя ошибка сервера.
+*/
+func (h *AdminHandler) DeactivateUser(w http.ResponseWriter, r *http.Request) {
+	login := chi.URLParam(r, "login")
+	ctx := r.Context()
+	_, admin, err := h.auth.GetFromContext(ctx)
+	if err != nil {
+		h.log.Error("AdminHandler:can't get params from the token", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	err = h.userService.DeactivateByLogin(ctx, login)
+	if err != nil {
+		if errors.Is(err, dto.ErrNotFound) {
+			if err = WriteResponse(w, http.StatusNotFound, ErrMessage("Пользователь не найден")); err != nil {
+				h.log.Error("AdminHandler: can't write response", zap.Error(err))
+			}
+			return
+		}
+		h.log.Error("AdminHandler:can't deactivate user", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	h.log.Info("User deactivated by administrator", zap.String("login", login), zap.String("admin", admin))
+	if err = WriteResponse(w, http.StatusOK, nil); err != nil {
+		h.log.Error("AdminHandler: can't write response", zap.Error(err))
+	}
+}
+
+/*
+200 — роль назначена;
+400 — неизвестная роль;
+401 — пользователь не авторизован;
+403 — недостаточно прав;
+404 — пользователь не найден;
+500 — внутренняя ошибка сервера.
+*/
+func (h *AdminHandler) GrantRole(w http.ResponseWriter, r *http.Request) {
+	h.changeRole(w, r, h.userService.GrantRole)
+}
+
+/*
+200 — роль снята, действует после обновления токена пользователя;
+400 — неизвестная роль;
+401 — пользователь не авторизован;
+403 — недостаточно прав;
+404 — пользователь не найден;
+500 — внутренняя ошибка сервера.
+*/
+func (h *AdminHandler) RevokeRole(w http.ResponseWriter, r *http.Request) {
+	h.changeRole(w, r, h.userService.RevokeRole)
+}
+
+func (h *AdminHandler) changeRole(
+	w http.ResponseWriter,
+	r *http.Request,
+	change func(ctx context.Context, login string, role string) error,
+) {
+	login := chi.URLParam(r, "login")
+	role := chi.URLParam(r, "role")
+	ctx := r.Context()
+	_, admin, err := h.auth.GetFromContext(ctx)
+	if err != nil {
+		h.log.Error("AdminHandler:can't get params from the token", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	err = change(ctx, login, role)
+	if err != nil {
+		var (
+			statusCode int
+			msg        string
+		)
+		switch {
+		case errors.Is(err, dto.ErrUnknownRole):
+			statusCode = http.StatusBadRequest
+			msg = "Неизвестная роль"
+		case errors.Is(err, dto.ErrNotFound):
+			statusCode = http.StatusNotFound
+			msg = "Пользователь не найден"
+		default:
+			h.log.Error("AdminHandler:can't change role", zap.Error(err))
+			statusCode = http.StatusInternalServerError
+			msg = "Внутренняя ошибка сервера"
+		}
+		if err = WriteResponse(w, statusCode, ErrMessage(msg)); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	h.log.Info("User role changed by administrator", zap.String("login", login), zap.String("role", role), zap.String("admin", admin))
+	if err = WriteResponse(w, http.StatusOK, nil); err != nil {
+		h.log.Error("AdminHandler: can't write response", zap.Error(err))
+	}
+}
+
+func (h *AdminHandler) decideAdjustment(
+	w http.ResponseWriter,
+	r *http.Request,
+	decide func(ctx context.Context, adjustmentID int, admin string) (*dto.Adjustment, error),
+) {
+	adjustmentID, err := strconv.Atoi(chi.URLParam(r, "adjustmentID"))
+	if err != nil {
+		h.log.Info("AdminHandler:bad adjustment id", zap.String("RequestURI", r.RequestURI))
+		if err = WriteResponse(w, http.StatusBadRequest, ErrMessage("Неверный формат запроса")); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	ctx := r.Context()
+	_, admin, err := h.auth.GetFromContext(ctx)
+	if err != nil {
+		h.log.Error("AdminHandler:can't get params from the token", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	adjustment, err := decide(ctx, adjustmentID, admin)
+	if err != nil {
+		h.writeAdjustmentError(w, err)
+		return
+	}
+	h.writeAdjustment(w, http.StatusOK, adjustment)
+}
+
+func (h *AdminHandler) writeAdjustment(w http.ResponseWriter, statusCode int, adjustment *dto.Adjustment) {
+	responseBody, err := json.Marshal(adjustment)
+	if err != nil {
+		h.log.Error("AdminHandler: can't serialize response", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if err = WriteResponse(w, statusCode, responseBody); err != nil {
+		h.log.Error("AdminHandler: can't write response", zap.Error(err))
+	}
+}
+
+func (h *AdminHandler) writeAdjustmentError(w http.ResponseWriter, err error) {
+	var (
+		statusCode int
+		msg        string
+	)
+	h.log.Error("AdminHandler:adjustment error", zap.Error(err))
+	switch err {
+	case dto.ErrBadParam:
+		statusCode = http.StatusBadRequest
+		msg = "Неверный формат запроса"
+	case dto.ErrNotEnoughFunds:
+		statusCode = http.StatusPaymentRequired
+		msg = "На счету недостаточно средств"
+	case dto.ErrSelfApproval:
+		statusCode = http.StatusForbidden
+		msg = "Корректировку должен подтвердить другой администратор"
+	case dto.ErrNotFound:
+		statusCode = http.StatusNotFound
+		msg = "Не найдено"
+	case dto.ErrAdjustmentNotPending:
+		statusCode = http.StatusConflict
+		msg = "Корректировка уже обработана"
+	default:
+		statusCode = http.StatusInternalServerError
+		msg = "Внутренняя ошибка сервера"
+	}
+	if err = WriteResponse(w, statusCode, ErrMessage(msg)); err != nil {
+		h.log.Error("AdminHandler: can't write response", zap.Error(err))
+	}
+}
+
+/*
+200 — записи журнала безопасности в теле ответа, от новых к старым;
+204 — нет записей;
+400 — неверный формат параметров: user_id, login, action, from, to (RFC 3339), limit;
+401 — пользователь не авторизован;
+403 — недостаточно прав;
+500 — внутренняя ошибка сервера.
+*/
+func (h *AdminHandler) GetSecurityEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := securityEventFilter(r)
+	if err != nil {
+		h.log.Info("AdminHandler: bad security event filter", zap.Error(err))
+		if err = WriteResponse(w, http.StatusBadRequest, ErrMessage("Неверный формат запроса")); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	res, err := h.eventService.Find(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, dto.ErrBadParam) {
+			if err = WriteResponse(w, http.StatusBadRequest, ErrMessage("Неверный формат запроса")); err != nil {
+				h.log.Error("AdminHandler: can't write response", zap.Error(err))
+			}
+			return
+		}
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if len(res) == 0 {
+		if err = WriteResponse(w, http.StatusNoContent, nil); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	responseBody, err := json.Marshal(res)
+	if err != nil {
+		h.log.Error("AdminHandler: can't serialize response", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if err = WriteResponse(w, http.StatusOK, responseBody); err != nil {
+		h.log.Error("AdminHandler: can't write response", zap.Error(err))
+	}
+}
+
+/*
+200 — записи журнала безопасности в теле ответа, от новых к старым. Без limit выгружаются все записи по фильтру.
+Записи передаются по мере чтения из базы; если выгрузка прервалась, массив в ответе не закрыт;
+204 — нет записей;
+400 — неверный формат параметров: user_id, login, action, from, to (RFC 3339), limit;
+401 — пользователь не авторизован;
+403 — недостаточно прав;
+500 — внутренняя ошибка сервера.
+*/
+func (h *AdminHandler) ExportSecurityEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := securityEventFilter(r)
+	if err != nil {
+		h.log.Info("AdminHandler: bad security event filter", zap.Error(err))
+		if err = WriteResponse(w, http.StatusBadRequest, ErrMessage("Неверный формат запроса")); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	out := newJSONArrayWriter(w)
+	err = h.eventService.Export(r.Context(), filter, func(event *dto.SecurityEvent) error {
+		return out.Write(event)
+	})
+	if err != nil {
+		if out.Started() {
+			h.log.Error("AdminHandler: security event export interrupted", zap.Error(err))
+			return
+		}
+		if errors.Is(err, dto.ErrBadParam) {
+			if err = WriteResponse(w, http.StatusBadRequest, ErrMessage("Неверный формат запроса")); err != nil {
+				h.log.Error("AdminHandler: can't write response", zap.Error(err))
+			}
+			return
+		}
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if !out.Started() {
+		if err = WriteResponse(w, http.StatusNoContent, nil); err != nil {
+			h.log.Error("AdminHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if err = out.Close(); err != nil {
+		h.log.Error("AdminHandler: can't write response", zap.Error(err))
+	}
+}
+
+func securityEventFilter(r *http.Request) (*dto.SecurityEventFilter, error) {
+	q := r.URL.Query()
+	filter := dto.SecurityEventFilter{Login: q.Get("login"), Action: q.Get("action")}
+	var err error
+	if v := q.Get("user_id"); v != "" {
+		if filter.UserID, err = strconv.Atoi(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := q.Get("from"); v != "" {
+		if filter.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, err
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if filter.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, err
+		}
+	}
+	return &filter, nil
+}
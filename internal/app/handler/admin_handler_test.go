@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/handler/mocks"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminHandler_LedgerAudit(t *testing.T) {
+	type args struct {
+		fix    bool
+		report *dto.LedgerReport
+		error  error
+	}
+	type wants struct {
+		responseCode int
+		contentType  string
+	}
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name: "AdminHandler. LedgerAudit. Case #1. Positive",
+			args: args{
+				fix:    false,
+				report: &dto.LedgerReport{},
+			},
+			wants: wants{
+				responseCode: http.StatusOK,
+				contentType:  "application/json",
+			},
+		},
+		{
+			name: "AdminHandler. LedgerAudit. Case #2. Fix",
+			args: args{
+				fix:    true,
+				report: &dto.LedgerReport{Corrections: []dto.LedgerCorrection{{AccountID: 1}}},
+			},
+			wants: wants{
+				responseCode: http.StatusOK,
+				contentType:  "application/json",
+			},
+		},
+		{
+			name: "AdminHandler. LedgerAudit. Case #3. Error",
+			args: args{
+				error: errors.New("any error"),
+			},
+			wants: wants{
+				responseCode: http.StatusInternalServerError,
+				contentType:  "application/json",
+			},
+		},
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	ledgerService := mocks.NewMockLedgerService(mockCtrl)
+	target := NewAdminHandler(ledgerService, nil, nil, nil, auth, log)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledgerService.EXPECT().Check(gomock.Any(), tt.args.fix).Return(tt.args.report, tt.args.error)
+
+			var h http.HandlerFunc
+			var request *http.Request
+			if tt.args.fix {
+				request = httptest.NewRequest("POST", "/api/admin/ledger/audit/fix", nil)
+				h = target.LedgerFix
+			} else {
+				request = httptest.NewRequest("GET", "/api/admin/ledger/audit", nil)
+				h = target.LedgerAudit
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+			contentType := res.Header.Get("Content-type")
+			assert.Equal(t, tt.wants.responseCode, res.StatusCode, "Expected status %d, got %d", tt.wants.responseCode, res.StatusCode)
+			assert.Equal(t, tt.wants.contentType, contentType, "Expected status %d, got %d", tt.wants.contentType, contentType)
+		})
+	}
+}
+
+func TestAdminHandler_CreateAdjustment(t *testing.T) {
+	type args struct {
+		body       string
+		adjustment *dto.Adjustment
+		error      error
+	}
+	type wants struct {
+		responseCode int
+		contentType  string
+	}
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name: "AdminHandler. CreateAdjustment. Case #1. Applied",
+			args: args{
+				body:       "{\"login\": \"user\",\"sum\": 50,\"reason_code\": \"COMPENSATION\"}",
+				adjustment: &dto.Adjustment{ID: 1, Status: "APPLIED"},
+			},
+			wants: wants{
+				responseCode: http.StatusCreated,
+				contentType:  "application/json",
+			},
+		},
+		{
+			name: "AdminHandler. CreateAdjustment. Case #2. Waiting for approval",
+			args: args{
+				body:       "{\"login\": \"user\",\"sum\": 5000,\"reason_code\": \"GOODWILL\"}",
+				adjustment: &dto.Adjustment{ID: 1, Status: "PENDING"},
+			},
+			wants: wants{
+				responseCode: http.StatusAccepted,
+				contentType:  "application/json",
+			},
+		},
+		{
+			name: "AdminHandler. CreateAdjustment. Case #3. User not found",
+			args: args{
+				body:  "{\"login\": \"nobody\",\"sum\": 50,\"reason_code\": \"COMPENSATION\"}",
+				error: dto.ErrNotFound,
+			},
+			wants: wants{
+				responseCode: http.StatusNotFound,
+				contentType:  "application/json",
+			},
+		},
+		{
+			name: "AdminHandler. CreateAdjustment. Case #4. Not enough funds",
+			args: args{
+				body:  "{\"login\": \"user\",\"sum\": -50,\"reason_code\": \"FRAUD_REVERSAL\"}",
+				error: dto.ErrNotEnoughFunds,
+			},
+			wants: wants{
+				responseCode: http.StatusPaymentRequired,
+				contentType:  "application/json",
+			},
+		},
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	adjustmentService := mocks.NewMockAdjustmentService(mockCtrl)
+	target := NewAdminHandler(nil, adjustmentService, nil, nil, auth, log)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adjustmentService.EXPECT().Create(gomock.Any(), gomock.Any(), "").Return(tt.args.adjustment, tt.args.error)
+			request := httptest.NewRequest("POST", "/api/admin/adjustments", strings.NewReader(tt.args.body))
+			request.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			h := http.HandlerFunc(target.CreateAdjustment)
+			h.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+			contentType := res.Header.Get("Content-type")
+			assert.Equal(t, tt.wants.responseCode, res.StatusCode, "Expected status %d, got %d", tt.wants.responseCode, res.StatusCode)
+			assert.Equal(t, tt.wants.contentType, contentType, "Expected status %d, got %d", tt.wants.contentType, contentType)
+		})
+	}
+}
+
+func TestAdminHandler_GetSecurityEvents(t *testing.T) {
+	type args struct {
+		query  string
+		filter *dto.SecurityEventFilter
+		events []dto.SecurityEvent
+	}
+	type wants struct {
+		responseCode int
+	}
+	from := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name: "AdminHandler. GetSecurityEvents. Case #1. Filter by user, action and time",
+			args: args{
+				query:  "?user_id=7&action=LOGIN&from=2021-10-01T00:00:00Z&limit=10",
+				filter: &dto.SecurityEventFilter{UserID: 7, Action: dto.AuditLogin, From: from, Limit: 10},
+				events: []dto.SecurityEvent{{ID: 1, Action: dto.AuditLogin, Result: dto.AuditSuccess, UserID: 7}},
+			},
+			wants: wants{responseCode: http.StatusOK},
+		},
+		{
+			name:  "AdminHandler. GetSecurityEvents. Case #2. Bad time",
+			args:  args{query: "?from=yesterday"},
+			wants: wants{responseCode: http.StatusBadRequest},
+		},
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	eventService := mocks.NewMockSecurityEventService(mockCtrl)
+	target := NewAdminHandler(nil, nil, nil, eventService, auth, log)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.args.filter != nil {
+				eventService.EXPECT().Find(gomock.Any(), tt.args.filter).Return(tt.args.events, nil)
+			}
+			request := httptest.NewRequest("GET", "/api/admin/security/events"+tt.args.query, nil)
+			w := httptest.NewRecorder()
+			h := http.HandlerFunc(target.GetSecurityEvents)
+			h.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, tt.wants.responseCode, res.StatusCode, "Expected status %d, got %d", tt.wants.responseCode, res.StatusCode)
+		})
+	}
+}
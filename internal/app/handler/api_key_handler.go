@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+)
+
+//go:generate mockgen -destination=mocks/mock_api_key_service.go -package=mocks . APIKeyService
+type APIKeyService interface {
+	Create(ctx context.Context, userID int, req *dto.APIKeyRequest) (*dto.APIKey, error)
+	GetList(ctx context.Context, userID int) ([]dto.APIKey, error)
+	Revoke(ctx context.Context, userID int, keyID string) error
+}
+
+type APIKeyHandler struct {
+	apiKeyService APIKeyService
+	auth          *Auth
+	log           *infrastructure.Logger
+}
+
+func NewAPIKeyHandler(s APIKeyService, auth *Auth, l *infrastructure.Logger) *APIKeyHandler {
+	var target APIKeyHandler
+	target.apiKeyService = s
+	target.auth = auth
+	target.log = l
+	return &target
+}
+
+/*
+201 — ключ создан, ключ целиком возвращается только в этом ответе;
+400 — неверный формат запроса или неизвестные права;
+401 — пользователь не авторизован;
+500 — внутренняя ошибка сервера.
+*/
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req dto.APIKeyRequest
+	b, err := getRequestBody(r)
+	if err != nil {
+		h.log.Error("APIKeyHandler:can't get request body", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("APIKeyHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if err = json.Unmarshal(b, &req); err != nil {
+		h.log.Info("APIKeyHandler:can't unmarshal body", zap.Error(err))
+		if err = WriteResponse(w, http.StatusBadRequest, ErrMessage("Неверный формат запроса")); err != nil {
+			h.log.Error("APIKeyHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	ctx := r.Context()
+	userID, _, err := h.auth.GetFromContext(ctx)
+	if err != nil {
+		h.log.Error("APIKeyHandler:can't get params from the token", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("APIKeyHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	key, err := h.apiKeyService.Create(ctx, userID, &req)
+	if err != nil {
+		var validationErr *dto.ValidationError
+		if errors.As(err, &validationErr) {
+			if err = WriteResponse(w, http.StatusBadRequest, ErrViolationsMessage("Неверный формат запроса", validationErr.Violations)); err != nil {
+				h.log.Error("APIKeyHandler: can't write response", zap.Error(err))
+			}
+			return
+		}
+		h.log.Error("APIKeyHandler:can't create api key", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("APIKeyHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	auditTarget(ctx, "keyID="+key.ID+" scopes="+strings.Join(key.Scopes, ","))
+	responseBody, err := json.Marshal(key)
+	if err != nil {
+		h.log.Error("APIKeyHandler: can't serialize response", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("APIKeyHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if err = WriteResponse(w, http.StatusCreated, responseBody); err != nil {
+		h.log.Error("APIKeyHandler: can't write response", zap.Error(err))
+	}
+}
+
+/*
+200 — список ключей, без секретной части;
+204 — ключей нет;
+401 — пользователь не авторизован;
+500 — внутренняя ошибка сервера.
+*/
+func (h *APIKeyHandler) GetList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _, err := h.auth.GetFromContext(ctx)
+	if err != nil {
+		h.log.Error("APIKeyHandler:can't get params from the token", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("APIKeyHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	keys, err := h.apiKeyService.GetList(ctx, userID)
+	if err != nil {
+		h.log.Error("APIKeyHandler:can't get api keys", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("APIKeyHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if len(keys) == 0 {
+		if err = WriteResponse(w, http.StatusNoContent, nil); err != nil {
+			h.log.Error("APIKeyHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	responseBody, err := json.Marshal(keys)
+	if err != nil {
+		h.log.Error("APIKeyHandler: can't serialize response", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("APIKeyHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if err = WriteResponse(w, http.StatusOK, responseBody); err != nil {
+		h.log.Error("APIKeyHandler: can't write response", zap.Error(err))
+	}
+}
+
+/*
+200 — ключ отозван;
+401 — пользователь не авторизован;
+404 — у пользователя нет такого действующего ключа;
+500 — внутренняя ошибка сервера.
+*/
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	keyID := chi.URLParam(r, "keyID")
+	ctx := r.Context()
+	userID, _, err := h.auth.GetFromContext(ctx)
+	if err != nil {
+		h.log.Error("APIKeyHandler:can't get params from the token", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("APIKeyHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	err = h.apiKeyService.Revoke(ctx, userID, keyID)
+	if err != nil {
+		if errors.Is(err, dto.ErrNotFound) {
+			if err = WriteResponse(w, http.StatusNotFound, ErrMessage("Ключ не найден")); err != nil {
+				h.log.Error("APIKeyHandler: can't write response", zap.Error(err))
+			}
+			return
+		}
+		h.log.Error("APIKeyHandler:can't revoke api key", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("APIKeyHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if err = WriteResponse(w, http.StatusOK, nil); err != nil {
+		h.log.Error("APIKeyHandler: can't write response", zap.Error(err))
+	}
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/handler/mocks"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuth_VerifierOrAPIKey(t *testing.T) {
+	type args struct {
+		apiKey string
+		bearer bool
+	}
+	type wants struct {
+		responseCode int
+		userID       int
+	}
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name:  "VerifierOrAPIKey. Case #1. Valid API key",
+			args:  args{apiKey: "gm_id_valid"},
+			wants: wants{responseCode: http.StatusOK, userID: 5},
+		},
+		{
+			name:  "VerifierOrAPIKey. Case #2. Revoked API key",
+			args:  args{apiKey: "gm_id_revoked"},
+			wants: wants{responseCode: http.StatusUnauthorized},
+		},
+		{
+			name:  "VerifierOrAPIKey. Case #3. JWT",
+			args:  args{bearer: true},
+			wants: wants{responseCode: http.StatusOK, userID: 7},
+		},
+		{
+			name:  "VerifierOrAPIKey. Case #4. No credentials",
+			wants: wants{responseCode: http.StatusUnauthorized},
+		},
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	apiKeys := mocks.NewMockAPIKeyAuthenticator(mockCtrl)
+	apiKeys.EXPECT().Authenticate(gomock.Any(), "gm_id_valid").
+		Return(&dto.APIKeyPrincipal{KeyID: "id", UserID: 5, Login: "script", Scopes: []string{"orders:read"}}, nil).AnyTimes()
+	apiKeys.EXPECT().Authenticate(gomock.Any(), "gm_id_revoked").Return(nil, dto.ErrUnauthorized).AnyTimes()
+	token, _, err := auth.GetNewToken(7, "user", "session", nil)
+	assert.NoError(t, err)
+
+	var userID int
+	h := auth.VerifierOrAPIKey(apiKeys)(jwtauth.Authenticator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _, _ = auth.GetFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID = 0
+			request := httptest.NewRequest(http.MethodGet, "/api/user/orders", nil)
+			if tt.args.apiKey != "" {
+				request.Header.Set(APIKeyHeader, tt.args.apiKey)
+			}
+			if tt.args.bearer {
+				request.Header.Set("Authorization", "Bearer "+token)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, tt.wants.responseCode, res.StatusCode)
+			assert.Equal(t, tt.wants.userID, userID)
+		})
+	}
+}
@@ -7,27 +7,53 @@ import (
 	"fmt"
 	"github.com/portnyagin/practicum_project/internal/app/dto"
 	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/mymiddleware"
 	"go.uber.org/zap"
+	"math"
 	"net/http"
+	"strconv"
 )
 
 //go:generate mockgen -destination=mocks/mock_auth_service.go -package=mocks . AuthService
 type AuthService interface {
 	Register(ctx context.Context, user *dto.User) (*dto.User, error)
 	Check(ctx context.Context, user *dto.User) (*dto.User, error)
+	ChangePassword(ctx context.Context, userID int, sessionID string, req *dto.ChangePasswordRequest) error
+	Deactivate(ctx context.Context, userID int, pass string) error
+}
+
+//go:generate mockgen -destination=mocks/mock_session_service.go -package=mocks . SessionService
+type SessionService interface {
+	Create(ctx context.Context, userID int) (*dto.Session, error)
+	Refresh(ctx context.Context, refreshToken string) (*dto.Session, error)
+	Revoke(ctx context.Context, sessionID string) error
+}
+
+//go:generate mockgen -destination=mocks/mock_login_throttle.go -package=mocks . LoginThrottle
+type LoginThrottle interface {
+	Check(ctx context.Context, login string, ip string) error
+	Failure(ctx context.Context, login string, ip string) error
+	Success(ctx context.Context, login string) error
 }
 
 type AuthHandler struct {
-	authService AuthService
-	auth        *Auth
-	log         *infrastructure.Logger
+	authService    AuthService
+	sessionService SessionService
+	loginThrottle  LoginThrottle
+	auth           *Auth
+	log            *infrastructure.Logger
+	// tokenInBody - Register и Login возвращают токены и в теле ответа
+	tokenInBody bool
 }
 
-func NewAuthHandler(as AuthService, auth *Auth, l *infrastructure.Logger) *AuthHandler {
+func NewAuthHandler(as AuthService, ss SessionService, lt LoginThrottle, auth *Auth, l *infrastructure.Logger, tokenInBody bool) *AuthHandler {
 	var target AuthHandler
 	target.log = l
 	target.authService = as
+	target.sessionService = ss
+	target.loginThrottle = lt
 	target.auth = auth
+	target.tokenInBody = tokenInBody
 	return &target
 }
 
@@ -50,10 +76,17 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	ctx := r.Context()
+	auditActor(ctx, 0, user.Login)
 	u, err := h.authService.Register(ctx, &user)
 	if err != nil {
 		h.log.Error("AuthHandler:recieved an error", zap.Error(err))
-		if errors.Is(err, dto.ErrDuplicateKey) {
+		var validationErr *dto.ValidationError
+		if errors.As(err, &validationErr) {
+			if err = WriteResponse(w, http.StatusBadRequest, ErrViolationsMessage("Неверный формат запроса", validationErr.Violations)); err != nil {
+				h.log.Error("AuthHandler: can't write response", zap.Error(err))
+			}
+			return
+		} else if errors.Is(err, dto.ErrDuplicateKey) {
 			if err = WriteResponse(w, http.StatusConflict, ErrMessage("Логин уже занят")); err != nil {
 				h.log.Error("AuthHandler: can't write response", zap.Error(err))
 			}
@@ -70,24 +103,34 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	token, err := h.auth.GetNewToken(u.ID, u.Login)
+	session, err := h.sessionService.Create(ctx, u.ID)
 	if err != nil {
-		h.log.Error("AuthHandler: can't make token", zap.Error(err))
+		h.log.Error("AuthHandler: can't create session", zap.Error(err))
 		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
 			h.log.Error("AuthHandler: can't write response", zap.Error(err))
 		}
 		return
 	}
-	jwtCookie, err := bakeCookie(token)
+	auditActor(ctx, u.ID, u.Login)
+	mymiddleware.ReadOwnWrites(ctx, u.ID)
+	token, err := h.issueTokens(w, u.ID, u.Login, session)
 	if err != nil {
-		h.log.Error("AuthHandler: can't set cookie", zap.Error(err))
 		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
 			h.log.Error("AuthHandler: can't write response", zap.Error(err))
 		}
 		return
 	}
-	http.SetCookie(w, jwtCookie)
-	if err = WriteResponse(w, http.StatusOK, nil); err != nil {
+	var responseBody []byte
+	if h.tokenInBody {
+		if responseBody, err = json.Marshal(token); err != nil {
+			h.log.Error("AuthHandler: can't serialize response", zap.Error(err))
+			if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+				h.log.Error("AuthHandler: can't write response", zap.Error(err))
+			}
+			return
+		}
+	}
+	if err = WriteResponse(w, http.StatusOK, responseBody); err != nil {
 		h.log.Error("AuthHandler: can't write response", zap.Error(err))
 		return
 	}
@@ -122,6 +165,23 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	ctx := r.Context()
+	auditActor(ctx, 0, user.Login)
+	ip := clientIP(r)
+	if err = h.loginThrottle.Check(ctx, user.Login, ip); err != nil {
+		var lockoutErr *dto.LockoutError
+		if errors.As(err, &lockoutErr) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockoutErr.RetryAfter.Seconds()))))
+			if err = WriteResponse(w, http.StatusTooManyRequests, ErrMessage("Слишком много попыток входа, повторите позже")); err != nil {
+				h.log.Error("AuthHandler: can't write response", zap.Error(err))
+			}
+			return
+		}
+		h.log.Error("AuthHandler: can't check login attempts", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AuthHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
 	u, err := h.authService.Check(ctx, &user)
 	if err != nil {
 		h.log.Error("AuthHandler: can't check user credential", zap.Error(err))
@@ -131,32 +191,305 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if u == nil {
+		if err = h.loginThrottle.Failure(ctx, user.Login, ip); err != nil {
+			h.log.Error("AuthHandler: can't register login failure", zap.Error(err))
+		}
 		if err = WriteResponse(w, http.StatusUnauthorized, ErrMessage("Неверная пара логин/пароль")); err != nil {
 			h.log.Error("AuthHandler: can't write response", zap.Error(err))
 			return
 		}
 		return
 	}
-	token, err := h.auth.GetNewToken(u.ID, u.Login)
+	if err = h.loginThrottle.Success(ctx, user.Login); err != nil {
+		h.log.Error("AuthHandler: can't reset login attempts", zap.Error(err))
+	}
+	session, err := h.sessionService.Create(ctx, u.ID)
+	if err != nil {
+		h.log.Error("AuthHandler: can't create session", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AuthHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	auditActor(ctx, u.ID, u.Login)
+	mymiddleware.ReadOwnWrites(ctx, u.ID)
+	token, err := h.issueTokens(w, u.ID, u.Login, session)
+	if err != nil {
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AuthHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	var responseBody []byte
+	if h.tokenInBody {
+		if responseBody, err = json.Marshal(token); err != nil {
+			h.log.Error("AuthHandler: can't serialize response", zap.Error(err))
+			if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+				h.log.Error("AuthHandler: can't write response", zap.Error(err))
+			}
+			return
+		}
+	}
+	if err = WriteResponse(w, http.StatusOK, responseBody); err != nil {
+		h.log.Error("AuthHandler: can't write response", zap.Error(err))
+		return
+	}
+	h.log.Info(fmt.Sprintf("User %s succefully logined", user.Login))
+}
+
+// PublicKeys отдает открытые ключи проверки токенов в формате JWK Set
+func (h *AuthHandler) PublicKeys(w http.ResponseWriter, r *http.Request) {
+	responseBody, err := json.Marshal(h.auth.PublicKeys())
+	if err != nil {
+		h.log.Error("AuthHandler: can't serialize key set", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AuthHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if err = WriteResponse(w, http.StatusOK, responseBody); err != nil {
+		h.log.Error("AuthHandler: can't write response", zap.Error(err))
+	}
+}
+
+func (h *AuthHandler) issueTokens(w http.ResponseWriter, userID int, login string, session *dto.Session) (*dto.Token, error) {
+	token, err := h.auth.issueTokens(w, userID, login, session)
 	if err != nil {
 		h.log.Error("AuthHandler: can't make token", zap.Error(err))
+		return nil, err
+	}
+	return token, nil
+}
+
+/*
+200 — токены обновлены;
+400 — refresh-токен не передан;
+401 — сессия истекла, отозвана или токен уже использован;
+500 — внутренняя ошибка сервера.
+*/
+func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req dto.RefreshRequest
+	b, err := getRequestBody(r)
+	if err != nil {
+		h.log.Error("AuthHandler:can't get request body", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AuthHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if len(b) > 0 {
+		if err = json.Unmarshal(b, &req); err != nil {
+			h.log.Info("AuthHandler:can't unmarshal body", zap.Error(err))
+			if err = WriteResponse(w, http.StatusBadRequest, ErrMessage("Неверный формат запроса")); err != nil {
+				h.log.Error("AuthHandler: can't write response", zap.Error(err))
+			}
+			return
+		}
+	}
+	if req.RefreshToken == "" {
+		if c, err := r.Cookie(refreshCookieName); err == nil {
+			req.RefreshToken = c.Value
+		}
+	}
+	ctx := r.Context()
+	session, err := h.sessionService.Refresh(ctx, req.RefreshToken)
+	if err != nil {
+		var (
+			statusCode int
+			msg        string
+		)
+		switch err {
+		case dto.ErrBadParam:
+			statusCode = http.StatusBadRequest
+			msg = "Неверный формат запроса"
+		case dto.ErrSessionExpired:
+			statusCode = http.StatusUnauthorized
+			msg = "Сессия истекла"
+		default:
+			h.log.Error("AuthHandler: can't refresh session", zap.Error(err))
+			statusCode = http.StatusInternalServerError
+			msg = "Внутренняя ошибка сервера"
+		}
+		if err = WriteResponse(w, statusCode, ErrMessage(msg)); err != nil {
+			h.log.Error("AuthHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	token, err := h.issueTokens(w, session.UserID, session.Login, session)
+	if err != nil {
 		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
 			h.log.Error("AuthHandler: can't write response", zap.Error(err))
 		}
 		return
 	}
-	jwtCookie, err := bakeCookie(token)
+	responseBody, err := json.Marshal(token)
+	if err != nil {
+		h.log.Error("AuthHandler: can't serialize response", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AuthHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if err = WriteResponse(w, http.StatusOK, responseBody); err != nil {
+		h.log.Error("AuthHandler: can't write response", zap.Error(err))
+	}
+}
+
+/*
+200 — сессия завершена;
+401 — пользователь не авторизован;
+500 — внутренняя ошибка сервера.
+*/
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sessionID, err := h.auth.GetSessionFromContext(ctx)
 	if err != nil {
-		h.log.Error("AuthHandler: can't set cookie", zap.Error(err))
+		h.log.Error("AuthHandler:can't get params from the token", zap.Error(err))
 		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
 			h.log.Error("AuthHandler: can't write response", zap.Error(err))
 		}
 		return
 	}
-	http.SetCookie(w, jwtCookie)
+	err = h.sessionService.Revoke(ctx, sessionID)
+	if err != nil && !errors.Is(err, dto.ErrNotFound) {
+		h.log.Error("AuthHandler: can't revoke session", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AuthHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	http.SetCookie(w, h.auth.dropCookie(accessCookieName, h.auth.cookie.Path))
+	http.SetCookie(w, h.auth.dropCookie(refreshCookieName, refreshCookiePath))
+	http.SetCookie(w, h.auth.dropCookie(csrfCookieName, h.auth.cookie.Path))
 	if err = WriteResponse(w, http.StatusOK, nil); err != nil {
 		h.log.Error("AuthHandler: can't write response", zap.Error(err))
+	}
+}
+
+/*
+200 — пароль изменен, остальные сессии пользователя завершены;
+400 — неверный формат запроса или новый пароль не соответствует требованиям;
+401 — пользователь не авторизован;
+403 — неверный текущий пароль;
+500 — внутренняя ошибка сервера.
+*/
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var req dto.ChangePasswordRequest
+	b, err := getRequestBody(r)
+	if err != nil {
+		h.log.Error("AuthHandler:can't get request body", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AuthHandler: can't write response", zap.Error(err))
+		}
 		return
 	}
-	h.log.Info(fmt.Sprintf("User %s succefully logined", user.Login))
+	if err = json.Unmarshal(b, &req); err != nil {
+		h.log.Info("AuthHandler:can't unmarshal body", zap.Error(err))
+		if err = WriteResponse(w, http.StatusBadRequest, ErrMessage("Неверный формат запроса")); err != nil {
+			h.log.Error("AuthHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	ctx := r.Context()
+	userID, _, err := h.auth.GetFromContext(ctx)
+	if err != nil {
+		h.log.Error("AuthHandler:can't get params from the token", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AuthHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	sessionID, err := h.auth.GetSessionFromContext(ctx)
+	if err != nil {
+		h.log.Error("AuthHandler:can't get params from the token", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AuthHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	err = h.authService.ChangePassword(ctx, userID, sessionID, &req)
+	if err != nil {
+		h.writeAccountError(w, err)
+		return
+	}
+	if err = WriteResponse(w, http.StatusOK, nil); err != nil {
+		h.log.Error("AuthHandler: can't write response", zap.Error(err))
+	}
+}
+
+/*
+200 — учетная запись деактивирована, все сессии завершены;
+400 — неверный формат запроса;
+401 — пользователь не авторизован;
+403 — неверный пароль;
+500 — внутренняя ошибка сервера.
+*/
+func (h *AuthHandler) Deactivate(w http.ResponseWriter, r *http.Request) {
+	var req dto.DeactivateRequest
+	b, err := getRequestBody(r)
+	if err != nil {
+		h.log.Error("AuthHandler:can't get request body", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AuthHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if err = json.Unmarshal(b, &req); err != nil {
+		h.log.Info("AuthHandler:can't unmarshal body", zap.Error(err))
+		if err = WriteResponse(w, http.StatusBadRequest, ErrMessage("Неверный формат запроса")); err != nil {
+			h.log.Error("AuthHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	ctx := r.Context()
+	userID, login, err := h.auth.GetFromContext(ctx)
+	if err != nil {
+		h.log.Error("AuthHandler:can't get params from the token", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("AuthHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if err = h.authService.Deactivate(ctx, userID, req.Pass); err != nil {
+		h.writeAccountError(w, err)
+		return
+	}
+	http.SetCookie(w, h.auth.dropCookie(accessCookieName, h.auth.cookie.Path))
+	http.SetCookie(w, h.auth.dropCookie(refreshCookieName, refreshCookiePath))
+	http.SetCookie(w, h.auth.dropCookie(csrfCookieName, h.auth.cookie.Path))
+	if err = WriteResponse(w, http.StatusOK, nil); err != nil {
+		h.log.Error("AuthHandler: can't write response", zap.Error(err))
+		return
+	}
+	h.log.Info(fmt.Sprintf("User %s deactivated", login))
+}
+
+func (h *AuthHandler) writeAccountError(w http.ResponseWriter, err error) {
+	var (
+		statusCode int
+		body       []byte
+	)
+	var validationErr *dto.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		statusCode = http.StatusBadRequest
+		body = ErrViolationsMessage("Неверный формат запроса", validationErr.Violations)
+	case errors.Is(err, dto.ErrBadParam):
+		statusCode = http.StatusBadRequest
+		body = ErrMessage("Неверный формат запроса")
+	case errors.Is(err, dto.ErrWrongPassword):
+		statusCode = http.StatusForbidden
+		body = ErrMessage("Неверный пароль")
+	case errors.Is(err, dto.ErrNotFound):
+		// пользователь деактивирован параллельным запросом
+		statusCode = http.StatusUnauthorized
+		body = ErrMessage("Пользователь не найден")
+	default:
+		h.log.Error("AuthHandler: account operation error", zap.Error(err))
+		statusCode = http.StatusInternalServerError
+		body = ErrMessage("Внутренняя ошибка сервера")
+	}
+	if err = WriteResponse(w, statusCode, body); err != nil {
+		h.log.Error("AuthHandler: can't write response", zap.Error(err))
+	}
 }
@@ -2,22 +2,27 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/go-chi/jwtauth/v5"
 	"github.com/golang/mock/gomock"
 	"github.com/portnyagin/practicum_project/internal/app/dto"
 	"github.com/portnyagin/practicum_project/internal/app/handler/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestAuthHandler_Register(t *testing.T) {
 	type wants struct {
 		responseCode int
 		contentType  string
+		violations   []dto.Violation
 	}
 	type args struct {
 		body      string
@@ -91,6 +96,19 @@ func TestAuthHandler_Register(t *testing.T) {
 				err:   dto.ErrDuplicateKey,
 			},
 		},
+		{name: "AuthHandler. Register. Case #7. Password policy violation",
+			wants: wants{
+				responseCode: http.StatusBadRequest,
+				contentType:  "application/json",
+				violations:   []dto.Violation{{Field: "password", Code: dto.PasswordTooShort}},
+			},
+			args: args{
+				body:  "{\"login\": \"%s\",\"password\": \"%s\"}",
+				login: "weakLogin",
+				pass:  "123",
+				err:   &dto.ValidationError{Violations: []dto.Violation{{Field: "password", Code: dto.PasswordTooShort}}},
+			},
+		},
 		{name: "AuthHandler. Register. Case #6. Service Error",
 			wants: wants{
 				responseCode: http.StatusBadRequest,
@@ -108,8 +126,10 @@ func TestAuthHandler_Register(t *testing.T) {
 	defer mockCtrl.Finish()
 
 	authService := mocks.NewMockAuthService(mockCtrl)
+	sessionService := mocks.NewMockSessionService(mockCtrl)
+	sessionService.EXPECT().Create(gomock.Any(), 10).Return(&dto.Session{ID: "session", UserID: 10, RefreshToken: "refresh"}, nil).AnyTimes()
 
-	target := NewAuthHandler(authService, auth, log)
+	target := NewAuthHandler(authService, sessionService, nil, auth, log, false)
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -140,6 +160,11 @@ func TestAuthHandler_Register(t *testing.T) {
 				}
 				assert.NotNil(t, token, "JWT token not set")
 			}
+			if tt.wants.violations != nil {
+				var resBody dto.Error
+				assert.NoError(t, json.NewDecoder(res.Body).Decode(&resBody))
+				assert.Equal(t, tt.wants.violations, resBody.Violations)
+			}
 		})
 	}
 }
@@ -148,12 +173,14 @@ func TestAuthHandler_Login(t *testing.T) {
 	type wants struct {
 		responseCode int
 		contentType  string
+		retryAfter   string
 	}
 	type args struct {
 		body    string
 		login   string
 		pass    string
 		allowed bool
+		locked  bool
 		err     error
 	}
 	tests := []struct {
@@ -227,12 +254,31 @@ func TestAuthHandler_Login(t *testing.T) {
 				err:     errors.New("any error"),
 			},
 		},
+		{name: "AuthHandler. Check. Case #6. Too Many Attempts",
+			wants: wants{
+				responseCode: http.StatusTooManyRequests,
+				contentType:  "application/json",
+				retryAfter:   "30",
+			},
+			args: args{
+				body:    "{\"login\": \"%s\",\"password\": \"%s\"}",
+				login:   "lockedLogin",
+				pass:    "lockedPass",
+				allowed: true,
+				locked:  true,
+			},
+		},
 	}
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
 	authService := mocks.NewMockAuthService(mockCtrl)
-	target := NewAuthHandler(authService, auth, log)
+	sessionService := mocks.NewMockSessionService(mockCtrl)
+	sessionService.EXPECT().Create(gomock.Any(), 10).Return(&dto.Session{ID: "session", UserID: 10, RefreshToken: "refresh"}, nil).AnyTimes()
+	loginThrottle := mocks.NewMockLoginThrottle(mockCtrl)
+	loginThrottle.EXPECT().Failure(gomock.Any(), "userLogin3", gomock.Any()).Return(nil)
+	loginThrottle.EXPECT().Success(gomock.Any(), "userLogin").Return(nil)
+	target := NewAuthHandler(authService, sessionService, loginThrottle, auth, log, false)
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -241,6 +287,11 @@ func TestAuthHandler_Login(t *testing.T) {
 			if tt.args.allowed {
 				resObj = &dto.User{ID: 10, Login: tt.args.login, Pass: tt.args.pass}
 			}
+			var throttleErr error
+			if tt.args.locked {
+				throttleErr = &dto.LockoutError{RetryAfter: 29500 * time.Millisecond}
+			}
+			loginThrottle.EXPECT().Check(ctx, tt.args.login, "192.0.2.1").Return(throttleErr).AnyTimes()
 			authService.EXPECT().
 				Check(ctx, &dto.User{Login: tt.args.login, Pass: tt.args.pass}).
 				Return(resObj, tt.args.err).
@@ -256,6 +307,7 @@ func TestAuthHandler_Login(t *testing.T) {
 			contentType := res.Header.Get("Content-type")
 			assert.Equal(t, tt.wants.responseCode, res.StatusCode, "Expected status %d, got %d", tt.wants.responseCode, res.StatusCode)
 			assert.Equal(t, tt.wants.contentType, contentType, "Expected contentType %d, got %d", tt.wants.contentType, contentType)
+			assert.Equal(t, tt.wants.retryAfter, res.Header.Get("Retry-After"))
 
 			if res.StatusCode == http.StatusOK {
 				cookies := res.Cookies()
@@ -266,7 +318,13 @@ func TestAuthHandler_Login(t *testing.T) {
 						break
 					}
 				}
-				assert.NotNil(t, token, "JWT token not set")
+				if assert.NotNil(t, token, "JWT token not set") {
+					assert.True(t, token.HttpOnly)
+					assert.Equal(t, "/", token.Path)
+					assert.Equal(t, http.SameSiteLaxMode, token.SameSite)
+					assert.False(t, token.Expires.IsZero())
+					assert.Equal(t, "Bearer "+token.Value, res.Header.Get("Authorization"))
+				}
 			}
 		})
 	}
@@ -303,7 +361,7 @@ func TestAuthHandler_RegisterInt(t *testing.T) {
 		},
 	}
 
-	target := NewAuthHandler(authService, auth, log)
+	target := NewAuthHandler(authService, sessionService, nil, auth, log, false)
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -320,3 +378,149 @@ func TestAuthHandler_RegisterInt(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthHandler_RefreshToken(t *testing.T) {
+	type args struct {
+		body    string
+		cookie  string
+		token   string
+		session *dto.Session
+		err     error
+	}
+	type wants struct {
+		responseCode int
+		contentType  string
+	}
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name: "AuthHandler. RefreshToken. Case #1. Token in body",
+			args: args{
+				body:    "{\"refresh_token\": \"old\"}",
+				token:   "old",
+				session: &dto.Session{ID: "session", UserID: 10, Login: "user", RefreshToken: "new"},
+			},
+			wants: wants{
+				responseCode: http.StatusOK,
+				contentType:  "application/json",
+			},
+		},
+		{
+			name: "AuthHandler. RefreshToken. Case #2. Token in cookie",
+			args: args{
+				cookie:  "old",
+				token:   "old",
+				session: &dto.Session{ID: "session", UserID: 10, Login: "user", RefreshToken: "new"},
+			},
+			wants: wants{
+				responseCode: http.StatusOK,
+				contentType:  "application/json",
+			},
+		},
+		{
+			name: "AuthHandler. RefreshToken. Case #3. Session revoked",
+			args: args{
+				cookie: "reused",
+				token:  "reused",
+				err:    dto.ErrSessionExpired,
+			},
+			wants: wants{
+				responseCode: http.StatusUnauthorized,
+				contentType:  "application/json",
+			},
+		},
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	sessionService := mocks.NewMockSessionService(mockCtrl)
+	target := NewAuthHandler(nil, sessionService, nil, auth, log, false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessionService.EXPECT().Refresh(gomock.Any(), tt.args.token).Return(tt.args.session, tt.args.err)
+			request := httptest.NewRequest("POST", "/api/user/token/refresh", strings.NewReader(tt.args.body))
+			if tt.args.cookie != "" {
+				request.AddCookie(&http.Cookie{Name: "refresh_token", Value: tt.args.cookie})
+			}
+			w := httptest.NewRecorder()
+			h := http.HandlerFunc(target.RefreshToken)
+			h.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+			contentType := res.Header.Get("Content-type")
+			assert.Equal(t, tt.wants.responseCode, res.StatusCode, "Expected status %d, got %d", tt.wants.responseCode, res.StatusCode)
+			assert.Equal(t, tt.wants.contentType, contentType, "Expected status %d, got %d", tt.wants.contentType, contentType)
+
+			if res.StatusCode == http.StatusOK {
+				cookies := map[string]string{}
+				for _, c := range res.Cookies() {
+					cookies[c.Name] = c.Value
+				}
+				assert.NotEmpty(t, cookies["jwt"], "JWT token not set")
+				assert.Equal(t, "new", cookies["refresh_token"])
+			}
+		})
+	}
+}
+
+func TestAuthHandler_ChangePassword(t *testing.T) {
+	type args struct {
+		body  string
+		error error
+	}
+	type wants struct {
+		responseCode int
+	}
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name:  "AuthHandler. ChangePassword. Case #1. Positive",
+			args:  args{body: "{\"old_password\": \"old\",\"new_password\": \"new password\"}"},
+			wants: wants{responseCode: http.StatusOK},
+		},
+		{
+			name:  "AuthHandler. ChangePassword. Case #2. Wrong password",
+			args:  args{body: "{\"old_password\": \"wrong\",\"new_password\": \"new password\"}", error: dto.ErrWrongPassword},
+			wants: wants{responseCode: http.StatusForbidden},
+		},
+		{
+			name: "AuthHandler. ChangePassword. Case #3. Weak password",
+			args: args{
+				body:  "{\"old_password\": \"old\",\"new_password\": \"new\"}",
+				error: &dto.ValidationError{Violations: []dto.Violation{{Field: "password", Code: dto.PasswordTooShort}}},
+			},
+			wants: wants{responseCode: http.StatusBadRequest},
+		},
+		{
+			name:  "AuthHandler. ChangePassword. Case #4. Service error",
+			args:  args{body: "{\"old_password\": \"old\",\"new_password\": \"new password\"}", error: errors.New("any error")},
+			wants: wants{responseCode: http.StatusInternalServerError},
+		},
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	authService := mocks.NewMockAuthService(mockCtrl)
+	target := NewAuthHandler(authService, nil, nil, auth, log, false)
+	tokenString, _, err := auth.GetNewToken(7, "user", "current", nil)
+	require.NoError(t, err)
+	token, err := auth.Decode(tokenString)
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authService.EXPECT().ChangePassword(gomock.Any(), 7, "current", gomock.Any()).Return(tt.args.error)
+			request := httptest.NewRequest("POST", "/api/user/password", strings.NewReader(tt.args.body))
+			request = request.WithContext(jwtauth.NewContext(request.Context(), token, nil))
+			w := httptest.NewRecorder()
+			h := http.HandlerFunc(target.ChangePassword)
+			h.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, tt.wants.responseCode, res.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"os"
+)
+
+// LoadKeySet читает ключи подписи токенов из файла в формате JWK Set (RFC 7517).
+// У каждого ключа должны быть заполнены kid и alg. Ключи, содержащие только открытую часть, используются
+// только для проверки токенов
+func LoadKeySet(path string) (jwk.Set, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := jwk.Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse key set %s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// isSigningKey - ключ содержит секрет или закрытую часть и может использоваться для подписи
+func isSigningKey(key jwk.Key) bool {
+	switch key.(type) {
+	case jwk.SymmetricKey, jwk.RSAPrivateKey, jwk.ECDSAPrivateKey, jwk.OKPPrivateKey:
+		return true
+	}
+	return false
+}
+
+// verificationKeySet проверяет ключи и оставляет у асимметричных ключей только открытую часть
+func verificationKeySet(keys jwk.Set) (jwk.Set, error) {
+	res := jwk.NewSet()
+	seen := make(map[string]bool)
+	for i := 0; i < keys.Len(); i++ {
+		key, _ := keys.Get(i)
+		if key.KeyID() == "" {
+			return nil, fmt.Errorf("key #%d has no kid", i)
+		}
+		if seen[key.KeyID()] {
+			return nil, fmt.Errorf("duplicate kid %s", key.KeyID())
+		}
+		seen[key.KeyID()] = true
+		var alg jwa.SignatureAlgorithm
+		if err := alg.Accept(key.Algorithm()); err != nil || alg == jwa.NoSignature {
+			return nil, fmt.Errorf("key %s has unsupported alg '%s'", key.KeyID(), key.Algorithm())
+		}
+		if _, ok := key.(jwk.SymmetricKey); ok {
+			res.Add(key)
+			continue
+		}
+		pub, err := jwk.PublicKeyOf(key)
+		if err != nil {
+			return nil, fmt.Errorf("can't get public key %s: %w", key.KeyID(), err)
+		}
+		res.Add(pub)
+	}
+	if res.Len() == 0 {
+		return nil, fmt.Errorf("key set is empty")
+	}
+	return res, nil
+}
+
+// signingKey возвращает ключ с идентификатором kid, а при пустом kid - последний ключ набора, пригодный для подписи.
+// Новые ключи добавляются в конец файла, поэтому последний ключ считается самым новым
+func signingKey(keys jwk.Set, kid string) (jwk.Key, error) {
+	if kid != "" {
+		key, ok := keys.LookupKeyID(kid)
+		if !ok {
+			return nil, fmt.Errorf("signing key %s not found", kid)
+		}
+		if !isSigningKey(key) {
+			return nil, fmt.Errorf("key %s has no private part", kid)
+		}
+		return key, nil
+	}
+	for i := keys.Len() - 1; i >= 0; i-- {
+		key, _ := keys.Get(i)
+		if isSigningKey(key) {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("key set has no signing keys")
+}
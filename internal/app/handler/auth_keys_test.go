@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func newTestKey(t *testing.T, raw interface{}, kid string, alg jwa.SignatureAlgorithm) jwk.Key {
+	key, err := jwk.New(raw)
+	require.NoError(t, err)
+	require.NoError(t, key.Set(jwk.KeyIDKey, kid))
+	require.NoError(t, key.Set(jwk.AlgorithmKey, alg))
+	return key
+}
+
+func tokenKeyID(t *testing.T, token string) string {
+	msg, err := jws.Parse([]byte(token))
+	require.NoError(t, err)
+	return msg.Signatures()[0].ProtectedHeaders().KeyID()
+}
+
+func TestAuth_KeyRotation(t *testing.T) {
+	oldKeys := jwk.NewSet()
+	oldKeys.Add(newTestKey(t, []byte("old secret"), "2021-01", jwa.HS256))
+	oldAuth, err := NewAuthWithKeys(oldKeys, "")
+	require.NoError(t, err)
+	oldToken, _, err := oldAuth.GetNewToken(1, "user", "session", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "2021-01", tokenKeyID(t, oldToken))
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keys := jwk.NewSet()
+	keys.Add(newTestKey(t, []byte("old secret"), "2021-01", jwa.HS256))
+	keys.Add(newTestKey(t, rsaKey, "2021-02", jwa.RS256))
+	auth, err := NewAuthWithKeys(keys, "")
+	require.NoError(t, err)
+
+	newToken, _, err := auth.GetNewToken(1, "user", "session", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "2021-02", tokenKeyID(t, newToken), "new tokens must be signed with the newest key")
+
+	for _, token := range []string{oldToken, newToken} {
+		decoded, err := auth.Decode(token)
+		if assert.NoError(t, err) {
+			login, _ := decoded.Get("login")
+			assert.Equal(t, "user", login)
+		}
+	}
+
+	// ключ выведен из оборота
+	retired := jwk.NewSet()
+	retired.Add(newTestKey(t, rsaKey, "2021-02", jwa.RS256))
+	auth, err = NewAuthWithKeys(retired, "")
+	require.NoError(t, err)
+	_, err = auth.Decode(oldToken)
+	assert.Error(t, err)
+	_, err = auth.Decode(newToken)
+	assert.NoError(t, err)
+}
+
+func TestAuth_PublicKeyVerification(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keys := jwk.NewSet()
+	keys.Add(newTestKey(t, []byte("secret"), "hmac", jwa.HS256))
+	keys.Add(newTestKey(t, priv, "ed", jwa.EdDSA))
+	auth, err := NewAuthWithKeys(keys, "ed")
+	require.NoError(t, err)
+	token, _, err := auth.GetNewToken(2, "user2", "session", nil)
+	require.NoError(t, err)
+
+	// сторонний сервис знает только открытые ключи
+	published := auth.PublicKeys()
+	require.Equal(t, 1, published.Len())
+	pubKey, _ := published.Get(0)
+	_, isPrivate := pubKey.(jwk.OKPPrivateKey)
+	assert.False(t, isPrivate)
+
+	verifyOnly := jwk.NewSet()
+	verifyOnly.Add(newTestKey(t, pub, "ed", jwa.EdDSA))
+	verifier, err := NewAuthWithKeys(verifyOnly, "")
+	assert.Error(t, err, "set without private keys can't sign tokens")
+	assert.Nil(t, verifier)
+
+	verifyOnly.Add(newTestKey(t, []byte("other"), "hmac", jwa.HS256))
+	verifier, err = NewAuthWithKeys(verifyOnly, "hmac")
+	require.NoError(t, err)
+	_, err = verifier.Decode(token)
+	assert.NoError(t, err)
+}
+
+func TestAuth_InvalidKeySet(t *testing.T) {
+	noKid := jwk.NewSet()
+	noKid.Add(newTestKey(t, []byte("secret"), "", jwa.HS256))
+	_, err := NewAuthWithKeys(noKid, "")
+	assert.Error(t, err)
+
+	none := jwk.NewSet()
+	none.Add(newTestKey(t, []byte("secret"), "k1", jwa.NoSignature))
+	_, err = NewAuthWithKeys(none, "")
+	assert.Error(t, err)
+
+	keys := jwk.NewSet()
+	keys.Add(newTestKey(t, []byte("secret"), "k1", jwa.HS256))
+	_, err = NewAuthWithKeys(keys, "k2")
+	assert.Error(t, err)
+}
@@ -3,6 +3,8 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/portnyagin/practicum_project/internal/app/dto"
 	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
 	"go.uber.org/zap"
@@ -14,6 +16,9 @@ type BalanceService interface {
 	GetCurrentBalance(ctx context.Context, userID int) (*dto.Balance, error)
 	Withdraw(ctx context.Context, obj *dto.Withdraw, userID int) error
 	GetWithdrawalsList(ctx context.Context, userID int) ([]dto.Withdrawal, error)
+	Transfer(ctx context.Context, obj *dto.Transfer, userID int) error
+	GetStatement(ctx context.Context, userID int) ([]dto.StatementEntry, error)
+	ExportStatement(ctx context.Context, userID int, fn func(entry *dto.StatementEntry) error) error
 }
 
 type BalanceHandler struct {
@@ -70,6 +75,8 @@ func (h *BalanceHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
 200 — успешная обработка запроса;
 401 — пользователь не авторизован;
 402 — на счету недостаточно средств;
+403 — списание отклонено правилами (код правила в поле code);
+409 — заказ уже оплачен баллами;
 422 — неверный номер заказа;
 500 — внутренняя ошибка сервера.
 */
@@ -111,6 +118,7 @@ func (h *BalanceHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	auditTarget(ctx, fmt.Sprintf("order=%s sum=%v", withdraw.OrderNum, withdraw.Amount))
 	err = h.balanceService.Withdraw(ctx, &withdraw, userID)
 
 	if err != nil {
@@ -119,6 +127,13 @@ func (h *BalanceHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 			msg        string
 		)
 		h.log.Error("BalanceHandler:Withdraw error", zap.Error(err))
+		var policyErr *dto.PolicyError
+		if errors.As(err, &policyErr) {
+			if err = WriteResponse(w, http.StatusForbidden, ErrCodeMessage("Списание отклонено правилами", policyErr.Code)); err != nil {
+				h.log.Error("BalanceHandler: can't write response", zap.Error(err))
+			}
+			return
+		}
 		switch err {
 		case dto.ErrNotEnoughFunds:
 			statusCode = http.StatusPaymentRequired
@@ -126,6 +141,9 @@ func (h *BalanceHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 		case dto.ErrBadOrderNum:
 			statusCode = http.StatusUnprocessableEntity
 			msg = "Неверный номер заказа"
+		case dto.ErrWithdrawalDuplicate:
+			statusCode = http.StatusConflict
+			msg = "Заказ уже оплачен баллами"
 		default:
 			statusCode = http.StatusInternalServerError
 			msg = "Внутренняя ошибка сервера"
@@ -133,6 +151,7 @@ func (h *BalanceHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 		if err = WriteResponse(w, statusCode, ErrMessage(msg)); err != nil {
 			h.log.Error("BalanceHandler: can't write response", zap.Error(err))
 		}
+		return
 	}
 
 	if err = WriteResponse(w, http.StatusOK, nil); err != nil {
@@ -175,3 +194,159 @@ func (h *BalanceHandler) GetWithdrawalsList(w http.ResponseWriter, r *http.Reque
 		}
 	}
 }
+
+/*
+200 — успешная обработка запроса;
+400 — неверный формат запроса;
+401 — пользователь не авторизован;
+402 — на счету недостаточно средств;
+404 — получатель не найден;
+422 — сумма перевода нарушает установленные ограничения;
+500 — внутренняя ошибка сервера.
+*/
+func (h *BalanceHandler) Transfer(w http.ResponseWriter, r *http.Request) {
+	b, err := getRequestBody(r)
+	if err != nil {
+		h.log.Error("BalanceHandler:can't get request body", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("BalanceHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	var transfer dto.Transfer
+	if len(b) == 0 || r.Header.Get("Content-Type") != "application/json" || json.Unmarshal(b, &transfer) != nil {
+		h.log.Info("BalanceHandler:bad transfer request body")
+		if err = WriteResponse(w, http.StatusBadRequest, ErrMessage("Неверный формат запроса")); err != nil {
+			h.log.Error("BalanceHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	ctx := r.Context()
+	userID, _, err := h.auth.GetFromContext(ctx)
+	if err != nil {
+		h.log.Error("BalanceHandler:can't get params from the token", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("BalanceHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	h.log.Info("Try to transfer funds", zap.String("recipient", transfer.Recipient), zap.Int("userID", userID))
+	auditTarget(ctx, fmt.Sprintf("recipient=%s sum=%v", transfer.Recipient, transfer.Amount))
+	err = h.balanceService.Transfer(ctx, &transfer, userID)
+	if err != nil {
+		var (
+			statusCode int
+			msg        string
+		)
+		h.log.Error("BalanceHandler:Transfer error", zap.Error(err))
+		switch err {
+		case dto.ErrBadParam, dto.ErrTransferToSelf:
+			statusCode = http.StatusBadRequest
+			msg = "Неверный формат запроса"
+		case dto.ErrNotEnoughFunds:
+			statusCode = http.StatusPaymentRequired
+			msg = "На счету недостаточно средств"
+		case dto.ErrRecipientNotFound:
+			statusCode = http.StatusNotFound
+			msg = "Получатель не найден"
+		case dto.ErrTransferLimit:
+			statusCode = http.StatusUnprocessableEntity
+			msg = "Сумма перевода нарушает установленные ограничения"
+		default:
+			statusCode = http.StatusInternalServerError
+			msg = "Внутренняя ошибка сервера"
+		}
+		if err = WriteResponse(w, statusCode, ErrMessage(msg)); err != nil {
+			h.log.Error("BalanceHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if err = WriteResponse(w, http.StatusOK, nil); err != nil {
+		h.log.Error("BalanceHandler: can't write response", zap.Error(err))
+	}
+	h.log.Info("Transfer success", zap.String("recipient", transfer.Recipient), zap.Int("userID", userID))
+}
+
+/*
+200 — успешная обработка запроса
+204 — нет данных для ответа.
+401 — пользователь не авторизован.
+500 — внутренняя ошибка сервера.
+*/
+func (h *BalanceHandler) GetStatement(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _, err := h.auth.GetFromContext(ctx)
+	if err != nil {
+		h.log.Error("BalanceHandler:can't get params from the token", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("BalanceHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	res, err := h.balanceService.GetStatement(ctx, userID)
+	if err != nil {
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("BalanceHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if len(res) == 0 {
+		if err = WriteResponse(w, http.StatusNoContent, nil); err != nil {
+			h.log.Error("BalanceHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	responseBody, err := json.Marshal(res)
+	if err != nil {
+		h.log.Error("BalanceHandler: can't serialize response", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("BalanceHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if err = WriteResponse(w, http.StatusOK, responseBody); err != nil {
+		h.log.Error("BalanceHandler: can't write response", zap.Error(err))
+	}
+}
+
+/*
+200 — выписка в теле ответа. Строки передаются по мере чтения из базы; если выгрузка прервалась,
+массив в ответе не закрыт;
+204 — нет данных для ответа.
+401 — пользователь не авторизован.
+500 — внутренняя ошибка сервера.
+*/
+func (h *BalanceHandler) ExportStatement(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _, err := h.auth.GetFromContext(ctx)
+	if err != nil {
+		h.log.Error("BalanceHandler:can't get params from the token", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("BalanceHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	out := newJSONArrayWriter(w)
+	err = h.balanceService.ExportStatement(ctx, userID, func(entry *dto.StatementEntry) error {
+		return out.Write(entry)
+	})
+	if err != nil {
+		if out.Started() {
+			h.log.Error("BalanceHandler: statement export interrupted", zap.Int("userID", userID), zap.Error(err))
+			return
+		}
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("BalanceHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if !out.Started() {
+		if err = WriteResponse(w, http.StatusNoContent, nil); err != nil {
+			h.log.Error("BalanceHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	if err = out.Close(); err != nil {
+		h.log.Error("BalanceHandler: can't write response", zap.Error(err))
+	}
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"github.com/golang/mock/gomock"
 	"github.com/portnyagin/practicum_project/internal/app/dto"
@@ -151,6 +152,28 @@ func TestBalanceHandler_Withdraw(t *testing.T) {
 				contentType:  "application/json",
 			},
 		},
+		{
+			name: "BalanceHandler. WithdrawalsList. Case #6. Rejected by policy",
+			args: args{
+				error: &dto.PolicyError{Code: dto.PolicyDailyLimitExceeded},
+				body:  "{\"order\": \"2377225624\",\"sum\": 751}",
+			},
+			wants: wants{
+				responseCode: http.StatusForbidden,
+				contentType:  "application/json",
+			},
+		},
+		{
+			name: "BalanceHandler. WithdrawalsList. Case #7. Order already paid",
+			args: args{
+				error: dto.ErrWithdrawalDuplicate,
+				body:  "{\"order\": \"2377225624\",\"sum\": 751}",
+			},
+			wants: wants{
+				responseCode: http.StatusConflict,
+				contentType:  "application/json",
+			},
+		},
 	}
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -231,3 +254,167 @@ func TestBalanceHandler_WithdrawalsList(t *testing.T) {
 		})
 	}
 }
+
+func TestBalanceHandler_Transfer(t *testing.T) {
+	type args struct {
+		error error
+		body  string
+	}
+	type wants struct {
+		responseCode int
+		contentType  string
+	}
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name: "BalanceHandler. Transfer. Case #1. Positive",
+			args: args{
+				error: nil,
+				body:  "{\"recipient\": \"friend\",\"sum\": 10}",
+			},
+			wants: wants{
+				responseCode: http.StatusOK,
+				contentType:  "application/json",
+			},
+		},
+		{
+			name: "BalanceHandler. Transfer. Case #2. NotEnoughFunds",
+			args: args{
+				error: dto.ErrNotEnoughFunds,
+				body:  "{\"recipient\": \"friend\",\"sum\": 10}",
+			},
+			wants: wants{
+				responseCode: http.StatusPaymentRequired,
+				contentType:  "application/json",
+			},
+		},
+		{
+			name: "BalanceHandler. Transfer. Case #3. Recipient not found",
+			args: args{
+				error: dto.ErrRecipientNotFound,
+				body:  "{\"recipient\": \"nobody\",\"sum\": 10}",
+			},
+			wants: wants{
+				responseCode: http.StatusNotFound,
+				contentType:  "application/json",
+			},
+		},
+		{
+			name: "BalanceHandler. Transfer. Case #4. Limit exceeded",
+			args: args{
+				error: dto.ErrTransferLimit,
+				body:  "{\"recipient\": \"friend\",\"sum\": 100000}",
+			},
+			wants: wants{
+				responseCode: http.StatusUnprocessableEntity,
+				contentType:  "application/json",
+			},
+		},
+		{
+			name: "BalanceHandler. Transfer. Case #5. Any error",
+			args: args{
+				error: errors.New("Any error"),
+				body:  "{\"recipient\": \"friend\",\"sum\": 10}",
+			},
+			wants: wants{
+				responseCode: http.StatusInternalServerError,
+				contentType:  "application/json",
+			},
+		},
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	balanceService := mocks.NewMockBalanceService(mockCtrl)
+	target := NewBalanceHandler(balanceService, auth, log)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			balanceService.EXPECT().Transfer(gomock.Any(), gomock.Any(), 0).Return(tt.args.error)
+			body := strings.NewReader(tt.args.body)
+			request := httptest.NewRequest("POST", "/api/user/balance/transfer", body)
+			request.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			h := http.HandlerFunc(target.Transfer)
+			h.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+			contentType := res.Header.Get("Content-type")
+			assert.Equal(t, tt.wants.responseCode, res.StatusCode, "Expected status %d, got %d", tt.wants.responseCode, res.StatusCode)
+			assert.Equal(t, tt.wants.contentType, contentType, "Expected status %d, got %d", tt.wants.contentType, contentType)
+		})
+	}
+}
+
+func TestBalanceHandler_ExportStatement(t *testing.T) {
+	entries := []dto.StatementEntry{
+		{OperationType: "CREDIT", Amount: 100, OrderNum: "79927398713"},
+		{OperationType: "DEBIT", Amount: 40, OrderNum: "2377225624"},
+	}
+	type args struct {
+		sent  int
+		error error
+	}
+	type wants struct {
+		responseCode int
+		body         string
+	}
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name: "BalanceHandler. ExportStatement. Case #1. Positive",
+			args: args{sent: 2},
+			wants: wants{
+				responseCode: http.StatusOK,
+				body:         `[{"operation_type":"CREDIT","sum":100,"order":"79927398713","processed_at":"0001-01-01T00:00:00Z"},{"operation_type":"DEBIT","sum":40,"order":"2377225624","processed_at":"0001-01-01T00:00:00Z"}]`,
+			},
+		},
+		{
+			name: "BalanceHandler. ExportStatement. Case #2. Error before first entry",
+			args: args{sent: 0, error: errors.New("any error")},
+			wants: wants{
+				responseCode: http.StatusInternalServerError,
+				body:         `{"msg":"Внутренняя ошибка сервера"}`,
+			},
+		},
+		{
+			name: "BalanceHandler. ExportStatement. Case #3. Interrupted export leaves array open",
+			args: args{sent: 1, error: errors.New("connection lost")},
+			wants: wants{
+				responseCode: http.StatusOK,
+				body:         `[{"operation_type":"CREDIT","sum":100,"order":"79927398713","processed_at":"0001-01-01T00:00:00Z"}`,
+			},
+		},
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	balanceService := mocks.NewMockBalanceService(mockCtrl)
+	target := NewBalanceHandler(balanceService, auth, log)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			balanceService.EXPECT().ExportStatement(gomock.Any(), 0, gomock.Any()).DoAndReturn(
+				func(ctx context.Context, userID int, fn func(entry *dto.StatementEntry) error) error {
+					for i := 0; i < tt.args.sent; i++ {
+						if err := fn(&entries[i]); err != nil {
+							return err
+						}
+					}
+					return tt.args.error
+				})
+
+			request := httptest.NewRequest("GET", "/api/user/balance/statement/export", nil)
+			w := httptest.NewRecorder()
+			h := http.HandlerFunc(target.ExportStatement)
+			h.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, tt.wants.responseCode, res.StatusCode, "Expected status %d, got %d", tt.wants.responseCode, res.StatusCode)
+			assert.Equal(t, "application/json", res.Header.Get("Content-type"))
+			assert.Equal(t, tt.wants.body, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/portnyagin/practicum_project/internal/app/handler (interfaces: AdjustmentService)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	dto "github.com/portnyagin/practicum_project/internal/app/dto"
+)
+
+// MockAdjustmentService is a mock of AdjustmentService interface.
+type MockAdjustmentService struct {
+	ctrl     *gomock.Controller
+	recorder *MockAdjustmentServiceMockRecorder
+}
+
+// MockAdjustmentServiceMockRecorder is the mock recorder for MockAdjustmentService.
+type MockAdjustmentServiceMockRecorder struct {
+	mock *MockAdjustmentService
+}
+
+// NewMockAdjustmentService creates a new mock instance.
+func NewMockAdjustmentService(ctrl *gomock.Controller) *MockAdjustmentService {
+	mock := &MockAdjustmentService{ctrl: ctrl}
+	mock.recorder = &MockAdjustmentServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAdjustmentService) EXPECT() *MockAdjustmentServiceMockRecorder {
+	return m.recorder
+}
+
+// Approve mocks base method.
+func (m *MockAdjustmentService) Approve(arg0 context.Context, arg1 int, arg2 string) (*dto.Adjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Approve", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*dto.Adjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Approve indicates an expected call of Approve.
+func (mr *MockAdjustmentServiceMockRecorder) Approve(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Approve", reflect.TypeOf((*MockAdjustmentService)(nil).Approve), arg0, arg1, arg2)
+}
+
+// Create mocks base method.
+func (m *MockAdjustmentService) Create(arg0 context.Context, arg1 *dto.AdjustmentRequest, arg2 string) (*dto.Adjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*dto.Adjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockAdjustmentServiceMockRecorder) Create(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockAdjustmentService)(nil).Create), arg0, arg1, arg2)
+}
+
+// GetList mocks base method.
+func (m *MockAdjustmentService) GetList(arg0 context.Context, arg1 string) ([]dto.Adjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetList", arg0, arg1)
+	ret0, _ := ret[0].([]dto.Adjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetList indicates an expected call of GetList.
+func (mr *MockAdjustmentServiceMockRecorder) GetList(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetList", reflect.TypeOf((*MockAdjustmentService)(nil).GetList), arg0, arg1)
+}
+
+// Reject mocks base method.
+func (m *MockAdjustmentService) Reject(arg0 context.Context, arg1 int, arg2 string) (*dto.Adjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reject", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*dto.Adjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Reject indicates an expected call of Reject.
+func (mr *MockAdjustmentServiceMockRecorder) Reject(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reject", reflect.TypeOf((*MockAdjustmentService)(nil).Reject), arg0, arg1, arg2)
+}
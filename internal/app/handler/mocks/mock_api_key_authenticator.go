@@ -0,0 +1,51 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/portnyagin/practicum_project/internal/app/handler (interfaces: APIKeyAuthenticator)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	dto "github.com/portnyagin/practicum_project/internal/app/dto"
+)
+
+// MockAPIKeyAuthenticator is a mock of APIKeyAuthenticator interface.
+type MockAPIKeyAuthenticator struct {
+	ctrl     *gomock.Controller
+	recorder *MockAPIKeyAuthenticatorMockRecorder
+}
+
+// MockAPIKeyAuthenticatorMockRecorder is the mock recorder for MockAPIKeyAuthenticator.
+type MockAPIKeyAuthenticatorMockRecorder struct {
+	mock *MockAPIKeyAuthenticator
+}
+
+// NewMockAPIKeyAuthenticator creates a new mock instance.
+func NewMockAPIKeyAuthenticator(ctrl *gomock.Controller) *MockAPIKeyAuthenticator {
+	mock := &MockAPIKeyAuthenticator{ctrl: ctrl}
+	mock.recorder = &MockAPIKeyAuthenticatorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAPIKeyAuthenticator) EXPECT() *MockAPIKeyAuthenticatorMockRecorder {
+	return m.recorder
+}
+
+// Authenticate mocks base method.
+func (m *MockAPIKeyAuthenticator) Authenticate(arg0 context.Context, arg1 string) (*dto.APIKeyPrincipal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Authenticate", arg0, arg1)
+	ret0, _ := ret[0].(*dto.APIKeyPrincipal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Authenticate indicates an expected call of Authenticate.
+func (mr *MockAPIKeyAuthenticatorMockRecorder) Authenticate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authenticate", reflect.TypeOf((*MockAPIKeyAuthenticator)(nil).Authenticate), arg0, arg1)
+}
@@ -35,6 +35,20 @@ func (m *MockAuthService) EXPECT() *MockAuthServiceMockRecorder {
 	return m.recorder
 }
 
+// ChangePassword mocks base method.
+func (m *MockAuthService) ChangePassword(arg0 context.Context, arg1 int, arg2 string, arg3 *dto.ChangePasswordRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ChangePassword indicates an expected call of ChangePassword.
+func (mr *MockAuthServiceMockRecorder) ChangePassword(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockAuthService)(nil).ChangePassword), arg0, arg1, arg2, arg3)
+}
+
 // Check mocks base method.
 func (m *MockAuthService) Check(arg0 context.Context, arg1 *dto.User) (*dto.User, error) {
 	m.ctrl.T.Helper()
@@ -50,6 +64,20 @@ func (mr *MockAuthServiceMockRecorder) Check(arg0, arg1 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockAuthService)(nil).Check), arg0, arg1)
 }
 
+// Deactivate mocks base method.
+func (m *MockAuthService) Deactivate(arg0 context.Context, arg1 int, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Deactivate", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Deactivate indicates an expected call of Deactivate.
+func (mr *MockAuthServiceMockRecorder) Deactivate(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deactivate", reflect.TypeOf((*MockAuthService)(nil).Deactivate), arg0, arg1, arg2)
+}
+
 // Register mocks base method.
 func (m *MockAuthService) Register(arg0 context.Context, arg1 *dto.User) (*dto.User, error) {
 	m.ctrl.T.Helper()
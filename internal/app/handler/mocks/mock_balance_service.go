@@ -35,6 +35,20 @@ func (m *MockBalanceService) EXPECT() *MockBalanceServiceMockRecorder {
 	return m.recorder
 }
 
+// ExportStatement mocks base method.
+func (m *MockBalanceService) ExportStatement(arg0 context.Context, arg1 int, arg2 func(*dto.StatementEntry) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportStatement", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportStatement indicates an expected call of ExportStatement.
+func (mr *MockBalanceServiceMockRecorder) ExportStatement(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportStatement", reflect.TypeOf((*MockBalanceService)(nil).ExportStatement), arg0, arg1, arg2)
+}
+
 // GetCurrentBalance mocks base method.
 func (m *MockBalanceService) GetCurrentBalance(arg0 context.Context, arg1 int) (*dto.Balance, error) {
 	m.ctrl.T.Helper()
@@ -50,6 +64,21 @@ func (mr *MockBalanceServiceMockRecorder) GetCurrentBalance(arg0, arg1 interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCurrentBalance", reflect.TypeOf((*MockBalanceService)(nil).GetCurrentBalance), arg0, arg1)
 }
 
+// GetStatement mocks base method.
+func (m *MockBalanceService) GetStatement(arg0 context.Context, arg1 int) ([]dto.StatementEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatement", arg0, arg1)
+	ret0, _ := ret[0].([]dto.StatementEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStatement indicates an expected call of GetStatement.
+func (mr *MockBalanceServiceMockRecorder) GetStatement(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatement", reflect.TypeOf((*MockBalanceService)(nil).GetStatement), arg0, arg1)
+}
+
 // GetWithdrawalsList mocks base method.
 func (m *MockBalanceService) GetWithdrawalsList(arg0 context.Context, arg1 int) ([]dto.Withdrawal, error) {
 	m.ctrl.T.Helper()
@@ -65,6 +94,20 @@ func (mr *MockBalanceServiceMockRecorder) GetWithdrawalsList(arg0, arg1 interfac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawalsList", reflect.TypeOf((*MockBalanceService)(nil).GetWithdrawalsList), arg0, arg1)
 }
 
+// Transfer mocks base method.
+func (m *MockBalanceService) Transfer(arg0 context.Context, arg1 *dto.Transfer, arg2 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Transfer", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Transfer indicates an expected call of Transfer.
+func (mr *MockBalanceServiceMockRecorder) Transfer(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Transfer", reflect.TypeOf((*MockBalanceService)(nil).Transfer), arg0, arg1, arg2)
+}
+
 // Withdraw mocks base method.
 func (m *MockBalanceService) Withdraw(arg0 context.Context, arg1 *dto.Withdraw, arg2 int) error {
 	m.ctrl.T.Helper()
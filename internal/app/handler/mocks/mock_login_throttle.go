@@ -0,0 +1,77 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/portnyagin/practicum_project/internal/app/handler (interfaces: LoginThrottle)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockLoginThrottle is a mock of LoginThrottle interface.
+type MockLoginThrottle struct {
+	ctrl     *gomock.Controller
+	recorder *MockLoginThrottleMockRecorder
+}
+
+// MockLoginThrottleMockRecorder is the mock recorder for MockLoginThrottle.
+type MockLoginThrottleMockRecorder struct {
+	mock *MockLoginThrottle
+}
+
+// NewMockLoginThrottle creates a new mock instance.
+func NewMockLoginThrottle(ctrl *gomock.Controller) *MockLoginThrottle {
+	mock := &MockLoginThrottle{ctrl: ctrl}
+	mock.recorder = &MockLoginThrottleMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLoginThrottle) EXPECT() *MockLoginThrottleMockRecorder {
+	return m.recorder
+}
+
+// Check mocks base method.
+func (m *MockLoginThrottle) Check(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Check", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Check indicates an expected call of Check.
+func (mr *MockLoginThrottleMockRecorder) Check(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockLoginThrottle)(nil).Check), arg0, arg1, arg2)
+}
+
+// Failure mocks base method.
+func (m *MockLoginThrottle) Failure(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Failure", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Failure indicates an expected call of Failure.
+func (mr *MockLoginThrottleMockRecorder) Failure(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Failure", reflect.TypeOf((*MockLoginThrottle)(nil).Failure), arg0, arg1, arg2)
+}
+
+// Success mocks base method.
+func (m *MockLoginThrottle) Success(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Success", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Success indicates an expected call of Success.
+func (mr *MockLoginThrottleMockRecorder) Success(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Success", reflect.TypeOf((*MockLoginThrottle)(nil).Success), arg0, arg1)
+}
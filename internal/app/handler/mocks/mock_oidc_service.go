@@ -0,0 +1,66 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/portnyagin/practicum_project/internal/app/handler (interfaces: OIDCService)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	dto "github.com/portnyagin/practicum_project/internal/app/dto"
+)
+
+// MockOIDCService is a mock of OIDCService interface.
+type MockOIDCService struct {
+	ctrl     *gomock.Controller
+	recorder *MockOIDCServiceMockRecorder
+}
+
+// MockOIDCServiceMockRecorder is the mock recorder for MockOIDCService.
+type MockOIDCServiceMockRecorder struct {
+	mock *MockOIDCService
+}
+
+// NewMockOIDCService creates a new mock instance.
+func NewMockOIDCService(ctrl *gomock.Controller) *MockOIDCService {
+	mock := &MockOIDCService{ctrl: ctrl}
+	mock.recorder = &MockOIDCServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOIDCService) EXPECT() *MockOIDCServiceMockRecorder {
+	return m.recorder
+}
+
+// Begin mocks base method.
+func (m *MockOIDCService) Begin(arg0 context.Context) (*dto.OIDCAuthRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Begin", arg0)
+	ret0, _ := ret[0].(*dto.OIDCAuthRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Begin indicates an expected call of Begin.
+func (mr *MockOIDCServiceMockRecorder) Begin(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Begin", reflect.TypeOf((*MockOIDCService)(nil).Begin), arg0)
+}
+
+// Complete mocks base method.
+func (m *MockOIDCService) Complete(arg0 context.Context, arg1, arg2, arg3 string) (*dto.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Complete", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*dto.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Complete indicates an expected call of Complete.
+func (mr *MockOIDCServiceMockRecorder) Complete(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Complete", reflect.TypeOf((*MockOIDCService)(nil).Complete), arg0, arg1, arg2, arg3)
+}
@@ -0,0 +1,65 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/portnyagin/practicum_project/internal/app/handler (interfaces: SecurityEventService)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	dto "github.com/portnyagin/practicum_project/internal/app/dto"
+)
+
+// MockSecurityEventService is a mock of SecurityEventService interface.
+type MockSecurityEventService struct {
+	ctrl     *gomock.Controller
+	recorder *MockSecurityEventServiceMockRecorder
+}
+
+// MockSecurityEventServiceMockRecorder is the mock recorder for MockSecurityEventService.
+type MockSecurityEventServiceMockRecorder struct {
+	mock *MockSecurityEventService
+}
+
+// NewMockSecurityEventService creates a new mock instance.
+func NewMockSecurityEventService(ctrl *gomock.Controller) *MockSecurityEventService {
+	mock := &MockSecurityEventService{ctrl: ctrl}
+	mock.recorder = &MockSecurityEventServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSecurityEventService) EXPECT() *MockSecurityEventServiceMockRecorder {
+	return m.recorder
+}
+
+// Export mocks base method.
+func (m *MockSecurityEventService) Export(arg0 context.Context, arg1 *dto.SecurityEventFilter, arg2 func(*dto.SecurityEvent) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Export", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Export indicates an expected call of Export.
+func (mr *MockSecurityEventServiceMockRecorder) Export(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Export", reflect.TypeOf((*MockSecurityEventService)(nil).Export), arg0, arg1, arg2)
+}
+
+// Find mocks base method.
+func (m *MockSecurityEventService) Find(arg0 context.Context, arg1 *dto.SecurityEventFilter) ([]dto.SecurityEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Find", arg0, arg1)
+	ret0, _ := ret[0].([]dto.SecurityEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Find indicates an expected call of Find.
+func (mr *MockSecurityEventServiceMockRecorder) Find(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockSecurityEventService)(nil).Find), arg0, arg1)
+}
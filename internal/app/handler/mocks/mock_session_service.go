@@ -0,0 +1,80 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/portnyagin/practicum_project/internal/app/handler (interfaces: SessionService)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	dto "github.com/portnyagin/practicum_project/internal/app/dto"
+)
+
+// MockSessionService is a mock of SessionService interface.
+type MockSessionService struct {
+	ctrl     *gomock.Controller
+	recorder *MockSessionServiceMockRecorder
+}
+
+// MockSessionServiceMockRecorder is the mock recorder for MockSessionService.
+type MockSessionServiceMockRecorder struct {
+	mock *MockSessionService
+}
+
+// NewMockSessionService creates a new mock instance.
+func NewMockSessionService(ctrl *gomock.Controller) *MockSessionService {
+	mock := &MockSessionService{ctrl: ctrl}
+	mock.recorder = &MockSessionServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSessionService) EXPECT() *MockSessionServiceMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockSessionService) Create(arg0 context.Context, arg1 int) (*dto.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", arg0, arg1)
+	ret0, _ := ret[0].(*dto.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockSessionServiceMockRecorder) Create(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSessionService)(nil).Create), arg0, arg1)
+}
+
+// Refresh mocks base method.
+func (m *MockSessionService) Refresh(arg0 context.Context, arg1 string) (*dto.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Refresh", arg0, arg1)
+	ret0, _ := ret[0].(*dto.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Refresh indicates an expected call of Refresh.
+func (mr *MockSessionServiceMockRecorder) Refresh(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refresh", reflect.TypeOf((*MockSessionService)(nil).Refresh), arg0, arg1)
+}
+
+// Revoke mocks base method.
+func (m *MockSessionService) Revoke(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revoke", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Revoke indicates an expected call of Revoke.
+func (mr *MockSessionServiceMockRecorder) Revoke(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockSessionService)(nil).Revoke), arg0, arg1)
+}
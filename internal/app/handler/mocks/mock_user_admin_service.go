@@ -0,0 +1,77 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/portnyagin/practicum_project/internal/app/handler (interfaces: UserAdminService)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockUserAdminService is a mock of UserAdminService interface.
+type MockUserAdminService struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserAdminServiceMockRecorder
+}
+
+// MockUserAdminServiceMockRecorder is the mock recorder for MockUserAdminService.
+type MockUserAdminServiceMockRecorder struct {
+	mock *MockUserAdminService
+}
+
+// NewMockUserAdminService creates a new mock instance.
+func NewMockUserAdminService(ctrl *gomock.Controller) *MockUserAdminService {
+	mock := &MockUserAdminService{ctrl: ctrl}
+	mock.recorder = &MockUserAdminServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUserAdminService) EXPECT() *MockUserAdminServiceMockRecorder {
+	return m.recorder
+}
+
+// DeactivateByLogin mocks base method.
+func (m *MockUserAdminService) DeactivateByLogin(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeactivateByLogin", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeactivateByLogin indicates an expected call of DeactivateByLogin.
+func (mr *MockUserAdminServiceMockRecorder) DeactivateByLogin(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeactivateByLogin", reflect.TypeOf((*MockUserAdminService)(nil).DeactivateByLogin), arg0, arg1)
+}
+
+// GrantRole mocks base method.
+func (m *MockUserAdminService) GrantRole(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GrantRole", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GrantRole indicates an expected call of GrantRole.
+func (mr *MockUserAdminServiceMockRecorder) GrantRole(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GrantRole", reflect.TypeOf((*MockUserAdminService)(nil).GrantRole), arg0, arg1, arg2)
+}
+
+// RevokeRole mocks base method.
+func (m *MockUserAdminService) RevokeRole(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeRole", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeRole indicates an expected call of RevokeRole.
+func (mr *MockUserAdminServiceMockRecorder) RevokeRole(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeRole", reflect.TypeOf((*MockUserAdminService)(nil).RevokeRole), arg0, arg1, arg2)
+}
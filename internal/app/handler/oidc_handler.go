@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/mymiddleware"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//go:generate mockgen -destination=mocks/mock_oidc_service.go -package=mocks . OIDCService
+type OIDCService interface {
+	Begin(ctx context.Context) (*dto.OIDCAuthRequest, error)
+	Complete(ctx context.Context, code string, verifier string, nonce string) (*dto.User, error)
+}
+
+const (
+	// oidcStateCookieName хранит state, nonce и PKCE verifier начатого входа до возврата от провайдера
+	oidcStateCookieName = "oidc_state"
+	oidcStateCookiePath = "/api/user/oidc"
+	oidcStateTTL        = 10 * time.Minute
+)
+
+type OIDCHandler struct {
+	oidcService    OIDCService
+	sessionService SessionService
+	auth           *Auth
+	log            *infrastructure.Logger
+	// postLoginRedirect - адрес, на который перенаправляется пользователь после входа. Если не задан, токены возвращаются в ответе
+	postLoginRedirect string
+	tokenInBody       bool
+}
+
+func NewOIDCHandler(os OIDCService, ss SessionService, auth *Auth, l *infrastructure.Logger, postLoginRedirect string, tokenInBody bool) *OIDCHandler {
+	var target OIDCHandler
+	target.oidcService = os
+	target.sessionService = ss
+	target.auth = auth
+	target.log = l
+	target.postLoginRedirect = postLoginRedirect
+	target.tokenInBody = tokenInBody
+	return &target
+}
+
+/*
+302 — перенаправление на страницу входа провайдера;
+500 — внутренняя ошибка сервера;
+502 — провайдер недоступен.
+*/
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	req, err := h.oidcService.Begin(r.Context())
+	if err != nil {
+		h.log.Error("OIDCHandler: can't start login", zap.Error(err))
+		status := http.StatusInternalServerError
+		msg := "Внутренняя ошибка сервера"
+		if errors.Is(err, dto.ErrRemoteServiceError) {
+			status = http.StatusBadGateway
+			msg = "Провайдер учетных записей недоступен"
+		}
+		if err = WriteResponse(w, status, ErrMessage(msg)); err != nil {
+			h.log.Error("OIDCHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	value := strings.Join([]string{req.State, req.Nonce, req.Verifier}, ".")
+	c := h.auth.bakeCookie(oidcStateCookieName, value, oidcStateCookiePath, time.Now().Add(oidcStateTTL))
+	// возврат от провайдера - переход с другого сайта: при SameSite=Strict cookie не была бы отправлена
+	c.HttpOnly = true
+	c.SameSite = http.SameSiteLaxMode
+	http.SetCookie(w, c)
+	http.Redirect(w, r, req.URL, http.StatusFound)
+}
+
+/*
+200 — пользователь вошел, токены выданы (или 302 на адрес после входа);
+400 — нет кода авторизации или state не совпадает с начатым входом;
+401 — провайдер отказал во входе, id_token недействителен или пользователь деактивирован;
+409 — не удалось подобрать свободный логин для нового пользователя;
+500 — внутренняя ошибка сервера;
+502 — провайдер недоступен.
+*/
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	// state одноразовый: cookie удаляется при любом исходе
+	http.SetCookie(w, h.auth.dropCookie(oidcStateCookieName, oidcStateCookiePath))
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		h.log.Info("OIDCHandler: provider returned error", zap.String("error", e), zap.String("description", q.Get("error_description")))
+		if err := WriteResponse(w, http.StatusUnauthorized, ErrMessage("Вход отклонен провайдером")); err != nil {
+			h.log.Error("OIDCHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	state, nonce, verifier, ok := h.stateFromCookie(r)
+	if !ok || q.Get("code") == "" || subtle.ConstantTimeCompare([]byte(q.Get("state")), []byte(state)) != 1 {
+		h.log.Info("OIDCHandler: state mismatch or missing code")
+		if err := WriteResponse(w, http.StatusBadRequest, ErrMessage("Неверный формат запроса")); err != nil {
+			h.log.Error("OIDCHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+
+	ctx := r.Context()
+	user, err := h.oidcService.Complete(ctx, q.Get("code"), verifier, nonce)
+	if err != nil {
+		var (
+			status int
+			msg    string
+		)
+		switch {
+		case errors.Is(err, dto.ErrUnauthorized):
+			status, msg = http.StatusUnauthorized, "Пользователь не аутентифицирован"
+		case errors.Is(err, dto.ErrBadParam):
+			status, msg = http.StatusBadRequest, "Неверный формат запроса"
+		case errors.Is(err, dto.ErrDuplicateKey):
+			status, msg = http.StatusConflict, "Логин уже занят"
+		case errors.Is(err, dto.ErrRemoteServiceError):
+			status, msg = http.StatusBadGateway, "Провайдер учетных записей недоступен"
+		default:
+			h.log.Error("OIDCHandler: can't complete login", zap.Error(err))
+			status, msg = http.StatusInternalServerError, "Внутренняя ошибка сервера"
+		}
+		if err = WriteResponse(w, status, ErrMessage(msg)); err != nil {
+			h.log.Error("OIDCHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+
+	auditActor(ctx, user.ID, user.Login)
+	mymiddleware.ReadOwnWrites(ctx, user.ID)
+	session, err := h.sessionService.Create(ctx, user.ID)
+	if err != nil {
+		h.log.Error("OIDCHandler: can't create session", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("OIDCHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	token, err := h.auth.issueTokens(w, user.ID, user.Login, session)
+	if err != nil {
+		h.log.Error("OIDCHandler: can't make token", zap.Error(err))
+		if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+			h.log.Error("OIDCHandler: can't write response", zap.Error(err))
+		}
+		return
+	}
+	h.log.Info("OIDCHandler: user logged in", zap.String("login", user.Login))
+	if h.postLoginRedirect != "" {
+		http.Redirect(w, r, h.postLoginRedirect, http.StatusFound)
+		return
+	}
+	var responseBody []byte
+	if h.tokenInBody {
+		if responseBody, err = json.Marshal(token); err != nil {
+			h.log.Error("OIDCHandler: can't serialize response", zap.Error(err))
+			if err = WriteResponse(w, http.StatusInternalServerError, ErrMessage("Внутренняя ошибка сервера")); err != nil {
+				h.log.Error("OIDCHandler: can't write response", zap.Error(err))
+			}
+			return
+		}
+	}
+	if err = WriteResponse(w, http.StatusOK, responseBody); err != nil {
+		h.log.Error("OIDCHandler: can't write response", zap.Error(err))
+	}
+}
+
+func (h *OIDCHandler) stateFromCookie(r *http.Request) (state string, nonce string, verifier string, ok bool) {
+	c, err := r.Cookie(oidcStateCookieName)
+	if err != nil {
+		return "", "", "", false
+	}
+	parts := strings.Split(c.Value, ".")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", "", false
+	}
+	return parts[0], parts[1], parts[2], true
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/handler/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOIDCHandler_Login(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	oidcService := mocks.NewMockOIDCService(mockCtrl)
+	target := NewOIDCHandler(oidcService, nil, auth, log, "", false)
+	oidcService.EXPECT().Begin(gomock.Any()).
+		Return(&dto.OIDCAuthRequest{URL: "http://idp/authorize?state=s", State: "s", Nonce: "n", Verifier: "v"}, nil)
+
+	request := httptest.NewRequest("GET", "/api/user/oidc/login", nil)
+	w := httptest.NewRecorder()
+	http.HandlerFunc(target.Login).ServeHTTP(w, request)
+	res := w.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusFound, res.StatusCode)
+	assert.Equal(t, "http://idp/authorize?state=s", res.Header.Get("Location"))
+	require.Len(t, res.Cookies(), 1)
+	c := res.Cookies()[0]
+	assert.Equal(t, oidcStateCookieName, c.Name)
+	assert.Equal(t, "s.n.v", c.Value)
+	assert.Equal(t, oidcStateCookiePath, c.Path)
+	assert.True(t, c.HttpOnly)
+}
+
+func TestOIDCHandler_Callback(t *testing.T) {
+	type args struct {
+		query  string
+		cookie string
+		user   *dto.User
+		err    error
+	}
+	type wants struct {
+		responseCode int
+		location     string
+	}
+	tests := []struct {
+		name     string
+		redirect string
+		args     args
+		wants    wants
+	}{
+		{
+			name: "OIDCHandler. Callback. Case #1. Success",
+			args: args{
+				query:  "?code=c&state=s",
+				cookie: "s.n.v",
+				user:   &dto.User{ID: 10, Login: "user"},
+			},
+			wants: wants{responseCode: http.StatusOK},
+		},
+		{
+			name:     "OIDCHandler. Callback. Case #2. Success with redirect",
+			redirect: "/app",
+			args: args{
+				query:  "?code=c&state=s",
+				cookie: "s.n.v",
+				user:   &dto.User{ID: 10, Login: "user"},
+			},
+			wants: wants{responseCode: http.StatusFound, location: "/app"},
+		},
+		{
+			name: "OIDCHandler. Callback. Case #3. State mismatch",
+			args: args{
+				query:  "?code=c&state=forged",
+				cookie: "s.n.v",
+			},
+			wants: wants{responseCode: http.StatusBadRequest},
+		},
+		{
+			name: "OIDCHandler. Callback. Case #4. No state cookie",
+			args: args{
+				query: "?code=c&state=s",
+			},
+			wants: wants{responseCode: http.StatusBadRequest},
+		},
+		{
+			name: "OIDCHandler. Callback. Case #5. Provider denied access",
+			args: args{
+				query:  "?error=access_denied&state=s",
+				cookie: "s.n.v",
+			},
+			wants: wants{responseCode: http.StatusUnauthorized},
+		},
+		{
+			name: "OIDCHandler. Callback. Case #6. Invalid id_token",
+			args: args{
+				query:  "?code=c&state=s",
+				cookie: "s.n.v",
+				err:    dto.ErrUnauthorized,
+			},
+			wants: wants{responseCode: http.StatusUnauthorized},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			oidcService := mocks.NewMockOIDCService(mockCtrl)
+			sessionService := mocks.NewMockSessionService(mockCtrl)
+			target := NewOIDCHandler(oidcService, sessionService, auth, log, tt.redirect, false)
+			if tt.args.user != nil || tt.args.err != nil {
+				oidcService.EXPECT().Complete(gomock.Any(), "c", "v", "n").Return(tt.args.user, tt.args.err)
+			}
+			if tt.args.user != nil {
+				sessionService.EXPECT().Create(gomock.Any(), tt.args.user.ID).
+					Return(&dto.Session{ID: "session", UserID: tt.args.user.ID, RefreshToken: "refresh"}, nil)
+			}
+
+			request := httptest.NewRequest("GET", "/api/user/oidc/callback"+tt.args.query, nil)
+			if tt.args.cookie != "" {
+				request.AddCookie(&http.Cookie{Name: oidcStateCookieName, Value: tt.args.cookie})
+			}
+			w := httptest.NewRecorder()
+			http.HandlerFunc(target.Callback).ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.wants.responseCode, res.StatusCode)
+			assert.Equal(t, tt.wants.location, res.Header.Get("Location"))
+			cookies := map[string]*http.Cookie{}
+			for _, c := range res.Cookies() {
+				cookies[c.Name] = c
+			}
+			if assert.Contains(t, cookies, oidcStateCookieName) {
+				assert.Equal(t, -1, cookies[oidcStateCookieName].MaxAge, "state cookie must be dropped")
+			}
+			if tt.args.user != nil {
+				assert.NotEmpty(t, res.Header.Get("Authorization"))
+				assert.Equal(t, "refresh", cookies["refresh_token"].Value)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// streamFlushEvery - через сколько элементов выгрузки ответ отправляется клиенту
+const streamFlushEvery = 100
+
+// jsonArrayWriter пишет ответ массивом JSON по одному элементу, не собирая его в памяти. Статус 200 отправляется
+// вместе с первым элементом, поэтому, пока элементов нет, можно ответить ошибкой или 204. Если выгрузка прервалась
+// после первого элемента, массив остается незакрытым: клиент получает некорректный JSON, а не часть данных под видом полных
+type jsonArrayWriter struct {
+	w     http.ResponseWriter
+	count int
+}
+
+func newJSONArrayWriter(w http.ResponseWriter) *jsonArrayWriter {
+	return &jsonArrayWriter{w: w}
+}
+
+func (a *jsonArrayWriter) Write(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if a.count == 0 {
+		a.w.Header().Set("Content-Type", "application/json")
+		a.w.WriteHeader(http.StatusOK)
+		b = append([]byte("["), b...)
+	} else {
+		b = append([]byte(","), b...)
+	}
+	if _, err = a.w.Write(b); err != nil {
+		return err
+	}
+	a.count++
+	if a.count%streamFlushEvery == 0 {
+		a.flush()
+	}
+	return nil
+}
+
+// Started - ответ уже начат, ошибку клиенту не передать
+func (a *jsonArrayWriter) Started() bool {
+	return a.count > 0
+}
+
+// Close закрывает массив. Вызывается только для начатого ответа
+func (a *jsonArrayWriter) Close() error {
+	if _, err := a.w.Write([]byte("]")); err != nil {
+		return err
+	}
+	a.flush()
+	return nil
+}
+
+func (a *jsonArrayWriter) flush() {
+	if f, ok := a.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -2,11 +2,20 @@ package handler
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
 	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/mymiddleware"
 	"io"
+	"net"
 	"net/http"
+	"time"
 )
 
 func ErrMessage(msg string) []byte {
@@ -17,6 +26,22 @@ func ErrMessage(msg string) []byte {
 	return b
 }
 
+func ErrCodeMessage(msg string, code string) []byte {
+	b, err := json.Marshal(dto.Error{Msg: msg, Code: code})
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+func ErrViolationsMessage(msg string, violations []dto.Violation) []byte {
+	b, err := json.Marshal(dto.Error{Msg: msg, Code: "VALIDATION_FAILED", Violations: violations})
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
 func WriteResponse(w http.ResponseWriter, status int, message []byte) error {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -40,24 +65,104 @@ func getRequestBody(r *http.Request) ([]byte, error) {
 	return b, nil
 }
 
+// clientIP - адрес клиента без порта. За прокси адрес подставляет middleware.RealIP
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// auditActor указывает пользователя в событии журнала безопасности, если до входа он не известен из токена
+func auditActor(ctx context.Context, userID int, login string) {
+	if event := mymiddleware.AuditEvent(ctx); event != nil {
+		event.UserID = userID
+		event.Login = login
+	}
+}
+
+// auditTarget указывает объект действия, переданный в теле запроса
+func auditTarget(ctx context.Context, target string) {
+	if event := mymiddleware.AuditEvent(ctx); event != nil {
+		event.Target = target
+	}
+}
+
 type Auth struct {
-	tokenAuth *jwtauth.JWTAuth
+	// keys - ключи проверки токенов: секреты HS* и открытые части асимметричных ключей
+	keys    jwk.Set
+	signKey jwk.Key
+	signAlg jwa.SignatureAlgorithm
+	// accessTTL - время жизни access-токена
+	accessTTL time.Duration
+	cookie    CookieSettings
 }
 
+const defaultAccessTokenTTL = 15 * time.Minute
+
+// NewAuth создает Auth с единственным ключом HS256
 func NewAuth(secret string) *Auth {
+	key, err := jwk.New([]byte(secret))
+	if err != nil {
+		// для []byte jwk.New ошибку не возвращает
+		panic(err)
+	}
+	key.Set(jwk.AlgorithmKey, jwa.HS256)
 	var auth Auth
-	auth.tokenAuth = jwtauth.New("HS256", []byte(secret), nil)
+	auth.keys = jwk.NewSet()
+	auth.keys.Add(key)
+	auth.signKey = key
+	auth.signAlg = jwa.HS256
+	auth.accessTTL = defaultAccessTokenTTL
+	auth.cookie = DefaultCookieSettings()
 	return &auth
 }
 
+// NewAuthWithKeys создает Auth с набором ключей. Токены подписываются ключом signingKeyID
+// (при пустом значении - последним ключом набора с закрытой частью), а проверяются любым ключом набора по kid
+func NewAuthWithKeys(keys jwk.Set, signingKeyID string) (*Auth, error) {
+	verifyKeys, err := verificationKeySet(keys)
+	if err != nil {
+		return nil, err
+	}
+	signKey, err := signingKey(keys, signingKeyID)
+	if err != nil {
+		return nil, err
+	}
+	var auth Auth
+	auth.keys = verifyKeys
+	auth.signKey = signKey
+	auth.accessTTL = defaultAccessTokenTTL
+	auth.cookie = DefaultCookieSettings()
+	if err = auth.signAlg.Accept(signKey.Algorithm()); err != nil {
+		return nil, err
+	}
+	return &auth, nil
+}
+
+func (auth *Auth) SetAccessTokenTTL(ttl time.Duration) {
+	if ttl > 0 {
+		auth.accessTTL = ttl
+	}
+}
+
+func (auth *Auth) AccessTokenTTL() time.Duration {
+	return auth.accessTTL
+}
+
 func (auth *Auth) GetFromContext(ctx context.Context) (userID int, login string, err error) {
 	_, m, err := jwtauth.FromContext(ctx)
 	if err != nil {
 		return 0, "", err
 	}
 
-	if u, ok := m["user_id"]; ok {
-		userID = int(u.(float64))
+	// в разобранном JWT числа имеют тип float64, в токене API-ключа - int
+	switch u := m["user_id"].(type) {
+	case float64:
+		userID = int(u)
+	case int:
+		userID = u
 	}
 
 	if l, ok := m["login"]; ok {
@@ -67,18 +172,221 @@ func (auth *Auth) GetFromContext(ctx context.Context) (userID int, login string,
 	return userID, login, nil
 }
 
-func (auth *Auth) GetNewToken(userID int, login string) (string, error) {
-	_, tokenString, err := auth.tokenAuth.Encode(map[string]interface{}{"user_id": userID, "login": login})
-	return tokenString, err
+// GetSessionFromContext возвращает идентификатор серверной сессии, к которой привязан токен
+func (auth *Auth) GetSessionFromContext(ctx context.Context) (string, error) {
+	_, m, err := jwtauth.FromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	sessionID, _ := m["sid"].(string)
+	return sessionID, nil
+}
+
+// GetNewToken выпускает access-токен сессии sessionID со сроком жизни accessTTL. Роли пользователя сохраняются в claim roles.
+// Вместе с токеном возвращается CSRF-токен, который сохраняется в claim csrf
+func (auth *Auth) GetNewToken(userID int, login string, sessionID string, roles []string) (token string, csrf string, err error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", "", err
+	}
+	csrf, err = newTokenID()
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	t := jwt.New()
+	claims := map[string]interface{}{
+		"user_id":         userID,
+		"login":           login,
+		"sid":             sessionID,
+		"roles":           roles,
+		"csrf":            csrf,
+		jwt.JwtIDKey:      jti,
+		jwt.IssuedAtKey:   now,
+		jwt.ExpirationKey: now.Add(auth.accessTTL),
+	}
+	for k, v := range claims {
+		if err = t.Set(k, v); err != nil {
+			return "", "", err
+		}
+	}
+	// kid ключа попадает в заголовок токена
+	b, err := jwt.Sign(t, auth.signAlg, auth.signKey)
+	if err != nil {
+		return "", "", err
+	}
+	return string(b), csrf, nil
+}
+
+// issueTokens выпускает access-токен сессии, передает его в заголовке Authorization
+// и выставляет cookie с access-, refresh- и CSRF-токенами
+func (auth *Auth) issueTokens(w http.ResponseWriter, userID int, login string, session *dto.Session) (*dto.Token, error) {
+	token, csrf, err := auth.GetNewToken(userID, login, session.ID, session.Roles)
+	if err != nil {
+		return nil, err
+	}
+	accessExpires := time.Now().Add(auth.accessTTL)
+	http.SetCookie(w, auth.bakeCookie(accessCookieName, token, auth.cookie.Path, accessExpires))
+	http.SetCookie(w, auth.bakeCookie(refreshCookieName, session.RefreshToken, refreshCookiePath, session.ExpiresAt))
+	http.SetCookie(w, auth.bakeCSRFCookie(csrf, accessExpires))
+	w.Header().Set("Authorization", "Bearer "+token)
+	return &dto.Token{
+		AccessToken:  token,
+		RefreshToken: session.RefreshToken,
+		ExpiresIn:    int(auth.accessTTL.Seconds()),
+	}, nil
+}
+
+// Decode проверяет подпись ключом, kid которого указан в заголовке токена. Алгоритм берется из описания ключа,
+// а не из заголовка токена
+func (auth *Auth) Decode(tokenString string) (jwt.Token, error) {
+	return jwt.Parse([]byte(tokenString), jwt.WithKeySet(auth.keys), jwt.UseDefaultKey(true))
+}
+
+// Verifier - аналог jwtauth.Verifier с поддержкой нескольких ключей. Результат проверки кладется в контекст,
+// поэтому jwtauth.Authenticator и jwtauth.FromContext работают без изменений
+func (auth *Auth) Verifier(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.verifyRequest(r)
+		ctx := jwtauth.NewContext(r.Context(), token, err)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// APIKeyHeader - заголовок с API-ключом
+const APIKeyHeader = "X-API-Key"
+
+//go:generate mockgen -destination=mocks/mock_api_key_authenticator.go -package=mocks . APIKeyAuthenticator
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (*dto.APIKeyPrincipal, error)
+}
+
+// VerifierOrAPIKey работает как Verifier, но при наличии заголовка X-API-Key проверяет ключ вместо JWT.
+// Для ключа строится неподписанный токен с claims user_id, login, api_key и scopes, поэтому обработчики получают
+// пользователя так же, как из JWT. Каждый маршрут группы должен проверять права ключа через mymiddleware.RequireScope
+func (auth *Auth) VerifierOrAPIKey(keys APIKeyAuthenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(APIKeyHeader)
+			if key == "" {
+				auth.Verifier(next).ServeHTTP(w, r)
+				return
+			}
+			principal, err := keys.Authenticate(r.Context(), key)
+			if err != nil {
+				if errors.Is(err, dto.ErrUnauthorized) {
+					ctx := jwtauth.NewContext(r.Context(), nil, jwtauth.ErrUnauthorized)
+					next.ServeHTTP(w, r.WithContext(ctx))
+					return
+				}
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			token := jwt.New()
+			claims := map[string]interface{}{
+				"user_id": principal.UserID,
+				"login":   principal.Login,
+				"api_key": principal.KeyID,
+				"scopes":  principal.Scopes,
+			}
+			for k, v := range claims {
+				if err = token.Set(k, v); err != nil {
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					return
+				}
+			}
+			ctx := jwtauth.NewContext(r.Context(), token, nil)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func (auth *Auth) verifyRequest(r *http.Request) (jwt.Token, error) {
+	tokenString := jwtauth.TokenFromHeader(r)
+	if tokenString == "" {
+		tokenString = jwtauth.TokenFromCookie(r)
+	}
+	if tokenString == "" {
+		return nil, jwtauth.ErrNoTokenFound
+	}
+	token, err := auth.Decode(tokenString)
+	if err != nil {
+		return nil, jwtauth.ErrorReason(err)
+	}
+	if err = jwt.Validate(token); err != nil {
+		return token, jwtauth.ErrorReason(err)
+	}
+	return token, nil
+}
+
+// PublicKeys возвращает открытые ключи асимметричных алгоритмов для проверки токенов другими сервисами
+func (auth *Auth) PublicKeys() jwk.Set {
+	res := jwk.NewSet()
+	for i := 0; i < auth.keys.Len(); i++ {
+		key, _ := auth.keys.Get(i)
+		if _, ok := key.(jwk.SymmetricKey); !ok {
+			res.Add(key)
+		}
+	}
+	return res
+}
+
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+const (
+	accessCookieName  = "jwt"
+	refreshCookieName = "refresh_token"
+	csrfCookieName    = "csrf_token"
+	// refresh-токен нужен только обработчику обновления и не отправляется с остальными запросами
+	refreshCookiePath = "/api/user/token"
+)
+
+// CookieSettings - атрибуты cookie, в которых выдаются токены
+type CookieSettings struct {
+	Path     string
+	Domain   string
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+}
+
+func DefaultCookieSettings() CookieSettings {
+	return CookieSettings{Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode}
+}
+
+func (auth *Auth) SetCookieSettings(settings CookieSettings) {
+	auth.cookie = settings
+}
+
+func (auth *Auth) bakeCookie(name string, value string, path string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   auth.cookie.Domain,
+		Expires:  expires,
+		Secure:   auth.cookie.Secure,
+		HttpOnly: auth.cookie.HttpOnly,
+		SameSite: auth.cookie.SameSite,
+	}
 }
 
-func (auth *Auth) GetJWTAuth() *jwtauth.JWTAuth {
-	return auth.tokenAuth
+// bakeCSRFCookie - cookie с CSRF-токеном должна быть доступна JavaScript, поэтому HttpOnly не выставляется
+func (auth *Auth) bakeCSRFCookie(value string, expires time.Time) *http.Cookie {
+	c := auth.bakeCookie(csrfCookieName, value, auth.cookie.Path, expires)
+	c.HttpOnly = false
+	return c
 }
 
-func bakeCookie(token string) (*http.Cookie, error) {
-	var c http.Cookie
-	c.Name = "jwt"
-	c.Value = token
-	return &c, nil
+// dropCookie удаляет cookie на стороне клиента
+func (auth *Auth) dropCookie(name string, path string) *http.Cookie {
+	c := auth.bakeCookie(name, "", path, time.Unix(0, 0))
+	c.MaxAge = -1
+	return c
 }
@@ -0,0 +1,317 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OIDCClientRequestTimeout = 10 * time.Second
+	OIDCDiscoveryURL         = "/.well-known/openid-configuration"
+	// допустимое расхождение часов с провайдером при проверке exp, iat и nbf
+	OIDCClockSkew = time.Minute
+)
+
+// OIDCConfig - регистрация сервиса у провайдера OpenID Connect
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+type oidcTokenResponse struct {
+	IDToken     string `json:"id_token"`
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+}
+
+// OIDCClient выполняет authorization code flow с PKCE. Описание провайдера и его ключи запрашиваются
+// при первом обращении и кешируются, ключи перечитываются при появлении неизвестного kid
+type OIDCClient struct {
+	config OIDCConfig
+	log    *infrastructure.Logger
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      jwk.Set
+}
+
+func NewOIDCClient(config OIDCConfig, log *infrastructure.Logger) *OIDCClient {
+	var target OIDCClient
+	target.config = config
+	target.config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	target.log = log
+	target.client = &http.Client{Timeout: OIDCClientRequestTimeout}
+	return &target
+}
+
+// AuthCodeURL возвращает адрес страницы входа провайдера. codeChallenge - S256 от PKCE verifier
+func (c *OIDCClient) AuthCodeURL(ctx context.Context, state string, nonce string, codeChallenge string) (string, error) {
+	d, err := c.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil {
+		c.log.Error("OIDCClient: AuthCodeURL. Can't parse authorization endpoint", zap.Error(err))
+		return "", err
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", c.config.ClientID)
+	q.Set("redirect_uri", c.config.RedirectURL)
+	q.Set("scope", strings.Join(c.config.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Exchange обменивает код авторизации на токены и проверяет id_token: подпись ключом провайдера, iss, aud и срок действия.
+// Отказ провайдера и недействительный id_token возвращаются как dto.ErrUnauthorized. Nonce проверяет вызывающая сторона
+func (c *OIDCClient) Exchange(ctx context.Context, code string, verifier string) (*dto.OIDCIdentity, error) {
+	d, err := c.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.config.RedirectURL)
+	form.Set("code_verifier", verifier)
+	form.Set("client_id", c.config.ClientID)
+	req, err := http.NewRequestWithContext(ctx, "POST", d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		c.log.Error("OIDCClient: Exchange. Can't build request", zap.Error(err))
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(c.config.ClientID), url.QueryEscape(c.config.ClientSecret))
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.log.Error("OIDCClient: Exchange. Can't execute request", zap.Error(err))
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		c.log.Error("OIDCClient: Exchange. Can't get response body", zap.Error(err))
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+		// invalid_grant, invalid_client: код устарел, уже использован или не подходит к verifier
+		c.log.Info("OIDCClient: Exchange. Code rejected by provider", zap.Int("statusCode", resp.StatusCode), zap.ByteString("body", body))
+		return nil, dto.ErrUnauthorized
+	}
+	if resp.StatusCode != http.StatusOK {
+		c.log.Error("OIDCClient: Exchange. Unexpected response from provider", zap.Int("statusCode", resp.StatusCode))
+		return nil, dto.ErrRemoteServiceError
+	}
+	var tokens oidcTokenResponse
+	if err = json.Unmarshal(body, &tokens); err != nil {
+		c.log.Error("OIDCClient: Exchange. Can't unmarshal response body", zap.Error(err))
+		return nil, dto.ErrRemoteServiceError
+	}
+	if tokens.IDToken == "" {
+		c.log.Error("OIDCClient: Exchange. Provider didn't return id_token")
+		return nil, dto.ErrRemoteServiceError
+	}
+	return c.verifyIDToken(ctx, d, tokens.IDToken)
+}
+
+func (c *OIDCClient) verifyIDToken(ctx context.Context, d *oidcDiscovery, idToken string) (*dto.OIDCIdentity, error) {
+	msg, err := jws.Parse([]byte(idToken))
+	if err != nil || len(msg.Signatures()) != 1 {
+		c.log.Info("OIDCClient: Exchange. Malformed id_token", zap.Error(err))
+		return nil, dto.ErrUnauthorized
+	}
+	headers := msg.Signatures()[0].ProtectedHeaders()
+	alg := headers.Algorithm()
+	if !isAsymmetricAlgorithm(alg) {
+		// HS* с client_secret и none не принимаются
+		c.log.Info("OIDCClient: Exchange. Unsupported id_token algorithm", zap.String("alg", alg.String()))
+		return nil, dto.ErrUnauthorized
+	}
+	key, err := c.lookupKey(ctx, headers.KeyID())
+	if err != nil {
+		return nil, err
+	}
+	if key.Algorithm() != "" && key.Algorithm() != alg.String() {
+		c.log.Info("OIDCClient: Exchange. id_token algorithm doesn't match key", zap.String("alg", alg.String()))
+		return nil, dto.ErrUnauthorized
+	}
+	var rawKey interface{}
+	if err = key.Raw(&rawKey); err != nil {
+		c.log.Error("OIDCClient: Exchange. Can't use provider key", zap.Error(err))
+		return nil, dto.ErrRemoteServiceError
+	}
+	token, err := jwt.Parse([]byte(idToken),
+		jwt.WithVerify(alg, rawKey),
+		jwt.WithValidate(true),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(c.config.ClientID),
+		jwt.WithAcceptableSkew(OIDCClockSkew))
+	if err != nil {
+		c.log.Info("OIDCClient: Exchange. Invalid id_token", zap.Error(err))
+		return nil, dto.ErrUnauthorized
+	}
+	if token.Subject() == "" {
+		c.log.Info("OIDCClient: Exchange. id_token without subject")
+		return nil, dto.ErrUnauthorized
+	}
+	// при нескольких получателях токен должен быть выдан именно этому клиенту
+	if len(token.Audience()) > 1 {
+		if azp, _ := token.Get("azp"); azp != c.config.ClientID {
+			c.log.Info("OIDCClient: Exchange. id_token issued to another party")
+			return nil, dto.ErrUnauthorized
+		}
+	}
+	identity := dto.OIDCIdentity{Issuer: token.Issuer(), Subject: token.Subject()}
+	identity.Email, _ = stringClaim(token, "email")
+	identity.PreferredUsername, _ = stringClaim(token, "preferred_username")
+	identity.Nonce, _ = stringClaim(token, "nonce")
+	if v, ok := token.Get("email_verified"); ok {
+		identity.EmailVerified, _ = v.(bool)
+	}
+	return &identity, nil
+}
+
+// lookupKey ищет ключ провайдера по kid. Неизвестный kid означает ротацию ключей, набор ключей перечитывается один раз
+func (c *OIDCClient) lookupKey(ctx context.Context, kid string) (jwk.Key, error) {
+	for _, refresh := range []bool{false, true} {
+		keys, err := c.getKeys(ctx, refresh)
+		if err != nil {
+			return nil, err
+		}
+		if kid == "" {
+			if keys.Len() == 1 {
+				key, _ := keys.Get(0)
+				return key, nil
+			}
+			c.log.Info("OIDCClient: Exchange. id_token without kid")
+			return nil, dto.ErrUnauthorized
+		}
+		if key, ok := keys.LookupKeyID(kid); ok {
+			return key, nil
+		}
+	}
+	c.log.Info("OIDCClient: Exchange. Unknown id_token key", zap.String("kid", kid))
+	return nil, dto.ErrUnauthorized
+}
+
+func (c *OIDCClient) getKeys(ctx context.Context, refresh bool) (jwk.Set, error) {
+	d, err := c.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keys != nil && !refresh {
+		return c.keys, nil
+	}
+	keys, err := jwk.Fetch(ctx, d.JwksURI, jwk.WithHTTPClient(c.client))
+	if err != nil {
+		c.log.Error("OIDCClient: Can't fetch provider keys", zap.String("jwks_uri", d.JwksURI), zap.Error(err))
+		return nil, dto.ErrRemoteServiceError
+	}
+	c.keys = keys
+	return keys, nil
+}
+
+func (c *OIDCClient) getDiscovery(ctx context.Context) (*oidcDiscovery, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.discovery != nil {
+		return c.discovery, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", c.config.Issuer+OIDCDiscoveryURL, nil)
+	if err != nil {
+		c.log.Error("OIDCClient: Can't build discovery request", zap.Error(err))
+		return nil, err
+	}
+	req.Header.Add("Accept", `application/json`)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.log.Error("OIDCClient: Can't execute discovery request", zap.Error(err))
+		return nil, dto.ErrRemoteServiceError
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		c.log.Error("OIDCClient: Unexpected discovery response", zap.Int("statusCode", resp.StatusCode))
+		return nil, dto.ErrRemoteServiceError
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		c.log.Error("OIDCClient: Can't get discovery body", zap.Error(err))
+		return nil, err
+	}
+	var d oidcDiscovery
+	if err = json.Unmarshal(body, &d); err != nil {
+		c.log.Error("OIDCClient: Can't unmarshal discovery body", zap.Error(err))
+		return nil, dto.ErrRemoteServiceError
+	}
+	if err = d.validate(c.config.Issuer); err != nil {
+		c.log.Error("OIDCClient: Invalid provider metadata", zap.Error(err))
+		return nil, dto.ErrRemoteServiceError
+	}
+	c.discovery = &d
+	return c.discovery, nil
+}
+
+func (d *oidcDiscovery) validate(issuer string) error {
+	// по спецификации issuer в описании совпадает с адресом, по которому оно получено
+	if strings.TrimSuffix(d.Issuer, "/") != issuer {
+		return fmt.Errorf("issuer mismatch: %q", d.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JwksURI == "" {
+		return errors.New("provider metadata is incomplete")
+	}
+	return nil
+}
+
+func isAsymmetricAlgorithm(alg jwa.SignatureAlgorithm) bool {
+	switch alg {
+	case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512, jwa.ES256, jwa.ES384, jwa.ES512, jwa.EdDSA:
+		return true
+	}
+	return false
+}
+
+func stringClaim(token jwt.Token, name string) (string, bool) {
+	v, ok := token.Get(name)
+	if !ok {
+		return "", false
+	}
+	s, ok := v.(string)
+	return s, ok
+}
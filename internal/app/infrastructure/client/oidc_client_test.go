@@ -0,0 +1,254 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID = "gophermart"
+	testCode     = "code-1"
+	testVerifier = "verifier-1"
+)
+
+// testIdP - локальный провайдер OpenID Connect: описание, ключи и выдача id_token по коду
+type testIdP struct {
+	server *httptest.Server
+	// signKey подписывает id_token, keys публикуются в jwks_uri
+	signKey jwk.Key
+	keys    jwk.Set
+	alg     jwa.SignatureAlgorithm
+	// claims дополняют и переопределяют стандартные claims id_token
+	claims map[string]interface{}
+}
+
+func newTestKey(t *testing.T, kid string) (private jwk.Key, public jwk.Key) {
+	raw, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	private, err = jwk.New(raw)
+	require.NoError(t, err)
+	require.NoError(t, private.Set(jwk.KeyIDKey, kid))
+	public, err = jwk.PublicKeyOf(private)
+	require.NoError(t, err)
+	return private, public
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	idp := testIdP{alg: jwa.RS256, keys: jwk.NewSet()}
+	var public jwk.Key
+	idp.signKey, public = newTestKey(t, "k1")
+	idp.keys.Add(public)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(OIDCDiscoveryURL, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(oidcDiscovery{
+			Issuer:                idp.server.URL,
+			AuthorizationEndpoint: idp.server.URL + "/authorize?prompt=login",
+			TokenEndpoint:         idp.server.URL + "/token",
+			JwksURI:               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(idp.keys)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.FormValue("grant_type") != "authorization_code" ||
+			r.FormValue("code") != testCode || r.FormValue("code_verifier") != testVerifier {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		if id, secret, ok := r.BasicAuth(); !ok || id != testClientID || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(oidcTokenResponse{IDToken: idp.idToken(t), AccessToken: "access", TokenType: "Bearer"})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return &idp
+}
+
+func (idp *testIdP) idToken(t *testing.T) string {
+	now := time.Now()
+	token := jwt.New()
+	claims := map[string]interface{}{
+		jwt.IssuerKey:     idp.server.URL,
+		jwt.SubjectKey:    "subject-1",
+		jwt.AudienceKey:   testClientID,
+		jwt.IssuedAtKey:   now,
+		jwt.ExpirationKey: now.Add(time.Minute),
+		"nonce":           "nonce-1",
+		"email":           "user@example.com",
+		"email_verified":  true,
+	}
+	for k, v := range idp.claims {
+		claims[k] = v
+	}
+	for k, v := range claims {
+		require.NoError(t, token.Set(k, v))
+	}
+	b, err := jwt.Sign(token, idp.alg, idp.signKey)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func newTestOIDCClient(t *testing.T, idp *testIdP) *OIDCClient {
+	log, err := zap.NewDevelopment()
+	require.NoError(t, err)
+	return NewOIDCClient(OIDCConfig{
+		Issuer:       idp.server.URL + "/",
+		ClientID:     testClientID,
+		ClientSecret: "secret",
+		RedirectURL:  "http://gophermart/api/user/oidc/callback",
+		Scopes:       []string{"openid", "email"},
+	}, log)
+}
+
+func TestOIDCClient_AuthCodeURL(t *testing.T) {
+	idp := newTestIdP(t)
+	c := newTestOIDCClient(t, idp)
+	h := sha256.Sum256([]byte(testVerifier))
+	challenge := base64.RawURLEncoding.EncodeToString(h[:])
+
+	res, err := c.AuthCodeURL(context.Background(), "state-1", "nonce-1", challenge)
+	require.NoError(t, err)
+	u, err := url.Parse(res)
+	require.NoError(t, err)
+	assert.Equal(t, idp.server.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	q := u.Query()
+	assert.Equal(t, "login", q.Get("prompt"), "endpoint query must be kept")
+	assert.Equal(t, "code", q.Get("response_type"))
+	assert.Equal(t, testClientID, q.Get("client_id"))
+	assert.Equal(t, "openid email", q.Get("scope"))
+	assert.Equal(t, "state-1", q.Get("state"))
+	assert.Equal(t, "nonce-1", q.Get("nonce"))
+	assert.Equal(t, challenge, q.Get("code_challenge"))
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+}
+
+func TestOIDCClient_Exchange(t *testing.T) {
+	otherKey, otherPublic := newTestKey(t, "k2")
+	tests := []struct {
+		name     string
+		prepare  func(idp *testIdP)
+		code     string
+		wantErr  error
+		wantSubj string
+	}{
+		{
+			name:     "OIDCClient. Exchange. Case #1. Valid id_token",
+			code:     testCode,
+			wantSubj: "subject-1",
+		},
+		{
+			name:    "OIDCClient. Exchange. Case #2. Code rejected by provider",
+			code:    "stolen",
+			wantErr: dto.ErrUnauthorized,
+		},
+		{
+			name: "OIDCClient. Exchange. Case #3. Token for another client",
+			prepare: func(idp *testIdP) {
+				idp.claims = map[string]interface{}{jwt.AudienceKey: "another"}
+			},
+			code:    testCode,
+			wantErr: dto.ErrUnauthorized,
+		},
+		{
+			name: "OIDCClient. Exchange. Case #4. Token from another issuer",
+			prepare: func(idp *testIdP) {
+				idp.claims = map[string]interface{}{jwt.IssuerKey: "http://evil"}
+			},
+			code:    testCode,
+			wantErr: dto.ErrUnauthorized,
+		},
+		{
+			name: "OIDCClient. Exchange. Case #5. Expired token",
+			prepare: func(idp *testIdP) {
+				idp.claims = map[string]interface{}{jwt.ExpirationKey: time.Now().Add(-time.Hour)}
+			},
+			code:    testCode,
+			wantErr: dto.ErrUnauthorized,
+		},
+		{
+			name: "OIDCClient. Exchange. Case #6. Signed by unpublished key",
+			prepare: func(idp *testIdP) {
+				idp.signKey = otherKey
+			},
+			code:    testCode,
+			wantErr: dto.ErrUnauthorized,
+		},
+		{
+			name: "OIDCClient. Exchange. Case #7. Signed by rotated key",
+			prepare: func(idp *testIdP) {
+				idp.signKey = otherKey
+				idp.keys.Add(otherPublic)
+			},
+			code:     testCode,
+			wantSubj: "subject-1",
+		},
+		{
+			name: "OIDCClient. Exchange. Case #8. Symmetric algorithm",
+			prepare: func(idp *testIdP) {
+				key, _ := jwk.New([]byte("secret"))
+				_ = key.Set(jwk.KeyIDKey, "k1")
+				idp.signKey = key
+				idp.alg = jwa.HS256
+			},
+			code:    testCode,
+			wantErr: dto.ErrUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newTestIdP(t)
+			c := newTestOIDCClient(t, idp)
+			// ключи провайдера кешируются до подмены
+			_, err := c.getKeys(context.Background(), false)
+			require.NoError(t, err)
+			if tt.prepare != nil {
+				tt.prepare(idp)
+			}
+			identity, err := c.Exchange(context.Background(), tt.code, testVerifier)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, idp.server.URL, identity.Issuer)
+				assert.Equal(t, tt.wantSubj, identity.Subject)
+				assert.Equal(t, "nonce-1", identity.Nonce)
+				assert.Equal(t, "user@example.com", identity.Email)
+				assert.True(t, identity.EmailVerified)
+			}
+		})
+	}
+}
+
+func TestOIDCClient_DiscoveryIssuerMismatch(t *testing.T) {
+	idp := newTestIdP(t)
+	log, _ := zap.NewDevelopment()
+	// тот же сервер, но описание выдано для другого issuer
+	issuer := strings.Replace(idp.server.URL, "127.0.0.1", "localhost", 1)
+	c := NewOIDCClient(OIDCConfig{Issuer: issuer, ClientID: testClientID}, log)
+	_, err := c.AuthCodeURL(context.Background(), "state", "nonce", "challenge")
+	assert.ErrorIs(t, err, dto.ErrRemoteServiceError)
+}
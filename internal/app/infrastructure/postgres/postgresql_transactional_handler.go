@@ -5,44 +5,245 @@ import (
 	"errors"
 	"fmt"
 	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
 	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
 	"go.uber.org/zap"
+	"strconv"
 	"time"
 )
 
+// Повторы транзакции WithTx при конфликте сериализации или взаимной блокировке
+const (
+	maxTxRetries   = 3
+	txRetryBackoff = 20 * time.Millisecond
+)
+
 type PostgresqlHandlerTX struct {
 	pool *pgxpool.Pool
-	log  *infrastructure.Logger
+	// реплика для транзакций только чтения, nil - все транзакции идут на основной сервер
+	replica *pgxpool.Pool
+	writes  *recentWrites
+	log     *infrastructure.Logger
+	tracer  *queryTracer
 }
 
-func NewPostgresqlHandlerTX(ctx context.Context, dataSource string, log *infrastructure.Logger) (*PostgresqlHandlerTX, error) {
-	// Format DSN
-	//("postgresql://%s:%s@%s:%s/%s", cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Dbname)
-	poolConfig, err := pgxpool.ParseConfig(dataSource)
-	if err != nil {
-		return nil, err
+// PoolConfig - размер пула соединений и ограничения времени выполнения на стороне сервера.
+// Нулевой таймаут не задается, действует настройка сервера
+type PoolConfig struct {
+	MaxConns        int32
+	MinConns        int32
+	MaxConnLifetime time.Duration
+	MaxConnIdleTime time.Duration
+	// statement_timeout, lock_timeout и idle_in_transaction_session_timeout каждого соединения
+	StatementTimeout                time.Duration
+	LockTimeout                     time.Duration
+	IdleInTransactionSessionTimeout time.Duration
+	// запросы дольше порога пишутся в журнал медленных запросов, 0 - не писать
+	SlowQueryThreshold time.Duration
+	// реплика для транзакций только чтения с теми же настройками пула, пустая - реплики нет
+	ReplicaDataSource string
+	// после изменений пользователя его транзакции чтения идут на основной сервер в течение этого времени.
+	// Изменения учитываются только сделанные через этот экземпляр обработчика
+	ReplicaReadAfterWrite time.Duration
+}
+
+// DefaultPoolConfig - настройки пула для утилит и тестов
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		MaxConns:              5,
+		MinConns:              2,
+		MaxConnLifetime:       time.Hour,
+		MaxConnIdleTime:       time.Second * 120,
+		SlowQueryThreshold:    200 * time.Millisecond,
+		ReplicaReadAfterWrite: 5 * time.Second,
 	}
+}
 
-	poolConfig.MaxConns = 5
-	poolConfig.MinConns = 2
-	poolConfig.MaxConnIdleTime = time.Second * 120
-	pool, err := pgxpool.ConnectConfig(ctx, poolConfig)
+// PoolStats - статистика пула соединений
+type PoolStats struct {
+	MaxConns             int32
+	TotalConns           int32
+	AcquiredConns        int32
+	IdleConns            int32
+	ConstructingConns    int32
+	AcquireCount         int64
+	EmptyAcquireCount    int64
+	CanceledAcquireCount int64
+	// суммарное время ожидания соединения, мс
+	AcquireDurationMs int64
+}
+
+func NewPostgresqlHandlerTX(ctx context.Context, dataSource string, log *infrastructure.Logger) (*PostgresqlHandlerTX, error) {
+	return NewPostgresqlHandlerTXWithConfig(ctx, dataSource, DefaultPoolConfig(), log)
+}
+
+func NewPostgresqlHandlerTXWithConfig(ctx context.Context, dataSource string, config PoolConfig, log *infrastructure.Logger) (*PostgresqlHandlerTX, error) {
+	if config.MaxConns <= 0 || config.MinConns < 0 || config.MinConns > config.MaxConns {
+		return nil, fmt.Errorf("bad pool size: min %d, max %d", config.MinConns, config.MaxConns)
+	}
+	pool, err := connectPool(ctx, dataSource, config)
 	if err != nil {
 		return nil, err
 	}
 	postgresqlHandler := new(PostgresqlHandlerTX)
 	postgresqlHandler.pool = pool
 	postgresqlHandler.log = log
+	postgresqlHandler.tracer = newQueryTracer(log, config.SlowQueryThreshold)
+	if config.ReplicaDataSource != "" {
+		replica, err := connectPool(ctx, config.ReplicaDataSource, config)
+		if err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("can't connect to replica: %w", err)
+		}
+		postgresqlHandler.replica = replica
+		postgresqlHandler.writes = newRecentWrites(config.ReplicaReadAfterWrite)
+	}
 	return postgresqlHandler, nil
 }
 
+func connectPool(ctx context.Context, dataSource string, config PoolConfig) (*pgxpool.Pool, error) {
+	// Format DSN
+	//("postgresql://%s:%s@%s:%s/%s", cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Dbname)
+	poolConfig, err := pgxpool.ParseConfig(dataSource)
+	if err != nil {
+		return nil, err
+	}
+
+	poolConfig.MaxConns = config.MaxConns
+	poolConfig.MinConns = config.MinConns
+	if config.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = config.MaxConnLifetime
+	}
+	if config.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = config.MaxConnIdleTime
+	}
+	setTimeout(poolConfig.ConnConfig.RuntimeParams, "statement_timeout", config.StatementTimeout)
+	setTimeout(poolConfig.ConnConfig.RuntimeParams, "lock_timeout", config.LockTimeout)
+	setTimeout(poolConfig.ConnConfig.RuntimeParams, "idle_in_transaction_session_timeout", config.IdleInTransactionSessionTimeout)
+	return pgxpool.ConnectConfig(ctx, poolConfig)
+}
+
+// setTimeout передает таймаут серверу параметром соединения, в миллисекундах
+func setTimeout(params map[string]string, name string, timeout time.Duration) {
+	if timeout > 0 {
+		params[name] = strconv.FormatInt(timeout.Milliseconds(), 10)
+	}
+}
+
+// Stats возвращает текущую статистику пула соединений
+func (handler *PostgresqlHandlerTX) Stats() PoolStats {
+	return poolStats(handler.pool)
+}
+
+// ReplicaStats возвращает статистику пула соединений реплики, nil - реплики нет
+func (handler *PostgresqlHandlerTX) ReplicaStats() *PoolStats {
+	if handler.replica == nil {
+		return nil
+	}
+	stats := poolStats(handler.replica)
+	return &stats
+}
+
+func poolStats(pool *pgxpool.Pool) PoolStats {
+	stat := pool.Stat()
+	return PoolStats{
+		MaxConns:             stat.MaxConns(),
+		TotalConns:           stat.TotalConns(),
+		AcquiredConns:        stat.AcquiredConns(),
+		IdleConns:            stat.IdleConns(),
+		ConstructingConns:    stat.ConstructingConns(),
+		AcquireCount:         stat.AcquireCount(),
+		EmptyAcquireCount:    stat.EmptyAcquireCount(),
+		CanceledAcquireCount: stat.CanceledAcquireCount(),
+		AcquireDurationMs:    stat.AcquireDuration().Milliseconds(),
+	}
+}
+
+// QueryStats возвращает статистику выполнения запросов по их именам
+func (handler *PostgresqlHandlerTX) QueryStats() map[string]QueryStats {
+	return handler.tracer.Stats()
+}
+
 func (handler *PostgresqlHandlerTX) NewTx(ctx context.Context) (pgx.Tx, error) {
 	return handler.pool.Begin(ctx)
 }
 
+// BeginTx начинает транзакцию. Если настроена реплика, транзакции только чтения идут на нее, кроме транзакций
+// пользователя, недавно изменявшего данные (basedbhandler.WriterFromContext): реплика может их еще не содержать.
+// Недавние изменения известны только этому процессу, см. recentWrites.
+// Если реплика недоступна, транзакция начинается на основном сервере
+func (handler *PostgresqlHandlerTX) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	if handler.replica == nil {
+		return handler.pool.BeginTx(ctx, opts)
+	}
+	if opts.AccessMode == pgx.ReadOnly {
+		writer := basedbhandler.WriterFromContext(ctx)
+		if writer == "" || !handler.writes.recent(writer, time.Now()) {
+			tx, err := handler.replica.BeginTx(ctx, opts)
+			if err == nil {
+				return tx, nil
+			}
+			handler.log.Warn("PostgresqlHandlerTX: can't begin transaction on replica, using primary", zap.Error(err))
+		}
+		return handler.pool.BeginTx(ctx, opts)
+	}
+	tx, err := handler.pool.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &writeTx{Tx: tx, ctx: ctx, writes: handler.writes}, nil
+}
+
+// WithTx выполняет fn в новой транзакции и фиксирует ее, если fn завершилась без ошибки. Транзакция передается fn
+// в контексте, как в middleware Transactional. Если транзакция уже есть в контексте, fn выполняется в ней без повторов:
+// повторить можно только транзакцию целиком. При конфликте сериализации или взаимной блокировке fn выполняется
+// заново в новой транзакции, не больше maxTxRetries раз, поэтому fn не должна иметь побочных эффектов вне базы
+func (handler *PostgresqlHandlerTX) WithTx(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context) error) error {
+	if tx, _ := ctx.Value(basedbhandler.TransactionKey("tx")).(pgx.Tx); tx != nil {
+		return fn(ctx)
+	}
+	for attempt := 1; ; attempt++ {
+		err := handler.runTx(ctx, opts, fn)
+		if err == nil || !isRetryable(err) || attempt > maxTxRetries {
+			return err
+		}
+		handler.log.Info("PostgresqlHandlerTX: WithTx. Retrying transaction", zap.Int("attempt", attempt), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(txRetryBackoff * time.Duration(attempt)):
+		}
+	}
+}
+
+func (handler *PostgresqlHandlerTX) runTx(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context) error) (err error) {
+	tx, err := handler.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback(ctx)
+			panic(p)
+		}
+	}()
+	if err = fn(context.WithValue(ctx, basedbhandler.TransactionKey("tx"), tx)); err != nil {
+		if e := tx.Rollback(ctx); e != nil {
+			handler.log.Error("PostgresqlHandlerTX: WithTx. Can't rollback transaction", zap.Error(e))
+		}
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == pgerrcode.SerializationFailure || pgErr.Code == pgerrcode.DeadlockDetected)
+}
+
 func (handler *PostgresqlHandlerTX) getTx(ctx context.Context) (tx pgx.Tx, err error) {
 	defer func() {
 		if recover() != nil {
@@ -80,20 +281,22 @@ func (handler *PostgresqlHandlerTX) Rollback(ctx context.Context) error {
 	}
 	err = tx.Rollback(ctx)
 	if err != nil {
-		handler.log.Error("Can't commit transaction", zap.Error(err))
+		handler.log.Error("Can't rollback transaction", zap.Error(err))
 		return err
 	}
 	return err
 }
 
 func (handler *PostgresqlHandlerTX) Execute(ctx context.Context, statement string, args ...interface{}) error {
+	var ct pgconn.CommandTag
+	start := time.Now()
 	tx, err := handler.getTx(ctx)
 	// Пытаемся получить транзакцию из контекста, если не нашли, работаем без транзакции
 	if err == nil {
 		if len(args) > 0 {
-			_, err = tx.Exec(ctx, statement, args...)
+			ct, err = tx.Exec(ctx, statement, args...)
 		} else {
-			_, err = tx.Exec(ctx, statement)
+			ct, err = tx.Exec(ctx, statement)
 		}
 	} else {
 		conn, e := handler.pool.Acquire(ctx)
@@ -103,12 +306,13 @@ func (handler *PostgresqlHandlerTX) Execute(ctx context.Context, statement strin
 		defer conn.Release()
 
 		if len(args) > 0 {
-			_, e = conn.Exec(ctx, statement, args...)
+			ct, e = conn.Exec(ctx, statement, args...)
 		} else {
-			_, e = conn.Exec(ctx, statement)
+			ct, e = conn.Exec(ctx, statement)
 		}
 		err = e
 	}
+	handler.tracer.trace(ctx, statement, args, start, ct.RowsAffected(), err)
 	return err
 }
 
@@ -127,29 +331,37 @@ func (handler *PostgresqlHandlerTX) ExecuteBatch(ctx context.Context, statement
 	} else {
 		return nil
 	}
+	start := time.Now()
 	tx, err := handler.getTx(ctx)
 	// Пытаемся получить транзакцию из контекста, если не нашли, работаем без транзакции
 	if err == nil {
-		br = tx.SendBatch(context.Background(), batch)
+		br = tx.SendBatch(ctx, batch)
 	} else {
 		conn, err := handler.pool.Acquire(ctx)
 		if err != nil {
 			return err
 		}
 		defer conn.Release()
-		br = conn.SendBatch(context.Background(), batch)
+		br = conn.SendBatch(ctx, batch)
 	}
-	ct, err = br.Exec()
-
-	if err != nil {
-		return err
+	var rows int64
+	for range args {
+		if ct, err = br.Exec(); err != nil {
+			break
+		}
+		rows += ct.RowsAffected()
+	}
+	if e := br.Close(); err == nil {
+		err = e
 	}
-	fmt.Println(ct.RowsAffected())
-	return nil
+	// аргументы всех запросов пакета в журнал не нужны, достаточно первого набора
+	handler.tracer.trace(ctx, statement, args[0], start, rows, err)
+	return err
 }
 
 func (handler *PostgresqlHandlerTX) QueryRow(ctx context.Context, statement string, args ...interface{}) (basedbhandler.Row, error) {
 	var row pgx.Row
+	start := time.Now()
 	tx, err := handler.getTx(ctx)
 	// Пытаемся получить транзакцию из контекста, если не нашли, работаем без транзакции
 	if err == nil {
@@ -159,22 +371,19 @@ func (handler *PostgresqlHandlerTX) QueryRow(ctx context.Context, statement stri
 			row = tx.QueryRow(ctx, statement)
 		}
 	} else {
-		conn, err := handler.pool.Acquire(ctx)
-		if err != nil {
-			return nil, err
-		}
-		defer conn.Release()
+		// соединение пула возвращается после чтения строки
 		if len(args) > 0 {
-			row = conn.QueryRow(ctx, statement, args...)
+			row = handler.pool.QueryRow(ctx, statement, args...)
 		} else {
-			row = conn.QueryRow(ctx, statement)
+			row = handler.pool.QueryRow(ctx, statement)
 		}
 	}
-	return row, nil
+	return &tracedRow{Row: row, ctx: ctx, tracer: handler.tracer, statement: statement, args: args, start: start}, nil
 }
 
 func (handler *PostgresqlHandlerTX) Query(ctx context.Context, statement string, args ...interface{}) (basedbhandler.Rows, error) {
 	var rows pgx.Rows
+	start := time.Now()
 	tx, err := handler.getTx(ctx)
 	// Пытаемся получить транзакцию из контекста, если не нашли, работаем без транзакции
 	if err == nil {
@@ -184,26 +393,25 @@ func (handler *PostgresqlHandlerTX) Query(ctx context.Context, statement string,
 			rows, err = tx.Query(ctx, statement)
 		}
 	} else {
-		conn, e := handler.pool.Acquire(ctx)
-		if e != nil {
-			return nil, e
-		}
-		defer conn.Release()
+		// соединение пула возвращается при закрытии строк
 		if len(args) > 0 {
-			rows, e = conn.Query(ctx, statement, args...)
+			rows, err = handler.pool.Query(ctx, statement, args...)
 		} else {
-			rows, e = conn.Query(ctx, statement)
+			rows, err = handler.pool.Query(ctx, statement)
 		}
-		err = e
 	}
 	if err != nil {
+		handler.tracer.trace(ctx, statement, args, start, 0, err)
 		return nil, err
 	}
-	return rows, nil
+	return &tracedRows{Rows: rows, ctx: ctx, tracer: handler.tracer, statement: statement, args: args, start: start}, nil
 }
 
 func (handler *PostgresqlHandlerTX) Close() {
 	if handler != nil {
 		handler.pool.Close()
+		if handler.replica != nil {
+			handler.replica.Close()
+		}
 	}
 }
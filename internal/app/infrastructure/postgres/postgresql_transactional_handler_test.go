@@ -4,9 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
 	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestPostgresqlHandlerTX_getTx(t *testing.T) {
@@ -188,3 +192,78 @@ func TestPostgresqlHandlerTX_ExecuteBatch(t *testing.T) {
 		})
 	}
 }
+
+func TestNewPostgresqlHandlerTXWithConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config PoolConfig
+	}{
+		{name: "NewPostgresqlHandlerTXWithConfig. Case #1. Empty pool", config: PoolConfig{MaxConns: 0}},
+		{name: "NewPostgresqlHandlerTXWithConfig. Case #2. Min above max", config: PoolConfig{MaxConns: 2, MinConns: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPostgresqlHandlerTXWithConfig(context.Background(), Datasource, tt.config, Log)
+			assert.Error(t, err)
+		})
+	}
+
+	h, err := NewPostgresqlHandlerTXWithConfig(context.Background(), Datasource, PoolConfig{
+		MaxConns:         3,
+		MinConns:         1,
+		StatementTimeout: 1500 * time.Millisecond,
+		LockTimeout:      time.Second,
+	}, Log)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer h.Close()
+	var timeout string
+	row, err := h.QueryRow(context.Background(), "show statement_timeout")
+	assert.NoError(t, err)
+	assert.NoError(t, row.Scan(&timeout))
+	assert.Equal(t, "1500ms", timeout)
+	assert.Equal(t, int32(3), h.Stats().MaxConns)
+}
+
+func TestPostgresqlHandlerTX_WithTx(t *testing.T) {
+	ctx := context.Background()
+	serializationFailure := &pgconn.PgError{Code: pgerrcode.SerializationFailure}
+
+	attempts := 0
+	err := target.WithTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable}, func(ctx context.Context) error {
+		attempts++
+		if err := target.Execute(ctx, "insert into test_table (a, b) values ($1, $2)", attempts, "with_tx"); err != nil {
+			return err
+		}
+		if attempts < 3 {
+			return serializationFailure
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	// откаченные попытки не оставляют строк
+	row, err := target.QueryRow(ctx, "select count(*) from test_table where b = 'with_tx'")
+	assert.NoError(t, err)
+	var count int
+	assert.NoError(t, row.Scan(&count))
+	assert.Equal(t, 1, count)
+
+	attempts = 0
+	err = target.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		attempts++
+		return serializationFailure
+	})
+	assert.ErrorIs(t, err, serializationFailure)
+	assert.Equal(t, maxTxRetries+1, attempts)
+
+	attempts = 0
+	err = target.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		attempts++
+		return errors.New("business error")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts, "only serialization failures and deadlocks are retried")
+}
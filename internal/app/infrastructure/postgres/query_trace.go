@@ -0,0 +1,212 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jackc/pgx/v4"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QueryStats - статистика выполнения запросов с одним именем
+type QueryStats struct {
+	Count  int64
+	Errors int64
+	Slow   int64
+	// суммарное и максимальное время выполнения, мс
+	TotalMs int64
+	MaxMs   int64
+}
+
+// queryTracer записывает каждый запрос в отладочный лог и статистику, а запросы дольше slowThreshold -
+// в журнал медленных запросов. Значения аргументов в журнал не попадают, только их типы
+type queryTracer struct {
+	log           *infrastructure.Logger
+	slowThreshold time.Duration
+	mu            sync.Mutex
+	stats         map[string]*QueryStats
+}
+
+func newQueryTracer(log *infrastructure.Logger, slowThreshold time.Duration) *queryTracer {
+	return &queryTracer{
+		log:           log,
+		slowThreshold: slowThreshold,
+		stats:         make(map[string]*QueryStats),
+	}
+}
+
+// trace учитывает выполненный запрос. rows - число затронутых или прочитанных строк
+func (t *queryTracer) trace(ctx context.Context, statement string, args []interface{}, start time.Time, rows int64, err error) {
+	duration := time.Since(start)
+	name := statementName(statement)
+	// отсутствие строки - результат запроса, а не ошибка
+	failed := err != nil && !errors.Is(err, pgx.ErrNoRows)
+	slow := t.slowThreshold > 0 && duration >= t.slowThreshold
+
+	t.mu.Lock()
+	s, ok := t.stats[name]
+	if !ok {
+		s = new(QueryStats)
+		t.stats[name] = s
+	}
+	s.Count++
+	if failed {
+		s.Errors++
+	}
+	if slow {
+		s.Slow++
+	}
+	s.TotalMs += duration.Milliseconds()
+	if duration.Milliseconds() > s.MaxMs {
+		s.MaxMs = duration.Milliseconds()
+	}
+	t.mu.Unlock()
+
+	fields := []zap.Field{
+		zap.String("statement", name),
+		zap.Duration("duration", duration),
+		zap.Int64("rows", rows),
+	}
+	if reqID := middleware.GetReqID(ctx); reqID != "" {
+		fields = append(fields, zap.String("request_id", reqID))
+	}
+	if failed {
+		fields = append(fields, zap.Error(err))
+	}
+	if slow {
+		fields = append(fields, zap.String("query", compactSQL(statement)), zap.Strings("args", sanitizeArgs(args)))
+		t.log.Warn("slow query", fields...)
+		return
+	}
+	t.log.Debug("query", fields...)
+}
+
+// Stats возвращает копию статистики по именам запросов
+func (t *queryTracer) Stats() map[string]QueryStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	res := make(map[string]QueryStats, len(t.stats))
+	for name, s := range t.stats {
+		res[name] = *s
+	}
+	return res
+}
+
+// statementName - короткое имя запроса для журнала и статистики: команда и таблица, например "select orders".
+// Запросы с одинаковой командой и таблицей учитываются вместе
+func statementName(statement string) string {
+	words := strings.Fields(strings.ToLower(statement))
+	if len(words) == 0 {
+		return "empty"
+	}
+	command := words[0]
+	var keyword string
+	switch command {
+	case "insert":
+		keyword = "into"
+	case "update":
+		keyword = "update"
+	case "select", "delete", "with":
+		keyword = "from"
+	default:
+		return command
+	}
+	for i, w := range words {
+		if w == keyword && i+1 < len(words) {
+			return command + " " + strings.Trim(words[i+1], "();,")
+		}
+	}
+	return command
+}
+
+// compactSQL сворачивает текст запроса в одну строку
+func compactSQL(statement string) string {
+	return strings.Join(strings.Fields(statement), " ")
+}
+
+// sanitizeArgs заменяет аргументы запроса их типами: в аргументах бывают логины, хеши паролей и токенов.
+// Для строк указывается длина
+func sanitizeArgs(args []interface{}) []string {
+	res := make([]string, 0, len(args))
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case nil:
+			res = append(res, "null")
+		case string:
+			res = append(res, fmt.Sprintf("string(%d)", len(v)))
+		case []byte:
+			res = append(res, fmt.Sprintf("bytes(%d)", len(v)))
+		default:
+			res = append(res, fmt.Sprintf("%T", arg))
+		}
+	}
+	return res
+}
+
+// tracedRows учитывает запрос, когда строки прочитаны до конца или закрыты
+type tracedRows struct {
+	pgx.Rows
+	ctx       context.Context
+	tracer    *queryTracer
+	statement string
+	args      []interface{}
+	start     time.Time
+	count     int64
+	done      bool
+}
+
+func (r *tracedRows) Next() bool {
+	if r.Rows.Next() {
+		r.count++
+		return true
+	}
+	r.finish()
+	return false
+}
+
+func (r *tracedRows) Close() {
+	r.Rows.Close()
+	r.finish()
+}
+
+func (r *tracedRows) Columns() []string {
+	fields := r.Rows.FieldDescriptions()
+	res := make([]string, len(fields))
+	for i, f := range fields {
+		res[i] = string(f.Name)
+	}
+	return res
+}
+
+func (r *tracedRows) finish() {
+	if r.done {
+		return
+	}
+	r.done = true
+	r.tracer.trace(r.ctx, r.statement, r.args, r.start, r.count, r.Rows.Err())
+}
+
+// tracedRow учитывает запрос при чтении строки
+type tracedRow struct {
+	pgx.Row
+	ctx       context.Context
+	tracer    *queryTracer
+	statement string
+	args      []interface{}
+	start     time.Time
+}
+
+func (r *tracedRow) Scan(dest ...interface{}) error {
+	err := r.Row.Scan(dest...)
+	var rows int64
+	if err == nil {
+		rows = 1
+	}
+	r.tracer.trace(r.ctx, r.statement, r.args, r.start, rows, err)
+	return err
+}
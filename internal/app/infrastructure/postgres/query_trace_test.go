@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"testing"
+	"time"
+)
+
+func Test_statementName(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement string
+		want      string
+	}{
+		{name: "statementName. Case #1. Select", statement: "select o.id, o.num \nfrom orders o \nwhere o.user_id = $1", want: "select orders"},
+		{name: "statementName. Case #2. Insert", statement: "INSERT INTO operations \n(account_id, amount) VALUES($1, $2)", want: "insert operations"},
+		{name: "statementName. Case #3. Update", statement: "update accounts set balance = $2 where id = $1", want: "update accounts"},
+		{name: "statementName. Case #4. Delete", statement: "delete from sessions where id = $1", want: "delete sessions"},
+		{name: "statementName. Case #5. Other command", statement: "truncate table test_table", want: "truncate"},
+		{name: "statementName. Case #6. Empty", statement: " \n", want: "empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, statementName(tt.statement))
+		})
+	}
+}
+
+func Test_sanitizeArgs(t *testing.T) {
+	got := sanitizeArgs([]interface{}{1, "secret", []byte("hash"), nil, float32(1.5), time.Time{}})
+	assert.Equal(t, []string{"int", "string(6)", "bytes(4)", "null", "float32", "time.Time"}, got)
+}
+
+func TestQueryTracer_trace(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	tracer := newQueryTracer(zap.New(core), 50*time.Millisecond)
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "req-1")
+	const statement = "select * \nfrom users where login = $1"
+
+	tracer.trace(ctx, statement, []interface{}{"user"}, time.Now(), 1, nil)
+	tracer.trace(ctx, statement, []interface{}{"user"}, time.Now(), 0, pgx.ErrNoRows)
+	tracer.trace(context.Background(), statement, []interface{}{"user"}, time.Now().Add(-time.Second), 0, errors.New("timeout"))
+
+	entries := logs.AllUntimed()
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, zapcore.DebugLevel, entries[0].Level)
+		assert.Equal(t, "select users", entries[0].ContextMap()["statement"])
+		assert.Equal(t, "req-1", entries[0].ContextMap()["request_id"])
+		assert.NotContains(t, entries[1].ContextMap(), "error", "no rows is not an error")
+
+		slow := entries[2]
+		assert.Equal(t, zapcore.WarnLevel, slow.Level)
+		assert.Equal(t, "select * from users where login = $1", slow.ContextMap()["query"])
+		assert.Equal(t, []interface{}{"string(4)"}, slow.ContextMap()["args"])
+		assert.Equal(t, "timeout", slow.ContextMap()["error"])
+		assert.NotContains(t, slow.ContextMap(), "request_id")
+	}
+
+	stats := tracer.Stats()["select users"]
+	assert.Equal(t, int64(3), stats.Count)
+	assert.Equal(t, int64(1), stats.Errors)
+	assert.Equal(t, int64(1), stats.Slow)
+	assert.GreaterOrEqual(t, stats.MaxMs, int64(1000))
+}
+
+func TestPostgresqlHandlerTX_QueryStats(t *testing.T) {
+	ctx := context.Background()
+	err := target.Execute(ctx, "insert into test_table (a, b) values ($1, $2)", 1, "str")
+	assert.NoError(t, err)
+	rows, err := target.Query(ctx, "select a, b from test_table where a = $1", 1)
+	assert.NoError(t, err)
+	for rows.Next() {
+	}
+
+	stats := target.QueryStats()
+	assert.GreaterOrEqual(t, stats["insert test_table"].Count, int64(1))
+	assert.GreaterOrEqual(t, stats["select test_table"].Count, int64(1))
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
+	"sync"
+	"time"
+)
+
+// recentWrites - время последних изменений пользователей. Реплика может еще не содержать изменения пользователя,
+// поэтому в течение window после них его транзакции чтения идут на основной сервер.
+// Отметки хранятся в памяти процесса: другие экземпляры сервиса о них не знают, и чтение своих изменений
+// гарантируется только для одного экземпляра или при привязке клиента к экземпляру на балансировщике
+type recentWrites struct {
+	window  time.Duration
+	mu      sync.Mutex
+	last    map[string]time.Time
+	sweptAt time.Time
+}
+
+func newRecentWrites(window time.Duration) *recentWrites {
+	return &recentWrites{
+		window: window,
+		last:   make(map[string]time.Time),
+	}
+}
+
+// mark отмечает изменения пользователя id. Отметки старше window удаляются не чаще раза в window
+func (w *recentWrites) mark(id string, now time.Time) {
+	if w.window <= 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.last[id] = now
+	if now.Sub(w.sweptAt) < w.window {
+		return
+	}
+	for writer, at := range w.last {
+		if now.Sub(at) >= w.window {
+			delete(w.last, writer)
+		}
+	}
+	w.sweptAt = now
+}
+
+// recent - пользователь id изменял данные меньше window назад
+func (w *recentWrites) recent(id string, now time.Time) bool {
+	if w.window <= 0 {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	at, ok := w.last[id]
+	return ok && now.Sub(at) < w.window
+}
+
+// writeTx отмечает изменения пользователя при фиксации транзакции. Пользователь берется из контекста начала
+// транзакции при фиксации: при регистрации и входе он становится известен только в процессе обработки запроса
+type writeTx struct {
+	pgx.Tx
+	ctx    context.Context
+	writes *recentWrites
+}
+
+// Commit отмечает изменения и при ошибке фиксации: неизвестно, применил ли их сервер
+func (tx *writeTx) Commit(ctx context.Context) error {
+	err := tx.Tx.Commit(ctx)
+	if id := basedbhandler.WriterFromContext(tx.ctx); id != "" {
+		tx.writes.mark(id, time.Now())
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRecentWrites(t *testing.T) {
+	writes := newRecentWrites(time.Minute)
+	now := time.Now()
+	writes.mark("1", now)
+
+	assert.True(t, writes.recent("1", now.Add(30*time.Second)))
+	assert.False(t, writes.recent("1", now.Add(time.Minute)), "window expired")
+	assert.False(t, writes.recent("2", now))
+
+	writes.mark("2", now.Add(2*time.Minute))
+	assert.NotContains(t, writes.last, "1", "expired writes are swept")
+	assert.Contains(t, writes.last, "2")
+
+	disabled := newRecentWrites(0)
+	disabled.mark("1", now)
+	assert.False(t, disabled.recent("1", now))
+}
+
+type commitTx struct {
+	pgx.Tx
+	err error
+}
+
+func (tx *commitTx) Commit(ctx context.Context) error {
+	return tx.err
+}
+
+func TestWriteTx_Commit(t *testing.T) {
+	tests := []struct {
+		name       string
+		writer     string
+		commitErr  error
+		wantRecent bool
+	}{
+		{name: "writeTx. Commit. Case #1. Success", writer: "1", wantRecent: true},
+		{name: "writeTx. Commit. Case #2. Commit error, write may be applied", writer: "1", commitErr: errors.New("conn closed"), wantRecent: true},
+		{name: "writeTx. Commit. Case #3. Unknown writer", writer: "", wantRecent: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writes := newRecentWrites(time.Minute)
+			ctx := basedbhandler.WithWriter(context.Background(), "")
+			tx := &writeTx{Tx: &commitTx{err: tt.commitErr}, ctx: ctx, writes: writes}
+			// пользователь стал известен после начала транзакции
+			basedbhandler.SetWriter(ctx, tt.writer)
+
+			err := tx.Commit(context.Background())
+			assert.Equal(t, tt.commitErr, err)
+			assert.Equal(t, tt.wantRecent, writes.recent("1", time.Now()))
+		})
+	}
+}
+
+func TestPostgresqlHandlerTX_BeginTx_Replica(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultPoolConfig()
+	config.ReplicaDataSource = Datasource
+	handler, err := NewPostgresqlHandlerTXWithConfig(ctx, Datasource, config, Log)
+	require.NoError(t, err)
+	defer handler.Close()
+	readOnly := pgx.TxOptions{AccessMode: pgx.ReadOnly}
+	userCtx := basedbhandler.WithWriter(ctx, "1")
+
+	acquired := handler.ReplicaStats().AcquireCount
+	tx, err := handler.BeginTx(userCtx, readOnly)
+	require.NoError(t, err)
+	assert.NoError(t, tx.Rollback(ctx))
+	assert.Equal(t, acquired+1, handler.ReplicaStats().AcquireCount, "read only transaction goes to replica")
+
+	tx, err = handler.BeginTx(userCtx, pgx.TxOptions{})
+	require.NoError(t, err)
+	assert.NoError(t, tx.Commit(ctx))
+
+	acquired = handler.Stats().AcquireCount
+	tx, err = handler.BeginTx(userCtx, readOnly)
+	require.NoError(t, err)
+	assert.NoError(t, tx.Rollback(ctx))
+	assert.Equal(t, acquired+1, handler.Stats().AcquireCount, "user reads own writes from primary")
+
+	acquired = handler.ReplicaStats().AcquireCount
+	tx, err = handler.BeginTx(basedbhandler.WithWriter(ctx, "2"), readOnly)
+	require.NoError(t, err)
+	assert.NoError(t, tx.Rollback(ctx))
+	assert.Equal(t, acquired+1, handler.ReplicaStats().AcquireCount, "other users read from replica")
+}
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"expvar"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure/postgres"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// publishPoolStats публикует статистику пула соединений в expvar под именем postgres_pool
+// и статистику запросов по их именам под именем postgres_queries. Статистика пула реплики, если она настроена, -
+// под именем postgres_replica_pool
+func publishPoolStats(h *postgres.PostgresqlHandlerTX) {
+	expvar.Publish("postgres_pool", expvar.Func(func() interface{} {
+		return h.Stats()
+	}))
+	if h.ReplicaStats() != nil {
+		expvar.Publish("postgres_replica_pool", expvar.Func(func() interface{} {
+			return h.ReplicaStats()
+		}))
+	}
+	expvar.Publish("postgres_queries", expvar.Func(func() interface{} {
+		return h.QueryStats()
+	}))
+}
+
+// serveMetrics отдает метрики expvar на отдельном адресе, чтобы они не были доступны снаружи вместе с API
+func serveMetrics(address string, log *infrastructure.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	log.Info("metrics server started", zap.String("address", address))
+	if err := http.ListenAndServe(address, mux); err != nil {
+		log.Error("can't start metrics server", zap.Error(err))
+	}
+}
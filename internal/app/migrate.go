@@ -0,0 +1,129 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	cfg "github.com/portnyagin/practicum_project/internal/app/config"
+	"github.com/portnyagin/practicum_project/internal/app/database/migration"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure/postgres"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+const migrateUsage = "usage: gophermart migrate up [version] | down [version] | status [-d dsn]"
+
+// Migrate выполняет команду gophermart migrate: up [version] применяет миграции до версии (по умолчанию до последней),
+// down [version] откатывает миграции до версии (по умолчанию откатывает последнюю), status печатает состояние миграций.
+// Возвращает код завершения: 0 - успешно, 2 - ошибка
+func Migrate() int {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatalf("can't initialize zap logger: %v", err)
+	}
+	defer logger.Sync()
+
+	config := cfg.NewMigrateConfig()
+	if err = config.Init(); err != nil {
+		logger.Error("can't init configuration", zap.Error(err))
+		return 2
+	}
+	// первый аргумент - сама команда migrate
+	args := pflag.Args()
+	if len(args) < 2 || len(args) > 3 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	version := -1
+	if len(args) == 3 {
+		if version, err = strconv.Atoi(args[2]); err != nil || version < 0 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+	}
+
+	migrations, err := migration.Migrations()
+	if err != nil {
+		logger.Error("can't load migrations", zap.Error(err))
+		return 2
+	}
+	ctx := context.Background()
+	postgresHandlerTx, err := postgres.NewPostgresqlHandlerTX(ctx, config.DatabaseDSN, logger)
+	if err != nil {
+		logger.Error("can't create postgres handler", zap.Error(err))
+		return 2
+	}
+	defer postgresHandlerTx.Close()
+	migrator := migration.NewMigrator(postgresHandlerTx, migrations, logger)
+
+	switch args[1] {
+	case "up":
+		if version < 0 {
+			version = 0
+		}
+		count, err := migrator.Up(ctx, version)
+		if err != nil {
+			logger.Error("can't apply migrations", zap.Error(err))
+			return 2
+		}
+		fmt.Fprintf(os.Stdout, "%d migration(s) applied\n", count)
+	case "down":
+		if version < 0 {
+			if version, err = previousVersion(ctx, migrator); err != nil {
+				logger.Error("can't get migrations status", zap.Error(err))
+				return 2
+			}
+		}
+		count, err := migrator.Down(ctx, version)
+		if err != nil {
+			logger.Error("can't roll back migrations", zap.Error(err))
+			return 2
+		}
+		fmt.Fprintf(os.Stdout, "%d migration(s) rolled back\n", count)
+	case "status":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			logger.Error("can't get migrations status", zap.Error(err))
+			return 2
+		}
+		for _, s := range status {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			if s.Unknown {
+				state += " (unknown to this build)"
+			}
+			fmt.Fprintf(os.Stdout, "%04d %-30s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
+
+// previousVersion - версия, к которой откатывается последняя примененная миграция
+func previousVersion(ctx context.Context, migrator *migration.Migrator) (int, error) {
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var applied []int
+	for _, s := range status {
+		if s.Applied {
+			applied = append(applied, s.Version)
+		}
+	}
+	if len(applied) < 2 {
+		return 0, nil
+	}
+	return applied[len(applied)-2], nil
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+//go:generate mockgen -destination=../service/mocks/mock_adjustment_repository.go -package=mocks . AdjustmentRepository
+type AdjustmentRepository interface {
+	Save(ctx context.Context, adjustment *Adjustment) error
+	Lock(ctx context.Context, adjustmentID int) (*Adjustment, error)
+	Update(ctx context.Context, adjustment *Adjustment) error
+	FindByStatus(ctx context.Context, status string) ([]Adjustment, error)
+}
+
+// Adjustment - запрос администратора на ручную корректировку баланса пользователя
+type Adjustment struct {
+	ID          int
+	UserID      int
+	Amount      float32
+	ReasonCode  string
+	Comment     string
+	RequestedBy string
+	ApprovedBy  string
+	Status      string
+	OperationID int
+	CreatedAt   time.Time
+	DecidedAt   time.Time
+}
+
+const (
+	AdjustmentStatusPending  = "PENDING"
+	AdjustmentStatusApplied  = "APPLIED"
+	AdjustmentStatusRejected = "REJECTED"
+)
+
+const (
+	AdjustmentReasonCompensation  = "COMPENSATION"
+	AdjustmentReasonGoodwill      = "GOODWILL"
+	AdjustmentReasonCorrection    = "CORRECTION"
+	AdjustmentReasonFraudReversal = "FRAUD_REVERSAL"
+	AdjustmentReasonOther         = "OTHER"
+)
+
+func IsAdjustmentReason(code string) bool {
+	switch code {
+	case AdjustmentReasonCompensation, AdjustmentReasonGoodwill, AdjustmentReasonCorrection,
+		AdjustmentReasonFraudReversal, AdjustmentReasonOther:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+//go:generate mockgen -destination=../service/mocks/mock_api_key_repository.go -package=mocks . APIKeyRepository
+type APIKeyRepository interface {
+	Save(ctx context.Context, key *APIKey) error
+	// GetActive возвращает неотозванный ключ активного пользователя
+	GetActive(ctx context.Context, keyID string) (*APIKey, error)
+	ListByUser(ctx context.Context, userID int) ([]APIKey, error)
+	// Revoke возвращает NoRowFound, если у пользователя нет такого действующего ключа
+	Revoke(ctx context.Context, userID int, keyID string, now time.Time) error
+}
+
+// Права API-ключа
+const (
+	ScopeOrdersRead  = "orders:read"
+	ScopeOrdersWrite = "orders:write"
+	ScopeWithdraw    = "balance:withdraw"
+)
+
+func IsKnownScope(scope string) bool {
+	switch scope {
+	case ScopeOrdersRead, ScopeOrdersWrite, ScopeWithdraw:
+		return true
+	}
+	return false
+}
+
+// APIKey - ключ доступа для скриптов и сервисов. Секретная часть ключа хранится только в виде хеша
+type APIKey struct {
+	ID        string
+	UserID    int
+	Login     string
+	Name      string
+	KeyHash   string
+	Scopes    []string
+	CreatedAt time.Time
+	RevokedAt time.Time
+}
@@ -8,10 +8,17 @@ import (
 //go:generate mockgen -destination=../service/mocks/mock_balance_repository.go -package=mocks . BalanceRepository
 type BalanceRepository interface {
 	FindWithdrawalByUser(ctx context.Context, userID int) ([]Withdrawal, error)
+	FindWithdrawalByOrder(ctx context.Context, orderNum string) ([]Operation, error)
+	FindStatementByUser(ctx context.Context, userID int) ([]StatementEntry, error)
+	// StreamStatementByUser передает fn строки выписки по одной, не собирая выписку в памяти. Ошибка fn прекращает чтение
+	StreamStatementByUser(ctx context.Context, userID int, fn func(entry *StatementEntry) error) error
 	LockAccount(ctx context.Context, userID int) (*Account, error)
 	SaveAccount(ctx context.Context, account *Account) error
 	CreateOperation(ctx context.Context, operation *Operation) error
 	GetAccount(ctx context.Context, userID int) (*Account, error)
+	GetTransferredSum(ctx context.Context, accountID int, since time.Time) (float32, error)
+	GetWithdrawnSum(ctx context.Context, accountID int, since time.Time) (float32, error)
+	GetLastCreditTime(ctx context.Context, accountID int, minAmount float32) (time.Time, error)
 }
 
 type Withdrawal struct {
@@ -19,4 +26,17 @@ type Withdrawal struct {
 	Amount      float32
 	Status      string
 	ProcessedAt time.Time
+	Part        int
+	OrderTotal  float32
+}
+
+// StatementEntry - строка выписки по счету. Для переводов заполняются TransferRef и Counterparty
+type StatementEntry struct {
+	OperationType string
+	Amount        float32
+	OrderNum      string
+	TransferRef   string
+	Counterparty  string
+	Reason        string
+	ProcessedAt   time.Time
 }
@@ -11,6 +11,9 @@ type DatabaseError struct {
 }
 
 func (t *DatabaseError) Error() string {
+	if t.Err == nil {
+		return "database error " + t.Code
+	}
 	return t.Err.Error()
 }
 
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+//go:generate mockgen -destination=../service/mocks/mock_identity_repository.go -package=mocks . IdentityRepository
+type IdentityRepository interface {
+	// Get возвращает привязку внешней учетной записи или NoRowFound
+	Get(ctx context.Context, issuer string, subject string) (*Identity, error)
+	Save(ctx context.Context, identity *Identity) error
+}
+
+// Identity - привязка учетной записи внешнего провайдера (OpenID Connect) к пользователю.
+// Учетная запись определяется парой issuer и subject, email хранится только для справки
+type Identity struct {
+	Issuer    string
+	Subject   string
+	UserID    int
+	Email     string
+	CreatedAt time.Time
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"context"
+	"math"
+)
+
+// LedgerTolerance - расхождения агрегатов счета с суммами операций меньше этой величины считаются погрешностью
+// float32. Правило общее для поиска расхождений и их исправления: иначе исправление пропускает расхождение,
+// которое проверка продолжает находить
+const LedgerTolerance = 0.005
+
+//go:generate mockgen -destination=../service/mocks/mock_ledger_repository.go -package=mocks . LedgerRepository
+type LedgerRepository interface {
+	CountAccounts(ctx context.Context) (int, error)
+	FindAccountDiscrepancies(ctx context.Context) ([]AccountDiscrepancy, error)
+	GetLedgerTotals(ctx context.Context, accountID int) (*LedgerTotals, error)
+	FindOrphanOperations(ctx context.Context) ([]Operation, error)
+	FindProcessedOrdersWithoutCredit(ctx context.Context) ([]Order, error)
+}
+
+// LedgerTotals - суммы операций по счету. Ожидается balance = Credit - Debit + Adjustment
+type LedgerTotals struct {
+	Credit     float32
+	Debit      float32
+	Adjustment float32
+}
+
+// LedgerMismatch - значения a и b расходятся больше чем на LedgerTolerance
+func LedgerMismatch(a, b float32) bool {
+	return math.Abs(float64(a-b)) >= LedgerTolerance
+}
+
+// AccountDiscrepancy - счет, агрегаты которого не совпадают с суммами операций
+type AccountDiscrepancy struct {
+	Account Account
+	Ledger  LedgerTotals
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+//go:generate mockgen -destination=../service/mocks/mock_login_attempt_repository.go -package=mocks . LoginAttemptRepository
+type LoginAttemptRepository interface {
+	Get(ctx context.Context, key string) (*LoginAttempt, error)
+	// RegisterFailure увеличивает счетчик неудач. Неудачи раньше windowStart не учитываются: счетчик начинается заново
+	RegisterFailure(ctx context.Context, key string, now time.Time, windowStart time.Time) (*LoginAttempt, error)
+	LockUntil(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+// LoginAttempt - неудачные попытки входа по логину (login:<login>) или адресу клиента (ip:<address>)
+type LoginAttempt struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
@@ -7,10 +7,23 @@ type Operation struct {
 	AccountID     int
 	OrderID       int
 	OrderNum      string
+	OrderPart     int
 	OperationType string
 	Amount        float32
-	ProcessedAt   time.Time
+	TransferRef   string
+	Reason        string
+	Actor         string
+	// ApprovedBy - администратор, подтвердивший корректировку, пусто, если подтверждение не требовалось
+	ApprovedBy  string
+	ProcessedAt time.Time
 }
 
 const OperationDebit = "DEBIT"
 const OperationCredit = "CREDIT"
+
+// OperationAdjustment - ручная корректировка баланса. Сумма со знаком: положительная зачисляет, отрицательная списывает.
+// Корректировки меняют только balance, агрегаты debit/credit остаются суммами операций DEBIT/CREDIT
+const OperationAdjustment = "ADJUSTMENT"
+
+// OperationReasonAuditCorrection - корректирующая операция, созданная проверкой целостности учета
+const OperationReasonAuditCorrection = "AUDIT_CORRECTION"
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+//go:generate mockgen -destination=../service/mocks/mock_security_event_repository.go -package=mocks . SecurityEventRepository
+type SecurityEventRepository interface {
+	// Save добавляет запись в журнал. Записи журнала не изменяются и не удаляются
+	Save(ctx context.Context, event *SecurityEvent) error
+	// Find возвращает записи от новых к старым
+	Find(ctx context.Context, filter *SecurityEventFilter) ([]SecurityEvent, error)
+	// Stream передает fn записи по одной от новых к старым, не собирая их в памяти. Ошибка fn прекращает чтение
+	Stream(ctx context.Context, filter *SecurityEventFilter, fn func(event *SecurityEvent) error) error
+}
+
+// SecurityEvent - событие журнала безопасности
+type SecurityEvent struct {
+	ID        int64
+	Action    string
+	Result    string
+	UserID    int
+	Login     string
+	Target    string
+	IP        string
+	UserAgent string
+	Details   string
+	CreatedAt time.Time
+}
+
+type SecurityEventFilter struct {
+	UserID int
+	Login  string
+	Action string
+	From   time.Time
+	To     time.Time
+	// Limit < 0 - без ограничения
+	Limit int
+}
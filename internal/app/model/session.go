@@ -0,0 +1,34 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+//go:generate mockgen -destination=../service/mocks/mock_session_repository.go -package=mocks . SessionRepository
+type SessionRepository interface {
+	Save(ctx context.Context, session *Session) error
+	Get(ctx context.Context, sessionID string) (*Session, error)
+	// LockByRefreshHash ищет сессию по текущему или предыдущему refresh-токену и блокирует ее до конца транзакции
+	LockByRefreshHash(ctx context.Context, refreshHash string) (*Session, error)
+	Update(ctx context.Context, session *Session) error
+	// RevokeByUser отзывает все действующие сессии пользователя, кроме exceptSessionID
+	RevokeByUser(ctx context.Context, userID int, exceptSessionID string, now time.Time) error
+}
+
+// Session - серверная сессия пользователя. Refresh-токен хранится только в виде хеша и меняется при каждом обновлении,
+// PreviousHash нужен для обнаружения повторного использования уже замененного токена
+type Session struct {
+	ID           string
+	UserID       int
+	RefreshHash  string
+	PreviousHash string
+	CreatedAt    time.Time
+	RefreshedAt  time.Time
+	ExpiresAt    time.Time
+	RevokedAt    time.Time
+}
+
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt.IsZero() && now.Before(s.ExpiresAt)
+}
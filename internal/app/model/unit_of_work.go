@@ -0,0 +1,13 @@
+package model
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+)
+
+//go:generate mockgen -destination=../service/mocks/mock_unit_of_work.go -package=mocks . UnitOfWork
+type UnitOfWork interface {
+	// WithTx выполняет fn в транзакции с параметрами opts. Контекст fn содержит транзакцию, ее используют репозитории.
+	// При ошибке fn транзакция откатывается, при конфликте сериализации или взаимной блокировке fn выполняется повторно
+	WithTx(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context) error) error
+}
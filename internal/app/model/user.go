@@ -7,6 +7,25 @@ type UserRepository interface {
 	Save(ctx context.Context, login string, pass string) (userID int, err error)
 	Check(ctx context.Context, login string, pass string) (bool, error)
 	GetUserByLogin(ctx context.Context, login string) (*User, error)
+	GetUserByID(ctx context.Context, userID int) (*User, error)
+	UpdatePassword(ctx context.Context, userID int, pass string) error
+	// Deactivate запрещает вход пользователя. Неактивные пользователи не находятся GetUserByLogin и GetUserByID
+	Deactivate(ctx context.Context, userID int) error
+	// GetRoles возвращает назначенные пользователю роли. Базовая роль RoleUser не хранится
+	GetRoles(ctx context.Context, userID int) ([]string, error)
+	AddRole(ctx context.Context, userID int, role string) error
+	RemoveRole(ctx context.Context, userID int, role string) error
+}
+
+const (
+	// RoleUser есть у любого пользователя
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// IsKnownRole - роль можно назначить пользователю
+func IsKnownRole(role string) bool {
+	return role == RoleAdmin
 }
 
 type User struct {
@@ -0,0 +1,106 @@
+package mymiddleware
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"go.uber.org/zap"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type SecurityAudit interface {
+	Record(ctx context.Context, event *dto.SecurityEvent)
+}
+
+type auditKey struct{}
+
+// Audit записывает в журнал безопасности событие action по каждому запросу маршрута. Пользователь берется из токена,
+// объект действия - из параметров маршрута, результат - из кода ответа. Обработчик может уточнить событие через AuditEvent.
+// Для маршрутов с токеном должен стоять после jwtauth.Authenticator
+func Audit(action string, audit SecurityAudit, log *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			event := dto.SecurityEvent{
+				Action:    action,
+				IP:        remoteIP(r),
+				UserAgent: r.UserAgent(),
+			}
+			if _, claims, err := jwtauth.FromContext(r.Context()); err == nil {
+				switch u := claims["user_id"].(type) {
+				case float64:
+					event.UserID = int(u)
+				case int:
+					event.UserID = u
+				}
+				event.Login, _ = claims["login"].(string)
+				if keyID, ok := claims["api_key"].(string); ok {
+					event.Details = "api_key=" + keyID
+				}
+			}
+
+			sw := statusWriter{ResponseWriter: w}
+			next.ServeHTTP(&sw, r.WithContext(context.WithValue(r.Context(), auditKey{}, &event)))
+
+			if event.Target == "" {
+				event.Target = routeParams(r)
+			}
+			if event.Result == "" {
+				event.Result = dto.AuditSuccess
+				if sw.status >= http.StatusBadRequest {
+					event.Result = dto.AuditFailure
+				}
+			}
+			status := fmt.Sprintf("status=%d", statusOrOK(sw.status))
+			if event.Details == "" {
+				event.Details = status
+			} else {
+				event.Details = status + " " + event.Details
+			}
+			// запись журнала не зависит от отмены запроса клиентом
+			audit.Record(context.Background(), &event)
+			log.Debug("Audit: event recorded", zap.String("action", action), zap.String("result", event.Result))
+		})
+	}
+}
+
+// AuditEvent возвращает событие журнала текущего запроса или nil, если маршрут не журналируется
+func AuditEvent(ctx context.Context) *dto.SecurityEvent {
+	event, _ := ctx.Value(auditKey{}).(*dto.SecurityEvent)
+	return event
+}
+
+// routeParams - параметры маршрута в виде name=value через пробел
+func routeParams(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return ""
+	}
+	var params []string
+	for i, key := range rctx.URLParams.Keys {
+		if key == "*" {
+			continue
+		}
+		params = append(params, key+"="+rctx.URLParams.Values[i])
+	}
+	return strings.Join(params, " ")
+}
+
+// remoteIP - адрес клиента без порта. За прокси адрес подставляет middleware.RealIP
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func statusOrOK(status int) int {
+	if status == 0 {
+		return http.StatusOK
+	}
+	return status
+}
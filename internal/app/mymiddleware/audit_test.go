@@ -0,0 +1,92 @@
+package mymiddleware
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type auditRecorder struct {
+	events []dto.SecurityEvent
+}
+
+func (a *auditRecorder) Record(ctx context.Context, event *dto.SecurityEvent) {
+	a.events = append(a.events, *event)
+}
+
+func TestAudit(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		status int
+		actor  string
+		want   dto.SecurityEvent
+	}{
+		{
+			name:   "Audit. Case #1. User token, success",
+			claims: map[string]interface{}{"user_id": float64(7), "login": "admin"},
+			status: http.StatusOK,
+			want: dto.SecurityEvent{
+				Action: dto.AuditRoleGrant, Result: dto.AuditSuccess, UserID: 7, Login: "admin",
+				Target: "login=user role=admin", IP: "10.0.0.1", UserAgent: "test", Details: "status=200",
+			},
+		},
+		{
+			name:   "Audit. Case #2. API key, failure",
+			claims: map[string]interface{}{"user_id": 5, "login": "script", "api_key": "key"},
+			status: http.StatusNotFound,
+			want: dto.SecurityEvent{
+				Action: dto.AuditRoleGrant, Result: dto.AuditFailure, UserID: 5, Login: "script",
+				Target: "login=user role=admin", IP: "10.0.0.1", UserAgent: "test", Details: "status=404 api_key=key",
+			},
+		},
+		{
+			name:   "Audit. Case #3. Actor set by handler",
+			status: http.StatusUnauthorized,
+			actor:  "guest",
+			want: dto.SecurityEvent{
+				Action: dto.AuditRoleGrant, Result: dto.AuditFailure, Login: "guest",
+				Target: "login=user role=admin", IP: "10.0.0.1", UserAgent: "test", Details: "status=401",
+			},
+		},
+	}
+	log, _ := zap.NewDevelopment()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit := &auditRecorder{}
+			router := chi.NewRouter()
+			router.With(Audit(dto.AuditRoleGrant, audit, log)).Put("/api/admin/users/{login}/roles/{role}", func(w http.ResponseWriter, r *http.Request) {
+				if tt.actor != "" {
+					AuditEvent(r.Context()).Login = tt.actor
+				}
+				w.WriteHeader(tt.status)
+			})
+			request := httptest.NewRequest(http.MethodPut, "/api/admin/users/user/roles/admin", nil)
+			request.RemoteAddr = "10.0.0.1:5000"
+			request.Header.Set("User-Agent", "test")
+			if tt.claims != nil {
+				token := jwt.New()
+				for k, v := range tt.claims {
+					assert.NoError(t, token.Set(k, v))
+				}
+				request = request.WithContext(jwtauth.NewContext(request.Context(), token, nil))
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, tt.status, res.StatusCode)
+			require.Len(t, audit.events, 1)
+			assert.Equal(t, tt.want, audit.events[0])
+		})
+	}
+	assert.Nil(t, AuditEvent(context.Background()))
+}
@@ -0,0 +1,47 @@
+package mymiddleware
+
+import (
+	"crypto/subtle"
+	"github.com/go-chi/jwtauth/v5"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+const CSRFHeader = "X-CSRF-Token"
+
+// CSRFProtect требует заголовок X-CSRF-Token, совпадающий с claim csrf токена, для изменяющих запросов,
+// аутентифицированных через cookie. Запросы с заголовком Authorization браузер сам не отправляет, поэтому не проверяются.
+// Должен стоять после jwtauth.Verifier и jwtauth.Authenticator
+func CSRFProtect(log *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+			if jwtauth.TokenFromHeader(r) != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			_, claims, err := jwtauth.FromContext(r.Context())
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			// API-ключ передается в заголовке, браузер не подставляет его сам
+			if isAPIKey(claims) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			expected, _ := claims["csrf"].(string)
+			actual := r.Header.Get(CSRFHeader)
+			if expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) != 1 {
+				log.Warn("CSRFProtect: csrf token mismatch", zap.String("uri", r.RequestURI))
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
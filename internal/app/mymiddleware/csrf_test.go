@@ -0,0 +1,75 @@
+package mymiddleware
+
+import (
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRFProtect(t *testing.T) {
+	type args struct {
+		method     string
+		bearer     bool
+		csrfHeader string
+	}
+	tests := []struct {
+		name         string
+		args         args
+		responseCode int
+	}{
+		{
+			name:         "CSRFProtect. Case #1. Safe method",
+			args:         args{method: http.MethodGet},
+			responseCode: http.StatusOK,
+		},
+		{
+			name:         "CSRFProtect. Case #2. Cookie without header",
+			args:         args{method: http.MethodPost},
+			responseCode: http.StatusForbidden,
+		},
+		{
+			name:         "CSRFProtect. Case #3. Cookie with wrong header",
+			args:         args{method: http.MethodPost, csrfHeader: "other"},
+			responseCode: http.StatusForbidden,
+		},
+		{
+			name:         "CSRFProtect. Case #4. Cookie with header",
+			args:         args{method: http.MethodPost, csrfHeader: "csrf-value"},
+			responseCode: http.StatusOK,
+		},
+		{
+			name:         "CSRFProtect. Case #5. Authorization header",
+			args:         args{method: http.MethodPost, bearer: true},
+			responseCode: http.StatusOK,
+		},
+	}
+	log, _ := zap.NewDevelopment()
+	h := CSRFProtect(log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	token := jwt.New()
+	assert.NoError(t, token.Set("csrf", "csrf-value"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.args.method, "/api/user/orders", nil)
+			if tt.args.bearer {
+				request.Header.Set("Authorization", "Bearer token")
+			} else {
+				request.AddCookie(&http.Cookie{Name: "jwt", Value: "token"})
+			}
+			if tt.args.csrfHeader != "" {
+				request.Header.Set(CSRFHeader, tt.args.csrfHeader)
+			}
+			request = request.WithContext(jwtauth.NewContext(request.Context(), token, nil))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, tt.responseCode, res.StatusCode)
+		})
+	}
+}
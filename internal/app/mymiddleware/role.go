@@ -0,0 +1,47 @@
+package mymiddleware
+
+import (
+	"github.com/go-chi/jwtauth/v5"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// RequireRole пропускает только пользователей, у которых в claim roles токена есть роль role.
+// Должен стоять после jwtauth.Verifier и jwtauth.Authenticator
+func RequireRole(role string, log *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, claims, err := jwtauth.FromContext(r.Context())
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if !hasClaimValue(claims["roles"], role) {
+				login, _ := claims["login"].(string)
+				log.Warn("RequireRole: access denied", zap.String("login", login), zap.String("role", role), zap.String("uri", r.RequestURI))
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// hasClaimValue проверяет наличие value в claim-списке. После разбора JSON список имеет тип []interface{}
+func hasClaimValue(claim interface{}, value string) bool {
+	switch values := claim.(type) {
+	case []interface{}:
+		for _, v := range values {
+			if v == value {
+				return true
+			}
+		}
+	case []string:
+		for _, v := range values {
+			if v == value {
+				return true
+			}
+		}
+	}
+	return false
+}
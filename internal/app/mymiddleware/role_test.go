@@ -0,0 +1,53 @@
+package mymiddleware
+
+import (
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		name         string
+		roles        interface{}
+		responseCode int
+	}{
+		{
+			name:         "RequireRole. Case #1. Admin",
+			roles:        []interface{}{"user", "admin"},
+			responseCode: http.StatusOK,
+		},
+		{
+			name:         "RequireRole. Case #2. Regular user",
+			roles:        []interface{}{"user"},
+			responseCode: http.StatusForbidden,
+		},
+		{
+			name:         "RequireRole. Case #3. Token without roles",
+			responseCode: http.StatusForbidden,
+		},
+	}
+	log, _ := zap.NewDevelopment()
+	h := RequireRole("admin", log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := jwt.New()
+			if tt.roles != nil {
+				assert.NoError(t, token.Set("roles", tt.roles))
+			}
+			request := httptest.NewRequest(http.MethodGet, "/api/admin/adjustments", nil)
+			request = request.WithContext(jwtauth.NewContext(request.Context(), token, nil))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, tt.responseCode, res.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package mymiddleware
+
+import (
+	"github.com/go-chi/jwtauth/v5"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// isAPIKey - токен построен по API-ключу, а не выпущен при входе пользователя
+func isAPIKey(claims map[string]interface{}) bool {
+	_, ok := claims["api_key"]
+	return ok
+}
+
+// RequireScope требует право scope у запросов с API-ключом. Запросы с токеном пользователя пропускаются.
+// Должен стоять после Verifier и jwtauth.Authenticator
+func RequireScope(scope string, log *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, claims, err := jwtauth.FromContext(r.Context())
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if isAPIKey(claims) && !hasClaimValue(claims["scopes"], scope) {
+				keyID, _ := claims["api_key"].(string)
+				log.Warn("RequireScope: access denied", zap.String("keyID", keyID), zap.String("scope", scope), zap.String("uri", r.RequestURI))
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package mymiddleware
+
+import (
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name         string
+		claims       map[string]interface{}
+		responseCode int
+	}{
+		{
+			name:         "RequireScope. Case #1. User token",
+			claims:       map[string]interface{}{"sid": "session"},
+			responseCode: http.StatusOK,
+		},
+		{
+			name:         "RequireScope. Case #2. API key with scope",
+			claims:       map[string]interface{}{"api_key": "key", "scopes": []string{"orders:read", "orders:write"}},
+			responseCode: http.StatusOK,
+		},
+		{
+			name:         "RequireScope. Case #3. API key without scope",
+			claims:       map[string]interface{}{"api_key": "key", "scopes": []string{"orders:read"}},
+			responseCode: http.StatusForbidden,
+		},
+	}
+	log, _ := zap.NewDevelopment()
+	h := RequireScope("orders:write", log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := jwt.New()
+			for k, v := range tt.claims {
+				assert.NoError(t, token.Set(k, v))
+			}
+			request := httptest.NewRequest(http.MethodPost, "/api/user/orders", nil)
+			request = request.WithContext(jwtauth.NewContext(request.Context(), token, nil))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, tt.responseCode, res.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package mymiddleware
+
+import (
+	"context"
+	"github.com/go-chi/jwtauth/v5"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+type SessionChecker interface {
+	IsActive(ctx context.Context, sessionID string) (bool, error)
+}
+
+// ActiveSession отклоняет токены отозванных и истекших сессий. Запросы с API-ключом не проверяются.
+// Должен стоять после jwtauth.Authenticator. Ставится на группу маршрутов до Transactional, поэтому проверка идет
+// вне транзакции маршрута, в режиме autocommit: IsActive только читает сессию и пользователя, а отзыв, случившийся
+// между этими чтениями, отклонит уже следующий запрос. Отклоненный запрос не начинает транзакцию
+func ActiveSession(checker SessionChecker, log *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, claims, err := jwtauth.FromContext(r.Context())
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			// API-ключ не привязан к сессии, его отзыв проверяется при каждом запросе
+			if isAPIKey(claims) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			sessionID, _ := claims["sid"].(string)
+			active, err := checker.IsActive(r.Context(), sessionID)
+			if err != nil {
+				log.Error("ActiveSession: can't check session", zap.Error(err))
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			if !active {
+				log.Info("ActiveSession: session is not active", zap.String("sessionID", sessionID), zap.String("uri", r.RequestURI))
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -1,11 +1,14 @@
 package mymiddleware
 
 import (
+	"bytes"
 	"context"
-	"fmt"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/jackc/pgx/v4"
 	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
 	"go.uber.org/zap"
 	"net/http"
+	"strconv"
 )
 
 type statusWriter struct {
@@ -18,34 +21,157 @@ func (w *statusWriter) WriteHeader(status int) {
 	w.ResponseWriter.WriteHeader(status)
 }
 
-func Transactional(handler basedbhandler.Transactioner, log *zap.Logger) func(http.Handler) http.Handler {
+// TxBeginner - источник транзакций для Transactional
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error)
+}
+
+// TxPolicy - режим транзакции маршрута
+type TxPolicy struct {
+	// NoTx - маршрут работает без транзакции, каждый запрос к базе выполняется в режиме autocommit
+	NoTx     bool
+	ReadOnly bool
+	// IsoLevel - уровень изоляции, пустой - уровень по умолчанию сервера (read committed)
+	IsoLevel pgx.TxIsoLevel
+	// Stream - ответ передается клиенту сразу, без ожидания конца транзакции. Только для ReadOnly:
+	// транзакции чтения нечего фиксировать, поэтому ответ не зависит от ее завершения
+	Stream bool
+}
+
+var (
+	// ReadWrite - изменяющие запросы
+	ReadWrite = TxPolicy{}
+	// ReadOnly - запросы на чтение
+	ReadOnly = TxPolicy{ReadOnly: true}
+	// ReadOnlySnapshot - чтение согласованного среза данных несколькими запросами, например, для сверки учета
+	ReadOnlySnapshot = TxPolicy{ReadOnly: true, IsoLevel: pgx.RepeatableRead}
+	// ReadOnlyStream - выгрузки большого объема: согласованный срез данных передается клиенту по мере чтения
+	ReadOnlyStream = TxPolicy{ReadOnly: true, IsoLevel: pgx.RepeatableRead, Stream: true}
+	// NoTx - маршруты, которым база не нужна или нужна вне транзакции
+	NoTx = TxPolicy{NoTx: true}
+)
+
+// Transactional выполняет обработчик в транзакции с режимом policy. Транзакция привязана к контексту запроса
+// и прерывается вместе с ним. Ответ обработчика задерживается до фиксации транзакции: если зафиксировать
+// не удалось, клиент получает 500 вместо ответа обработчика. Ответ с ошибкой (кроме перенаправлений) откатывает транзакцию.
+// В режиме Stream ответ передается клиенту сразу, а транзакция чтения завершается после обработчика.
+// Пользователь из токена - автор изменений транзакции: после них его запросы чтения не идут на отстающую реплику
+func Transactional(handler TxBeginner, policy TxPolicy, log *zap.Logger) func(http.Handler) http.Handler {
+	if policy.Stream && !policy.ReadOnly {
+		panic("TransactionMiddleware: stream policy requires read only transaction")
+	}
 	return func(next http.Handler) http.Handler {
+		if policy.NoTx {
+			return next
+		}
+		opts := pgx.TxOptions{IsoLevel: policy.IsoLevel, AccessMode: pgx.ReadWrite}
+		if policy.ReadOnly {
+			opts.AccessMode = pgx.ReadOnly
+		}
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			tx, err := handler.NewTx(context.Background())
+			ctx := basedbhandler.WithWriter(r.Context(), claimsWriter(r))
+			tx, err := handler.BeginTx(ctx, opts)
 			if err != nil {
 				log.Error("TransactionMiddleware: can't start transaction", zap.Error(err))
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
 				return
 			}
 			defer func() {
-				if r := recover(); r != nil {
-					log.Error("TransactionMiddleware: Panic. Try to rollback", zap.Error(err))
-					err = tx.Rollback(context.Background())
-					fmt.Println(err)
+				if p := recover(); p != nil {
+					log.Error("TransactionMiddleware: Panic. Try to rollback")
+					if err := tx.Rollback(ctx); err != nil {
+						log.Error("TransactionMiddleware: Can't rollback", zap.Error(err))
+					}
+					panic(p)
 				}
 			}()
-			ctx := context.WithValue(r.Context(), basedbhandler.TransactionKey("tx"), tx)
 
-			sw := statusWriter{ResponseWriter: w}
-			next.ServeHTTP(&sw, r.WithContext(ctx))
-			if sw.status > http.StatusNoContent {
-				if err := handler.Rollback(ctx); err != nil {
-					log.Error("TransactionMiddleware: Can't commit", zap.Error(err))
+			txCtx := context.WithValue(ctx, basedbhandler.TransactionKey("tx"), tx)
+			if policy.Stream {
+				next.ServeHTTP(w, r.WithContext(txCtx))
+				if err := tx.Rollback(ctx); err != nil {
+					log.Error("TransactionMiddleware: Can't finish read only transaction", zap.Error(err))
 				}
-			} else {
-				if err := handler.Commit(ctx); err != nil {
+				return
+			}
+			bw := newBufferedWriter()
+			next.ServeHTTP(bw, r.WithContext(txCtx))
+			// перенаправление (например, после входа через провайдер) - успешный исход запроса
+			if bw.status > http.StatusNoContent && !isRedirect(bw.status) {
+				if err := tx.Rollback(ctx); err != nil {
 					log.Error("TransactionMiddleware: Can't rollback", zap.Error(err))
 				}
+				bw.flush(w)
+				return
 			}
+			if err := tx.Commit(ctx); err != nil {
+				log.Error("TransactionMiddleware: Can't commit", zap.Error(err), zap.String("uri", r.RequestURI))
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			bw.flush(w)
 		})
 	}
 }
+
+// ReadOwnWrites указывает автора изменений транзакции запроса, если его нет в токене (регистрация, вход)
+func ReadOwnWrites(ctx context.Context, userID int) {
+	basedbhandler.SetWriter(ctx, strconv.Itoa(userID))
+}
+
+// claimsWriter возвращает пользователя из токена запроса или пустую строку
+func claimsWriter(r *http.Request) string {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		return ""
+	}
+	switch u := claims["user_id"].(type) {
+	case float64:
+		return strconv.Itoa(int(u))
+	case int:
+		return strconv.Itoa(u)
+	}
+	return ""
+}
+
+func isRedirect(status int) bool {
+	return status >= http.StatusMultipleChoices && status < http.StatusBadRequest
+}
+
+// bufferedWriter накапливает ответ обработчика до завершения транзакции
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedWriter() *bufferedWriter {
+	return &bufferedWriter{header: make(http.Header)}
+}
+
+func (w *bufferedWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+func (w *bufferedWriter) flush(dst http.ResponseWriter) {
+	for k, v := range w.header {
+		dst.Header()[k] = v
+	}
+	dst.WriteHeader(statusOrOK(w.status))
+	if w.body.Len() > 0 {
+		_, _ = dst.Write(w.body.Bytes())
+	}
+}
@@ -0,0 +1,227 @@
+package mymiddleware
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/jackc/pgx/v4"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeTx struct {
+	pgx.Tx
+	commitErr  error
+	committed  bool
+	rolledBack bool
+}
+
+func (tx *fakeTx) Commit(ctx context.Context) error {
+	tx.committed = true
+	return tx.commitErr
+}
+
+func (tx *fakeTx) Rollback(ctx context.Context) error {
+	tx.rolledBack = true
+	return nil
+}
+
+type fakeBeginner struct {
+	tx   *fakeTx
+	err  error
+	opts pgx.TxOptions
+	ctx  context.Context
+}
+
+func (b *fakeBeginner) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	b.opts = opts
+	b.ctx = ctx
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.tx, nil
+}
+
+func TestTransactional(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       TxPolicy
+		status       int
+		beginErr     error
+		commitErr    error
+		wantStatus   int
+		wantBody     string
+		wantCommit   bool
+		wantRollback bool
+		wantOpts     pgx.TxOptions
+	}{
+		{
+			name:       "Transactional. Case #1. Success, commit",
+			policy:     ReadWrite,
+			status:     http.StatusOK,
+			wantStatus: http.StatusOK,
+			wantBody:   "done",
+			wantCommit: true,
+			wantOpts:   pgx.TxOptions{AccessMode: pgx.ReadWrite},
+		},
+		{
+			name:         "Transactional. Case #2. Error response, rollback",
+			policy:       ReadWrite,
+			status:       http.StatusBadRequest,
+			wantStatus:   http.StatusBadRequest,
+			wantBody:     "done",
+			wantRollback: true,
+			wantOpts:     pgx.TxOptions{AccessMode: pgx.ReadWrite},
+		},
+		{
+			name:       "Transactional. Case #3. Commit failed, response dropped",
+			policy:     ReadWrite,
+			status:     http.StatusOK,
+			commitErr:  errors.New("serialization failure"),
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   "Internal Server Error\n",
+			wantCommit: true,
+			wantOpts:   pgx.TxOptions{AccessMode: pgx.ReadWrite},
+		},
+		{
+			name:       "Transactional. Case #4. Can't begin transaction",
+			policy:     ReadWrite,
+			status:     http.StatusOK,
+			beginErr:   errors.New("pool closed"),
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   "Service Unavailable\n",
+			wantOpts:   pgx.TxOptions{AccessMode: pgx.ReadWrite},
+		},
+		{
+			name:       "Transactional. Case #5. Read only snapshot",
+			policy:     ReadOnlySnapshot,
+			status:     http.StatusOK,
+			wantStatus: http.StatusOK,
+			wantBody:   "done",
+			wantCommit: true,
+			wantOpts:   pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly},
+		},
+		{
+			name:       "Transactional. Case #6. Redirect, commit",
+			policy:     ReadWrite,
+			status:     http.StatusFound,
+			wantStatus: http.StatusFound,
+			wantBody:   "done",
+			wantCommit: true,
+			wantOpts:   pgx.TxOptions{AccessMode: pgx.ReadWrite},
+		},
+	}
+	log, _ := zap.NewDevelopment()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &fakeTx{commitErr: tt.commitErr}
+			beginner := &fakeBeginner{tx: tx, err: tt.beginErr}
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, tx, r.Context().Value(basedbhandler.TransactionKey("tx")))
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte("done"))
+			})
+			w := httptest.NewRecorder()
+			Transactional(beginner, tt.policy, log)(next).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantBody, w.Body.String())
+			assert.Equal(t, tt.wantCommit, tx.committed)
+			assert.Equal(t, tt.wantRollback, tx.rolledBack)
+			assert.Equal(t, tt.wantOpts, beginner.opts)
+		})
+	}
+}
+
+func TestTransactional_NoTx(t *testing.T) {
+	log, _ := zap.NewDevelopment()
+	beginner := &fakeBeginner{err: errors.New("must not be called")}
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		assert.Nil(t, r.Context().Value(basedbhandler.TransactionKey("tx")))
+		w.WriteHeader(http.StatusOK)
+	})
+	w := httptest.NewRecorder()
+	Transactional(beginner, NoTx, log)(next).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.True(t, called)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestTransactional_Stream(t *testing.T) {
+	log, _ := zap.NewDevelopment()
+	tx := &fakeTx{}
+	beginner := &fakeBeginner{tx: tx}
+	w := httptest.NewRecorder()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, tx, r.Context().Value(basedbhandler.TransactionKey("tx")))
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write([]byte("["))
+		assert.Equal(t, "[", w.Body.String(), "response is not buffered")
+		_, _ = rw.Write([]byte("]"))
+	})
+	Transactional(beginner, ReadOnlyStream, log)(next).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[]", w.Body.String())
+	assert.True(t, tx.rolledBack)
+	assert.Equal(t, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}, beginner.opts)
+
+	assert.Panics(t, func() {
+		Transactional(beginner, TxPolicy{Stream: true}, log)
+	}, "stream policy requires read only transaction")
+}
+
+func TestTransactional_Writer(t *testing.T) {
+	tests := []struct {
+		name       string
+		claims     map[string]interface{}
+		loggedIn   int
+		wantWriter string
+	}{
+		{
+			name:       "Transactional. Writer. Case #1. User from token",
+			claims:     map[string]interface{}{"user_id": 7},
+			wantWriter: "7",
+		},
+		{
+			name:       "Transactional. Writer. Case #2. User logged in while handling request",
+			loggedIn:   5,
+			wantWriter: "5",
+		},
+		{
+			name:       "Transactional. Writer. Case #3. Anonymous request",
+			wantWriter: "",
+		},
+	}
+	log, _ := zap.NewDevelopment()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			beginner := &fakeBeginner{tx: &fakeTx{}}
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.loggedIn != 0 {
+					ReadOwnWrites(r.Context(), tt.loggedIn)
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+			request := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.claims != nil {
+				token := jwt.New()
+				for k, v := range tt.claims {
+					assert.NoError(t, token.Set(k, v))
+				}
+				request = request.WithContext(jwtauth.NewContext(request.Context(), token, nil))
+			}
+			Transactional(beginner, ReadWrite, log)(next).ServeHTTP(httptest.NewRecorder(), request)
+
+			// автор изменений читается из контекста начала транзакции при ее фиксации
+			assert.Equal(t, tt.wantWriter, basedbhandler.WriterFromContext(beginner.ctx))
+		})
+	}
+}
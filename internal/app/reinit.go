@@ -0,0 +1,67 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgconn"
+	cfg "github.com/portnyagin/practicum_project/internal/app/config"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/repository"
+	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
+	"go.uber.org/zap"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// reinitDatabase удаляет структуру базы вместе с данными. Без подтверждения, а при наличии данных - без
+// принудительного флага отказывается работать. Структура создается заново миграциями при обычном старте
+func reinitDatabase(ctx context.Context, config *cfg.AppConfig, h basedbhandler.TransactionalDBHandler, log *infrastructure.Logger) error {
+	if !config.ReinitConfirm {
+		return fmt.Errorf("database reinit drops all data and must be confirmed with --reinit-confirm or REINIT_CONFIRM=true")
+	}
+	tables, err := repository.NonEmptyTables(ctx, h)
+	if err != nil {
+		return fmt.Errorf("can't check database content: %w", err)
+	}
+	if len(tables) > 0 {
+		if !config.ReinitForce {
+			return fmt.Errorf("database reinit refused, tables contain data: %s; use --reinit-force or REINIT_FORCE=true to drop them",
+				strings.Join(tables, ", "))
+		}
+		log.Warn("Reinit: dropping tables with data", zap.Strings("tables", tables))
+	}
+	if config.ReinitBackupFile != "" {
+		if err = dumpSchema(ctx, config.DatabaseDSN, config.ReinitBackupFile); err != nil {
+			return fmt.Errorf("can't dump database schema: %w", err)
+		}
+		log.Info("Reinit: database schema saved", zap.String("file", config.ReinitBackupFile))
+	}
+	return repository.ClearDatabase(ctx, h, log)
+}
+
+// dumpSchema сохраняет схему базы в файл утилитой pg_dump. Параметры подключения передаются через переменные
+// окружения, чтобы пароль не попал в список процессов
+func dumpSchema(ctx context.Context, dsn string, file string) error {
+	conn, err := pgconn.ParseConfig(dsn)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "pg_dump", "--schema-only", "--no-owner", "--file", file)
+	cmd.Env = append(os.Environ(),
+		"PGHOST="+conn.Host,
+		"PGPORT="+strconv.Itoa(int(conn.Port)),
+		"PGUSER="+conn.User,
+		"PGPASSWORD="+conn.Password,
+		"PGDATABASE="+conn.Database,
+	)
+	if conn.TLSConfig == nil {
+		cmd.Env = append(cmd.Env, "PGSSLMODE=disable")
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("pg_dump: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -1,6 +1,6 @@
 package repository
 
-const CreateAccount = "INSERT INTO accounts (id, user_id) VALUES(nextval('seq_account'), $1);"
+const CreateAccount = "INSERT INTO accounts (user_id) VALUES($1);"
 
 const UpdateAccountForUser = "UPDATE accounts \n" +
 	"SET balance=$2, debit=$3, credit=$4 \n" +
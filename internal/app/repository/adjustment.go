@@ -0,0 +1,19 @@
+package repository
+
+const CreateAdjustment = "INSERT INTO adjustments \n" +
+	"(user_id, amount, reason_code, comment, requested_by, status, created_at) \n" +
+	"VALUES($1, $2, $3, $4, $5, $6, $7) \n" +
+	"returning id;"
+
+const GetAdjustmentForUpdate = "select id, user_id, amount, reason_code, comment, requested_by, COALESCE(approved_by, ''), \n" +
+	"status, COALESCE(operation_id, 0), created_at, COALESCE(decided_at, to_timestamp(0)) \n" +
+	"from adjustments where id = $1 for update"
+
+const UpdateAdjustment = "UPDATE adjustments \n" +
+	"SET status=$2, approved_by=nullif($3, ''), operation_id=nullif($4, 0), decided_at=$5 \n" +
+	"WHERE id=$1;"
+
+const FindAdjustmentsByStatus = "select id, user_id, amount, reason_code, comment, requested_by, COALESCE(approved_by, ''), \n" +
+	"status, COALESCE(operation_id, 0), created_at, COALESCE(decided_at, to_timestamp(0)) \n" +
+	"from adjustments where status = $1 \n" +
+	"order by created_at, id"
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
+	"go.uber.org/zap"
+)
+
+type AdjustmentRepository struct {
+	h basedbhandler.DBHandler
+	l *infrastructure.Logger
+}
+
+func NewAdjustmentRepository(dbHandler basedbhandler.DBHandler, log *infrastructure.Logger) (model.AdjustmentRepository, error) {
+	var target AdjustmentRepository
+	if dbHandler == nil {
+		return nil, errors.New("can't init adjustment repository")
+	}
+	target.h = dbHandler
+	target.l = log
+	return &target, nil
+}
+
+func (r *AdjustmentRepository) Save(ctx context.Context, adjustment *model.Adjustment) error {
+	row, err := r.h.QueryRow(ctx, CreateAdjustment,
+		adjustment.UserID,
+		adjustment.Amount,
+		adjustment.ReasonCode,
+		adjustment.Comment,
+		adjustment.RequestedBy,
+		adjustment.Status,
+		adjustment.CreatedAt)
+	if err != nil {
+		r.l.Error("AdjustmentRepository: can't create adjustment", zap.Error(err))
+		return err
+	}
+	if err = row.Scan(&adjustment.ID); err != nil {
+		r.l.Error("AdjustmentRepository: can't create adjustment", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *AdjustmentRepository) Lock(ctx context.Context, adjustmentID int) (*model.Adjustment, error) {
+	row, err := r.h.QueryRow(ctx, GetAdjustmentForUpdate, adjustmentID)
+	if err != nil {
+		r.l.Error("AdjustmentRepository: can't get adjustment for update", zap.Error(err))
+		return nil, err
+	}
+	var res model.Adjustment
+	err = row.Scan(&res.ID, &res.UserID, &res.Amount, &res.ReasonCode, &res.Comment, &res.RequestedBy, &res.ApprovedBy,
+		&res.Status, &res.OperationID, &res.CreatedAt, &res.DecidedAt)
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			return nil, &model.NoRowFound
+		}
+		r.l.Error("AdjustmentRepository: can't get adjustment for update", zap.Error(err))
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (r *AdjustmentRepository) Update(ctx context.Context, adjustment *model.Adjustment) error {
+	err := r.h.Execute(ctx, UpdateAdjustment,
+		adjustment.ID,
+		adjustment.Status,
+		adjustment.ApprovedBy,
+		adjustment.OperationID,
+		adjustment.DecidedAt)
+	if err != nil {
+		r.l.Error("AdjustmentRepository: can't update adjustment", zap.Int("adjustmentID", adjustment.ID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *AdjustmentRepository) FindByStatus(ctx context.Context, status string) ([]model.Adjustment, error) {
+	rows, err := r.h.Query(ctx, FindAdjustmentsByStatus, status)
+	var resArray []model.Adjustment
+	if err != nil {
+		r.l.Error("AdjustmentRepository: request error", zap.String("query", FindAdjustmentsByStatus), zap.String("status", status), zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var o model.Adjustment
+		err := rows.Scan(&o.ID, &o.UserID, &o.Amount, &o.ReasonCode, &o.Comment, &o.RequestedBy, &o.ApprovedBy,
+			&o.Status, &o.OperationID, &o.CreatedAt, &o.DecidedAt)
+		if err != nil {
+			r.l.Error("AdjustmentRepository: scan rows error", zap.String("query", FindAdjustmentsByStatus), zap.Error(err))
+			return nil, err
+		}
+		resArray = append(resArray, o)
+	}
+	if err = rows.Err(); err != nil {
+		r.l.Error("AdjustmentRepository: read rows error", zap.String("query", FindAdjustmentsByStatus), zap.Error(err))
+		return nil, err
+	}
+	return resArray, nil
+}
@@ -0,0 +1,15 @@
+package repository
+
+const CreateAPIKey = "INSERT INTO api_keys \n" +
+	"(id, user_id, name, key_hash, scopes, created_at) \n" +
+	"VALUES($1, $2, $3, $4, $5, $6);"
+
+const GetActiveAPIKey = "select k.id, k.user_id, u.login, k.name, k.key_hash, k.scopes, k.created_at \n" +
+	"from api_keys k join users u on u.id = k.user_id \n" +
+	"where k.id = $1 and k.revoked_at is null and u.active <> 0"
+
+const FindAPIKeysByUser = "select id, user_id, name, scopes, created_at, revoked_at \n" +
+	"from api_keys where user_id = $1 order by created_at"
+
+const RevokeAPIKey = "UPDATE api_keys SET revoked_at=$3 \n" +
+	"WHERE user_id=$1 and id=$2 and revoked_at is null returning id;"
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+type APIKeyRepository struct {
+	h basedbhandler.DBHandler
+	l *infrastructure.Logger
+}
+
+func NewAPIKeyRepository(dbHandler basedbhandler.DBHandler, log *infrastructure.Logger) (model.APIKeyRepository, error) {
+	var target APIKeyRepository
+	if dbHandler == nil {
+		return nil, errors.New("can't init api key repository")
+	}
+	target.h = dbHandler
+	target.l = log
+	return &target, nil
+}
+
+func (r *APIKeyRepository) Save(ctx context.Context, key *model.APIKey) error {
+	err := r.h.Execute(ctx, CreateAPIKey,
+		key.ID,
+		key.UserID,
+		key.Name,
+		key.KeyHash,
+		strings.Join(key.Scopes, ","),
+		key.CreatedAt)
+	if err != nil {
+		r.l.Error("APIKeyRepository: can't create api key", zap.Int("userID", key.UserID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *APIKeyRepository) GetActive(ctx context.Context, keyID string) (*model.APIKey, error) {
+	row, err := r.h.QueryRow(ctx, GetActiveAPIKey, keyID)
+	if err != nil {
+		r.l.Error("APIKeyRepository: can't get api key", zap.Error(err))
+		return nil, err
+	}
+	var (
+		res    model.APIKey
+		scopes string
+	)
+	err = row.Scan(&res.ID, &res.UserID, &res.Login, &res.Name, &res.KeyHash, &scopes, &res.CreatedAt)
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			return nil, &model.NoRowFound
+		}
+		r.l.Error("APIKeyRepository: can't get api key", zap.Error(err))
+		return nil, err
+	}
+	res.Scopes = splitScopes(scopes)
+	return &res, nil
+}
+
+func (r *APIKeyRepository) ListByUser(ctx context.Context, userID int) ([]model.APIKey, error) {
+	rows, err := r.h.Query(ctx, FindAPIKeysByUser, userID)
+	if err != nil {
+		r.l.Error("APIKeyRepository: request error", zap.String("query", FindAPIKeysByUser), zap.Int("userID", userID), zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+	var resArray []model.APIKey
+	for rows.Next() {
+		var (
+			k         model.APIKey
+			scopes    string
+			revokedAt *time.Time
+		)
+		if err = rows.Scan(&k.ID, &k.UserID, &k.Name, &scopes, &k.CreatedAt, &revokedAt); err != nil {
+			r.l.Error("APIKeyRepository: scan rows error", zap.String("query", FindAPIKeysByUser), zap.Int("userID", userID), zap.Error(err))
+			return nil, err
+		}
+		k.Scopes = splitScopes(scopes)
+		if revokedAt != nil {
+			k.RevokedAt = *revokedAt
+		}
+		resArray = append(resArray, k)
+	}
+	if err = rows.Err(); err != nil {
+		r.l.Error("APIKeyRepository: read rows error", zap.String("query", FindAPIKeysByUser), zap.Int("userID", userID), zap.Error(err))
+		return nil, err
+	}
+	return resArray, nil
+}
+
+func (r *APIKeyRepository) Revoke(ctx context.Context, userID int, keyID string, now time.Time) error {
+	row, err := r.h.QueryRow(ctx, RevokeAPIKey, userID, keyID, now)
+	if err != nil {
+		r.l.Error("APIKeyRepository: can't revoke api key", zap.Error(err))
+		return err
+	}
+	var id string
+	if err = row.Scan(&id); err != nil {
+		if err.Error() == "no rows in result set" {
+			return &model.NoRowFound
+		}
+		r.l.Error("APIKeyRepository: can't revoke api key", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func splitScopes(scopes string) []string {
+	if scopes == "" {
+		return nil
+	}
+	return strings.Split(scopes, ",")
+}
@@ -3,10 +3,13 @@ package repository
 import (
 	"context"
 	"errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
 	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
 	"github.com/portnyagin/practicum_project/internal/app/model"
 	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
 	"go.uber.org/zap"
+	"time"
 )
 
 type BalanceRepository struct {
@@ -31,18 +34,79 @@ func (r *BalanceRepository) FindWithdrawalByUser(ctx context.Context, userID int
 		r.l.Error("BalanceRepository: request error", zap.String("query", GetWithdrawalByUser), zap.Int("userID", userID), zap.Error(err))
 		return nil, err
 	}
+	defer rows.Close()
 	for rows.Next() {
 		var o model.Withdrawal
-		err := rows.Scan(&o.OrderNum, &o.Amount, &o.Status, &o.ProcessedAt)
+		err := rows.Scan(&o.OrderNum, &o.Amount, &o.Status, &o.ProcessedAt, &o.Part, &o.OrderTotal)
 		if err != nil {
 			r.l.Error("BalanceRepository: scan rows error", zap.String("query", GetWithdrawalByUser), zap.Int("userID", userID), zap.Error(err))
-			break
+			return nil, err
 		}
 		resArray = append(resArray, o)
 	}
+	if err = rows.Err(); err != nil {
+		r.l.Error("BalanceRepository: read rows error", zap.String("query", GetWithdrawalByUser), zap.Int("userID", userID), zap.Error(err))
+		return nil, err
+	}
 	return resArray, nil
 }
 
+func (r *BalanceRepository) FindWithdrawalByOrder(ctx context.Context, orderNum string) ([]model.Operation, error) {
+	rows, err := r.h.Query(ctx, FindWithdrawalByOrder, orderNum)
+	var resArray []model.Operation
+	if err != nil {
+		r.l.Error("BalanceRepository: request error", zap.String("query", FindWithdrawalByOrder), zap.String("orderNum", orderNum), zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		o := model.Operation{OperationType: model.OperationDebit}
+		err := rows.Scan(&o.ID, &o.AccountID, &o.OrderNum, &o.OrderPart, &o.Amount, &o.ProcessedAt)
+		if err != nil {
+			r.l.Error("BalanceRepository: scan rows error", zap.String("query", FindWithdrawalByOrder), zap.String("orderNum", orderNum), zap.Error(err))
+			return nil, err
+		}
+		resArray = append(resArray, o)
+	}
+	if err = rows.Err(); err != nil {
+		r.l.Error("BalanceRepository: read rows error", zap.String("query", FindWithdrawalByOrder), zap.String("orderNum", orderNum), zap.Error(err))
+		return nil, err
+	}
+	return resArray, nil
+}
+
+func (r *BalanceRepository) FindStatementByUser(ctx context.Context, userID int) ([]model.StatementEntry, error) {
+	var resArray []model.StatementEntry
+	err := r.StreamStatementByUser(ctx, userID, func(entry *model.StatementEntry) error {
+		resArray = append(resArray, *entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resArray, nil
+}
+
+func (r *BalanceRepository) StreamStatementByUser(ctx context.Context, userID int, fn func(entry *model.StatementEntry) error) error {
+	rows, err := r.h.Query(ctx, GetStatementByUser, userID)
+	if err != nil {
+		r.l.Error("BalanceRepository: request error", zap.String("query", GetStatementByUser), zap.Int("userID", userID), zap.Error(err))
+		return err
+	}
+	err = basedbhandler.ForEachRow(rows, func(rows basedbhandler.Rows) error {
+		var o model.StatementEntry
+		if err := rows.Scan(&o.OperationType, &o.Amount, &o.OrderNum, &o.TransferRef, &o.Counterparty, &o.Reason, &o.ProcessedAt); err != nil {
+			return err
+		}
+		return fn(&o)
+	})
+	if err != nil {
+		r.l.Error("BalanceRepository: read rows error", zap.String("query", GetStatementByUser), zap.Int("userID", userID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
 func (r *BalanceRepository) LockAccount(ctx context.Context, userID int) (*model.Account, error) {
 	row, err := r.h.QueryRow(ctx, GetAccountForUpdate, userID)
 	if err != nil {
@@ -73,13 +137,26 @@ func (r *BalanceRepository) SaveAccount(ctx context.Context, account *model.Acco
 }
 
 func (r *BalanceRepository) CreateOperation(ctx context.Context, operation *model.Operation) error {
-	err := r.h.Execute(ctx, CreateOperation,
+	row, err := r.h.QueryRow(ctx, CreateOperation,
 		operation.AccountID,
 		operation.OrderID,
 		operation.OrderNum,
 		operation.OperationType,
 		operation.Amount,
-		operation.ProcessedAt)
+		operation.ProcessedAt,
+		operation.TransferRef,
+		operation.Reason,
+		operation.OrderPart,
+		operation.Actor,
+		operation.ApprovedBy)
+	if err == nil {
+		err = row.Scan(&operation.ID)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		r.l.Info("BalanceRepository: operation already exists", zap.String("orderNum", operation.OrderNum), zap.Error(err))
+		return &model.UniqueViolation
+	}
 	if err != nil {
 		r.l.Error("BalanceRepository: cannt create operation", zap.Error(err))
 		return err
@@ -106,3 +183,48 @@ func (r *BalanceRepository) GetAccount(ctx context.Context, userID int) (*model.
 
 	return &account, nil
 }
+
+func (r *BalanceRepository) GetTransferredSum(ctx context.Context, accountID int, since time.Time) (float32, error) {
+	row, err := r.h.QueryRow(ctx, GetTransferredSum, accountID, since)
+	if err != nil {
+		r.l.Error("BalanceRepository: request error", zap.String("query", GetTransferredSum), zap.Int("accountID", accountID), zap.Error(err))
+		return 0, err
+	}
+	var res float32
+	err = row.Scan(&res)
+	if err != nil {
+		r.l.Error("BalanceRepository: scan rows error", zap.String("query", GetTransferredSum), zap.Int("accountID", accountID), zap.Error(err))
+		return 0, err
+	}
+	return res, nil
+}
+
+func (r *BalanceRepository) GetWithdrawnSum(ctx context.Context, accountID int, since time.Time) (float32, error) {
+	row, err := r.h.QueryRow(ctx, GetWithdrawnSum, accountID, since)
+	if err != nil {
+		r.l.Error("BalanceRepository: request error", zap.String("query", GetWithdrawnSum), zap.Int("accountID", accountID), zap.Error(err))
+		return 0, err
+	}
+	var res float32
+	err = row.Scan(&res)
+	if err != nil {
+		r.l.Error("BalanceRepository: scan rows error", zap.String("query", GetWithdrawnSum), zap.Int("accountID", accountID), zap.Error(err))
+		return 0, err
+	}
+	return res, nil
+}
+
+func (r *BalanceRepository) GetLastCreditTime(ctx context.Context, accountID int, minAmount float32) (time.Time, error) {
+	var res time.Time
+	row, err := r.h.QueryRow(ctx, GetLastCreditTime, accountID, minAmount)
+	if err != nil {
+		r.l.Error("BalanceRepository: request error", zap.String("query", GetLastCreditTime), zap.Int("accountID", accountID), zap.Error(err))
+		return res, err
+	}
+	err = row.Scan(&res)
+	if err != nil {
+		r.l.Error("BalanceRepository: scan rows error", zap.String("query", GetLastCreditTime), zap.Int("accountID", accountID), zap.Error(err))
+		return res, err
+	}
+	return res, nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestBalanceRepository_GetLastCreditTime(t *testing.T) {
+	ctx := context.Background()
+	initDatabase(ctx, postgresHandler)
+	users, _ := NewUserRepository(postgresHandler, Log)
+	target, _ := NewBalanceRepository(postgresHandler, Log)
+	userID, err := users.Save(ctx, "user", "pass")
+	require.NoError(t, err)
+	account, err := target.GetAccount(ctx, userID)
+	require.NoError(t, err)
+
+	accrualAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	tests := []struct {
+		name      string
+		operation model.Operation
+		want      time.Time
+	}{
+		{
+			name:      "BalanceRepository. GetLastCreditTime. Case #1. Large accrual",
+			operation: model.Operation{OperationType: model.OperationCredit, Amount: 1000, ProcessedAt: accrualAt},
+			want:      accrualAt,
+		},
+		{
+			name:      "BalanceRepository. GetLastCreditTime. Case #2. Small accrual is ignored",
+			operation: model.Operation{OperationType: model.OperationCredit, Amount: 10, ProcessedAt: accrualAt.Add(time.Minute)},
+			want:      accrualAt,
+		},
+		{
+			name: "BalanceRepository. GetLastCreditTime. Case #3. Adjustment is ignored",
+			operation: model.Operation{OperationType: model.OperationAdjustment, Amount: 1000, ProcessedAt: accrualAt.Add(2 * time.Minute),
+				Reason: model.AdjustmentReasonCompensation},
+			want: accrualAt,
+		},
+		{
+			name: "BalanceRepository. GetLastCreditTime. Case #4. Incoming transfer counts",
+			operation: model.Operation{OperationType: model.OperationCredit, Amount: 1000, ProcessedAt: accrualAt.Add(3 * time.Minute),
+				TransferRef: "ref"},
+			want: accrualAt.Add(3 * time.Minute),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.operation.AccountID = account.ID
+			require.NoError(t, target.CreateOperation(ctx, &tt.operation))
+			got, err := target.GetLastCreditTime(ctx, account.ID, 500)
+			require.NoError(t, err)
+			assert.True(t, tt.want.Equal(got), "expected %s, got %s", tt.want, got)
+		})
+	}
+}
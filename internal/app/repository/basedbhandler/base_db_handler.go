@@ -16,6 +16,39 @@ type DBHandler interface {
 
 type TransactionKey string
 
+// WithoutTransaction возвращает контекст, запросы в котором выполняются вне транзакции запроса, в режиме autocommit
+func WithoutTransaction(ctx context.Context) context.Context {
+	return context.WithValue(ctx, TransactionKey("tx"), nil)
+}
+
+type writerKey struct{}
+
+// writer - пользователь, изменения которого фиксирует транзакция запроса
+type writer struct {
+	id string
+}
+
+// WithWriter возвращает контекст, изменения в котором принадлежат пользователю id. Если пользователь станет известен
+// только при обработке запроса (регистрация, вход), id пустой, а пользователь указывается SetWriter
+func WithWriter(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, writerKey{}, &writer{id: id})
+}
+
+// SetWriter указывает пользователя, изменения которого фиксирует транзакция запроса. Без WithWriter ничего не делает
+func SetWriter(ctx context.Context, id string) {
+	if w, ok := ctx.Value(writerKey{}).(*writer); ok {
+		w.id = id
+	}
+}
+
+// WriterFromContext возвращает пользователя, изменения которого фиксирует транзакция запроса, или пустую строку
+func WriterFromContext(ctx context.Context) string {
+	if w, ok := ctx.Value(writerKey{}).(*writer); ok {
+		return w.id
+	}
+	return ""
+}
+
 type TransactionalDBHandler interface {
 	Execute(ctx context.Context, statement string, args ...interface{}) error
 	ExecuteBatch(ctx context.Context, statement string, args [][]interface{}) error
@@ -30,9 +63,30 @@ type Transactioner interface {
 	NewTx(ctx context.Context) (pgx.Tx, error)
 }
 
+//go:generate mockgen -destination=mocks/mock_rows.go -package=mocks . Rows
+
+// Rows - результат запроса. Строки нужно закрыть: до Close соединение занято и другие запросы транзакции
+// не выполняются. После того, как Next вернул false, ошибку чтения возвращает Err
 type Rows interface {
 	Scan(dest ...interface{}) error
 	Next() bool
+	Err() error
+	// Close можно вызывать повторно, в том числе после того, как Next вернул false
+	Close()
+	// Columns - имена столбцов результата в порядке Scan
+	Columns() []string
+}
+
+// ForEachRow вызывает fn для каждой строки rows и закрывает их. Строки читаются по одной, поэтому большой
+// результат не собирается в памяти. Ошибка fn прекращает чтение и возвращается
+func ForEachRow(rows Rows, fn func(rows Rows) error) error {
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
 }
 
 //go:generate mockgen -destination=mocks/mock_row.go -package=mocks . Row
@@ -0,0 +1,54 @@
+package basedbhandler_test
+
+import (
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
+	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestForEachRow(t *testing.T) {
+	readErr := errors.New("connection lost")
+	stopErr := errors.New("client gone")
+	tests := []struct {
+		name     string
+		rows     int
+		rowsErr  error
+		stopAt   int
+		wantRead int
+		wantErr  error
+	}{
+		{name: "ForEachRow. Case #1. All rows", rows: 3, wantRead: 3},
+		{name: "ForEachRow. Case #2. Read error after rows", rows: 2, rowsErr: readErr, wantRead: 2, wantErr: readErr},
+		{name: "ForEachRow. Case #3. fn stops reading", rows: 3, stopAt: 2, wantRead: 2, wantErr: stopErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			rows := mocks.NewMockRows(mockCtrl)
+			next := 0
+			rows.EXPECT().Next().DoAndReturn(func() bool {
+				next++
+				return next <= tt.rows
+			}).AnyTimes()
+			if tt.stopAt == 0 {
+				rows.EXPECT().Err().Return(tt.rowsErr)
+			}
+			rows.EXPECT().Close()
+
+			read := 0
+			err := basedbhandler.ForEachRow(rows, func(rows basedbhandler.Rows) error {
+				read++
+				if read == tt.stopAt {
+					return stopErr
+				}
+				return nil
+			})
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantRead, read)
+		})
+	}
+}
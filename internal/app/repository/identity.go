@@ -0,0 +1,8 @@
+package repository
+
+const CreateIdentity = "INSERT INTO user_identities \n" +
+	"(issuer, subject, user_id, email, created_at) \n" +
+	"VALUES($1, $2, $3, $4, $5);"
+
+const GetIdentity = "select issuer, subject, user_id, email, created_at \n" +
+	"from user_identities where issuer = $1 and subject = $2"
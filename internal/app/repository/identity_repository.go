@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
+	"go.uber.org/zap"
+)
+
+type IdentityRepository struct {
+	h basedbhandler.DBHandler
+	l *infrastructure.Logger
+}
+
+func NewIdentityRepository(dbHandler basedbhandler.DBHandler, log *infrastructure.Logger) (model.IdentityRepository, error) {
+	var target IdentityRepository
+	if dbHandler == nil {
+		return nil, errors.New("can't init identity repository")
+	}
+	target.h = dbHandler
+	target.l = log
+	return &target, nil
+}
+
+func (r *IdentityRepository) Get(ctx context.Context, issuer string, subject string) (*model.Identity, error) {
+	row, err := r.h.QueryRow(ctx, GetIdentity, issuer, subject)
+	if err != nil {
+		r.l.Error("IdentityRepository: can't get identity", zap.Error(err))
+		return nil, err
+	}
+	var res model.Identity
+	err = row.Scan(&res.Issuer, &res.Subject, &res.UserID, &res.Email, &res.CreatedAt)
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			return nil, &model.NoRowFound
+		}
+		r.l.Error("IdentityRepository: can't get identity", zap.Error(err))
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (r *IdentityRepository) Save(ctx context.Context, identity *model.Identity) error {
+	err := r.h.Execute(ctx, CreateIdentity,
+		identity.Issuer,
+		identity.Subject,
+		identity.UserID,
+		identity.Email,
+		identity.CreatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		if pgErr.Code == pgerrcode.UniqueViolation {
+			return &model.UniqueViolation
+		}
+	}
+	if err != nil {
+		r.l.Error("IdentityRepository: can't create identity", zap.Int("userID", identity.UserID), zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -2,24 +2,81 @@ package repository
 
 import (
 	"context"
-	"fmt"
-	"github.com/portnyagin/practicum_project/internal/app/database/ddl"
+	"github.com/jackc/pgx/v4"
+	"github.com/portnyagin/practicum_project/internal/app/database/migration"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
 	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
+	"go.uber.org/zap"
 )
 
-func InitDatabase(ctx context.Context, h basedbhandler.DBHandler) error {
-	err := h.Execute(ctx, ddl.CreateDatabaseStructure)
+// InitDatabase применяет к базе все миграции сборки
+func InitDatabase(ctx context.Context, h basedbhandler.TransactionalDBHandler, log *infrastructure.Logger) error {
+	migrator, err := newMigrator(h, log)
 	if err != nil {
 		return err
 	}
-	fmt.Println("Database structure created successfully")
+	count, err := migrator.Up(ctx, 0)
+	if err != nil {
+		return err
+	}
+	log.Info("Database structure is up to date", zap.Int("applied", count), zap.Int("version", migrator.Latest()))
 	return nil
 }
 
-func ClearDatabase(ctx context.Context, h basedbhandler.DBHandler) error {
-	err := h.Execute(ctx, ddl.ClearDatabaseStructure)
+// ClearDatabase откатывает все миграции сборки
+func ClearDatabase(ctx context.Context, h basedbhandler.TransactionalDBHandler, log *infrastructure.Logger) error {
+	migrator, err := newMigrator(h, log)
 	if err != nil {
 		return err
 	}
-	return nil
+	_, err = migrator.Down(ctx, 0)
+	return err
+}
+
+const getTables = "select table_name from information_schema.tables " +
+	"where table_schema = current_schema() and table_type = 'BASE TABLE' and table_name <> 'schema_migrations' order by table_name"
+
+// NonEmptyTables возвращает таблицы текущей схемы, в которых есть данные. Служебная таблица миграций не учитывается
+func NonEmptyTables(ctx context.Context, h basedbhandler.TransactionalDBHandler) ([]string, error) {
+	rows, err := h.Query(ctx, getTables)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err = rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	// соединение транзакции занято, пока строки не закрыты
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, table := range tables {
+		row, err := h.QueryRow(ctx, "select exists (select 1 from "+pgx.Identifier{table}.Sanitize()+")")
+		if err != nil {
+			return nil, err
+		}
+		var exists bool
+		if err = row.Scan(&exists); err != nil {
+			return nil, err
+		}
+		if exists {
+			res = append(res, table)
+		}
+	}
+	return res, nil
+}
+
+func newMigrator(h basedbhandler.TransactionalDBHandler, log *infrastructure.Logger) (*migration.Migrator, error) {
+	migrations, err := migration.Migrations()
+	if err != nil {
+		return nil, err
+	}
+	return migration.NewMigrator(h, migrations, log), nil
 }
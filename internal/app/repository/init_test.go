@@ -15,12 +15,12 @@ var postgresHandler *postgres.PostgresqlHandlerTX
 var Log *zap.Logger
 
 func initDatabase(ctx context.Context, h *postgres.PostgresqlHandlerTX) {
-	err := ClearDatabase(ctx, h)
+	err := ClearDatabase(ctx, h, Log)
 	if err != nil {
 		fmt.Println("can't clear database")
 		panic(err)
 	}
-	err = InitDatabase(ctx, h)
+	err = InitDatabase(ctx, h, Log)
 	if err != nil {
 		fmt.Println("can't init database")
 		panic(err)
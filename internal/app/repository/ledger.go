@@ -0,0 +1,37 @@
+package repository
+
+const CountAccounts = "select count(*) from accounts"
+
+const FindAccountDiscrepancies = "select id, user_id, balance, debit, credit, ledger_credit, ledger_debit, ledger_adjustment \n" +
+	"from ( \n" +
+	"select acc.id, acc.user_id, acc.balance, acc.debit, acc.credit, \n" +
+	"COALESCE(sum(op.amount) filter (where op.operation_type = 'CREDIT'), 0) as ledger_credit, \n" +
+	"COALESCE(sum(op.amount) filter (where op.operation_type = 'DEBIT'), 0) as ledger_debit, \n" +
+	"COALESCE(sum(op.amount) filter (where op.operation_type = 'ADJUSTMENT'), 0) as ledger_adjustment \n" +
+	"from accounts acc left join operations op on op.account_id = acc.id \n" +
+	"group by acc.id, acc.user_id, acc.balance, acc.debit, acc.credit \n" +
+	") totals \n" +
+	"where abs(credit - ledger_credit) >= $1 \n" +
+	"or abs(debit - ledger_debit) >= $1 \n" +
+	"or abs(balance - (ledger_credit - ledger_debit + ledger_adjustment)) >= $1 \n" +
+	"order by id"
+
+const GetLedgerTotals = "select \n" +
+	"COALESCE(sum(amount) filter (where operation_type = 'CREDIT'), 0), \n" +
+	"COALESCE(sum(amount) filter (where operation_type = 'DEBIT'), 0), \n" +
+	"COALESCE(sum(amount) filter (where operation_type = 'ADJUSTMENT'), 0) \n" +
+	"from operations where account_id = $1"
+
+const FindOrphanOperations = "select op.id, op.account_id, COALESCE(op.order_id, 0), op.order_num, op.operation_type, op.amount, op.processed_at \n" +
+	"from operations op \n" +
+	"left join accounts acc on acc.id = op.account_id \n" +
+	"left join orders ord on ord.id = op.order_id \n" +
+	"where acc.id is null \n" +
+	"or (op.order_id is not null and ord.id is null) \n" +
+	"order by op.id"
+
+const FindProcessedOrdersWithoutCredit = "select ord.id, ord.user_id, ord.num, ord.status, ord.upload_at, ord.updated_at \n" +
+	"from orders ord \n" +
+	"where ord.status = 'PROCESSED' \n" +
+	"and not exists (select 1 from operations op where op.order_id = ord.id and op.operation_type = 'CREDIT') \n" +
+	"order by ord.id"
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
+	"go.uber.org/zap"
+)
+
+type LedgerRepository struct {
+	h basedbhandler.DBHandler
+	l *infrastructure.Logger
+}
+
+func NewLedgerRepository(dbHandler basedbhandler.DBHandler, log *infrastructure.Logger) (model.LedgerRepository, error) {
+	var target LedgerRepository
+	if dbHandler == nil {
+		return nil, errors.New("can't init ledger repository")
+	}
+	target.h = dbHandler
+	target.l = log
+	return &target, nil
+}
+
+func (r *LedgerRepository) CountAccounts(ctx context.Context) (int, error) {
+	row, err := r.h.QueryRow(ctx, CountAccounts)
+	if err != nil {
+		r.l.Error("LedgerRepository: request error", zap.String("query", CountAccounts), zap.Error(err))
+		return 0, err
+	}
+	var res int
+	if err = row.Scan(&res); err != nil {
+		r.l.Error("LedgerRepository: scan rows error", zap.String("query", CountAccounts), zap.Error(err))
+		return 0, err
+	}
+	return res, nil
+}
+
+func (r *LedgerRepository) FindAccountDiscrepancies(ctx context.Context) ([]model.AccountDiscrepancy, error) {
+	rows, err := r.h.Query(ctx, FindAccountDiscrepancies, model.LedgerTolerance)
+	var resArray []model.AccountDiscrepancy
+	if err != nil {
+		r.l.Error("LedgerRepository: request error", zap.String("query", FindAccountDiscrepancies), zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var o model.AccountDiscrepancy
+		err := rows.Scan(&o.Account.ID, &o.Account.UserID, &o.Account.Balance, &o.Account.Debit, &o.Account.Credit,
+			&o.Ledger.Credit, &o.Ledger.Debit, &o.Ledger.Adjustment)
+		if err != nil {
+			r.l.Error("LedgerRepository: scan rows error", zap.String("query", FindAccountDiscrepancies), zap.Error(err))
+			return nil, err
+		}
+		resArray = append(resArray, o)
+	}
+	if err = rows.Err(); err != nil {
+		r.l.Error("LedgerRepository: read rows error", zap.String("query", FindAccountDiscrepancies), zap.Error(err))
+		return nil, err
+	}
+	return resArray, nil
+}
+
+func (r *LedgerRepository) GetLedgerTotals(ctx context.Context, accountID int) (*model.LedgerTotals, error) {
+	row, err := r.h.QueryRow(ctx, GetLedgerTotals, accountID)
+	if err != nil {
+		r.l.Error("LedgerRepository: request error", zap.String("query", GetLedgerTotals), zap.Int("accountID", accountID), zap.Error(err))
+		return nil, err
+	}
+	var res model.LedgerTotals
+	if err = row.Scan(&res.Credit, &res.Debit, &res.Adjustment); err != nil {
+		r.l.Error("LedgerRepository: scan rows error", zap.String("query", GetLedgerTotals), zap.Int("accountID", accountID), zap.Error(err))
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (r *LedgerRepository) FindOrphanOperations(ctx context.Context) ([]model.Operation, error) {
+	rows, err := r.h.Query(ctx, FindOrphanOperations)
+	var resArray []model.Operation
+	if err != nil {
+		r.l.Error("LedgerRepository: request error", zap.String("query", FindOrphanOperations), zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var o model.Operation
+		err := rows.Scan(&o.ID, &o.AccountID, &o.OrderID, &o.OrderNum, &o.OperationType, &o.Amount, &o.ProcessedAt)
+		if err != nil {
+			r.l.Error("LedgerRepository: scan rows error", zap.String("query", FindOrphanOperations), zap.Error(err))
+			return nil, err
+		}
+		resArray = append(resArray, o)
+	}
+	if err = rows.Err(); err != nil {
+		r.l.Error("LedgerRepository: read rows error", zap.String("query", FindOrphanOperations), zap.Error(err))
+		return nil, err
+	}
+	return resArray, nil
+}
+
+func (r *LedgerRepository) FindProcessedOrdersWithoutCredit(ctx context.Context) ([]model.Order, error) {
+	rows, err := r.h.Query(ctx, FindProcessedOrdersWithoutCredit)
+	var resArray []model.Order
+	if err != nil {
+		r.l.Error("LedgerRepository: request error", zap.String("query", FindProcessedOrdersWithoutCredit), zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var o model.Order
+		err := rows.Scan(&o.ID, &o.UserID, &o.Num, &o.Status, &o.UploadAt, &o.UpdatedAt)
+		if err != nil {
+			r.l.Error("LedgerRepository: scan rows error", zap.String("query", FindProcessedOrdersWithoutCredit), zap.Error(err))
+			return nil, err
+		}
+		resArray = append(resArray, o)
+	}
+	if err = rows.Err(); err != nil {
+		r.l.Error("LedgerRepository: read rows error", zap.String("query", FindProcessedOrdersWithoutCredit), zap.Error(err))
+		return nil, err
+	}
+	return resArray, nil
+}
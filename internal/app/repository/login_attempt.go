@@ -0,0 +1,13 @@
+package repository
+
+const GetLoginAttempt = "select key, failures, last_failure_at, locked_until from login_attempts where key = $1"
+
+const RegisterLoginFailure = "INSERT INTO login_attempts AS a (key, failures, last_failure_at) \n" +
+	"VALUES($1, 1, $2) \n" +
+	"ON CONFLICT (key) DO UPDATE \n" +
+	"SET failures = CASE WHEN a.last_failure_at < $3 THEN 1 ELSE a.failures + 1 END, last_failure_at = $2 \n" +
+	"returning key, failures, last_failure_at, locked_until;"
+
+const LockLoginAttempt = "UPDATE login_attempts SET locked_until = $2 WHERE key = $1;"
+
+const ResetLoginAttempt = "DELETE FROM login_attempts WHERE key = $1;"
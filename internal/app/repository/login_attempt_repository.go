@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
+	"go.uber.org/zap"
+	"time"
+)
+
+// LoginAttemptRepository пишет вне транзакции запроса: при неудачном входе транзакция откатывается,
+// а счетчик попыток должен сохраниться
+type LoginAttemptRepository struct {
+	h basedbhandler.DBHandler
+	l *infrastructure.Logger
+}
+
+func NewLoginAttemptRepository(dbHandler basedbhandler.DBHandler, log *infrastructure.Logger) (model.LoginAttemptRepository, error) {
+	var target LoginAttemptRepository
+	if dbHandler == nil {
+		return nil, errors.New("can't init login attempt repository")
+	}
+	target.h = dbHandler
+	target.l = log
+	return &target, nil
+}
+
+func (r *LoginAttemptRepository) Get(ctx context.Context, key string) (*model.LoginAttempt, error) {
+	row, err := r.h.QueryRow(basedbhandler.WithoutTransaction(ctx), GetLoginAttempt, key)
+	if err != nil {
+		r.l.Error("LoginAttemptRepository: can't get login attempt", zap.Error(err))
+		return nil, err
+	}
+	res, err := r.scan(row)
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			return nil, &model.NoRowFound
+		}
+		r.l.Error("LoginAttemptRepository: can't get login attempt", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *LoginAttemptRepository) RegisterFailure(ctx context.Context, key string, now time.Time, windowStart time.Time) (*model.LoginAttempt, error) {
+	row, err := r.h.QueryRow(basedbhandler.WithoutTransaction(ctx), RegisterLoginFailure, key, now, windowStart)
+	if err != nil {
+		r.l.Error("LoginAttemptRepository: can't register login failure", zap.Error(err))
+		return nil, err
+	}
+	res, err := r.scan(row)
+	if err != nil {
+		r.l.Error("LoginAttemptRepository: can't register login failure", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *LoginAttemptRepository) LockUntil(ctx context.Context, key string, until time.Time) error {
+	err := r.h.Execute(basedbhandler.WithoutTransaction(ctx), LockLoginAttempt, key, until)
+	if err != nil {
+		r.l.Error("LoginAttemptRepository: can't lock login", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *LoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	err := r.h.Execute(basedbhandler.WithoutTransaction(ctx), ResetLoginAttempt, key)
+	if err != nil {
+		r.l.Error("LoginAttemptRepository: can't reset login attempts", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *LoginAttemptRepository) scan(row basedbhandler.Row) (*model.LoginAttempt, error) {
+	var (
+		res         model.LoginAttempt
+		lockedUntil *time.Time
+	)
+	if err := row.Scan(&res.Key, &res.Failures, &res.LastFailureAt, &lockedUntil); err != nil {
+		return nil, err
+	}
+	if lockedUntil != nil {
+		res.LockedUntil = *lockedUntil
+	}
+	return &res, nil
+}
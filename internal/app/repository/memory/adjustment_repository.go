@@ -0,0 +1,154 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"go.uber.org/zap"
+	"sort"
+)
+
+type AdjustmentRepository struct {
+	s *Storage
+	l *infrastructure.Logger
+}
+
+func NewAdjustmentRepository(storage *Storage, log *infrastructure.Logger) (model.AdjustmentRepository, error) {
+	var target AdjustmentRepository
+	if storage == nil {
+		return nil, errors.New("can't init adjustment repository")
+	}
+	target.s = storage
+	target.l = log
+	return &target, nil
+}
+
+// Save сохраняет новую корректировку и заполняет ее ID
+func (r *AdjustmentRepository) Save(ctx context.Context, adjustment *model.Adjustment) error {
+	err := r.s.write(ctx, func(tx *Tx) error {
+		s := tx.s
+		if err := s.checkUser(adjustment.UserID, "adjustments_user_fk"); err != nil {
+			return err
+		}
+		if !isAdjustmentStatus(adjustment.Status) {
+			return checkViolation("adjustment_status_check")
+		}
+		id := s.nextID("adjustments")
+		s.adjustments[id] = &model.Adjustment{
+			ID:          id,
+			UserID:      adjustment.UserID,
+			Amount:      adjustment.Amount,
+			ReasonCode:  adjustment.ReasonCode,
+			Comment:     adjustment.Comment,
+			RequestedBy: adjustment.RequestedBy,
+			Status:      adjustment.Status,
+			CreatedAt:   adjustment.CreatedAt,
+			DecidedAt:   epoch,
+		}
+		tx.onRollback(func() {
+			delete(s.adjustments, id)
+		})
+		adjustment.ID = id
+		return nil
+	})
+	if err != nil {
+		r.l.Error("AdjustmentRepository: can't create adjustment", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *AdjustmentRepository) Lock(ctx context.Context, adjustmentID int) (*model.Adjustment, error) {
+	var res model.Adjustment
+	err := r.s.write(ctx, func(tx *Tx) error {
+		s := tx.s
+		if _, ok := s.adjustments[adjustmentID]; !ok {
+			return &model.NoRowFound
+		}
+		if err := s.lockRow(ctx, tx, "adjustments", adjustmentID); err != nil {
+			return err
+		}
+		a, ok := s.adjustments[adjustmentID]
+		if !ok {
+			return &model.NoRowFound
+		}
+		res = *a
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, &model.NoRowFound) {
+			r.l.Error("AdjustmentRepository: can't get adjustment for update", zap.Error(err))
+		}
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Update сохраняет решение по корректировке: статус, подтвердившего администратора, операцию и время решения
+func (r *AdjustmentRepository) Update(ctx context.Context, adjustment *model.Adjustment) error {
+	err := r.s.write(ctx, func(tx *Tx) error {
+		s := tx.s
+		if _, ok := s.adjustments[adjustment.ID]; !ok {
+			return nil
+		}
+		if !isAdjustmentStatus(adjustment.Status) {
+			return checkViolation("adjustment_status_check")
+		}
+		if _, ok := s.operations[adjustment.OperationID]; adjustment.OperationID != 0 && !ok {
+			return foreignKeyViolation("adjustments_operation_fk")
+		}
+		if err := s.lockRow(ctx, tx, "adjustments", adjustment.ID); err != nil {
+			return err
+		}
+		a, ok := s.adjustments[adjustment.ID]
+		if !ok {
+			return nil
+		}
+		old := *a
+		a.Status = adjustment.Status
+		a.ApprovedBy = adjustment.ApprovedBy
+		a.OperationID = adjustment.OperationID
+		a.DecidedAt = adjustment.DecidedAt
+		tx.onRollback(func() {
+			*a = old
+		})
+		return nil
+	})
+	if err != nil {
+		r.l.Error("AdjustmentRepository: can't update adjustment", zap.Int("adjustmentID", adjustment.ID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *AdjustmentRepository) FindByStatus(ctx context.Context, status string) ([]model.Adjustment, error) {
+	var resArray []model.Adjustment
+	err := r.s.read(ctx, func(tx *Tx) error {
+		for _, a := range tx.s.adjustments {
+			if a.Status == status {
+				resArray = append(resArray, *a)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		r.l.Error("AdjustmentRepository: request error", zap.String("status", status), zap.Error(err))
+		return nil, err
+	}
+	sort.Slice(resArray, func(i, j int) bool {
+		if !resArray[i].CreatedAt.Equal(resArray[j].CreatedAt) {
+			return resArray[i].CreatedAt.Before(resArray[j].CreatedAt)
+		}
+		return resArray[i].ID < resArray[j].ID
+	})
+	return resArray, nil
+}
+
+func isAdjustmentStatus(status string) bool {
+	switch status {
+	case model.AdjustmentStatusPending, model.AdjustmentStatusApplied, model.AdjustmentStatusRejected:
+		return true
+	}
+	return false
+}
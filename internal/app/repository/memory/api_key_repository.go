@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"sort"
+	"time"
+)
+
+type APIKeyRepository struct {
+	s *Storage
+	l *infrastructure.Logger
+}
+
+func NewAPIKeyRepository(storage *Storage, log *infrastructure.Logger) (model.APIKeyRepository, error) {
+	var target APIKeyRepository
+	if storage == nil {
+		return nil, errors.New("can't init api key repository")
+	}
+	target.s = storage
+	target.l = log
+	return &target, nil
+}
+
+func (r *APIKeyRepository) Save(ctx context.Context, key *model.APIKey) error {
+	return r.s.write(ctx, func(tx *Tx) error {
+		s := tx.s
+		if err := s.checkUser(key.UserID, "api_keys_user_fk"); err != nil {
+			return err
+		}
+		if _, ok := s.apiKeys[key.ID]; ok {
+			return uniqueViolation("api_keys_pkey")
+		}
+		s.apiKeys[key.ID] = &model.APIKey{
+			ID:        key.ID,
+			UserID:    key.UserID,
+			Name:      key.Name,
+			KeyHash:   key.KeyHash,
+			Scopes:    append([]string(nil), key.Scopes...),
+			CreatedAt: key.CreatedAt,
+		}
+		tx.onRollback(func() {
+			delete(s.apiKeys, key.ID)
+		})
+		return nil
+	})
+}
+
+func (r *APIKeyRepository) GetActive(ctx context.Context, keyID string) (*model.APIKey, error) {
+	var res model.APIKey
+	err := r.s.read(ctx, func(tx *Tx) error {
+		s := tx.s
+		k, ok := s.apiKeys[keyID]
+		if !ok || !k.RevokedAt.IsZero() {
+			return &model.NoRowFound
+		}
+		u, ok := s.users[k.UserID]
+		if !ok || !u.active {
+			return &model.NoRowFound
+		}
+		res = *k
+		res.Login = u.Login
+		res.Scopes = append([]string(nil), k.Scopes...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListByUser возвращает ключи пользователя, в том числе отозванные, без хеша секрета
+func (r *APIKeyRepository) ListByUser(ctx context.Context, userID int) ([]model.APIKey, error) {
+	var resArray []model.APIKey
+	err := r.s.read(ctx, func(tx *Tx) error {
+		for _, k := range tx.s.apiKeys {
+			if k.UserID != userID {
+				continue
+			}
+			resArray = append(resArray, model.APIKey{
+				ID:        k.ID,
+				UserID:    k.UserID,
+				Name:      k.Name,
+				Scopes:    append([]string(nil), k.Scopes...),
+				CreatedAt: k.CreatedAt,
+				RevokedAt: k.RevokedAt,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(resArray, func(i, j int) bool {
+		return resArray[i].CreatedAt.Before(resArray[j].CreatedAt)
+	})
+	return resArray, nil
+}
+
+func (r *APIKeyRepository) Revoke(ctx context.Context, userID int, keyID string, now time.Time) error {
+	return r.s.write(ctx, func(tx *Tx) error {
+		s := tx.s
+		if k, ok := s.apiKeys[keyID]; !ok || k.UserID != userID || !k.RevokedAt.IsZero() {
+			return &model.NoRowFound
+		}
+		if err := s.lockRow(ctx, tx, "api_keys", keyID); err != nil {
+			return err
+		}
+		k, ok := s.apiKeys[keyID]
+		if !ok || k.UserID != userID || !k.RevokedAt.IsZero() {
+			return &model.NoRowFound
+		}
+		k.RevokedAt = now
+		tx.onRollback(func() {
+			k.RevokedAt = time.Time{}
+		})
+		return nil
+	})
+}
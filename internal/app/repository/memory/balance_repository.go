@@ -0,0 +1,351 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"go.uber.org/zap"
+	"time"
+)
+
+type BalanceRepository struct {
+	s *Storage
+	l *infrastructure.Logger
+}
+
+func NewBalanceRepository(storage *Storage, log *infrastructure.Logger) (model.BalanceRepository, error) {
+	var target BalanceRepository
+	if storage == nil {
+		return nil, errors.New("can't init balance repository")
+	}
+	target.s = storage
+	target.l = log
+	return &target, nil
+}
+
+// FindWithdrawalByUser возвращает списания пользователя в оплату заказов. OrderTotal - сумма списаний по заказу
+// нарастающим итогом
+func (r *BalanceRepository) FindWithdrawalByUser(ctx context.Context, userID int) ([]model.Withdrawal, error) {
+	var resArray []model.Withdrawal
+	err := r.s.read(ctx, func(tx *Tx) error {
+		s := tx.s
+		accountID, ok := s.accountUsers[userID]
+		if !ok {
+			return nil
+		}
+		totals := make(map[string]float32)
+		for _, op := range s.sortedOperations(func(op *model.Operation) bool {
+			return op.AccountID == accountID && isWithdrawal(op)
+		}) {
+			totals[op.OrderNum] += op.Amount
+			resArray = append(resArray, model.Withdrawal{
+				OrderNum:    op.OrderNum,
+				Amount:      op.Amount,
+				Status:      model.OrderStatusProcessed,
+				ProcessedAt: op.ProcessedAt,
+				Part:        orderPartOrFirst(op.OrderPart),
+				OrderTotal:  totals[op.OrderNum],
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		r.l.Error("BalanceRepository: request error", zap.Int("userID", userID), zap.Error(err))
+		return nil, err
+	}
+	return resArray, nil
+}
+
+func (r *BalanceRepository) FindWithdrawalByOrder(ctx context.Context, orderNum string) ([]model.Operation, error) {
+	var resArray []model.Operation
+	err := r.s.read(ctx, func(tx *Tx) error {
+		for _, op := range tx.s.sortedOperations(func(op *model.Operation) bool {
+			return op.OrderNum == orderNum && isWithdrawal(op)
+		}) {
+			resArray = append(resArray, model.Operation{
+				ID:            op.ID,
+				AccountID:     op.AccountID,
+				OrderNum:      op.OrderNum,
+				OrderPart:     orderPartOrFirst(op.OrderPart),
+				OperationType: model.OperationDebit,
+				Amount:        op.Amount,
+				ProcessedAt:   op.ProcessedAt,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		r.l.Error("BalanceRepository: request error", zap.String("orderNum", orderNum), zap.Error(err))
+		return nil, err
+	}
+	return resArray, nil
+}
+
+// FindStatementByUser возвращает все операции по счету пользователя. Для перевода Counterparty - логин владельца
+// второго счета перевода
+func (r *BalanceRepository) FindStatementByUser(ctx context.Context, userID int) ([]model.StatementEntry, error) {
+	var resArray []model.StatementEntry
+	err := r.s.read(ctx, func(tx *Tx) error {
+		s := tx.s
+		accountID, ok := s.accountUsers[userID]
+		if !ok {
+			return nil
+		}
+		for _, op := range s.sortedOperations(func(op *model.Operation) bool {
+			return op.AccountID == accountID
+		}) {
+			entry := model.StatementEntry{
+				OperationType: op.OperationType,
+				Amount:        op.Amount,
+				OrderNum:      op.OrderNum,
+				TransferRef:   op.TransferRef,
+				Reason:        op.Reason,
+				ProcessedAt:   op.ProcessedAt,
+			}
+			counterparts := s.counterparts(op)
+			if len(counterparts) == 0 {
+				resArray = append(resArray, entry)
+			}
+			for _, login := range counterparts {
+				entry.Counterparty = login
+				resArray = append(resArray, entry)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		r.l.Error("BalanceRepository: request error", zap.Int("userID", userID), zap.Error(err))
+		return nil, err
+	}
+	return resArray, nil
+}
+
+// StreamStatementByUser читает выписку целиком и передает ее fn вне блокировки хранилища:
+// fn может долго писать ответ клиенту
+func (r *BalanceRepository) StreamStatementByUser(ctx context.Context, userID int, fn func(entry *model.StatementEntry) error) error {
+	statement, err := r.FindStatementByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for i := range statement {
+		if err = fn(&statement[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// counterparts возвращает логины владельцев второй стороны перевода
+func (s *Storage) counterparts(op *model.Operation) []string {
+	if op.TransferRef == "" {
+		return nil
+	}
+	var res []string
+	for _, cp := range s.sortedOperations(func(cp *model.Operation) bool {
+		return cp.TransferRef == op.TransferRef && cp.ID != op.ID
+	}) {
+		login := ""
+		if acc, ok := s.accounts[cp.AccountID]; ok {
+			if u, ok := s.users[acc.UserID]; ok {
+				login = u.Login
+			}
+		}
+		res = append(res, login)
+	}
+	return res
+}
+
+func (r *BalanceRepository) LockAccount(ctx context.Context, userID int) (*model.Account, error) {
+	var account model.Account
+	err := r.s.write(ctx, func(tx *Tx) error {
+		s := tx.s
+		accountID, ok := s.accountUsers[userID]
+		if !ok {
+			return &model.NoRowFound
+		}
+		if err := s.lockRow(ctx, tx, "accounts", accountID); err != nil {
+			return err
+		}
+		acc, ok := s.accounts[accountID]
+		if !ok {
+			return &model.NoRowFound
+		}
+		account = *acc
+		return nil
+	})
+	if err != nil {
+		r.l.Error("BalanceRepository: cannt get account for update", zap.Error(err))
+		return nil, err
+	}
+	return &account, nil
+}
+
+// SaveAccount сохраняет остаток и агрегаты счета пользователя. Отрицательные значения нарушают account_balance_check
+func (r *BalanceRepository) SaveAccount(ctx context.Context, account *model.Account) error {
+	err := r.s.write(ctx, func(tx *Tx) error {
+		s := tx.s
+		accountID, ok := s.accountUsers[account.UserID]
+		if !ok {
+			return nil
+		}
+		if account.Balance < 0 || account.Debit < 0 || account.Credit < 0 {
+			return checkViolation("account_balance_check")
+		}
+		if err := s.lockRow(ctx, tx, "accounts", accountID); err != nil {
+			return err
+		}
+		acc, ok := s.accounts[accountID]
+		if !ok {
+			return nil
+		}
+		old := *acc
+		acc.Balance = account.Balance
+		acc.Debit = account.Debit
+		acc.Credit = account.Credit
+		tx.onRollback(func() {
+			*acc = old
+		})
+		return nil
+	})
+	if err != nil {
+		r.l.Error("BalanceRepository: cannt save account", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// CreateOperation сохраняет операцию и заполняет ее ID. Повторное списание той же части заказа - UniqueViolation
+func (r *BalanceRepository) CreateOperation(ctx context.Context, operation *model.Operation) error {
+	err := r.s.write(ctx, func(tx *Tx) error {
+		s := tx.s
+		if _, ok := s.accounts[operation.AccountID]; !ok {
+			return foreignKeyViolation("operations_account_fk")
+		}
+		if _, ok := s.orders[operation.OrderID]; operation.OrderID != 0 && !ok {
+			return foreignKeyViolation("operations_order_fk")
+		}
+		switch operation.OperationType {
+		case model.OperationDebit, model.OperationCredit:
+			if operation.Amount < 0 {
+				return checkViolation("operation_amount_check")
+			}
+		case model.OperationAdjustment:
+			if operation.Amount == 0 {
+				return checkViolation("operation_amount_check")
+			}
+		default:
+			return checkViolation("operation_type_check")
+		}
+		part := orderPart{orderNum: operation.OrderNum, part: operation.OrderPart}
+		partIndexed := operation.OperationType == model.OperationDebit && operation.OrderPart != 0
+		if _, ok := s.orderParts[part]; partIndexed && ok {
+			return &model.UniqueViolation
+		}
+		id := s.nextID("operations")
+		op := *operation
+		op.ID = id
+		s.operations[id] = &op
+		if partIndexed {
+			s.orderParts[part] = id
+		}
+		tx.onRollback(func() {
+			delete(s.operations, id)
+			if partIndexed {
+				delete(s.orderParts, part)
+			}
+		})
+		operation.ID = id
+		return nil
+	})
+	if errors.Is(err, &model.UniqueViolation) {
+		r.l.Info("BalanceRepository: operation already exists", zap.String("orderNum", operation.OrderNum))
+		return err
+	}
+	if err != nil {
+		r.l.Error("BalanceRepository: cannt create operation", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *BalanceRepository) GetAccount(ctx context.Context, userID int) (*model.Account, error) {
+	var account model.Account
+	err := r.s.read(ctx, func(tx *Tx) error {
+		accountID, ok := tx.s.accountUsers[userID]
+		if !ok {
+			return &model.NoRowFound
+		}
+		account = *tx.s.accounts[accountID]
+		return nil
+	})
+	if err != nil {
+		r.l.Error("BalanceRepository: cannt get account", zap.Error(err))
+		return nil, err
+	}
+	return &account, nil
+}
+
+// GetTransferredSum - сумма переводов со счета начиная с since
+func (r *BalanceRepository) GetTransferredSum(ctx context.Context, accountID int, since time.Time) (float32, error) {
+	return r.sum(ctx, func(op *model.Operation) bool {
+		return op.AccountID == accountID && op.OperationType == model.OperationDebit && op.TransferRef != "" &&
+			!op.ProcessedAt.Before(since)
+	})
+}
+
+// GetWithdrawnSum - сумма списаний в оплату заказов со счета начиная с since
+func (r *BalanceRepository) GetWithdrawnSum(ctx context.Context, accountID int, since time.Time) (float32, error) {
+	return r.sum(ctx, func(op *model.Operation) bool {
+		return op.AccountID == accountID && isWithdrawal(op) && !op.ProcessedAt.Before(since)
+	})
+}
+
+func (r *BalanceRepository) sum(ctx context.Context, filter func(op *model.Operation) bool) (float32, error) {
+	var res float64
+	err := r.s.read(ctx, func(tx *Tx) error {
+		for _, op := range tx.s.operations {
+			if filter(op) {
+				res += float64(op.Amount)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		r.l.Error("BalanceRepository: request error", zap.Error(err))
+		return 0, err
+	}
+	return float32(res), nil
+}
+
+// GetLastCreditTime - время последнего зачисления (начисления за заказ или входящего перевода) не меньше minAmount,
+// если зачислений не было - начало эпохи. Корректировки ADJUSTMENT не учитываются
+func (r *BalanceRepository) GetLastCreditTime(ctx context.Context, accountID int, minAmount float32) (time.Time, error) {
+	res := epoch
+	err := r.s.read(ctx, func(tx *Tx) error {
+		for _, op := range tx.s.operations {
+			if op.AccountID == accountID && op.OperationType == model.OperationCredit && op.Amount >= minAmount &&
+				op.ProcessedAt.After(res) {
+				res = op.ProcessedAt
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		r.l.Error("BalanceRepository: request error", zap.Int("accountID", accountID), zap.Error(err))
+		return res, err
+	}
+	return res, nil
+}
+
+// isWithdrawal - списание в оплату заказа, а не перевод и не корректирующая операция
+func isWithdrawal(op *model.Operation) bool {
+	return op.OperationType == model.OperationDebit && op.TransferRef == "" && op.Reason == ""
+}
+
+func orderPartOrFirst(part int) int {
+	if part == 0 {
+		return 1
+	}
+	return part
+}
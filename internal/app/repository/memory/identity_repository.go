@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+)
+
+type IdentityRepository struct {
+	s *Storage
+	l *infrastructure.Logger
+}
+
+func NewIdentityRepository(storage *Storage, log *infrastructure.Logger) (model.IdentityRepository, error) {
+	var target IdentityRepository
+	if storage == nil {
+		return nil, errors.New("can't init identity repository")
+	}
+	target.s = storage
+	target.l = log
+	return &target, nil
+}
+
+func (r *IdentityRepository) Get(ctx context.Context, issuer string, subject string) (*model.Identity, error) {
+	var res model.Identity
+	err := r.s.read(ctx, func(tx *Tx) error {
+		identity, ok := tx.s.identities[identityKey{issuer: issuer, subject: subject}]
+		if !ok {
+			return &model.NoRowFound
+		}
+		res = *identity
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Save привязывает учетную запись провайдера. Уже привязанная учетная запись - UniqueViolation
+func (r *IdentityRepository) Save(ctx context.Context, identity *model.Identity) error {
+	return r.s.write(ctx, func(tx *Tx) error {
+		s := tx.s
+		if err := s.checkUser(identity.UserID, "user_identities_user_fk"); err != nil {
+			return err
+		}
+		key := identityKey{issuer: identity.Issuer, subject: identity.Subject}
+		if _, ok := s.identities[key]; ok {
+			return &model.UniqueViolation
+		}
+		saved := *identity
+		s.identities[key] = &saved
+		tx.onRollback(func() {
+			delete(s.identities, key)
+		})
+		return nil
+	})
+}
@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"go.uber.org/zap"
+	"sort"
+)
+
+type LedgerRepository struct {
+	s *Storage
+	l *infrastructure.Logger
+}
+
+func NewLedgerRepository(storage *Storage, log *infrastructure.Logger) (model.LedgerRepository, error) {
+	var target LedgerRepository
+	if storage == nil {
+		return nil, errors.New("can't init ledger repository")
+	}
+	target.s = storage
+	target.l = log
+	return &target, nil
+}
+
+func (r *LedgerRepository) CountAccounts(ctx context.Context) (int, error) {
+	var res int
+	err := r.s.read(ctx, func(tx *Tx) error {
+		res = len(tx.s.accounts)
+		return nil
+	})
+	if err != nil {
+		r.l.Error("LedgerRepository: request error", zap.Error(err))
+		return 0, err
+	}
+	return res, nil
+}
+
+// FindAccountDiscrepancies возвращает счета, у которых balance, debit или credit расходятся с суммами операций
+// больше чем на model.LedgerTolerance
+func (r *LedgerRepository) FindAccountDiscrepancies(ctx context.Context) ([]model.AccountDiscrepancy, error) {
+	var resArray []model.AccountDiscrepancy
+	err := r.s.read(ctx, func(tx *Tx) error {
+		s := tx.s
+		totals := make(map[int]*model.LedgerTotals)
+		for id := range s.accounts {
+			totals[id] = &model.LedgerTotals{}
+		}
+		for _, op := range s.operations {
+			if t, ok := totals[op.AccountID]; ok {
+				addToTotals(t, op)
+			}
+		}
+		for id, acc := range s.accounts {
+			t := totals[id]
+			if model.LedgerMismatch(acc.Credit, t.Credit) || model.LedgerMismatch(acc.Debit, t.Debit) ||
+				model.LedgerMismatch(acc.Balance, t.Credit-t.Debit+t.Adjustment) {
+				resArray = append(resArray, model.AccountDiscrepancy{Account: *acc, Ledger: *t})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		r.l.Error("LedgerRepository: request error", zap.Error(err))
+		return nil, err
+	}
+	sort.Slice(resArray, func(i, j int) bool {
+		return resArray[i].Account.ID < resArray[j].Account.ID
+	})
+	return resArray, nil
+}
+
+func (r *LedgerRepository) GetLedgerTotals(ctx context.Context, accountID int) (*model.LedgerTotals, error) {
+	var res model.LedgerTotals
+	err := r.s.read(ctx, func(tx *Tx) error {
+		for _, op := range tx.s.operations {
+			if op.AccountID == accountID {
+				addToTotals(&res, op)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		r.l.Error("LedgerRepository: request error", zap.Int("accountID", accountID), zap.Error(err))
+		return nil, err
+	}
+	return &res, nil
+}
+
+// FindOrphanOperations возвращает операции, счет или заказ которых не существует
+func (r *LedgerRepository) FindOrphanOperations(ctx context.Context) ([]model.Operation, error) {
+	var resArray []model.Operation
+	err := r.s.read(ctx, func(tx *Tx) error {
+		s := tx.s
+		for _, op := range s.operations {
+			_, accountFound := s.accounts[op.AccountID]
+			_, orderFound := s.orders[op.OrderID]
+			if !accountFound || (op.OrderID != 0 && !orderFound) {
+				resArray = append(resArray, model.Operation{
+					ID:            op.ID,
+					AccountID:     op.AccountID,
+					OrderID:       op.OrderID,
+					OrderNum:      op.OrderNum,
+					OperationType: op.OperationType,
+					Amount:        op.Amount,
+					ProcessedAt:   op.ProcessedAt,
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		r.l.Error("LedgerRepository: request error", zap.Error(err))
+		return nil, err
+	}
+	sort.Slice(resArray, func(i, j int) bool {
+		return resArray[i].ID < resArray[j].ID
+	})
+	return resArray, nil
+}
+
+// FindProcessedOrdersWithoutCredit возвращает обработанные заказы, по которым нет начисления
+func (r *LedgerRepository) FindProcessedOrdersWithoutCredit(ctx context.Context) ([]model.Order, error) {
+	var resArray []model.Order
+	err := r.s.read(ctx, func(tx *Tx) error {
+		s := tx.s
+		credited := make(map[int]bool)
+		for _, op := range s.operations {
+			if op.OperationType == model.OperationCredit {
+				credited[op.OrderID] = true
+			}
+		}
+		for _, o := range s.orders {
+			if o.Status == model.OrderStatusProcessed && !credited[o.ID] {
+				resArray = append(resArray, *o)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		r.l.Error("LedgerRepository: request error", zap.Error(err))
+		return nil, err
+	}
+	sort.Slice(resArray, func(i, j int) bool {
+		return resArray[i].ID < resArray[j].ID
+	})
+	return resArray, nil
+}
+
+func addToTotals(t *model.LedgerTotals, op *model.Operation) {
+	switch op.OperationType {
+	case model.OperationCredit:
+		t.Credit += op.Amount
+	case model.OperationDebit:
+		t.Debit += op.Amount
+	case model.OperationAdjustment:
+		t.Adjustment += op.Amount
+	}
+}
@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
+	"time"
+)
+
+// LoginAttemptRepository пишет вне транзакции запроса: при неудачном входе транзакция откатывается,
+// а счетчик попыток должен сохраниться
+type LoginAttemptRepository struct {
+	s *Storage
+	l *infrastructure.Logger
+}
+
+func NewLoginAttemptRepository(storage *Storage, log *infrastructure.Logger) (model.LoginAttemptRepository, error) {
+	var target LoginAttemptRepository
+	if storage == nil {
+		return nil, errors.New("can't init login attempt repository")
+	}
+	target.s = storage
+	target.l = log
+	return &target, nil
+}
+
+func (r *LoginAttemptRepository) Get(ctx context.Context, key string) (*model.LoginAttempt, error) {
+	var res model.LoginAttempt
+	err := r.s.read(basedbhandler.WithoutTransaction(ctx), func(tx *Tx) error {
+		a, ok := tx.s.loginAttempts[key]
+		if !ok {
+			return &model.NoRowFound
+		}
+		res = *a
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (r *LoginAttemptRepository) RegisterFailure(ctx context.Context, key string, now time.Time, windowStart time.Time) (*model.LoginAttempt, error) {
+	var res model.LoginAttempt
+	err := r.s.write(basedbhandler.WithoutTransaction(ctx), func(tx *Tx) error {
+		s := tx.s
+		if err := s.lockRow(ctx, tx, "login_attempts", key); err != nil {
+			return err
+		}
+		a, ok := s.loginAttempts[key]
+		if !ok {
+			a = &model.LoginAttempt{Key: key}
+			s.loginAttempts[key] = a
+		}
+		if a.LastFailureAt.Before(windowStart) {
+			a.Failures = 0
+		}
+		a.Failures++
+		a.LastFailureAt = now
+		res = *a
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (r *LoginAttemptRepository) LockUntil(ctx context.Context, key string, until time.Time) error {
+	return r.s.write(basedbhandler.WithoutTransaction(ctx), func(tx *Tx) error {
+		s := tx.s
+		if err := s.lockRow(ctx, tx, "login_attempts", key); err != nil {
+			return err
+		}
+		if a, ok := s.loginAttempts[key]; ok {
+			a.LockedUntil = until
+		}
+		return nil
+	})
+}
+
+func (r *LoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	return r.s.write(basedbhandler.WithoutTransaction(ctx), func(tx *Tx) error {
+		s := tx.s
+		if err := s.lockRow(ctx, tx, "login_attempts", key); err != nil {
+			return err
+		}
+		delete(s.loginAttempts, key)
+		return nil
+	})
+}
@@ -0,0 +1,219 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"go.uber.org/zap"
+	"sort"
+)
+
+// notProcessedLimit - число заказов, которое FindNotProcessed возвращает за раз
+const notProcessedLimit = 20
+
+type OrderRepository struct {
+	s *Storage
+	l *infrastructure.Logger
+}
+
+func NewOrderRepository(storage *Storage, log *infrastructure.Logger) (model.OrderRepository, error) {
+	var target OrderRepository
+	if storage == nil {
+		return nil, errors.New("can't init order repository")
+	}
+	target.s = storage
+	target.l = log
+	return &target, nil
+}
+
+// Save сохраняет заказ. Занятый номер заказа - UniqueViolation
+func (r *OrderRepository) Save(ctx context.Context, order *model.Order) error {
+	return r.s.write(ctx, func(tx *Tx) error {
+		s := tx.s
+		if err := s.checkUser(order.UserID, "orders_user_fk"); err != nil {
+			return err
+		}
+		if !isOrderStatus(order.Status) {
+			return checkViolation("order_status_check")
+		}
+		if _, ok := s.orderNums[order.Num]; ok {
+			return &model.UniqueViolation
+		}
+		id := s.nextID("orders")
+		s.orders[id] = &model.Order{
+			ID:        id,
+			UserID:    order.UserID,
+			Num:       order.Num,
+			Status:    order.Status,
+			UploadAt:  order.UploadAt,
+			UpdatedAt: order.UpdatedAt,
+		}
+		s.orderNums[order.Num] = id
+		tx.onRollback(func() {
+			delete(s.orders, id)
+			delete(s.orderNums, order.Num)
+		})
+		return nil
+	})
+}
+
+func (r *OrderRepository) GetByID(ctx context.Context, orderID int) (*model.Order, error) {
+	var res model.Order
+	err := r.s.read(ctx, func(tx *Tx) error {
+		o, ok := tx.s.orders[orderID]
+		if !ok {
+			return &model.NoRowFound
+		}
+		res = *o
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (r *OrderRepository) GetByNum(ctx context.Context, num string) (*model.Order, error) {
+	var res model.Order
+	err := r.s.read(ctx, func(tx *Tx) error {
+		id, ok := tx.s.orderNums[num]
+		if !ok {
+			return &model.NoRowFound
+		}
+		res = *tx.s.orders[id]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// UpdateStatus меняет статус и время изменения заказа, если статус действительно изменился
+func (r *OrderRepository) UpdateStatus(ctx context.Context, order *model.Order) error {
+	return r.s.write(ctx, func(tx *Tx) error {
+		s := tx.s
+		if o, ok := s.orders[order.ID]; !ok || o.Status == order.Status {
+			return nil
+		}
+		if !isOrderStatus(order.Status) {
+			return checkViolation("order_status_check")
+		}
+		if err := s.lockRow(ctx, tx, "orders", order.ID); err != nil {
+			return err
+		}
+		o, ok := s.orders[order.ID]
+		if !ok || o.Status == order.Status {
+			return nil
+		}
+		old := *o
+		o.Status = order.Status
+		o.UpdatedAt = order.UpdatedAt
+		tx.onRollback(func() {
+			*o = old
+		})
+		return nil
+	})
+}
+
+// FindByUser возвращает заказы пользователя в порядке загрузки. Accrual - сумма начисления по заказу
+func (r *OrderRepository) FindByUser(ctx context.Context, userID int) ([]model.Order, error) {
+	var resArray []model.Order
+	err := r.s.read(ctx, func(tx *Tx) error {
+		s := tx.s
+		var orders []*model.Order
+		for _, o := range s.orders {
+			if o.UserID == userID {
+				orders = append(orders, o)
+			}
+		}
+		sort.Slice(orders, func(i, j int) bool {
+			if !orders[i].UploadAt.Equal(orders[j].UploadAt) {
+				return orders[i].UploadAt.Before(orders[j].UploadAt)
+			}
+			return orders[i].ID < orders[j].ID
+		})
+		for _, o := range orders {
+			credits := s.sortedOperations(func(op *model.Operation) bool {
+				return op.OrderID == o.ID && op.OperationType == model.OperationCredit
+			})
+			if len(credits) == 0 {
+				resArray = append(resArray, *o)
+			}
+			// как и left join, каждое начисление по заказу дает отдельную строку
+			for _, op := range credits {
+				res := *o
+				res.Accrual = op.Amount
+				resArray = append(resArray, res)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		r.l.Error("OrderRepository: request error", zap.Int("userID", userID), zap.Error(err))
+		return nil, err
+	}
+	return resArray, nil
+}
+
+func (r *OrderRepository) LockOrder(ctx context.Context, OrderNum string) (*model.Order, error) {
+	var res model.Order
+	err := r.s.write(ctx, func(tx *Tx) error {
+		s := tx.s
+		id, ok := s.orderNums[OrderNum]
+		if !ok {
+			return &model.NoRowFound
+		}
+		if err := s.lockRow(ctx, tx, "orders", id); err != nil {
+			return err
+		}
+		o, ok := s.orders[id]
+		if !ok {
+			return &model.NoRowFound
+		}
+		res = *o
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, &model.NoRowFound) {
+			r.l.Error("OrderRepository: can't get order for update", zap.Error(err))
+		}
+		return nil, err
+	}
+	return &res, nil
+}
+
+// FindNotProcessed возвращает не более notProcessedLimit заказов, ожидающих расчета начисления
+func (r *OrderRepository) FindNotProcessed(ctx context.Context) ([]model.Order, error) {
+	var resArray []model.Order
+	err := r.s.read(ctx, func(tx *Tx) error {
+		for _, o := range tx.s.orders {
+			switch o.Status {
+			case model.OrderStatusProcessing, model.OrderStatusNew, model.OrderStatusRegistered:
+				resArray = append(resArray, *o)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		r.l.Error("OrderRepository: request error", zap.Error(err))
+		return nil, err
+	}
+	sort.Slice(resArray, func(i, j int) bool {
+		return resArray[i].ID < resArray[j].ID
+	})
+	if len(resArray) > notProcessedLimit {
+		resArray = resArray[:notProcessedLimit]
+	}
+	return resArray, nil
+}
+
+func isOrderStatus(status string) bool {
+	switch status {
+	case model.OrderStatusNew, model.OrderStatusRegistered, model.OrderStatusInvalid, model.OrderStatusProcessing,
+		model.OrderStatusProcessed:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package memory
+
+import (
+	"context"
+	"github.com/jackc/pgerrcode"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestUserRepository(t *testing.T) {
+	ctx := context.Background()
+	target, err := NewUserRepository(NewStorage(), log)
+	require.NoError(t, err)
+
+	userID, err := target.Save(ctx, "user", "pass")
+	require.NoError(t, err)
+	_, err = target.Save(ctx, "user", "other pass")
+	assert.ErrorIs(t, err, &model.UniqueViolation)
+
+	u, err := target.GetUserByLogin(ctx, "user")
+	require.NoError(t, err)
+	assert.Equal(t, userID, u.ID)
+	_, err = target.GetUserByLogin(ctx, "unknown")
+	assert.ErrorIs(t, err, &model.NoRowFound)
+
+	require.NoError(t, target.Deactivate(ctx, userID))
+	_, err = target.GetUserByID(ctx, userID)
+	assert.ErrorIs(t, err, &model.NoRowFound)
+}
+
+func TestOrderRepository(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorage()
+	users, _ := NewUserRepository(s, log)
+	target, err := NewOrderRepository(s, log)
+	require.NoError(t, err)
+	userID, err := users.Save(ctx, "user", "pass")
+	require.NoError(t, err)
+
+	require.NoError(t, target.Save(ctx, &model.Order{UserID: userID, Num: "12345678903", Status: model.OrderStatusNew}))
+	err = target.Save(ctx, &model.Order{UserID: userID, Num: "12345678903", Status: model.OrderStatusNew})
+	assert.ErrorIs(t, err, &model.UniqueViolation)
+	err = target.Save(ctx, &model.Order{UserID: userID + 1, Num: "79927398713", Status: model.OrderStatusNew})
+	assertPgCode(t, err, pgerrcode.ForeignKeyViolation)
+	err = target.Save(ctx, &model.Order{UserID: userID, Num: "79927398713", Status: "UNKNOWN"})
+	assertPgCode(t, err, pgerrcode.CheckViolation)
+
+	order, err := target.GetByNum(ctx, "12345678903")
+	require.NoError(t, err)
+	assert.Equal(t, userID, order.UserID)
+	orders, err := target.FindByUser(ctx, userID)
+	require.NoError(t, err)
+	assert.Len(t, orders, 1)
+}
+
+func TestBalanceRepository(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorage()
+	users, _ := NewUserRepository(s, log)
+	target, err := NewBalanceRepository(s, log)
+	require.NoError(t, err)
+	userID, err := users.Save(ctx, "user", "pass")
+	require.NoError(t, err)
+	account, err := target.GetAccount(ctx, userID)
+	require.NoError(t, err)
+
+	account.Balance = -1
+	err = target.SaveAccount(ctx, account)
+	assertPgCode(t, err, pgerrcode.CheckViolation)
+
+	withdrawal := model.Operation{AccountID: account.ID, OrderNum: "2377225624", OrderPart: 1, OperationType: model.OperationDebit, Amount: 10, ProcessedAt: time.Now()}
+	require.NoError(t, target.CreateOperation(ctx, &withdrawal))
+	assert.NotZero(t, withdrawal.ID)
+	again := withdrawal
+	err = target.CreateOperation(ctx, &again)
+	assert.ErrorIs(t, err, &model.UniqueViolation)
+
+	sum, err := target.GetWithdrawnSum(ctx, account.ID, epoch)
+	require.NoError(t, err)
+	assert.Equal(t, float32(10), sum)
+
+	// входящий перевод - зачисление, корректировка ADJUSTMENT - нет
+	transferAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	transfer := model.Operation{AccountID: account.ID, OperationType: model.OperationCredit, Amount: 1000, TransferRef: "ref", ProcessedAt: transferAt}
+	require.NoError(t, target.CreateOperation(ctx, &transfer))
+	adjustment := model.Operation{AccountID: account.ID, OperationType: model.OperationAdjustment, Amount: 1000, ProcessedAt: time.Now(),
+		Reason: model.AdjustmentReasonCompensation}
+	require.NoError(t, target.CreateOperation(ctx, &adjustment))
+	lastCredit, err := target.GetLastCreditTime(ctx, account.ID, 500)
+	require.NoError(t, err)
+	assert.Equal(t, transferAt, lastCredit)
+}
+
+func TestLedgerRepository_FindAccountDiscrepancies(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorage()
+	users, _ := NewUserRepository(s, log)
+	balance, _ := NewBalanceRepository(s, log)
+	target, err := NewLedgerRepository(s, log)
+	require.NoError(t, err)
+
+	var accounts []*model.Account
+	for _, login := range []string{"rounding", "mismatch"} {
+		userID, err := users.Save(ctx, login, "pass")
+		require.NoError(t, err)
+		account, err := balance.GetAccount(ctx, userID)
+		require.NoError(t, err)
+		accounts = append(accounts, account)
+	}
+	// расхождение меньше model.LedgerTolerance исправление пропускает, поэтому проверка его не находит
+	accounts[0].Balance = 0.001
+	require.NoError(t, balance.SaveAccount(ctx, accounts[0]))
+	accounts[1].Balance = 1
+	require.NoError(t, balance.SaveAccount(ctx, accounts[1]))
+
+	discrepancies, err := target.FindAccountDiscrepancies(ctx)
+	require.NoError(t, err)
+	if assert.Len(t, discrepancies, 1) {
+		assert.Equal(t, accounts[1].ID, discrepancies[0].Account.ID)
+	}
+}
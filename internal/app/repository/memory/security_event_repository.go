@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
+	"sort"
+)
+
+// SecurityEventRepository пишет вне транзакции запроса: событие неудачного или отклоненного действия
+// должно сохраниться, хотя транзакция запроса откатывается
+type SecurityEventRepository struct {
+	s *Storage
+	l *infrastructure.Logger
+}
+
+func NewSecurityEventRepository(storage *Storage, log *infrastructure.Logger) (model.SecurityEventRepository, error) {
+	var target SecurityEventRepository
+	if storage == nil {
+		return nil, errors.New("can't init security event repository")
+	}
+	target.s = storage
+	target.l = log
+	return &target, nil
+}
+
+func (r *SecurityEventRepository) Save(ctx context.Context, event *model.SecurityEvent) error {
+	return r.s.write(basedbhandler.WithoutTransaction(ctx), func(tx *Tx) error {
+		s := tx.s
+		saved := *event
+		saved.ID = int64(s.nextID("security_events"))
+		s.securityEvents = append(s.securityEvents, saved)
+		event.ID = saved.ID
+		return nil
+	})
+}
+
+// Find возвращает записи от новых к старым. Пустые значения фильтра не ограничивают выборку
+func (r *SecurityEventRepository) Find(ctx context.Context, filter *model.SecurityEventFilter) ([]model.SecurityEvent, error) {
+	var resArray []model.SecurityEvent
+	err := r.s.read(ctx, func(tx *Tx) error {
+		for _, e := range tx.s.securityEvents {
+			if (filter.UserID == 0 || e.UserID == filter.UserID) &&
+				(filter.Login == "" || e.Login == filter.Login) &&
+				(filter.Action == "" || e.Action == filter.Action) &&
+				(filter.From.IsZero() || !e.CreatedAt.Before(filter.From)) &&
+				(filter.To.IsZero() || e.CreatedAt.Before(filter.To)) {
+				resArray = append(resArray, e)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(resArray, func(i, j int) bool {
+		if !resArray[i].CreatedAt.Equal(resArray[j].CreatedAt) {
+			return resArray[i].CreatedAt.After(resArray[j].CreatedAt)
+		}
+		return resArray[i].ID > resArray[j].ID
+	})
+	if filter.Limit >= 0 && len(resArray) > filter.Limit {
+		resArray = resArray[:filter.Limit]
+	}
+	return resArray, nil
+}
+
+// Stream передает fn записи вне блокировки хранилища
+func (r *SecurityEventRepository) Stream(ctx context.Context, filter *model.SecurityEventFilter, fn func(event *model.SecurityEvent) error) error {
+	events, err := r.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	for i := range events {
+		if err = fn(&events[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"time"
+)
+
+type SessionRepository struct {
+	s *Storage
+	l *infrastructure.Logger
+}
+
+func NewSessionRepository(storage *Storage, log *infrastructure.Logger) (model.SessionRepository, error) {
+	var target SessionRepository
+	if storage == nil {
+		return nil, errors.New("can't init session repository")
+	}
+	target.s = storage
+	target.l = log
+	return &target, nil
+}
+
+func (r *SessionRepository) Save(ctx context.Context, session *model.Session) error {
+	return r.s.write(ctx, func(tx *Tx) error {
+		s := tx.s
+		if err := s.checkUser(session.UserID, "sessions_user_fk"); err != nil {
+			return err
+		}
+		if _, ok := s.sessions[session.ID]; ok {
+			return uniqueViolation("sessions_pkey")
+		}
+		if s.sessionByRefreshHash(session.RefreshHash, false) != nil {
+			return uniqueViolation("session_refresh_hash_idx")
+		}
+		s.sessions[session.ID] = &model.Session{
+			ID:          session.ID,
+			UserID:      session.UserID,
+			RefreshHash: session.RefreshHash,
+			CreatedAt:   session.CreatedAt,
+			RefreshedAt: session.RefreshedAt,
+			ExpiresAt:   session.ExpiresAt,
+		}
+		tx.onRollback(func() {
+			delete(s.sessions, session.ID)
+		})
+		return nil
+	})
+}
+
+func (r *SessionRepository) Get(ctx context.Context, sessionID string) (*model.Session, error) {
+	var res model.Session
+	err := r.s.read(ctx, func(tx *Tx) error {
+		sess, ok := tx.s.sessions[sessionID]
+		if !ok {
+			return &model.NoRowFound
+		}
+		res = *sess
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (r *SessionRepository) LockByRefreshHash(ctx context.Context, refreshHash string) (*model.Session, error) {
+	var res model.Session
+	err := r.s.write(ctx, func(tx *Tx) error {
+		s := tx.s
+		sess := s.sessionByRefreshHash(refreshHash, true)
+		if sess == nil {
+			return &model.NoRowFound
+		}
+		if err := s.lockRow(ctx, tx, "sessions", sess.ID); err != nil {
+			return err
+		}
+		// пока ждали блокировку, сессию могли обновить
+		sess = s.sessionByRefreshHash(refreshHash, true)
+		if sess == nil {
+			return &model.NoRowFound
+		}
+		res = *sess
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (r *SessionRepository) Update(ctx context.Context, session *model.Session) error {
+	return r.s.write(ctx, func(tx *Tx) error {
+		s := tx.s
+		if _, ok := s.sessions[session.ID]; !ok {
+			return nil
+		}
+		if other := s.sessionByRefreshHash(session.RefreshHash, false); other != nil && other.ID != session.ID {
+			return uniqueViolation("session_refresh_hash_idx")
+		}
+		if err := s.lockRow(ctx, tx, "sessions", session.ID); err != nil {
+			return err
+		}
+		sess, ok := s.sessions[session.ID]
+		if !ok {
+			return nil
+		}
+		old := *sess
+		sess.RefreshHash = session.RefreshHash
+		sess.PreviousHash = session.PreviousHash
+		sess.RefreshedAt = session.RefreshedAt
+		sess.ExpiresAt = session.ExpiresAt
+		sess.RevokedAt = session.RevokedAt
+		tx.onRollback(func() {
+			*sess = old
+		})
+		return nil
+	})
+}
+
+// RevokeByUser блокирует и отзывает действующие сессии пользователя, кроме exceptSessionID
+func (r *SessionRepository) RevokeByUser(ctx context.Context, userID int, exceptSessionID string, now time.Time) error {
+	return r.s.write(ctx, func(tx *Tx) error {
+		s := tx.s
+		revoke := func(sess *model.Session) bool {
+			return sess.UserID == userID && sess.ID != exceptSessionID && sess.RevokedAt.IsZero()
+		}
+		var ids []string
+		for id, sess := range s.sessions {
+			if revoke(sess) {
+				ids = append(ids, id)
+			}
+		}
+		for _, id := range ids {
+			if err := s.lockRow(ctx, tx, "sessions", id); err != nil {
+				return err
+			}
+		}
+		for _, id := range ids {
+			sess, ok := s.sessions[id]
+			if !ok || !revoke(sess) {
+				continue
+			}
+			sess.RevokedAt = now
+			tx.onRollback(func() {
+				sess.RevokedAt = time.Time{}
+			})
+		}
+		return nil
+	})
+}
+
+// sessionByRefreshHash ищет сессию по текущему refresh-токену, а при withPrevious - и по предыдущему
+func (s *Storage) sessionByRefreshHash(refreshHash string, withPrevious bool) *model.Session {
+	for _, sess := range s.sessions {
+		if sess.RefreshHash == refreshHash || (withPrevious && sess.PreviousHash != "" && sess.PreviousHash == refreshHash) {
+			return sess
+		}
+	}
+	return nil
+}
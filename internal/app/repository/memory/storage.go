@@ -0,0 +1,327 @@
+// Package memory - хранилище данных сервиса в памяти процесса для режима разработки и тестов без базы данных.
+//
+// Репозитории повторяют поведение репозиториев PostgreSQL: уникальные и внешние ключи, проверочные ограничения,
+// NoRowFound, блокировку строк до конца транзакции и откат транзакции. Отличия от базы: изменения незафиксированной
+// транзакции сразу видны другим транзакциям, а уровень изоляции транзакции не учитывается.
+// Данные теряются при остановке сервиса
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Storage хранит таблицы сервиса. Все обращения к таблицам выполняются под mu, блокировки строк живут до конца транзакции
+type Storage struct {
+	mu    sync.Mutex
+	locks map[string]*Tx
+	seq   map[string]int
+
+	users          map[int]*userRow
+	userLogins     map[string]int
+	userRoles      map[userRole]bool
+	accounts       map[int]*model.Account
+	accountUsers   map[int]int
+	orders         map[int]*model.Order
+	orderNums      map[string]int
+	operations     map[int]*model.Operation
+	orderParts     map[orderPart]int
+	adjustments    map[int]*model.Adjustment
+	sessions       map[string]*model.Session
+	apiKeys        map[string]*model.APIKey
+	loginAttempts  map[string]*model.LoginAttempt
+	identities     map[identityKey]*model.Identity
+	securityEvents []model.SecurityEvent
+}
+
+type userRow struct {
+	model.User
+	active bool
+}
+
+type userRole struct {
+	userID int
+	role   string
+}
+
+// orderPart - уникальный ключ части списания по заказу
+type orderPart struct {
+	orderNum string
+	part     int
+}
+
+type identityKey struct {
+	issuer  string
+	subject string
+}
+
+func NewStorage() *Storage {
+	var target Storage
+	target.locks = make(map[string]*Tx)
+	target.seq = make(map[string]int)
+	target.users = make(map[int]*userRow)
+	target.userLogins = make(map[string]int)
+	target.userRoles = make(map[userRole]bool)
+	target.accounts = make(map[int]*model.Account)
+	target.accountUsers = make(map[int]int)
+	target.orders = make(map[int]*model.Order)
+	target.orderNums = make(map[string]int)
+	target.operations = make(map[int]*model.Operation)
+	target.orderParts = make(map[orderPart]int)
+	target.adjustments = make(map[int]*model.Adjustment)
+	target.sessions = make(map[string]*model.Session)
+	target.apiKeys = make(map[string]*model.APIKey)
+	target.loginAttempts = make(map[string]*model.LoginAttempt)
+	target.identities = make(map[identityKey]*model.Identity)
+	return &target
+}
+
+// Tx - транзакция хранилища. Реализует только Commit и Rollback из pgx.Tx: хранилище не выполняет SQL,
+// транзакция лишь передается через контекст запроса так же, как транзакция PostgreSQL
+type Tx struct {
+	pgx.Tx
+	s        *Storage
+	readOnly bool
+	// failed - в транзакции произошла ошибка, как и в PostgreSQL, дальше ее можно только откатить
+	failed bool
+	closed bool
+	undo   []func()
+	locks  []string
+	// waiting - транзакция, освобождения строки которой ждет эта транзакция. Нужна для обнаружения взаимоблокировок
+	waiting *Tx
+	done    chan struct{}
+}
+
+// BeginTx начинает транзакцию. Транзакцию нужно завершить Commit или Rollback, иначе заблокированные ею строки
+// останутся недоступны
+func (s *Storage) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	return s.newTx(opts.AccessMode == pgx.ReadOnly), nil
+}
+
+func (s *Storage) newTx(readOnly bool) *Tx {
+	return &Tx{s: s, readOnly: readOnly, done: make(chan struct{})}
+}
+
+// WithTx выполняет fn в транзакции, так же как PostgresqlHandlerTX.WithTx. Если в контексте уже есть транзакция
+// хранилища, fn выполняется в ней
+func (s *Storage) WithTx(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context) error) (err error) {
+	if s.txFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+	tx := s.newTx(opts.AccessMode == pgx.ReadOnly)
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback(ctx)
+			panic(p)
+		}
+	}()
+	if err = fn(context.WithValue(ctx, basedbhandler.TransactionKey("tx"), tx)); err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (tx *Tx) Commit(ctx context.Context) error {
+	tx.s.mu.Lock()
+	defer tx.s.mu.Unlock()
+	if tx.closed {
+		return pgx.ErrTxClosed
+	}
+	if tx.failed {
+		tx.rollback()
+		return pgx.ErrTxCommitRollback
+	}
+	tx.finish()
+	return nil
+}
+
+func (tx *Tx) Rollback(ctx context.Context) error {
+	tx.s.mu.Lock()
+	defer tx.s.mu.Unlock()
+	if tx.closed {
+		return pgx.ErrTxClosed
+	}
+	tx.rollback()
+	return nil
+}
+
+func (tx *Tx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+	tx.finish()
+}
+
+// finish снимает блокировки строк и будит ожидающие их транзакции
+func (tx *Tx) finish() {
+	for _, key := range tx.locks {
+		delete(tx.s.locks, key)
+	}
+	tx.undo = nil
+	tx.locks = nil
+	tx.closed = true
+	close(tx.done)
+}
+
+// onRollback запоминает действие, отменяющее изменение, на случай отката транзакции
+func (tx *Tx) onRollback(fn func()) {
+	tx.undo = append(tx.undo, fn)
+}
+
+func (s *Storage) txFromContext(ctx context.Context) *Tx {
+	tx, _ := ctx.Value(basedbhandler.TransactionKey("tx")).(*Tx)
+	if tx == nil || tx.s != s {
+		return nil
+	}
+	return tx
+}
+
+// read выполняет чтение в транзакции из контекста или, если ее нет, в отдельной транзакции
+func (s *Storage) read(ctx context.Context, fn func(tx *Tx) error) error {
+	return s.exec(ctx, false, fn)
+}
+
+// write выполняет изменение в транзакции из контекста или, если ее нет, в отдельной транзакции (autocommit)
+func (s *Storage) write(ctx context.Context, fn func(tx *Tx) error) error {
+	return s.exec(ctx, true, fn)
+}
+
+func (s *Storage) exec(ctx context.Context, write bool, fn func(tx *Tx) error) error {
+	tx := s.txFromContext(ctx)
+	autocommit := tx == nil
+	if autocommit {
+		tx = s.newTx(false)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	switch {
+	case tx.closed:
+		return pgx.ErrTxClosed
+	case tx.failed:
+		return pgError(pgerrcode.InFailedSQLTransaction, "current transaction is aborted, commands ignored until end of transaction block")
+	case write && tx.readOnly:
+		err = pgError(pgerrcode.ReadOnlySQLTransaction, "cannot execute statement in a read-only transaction")
+	default:
+		err = fn(tx)
+	}
+	// отсутствие строки - результат запроса, а не ошибка базы, транзакция остается рабочей
+	if err != nil && !errors.Is(err, &model.NoRowFound) {
+		tx.failed = true
+	}
+	if autocommit {
+		if tx.failed {
+			tx.rollback()
+		} else {
+			tx.finish()
+		}
+	}
+	return err
+}
+
+// lockRow блокирует строку до конца транзакции tx, как select ... for update. Если строка заблокирована другой
+// транзакцией, ждет ее завершения, временно освобождая хранилище. После ожидания строку нужно прочитать заново
+func (s *Storage) lockRow(ctx context.Context, tx *Tx, table string, id interface{}) error {
+	key := fmt.Sprintf("%s:%v", table, id)
+	for {
+		owner := s.locks[key]
+		if owner == nil {
+			s.locks[key] = tx
+			tx.locks = append(tx.locks, key)
+			return nil
+		}
+		if owner == tx {
+			return nil
+		}
+		for t := owner; t != nil; t = t.waiting {
+			if t == tx {
+				return pgError(pgerrcode.DeadlockDetected, "deadlock detected")
+			}
+		}
+		tx.waiting = owner
+		s.mu.Unlock()
+		select {
+		case <-owner.done:
+		case <-ctx.Done():
+		}
+		s.mu.Lock()
+		tx.waiting = nil
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// nextID - следующее значение последовательности. Как и в PostgreSQL, значения не возвращаются при откате транзакции
+func (s *Storage) nextID(table string) int {
+	s.seq[table]++
+	return s.seq[table]
+}
+
+func (s *Storage) checkUser(userID int, constraint string) error {
+	if _, ok := s.users[userID]; !ok {
+		return foreignKeyViolation(constraint)
+	}
+	return nil
+}
+
+func pgError(code string, message string) error {
+	return &pgconn.PgError{Severity: "ERROR", Code: code, Message: message}
+}
+
+func uniqueViolation(constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           pgerrcode.UniqueViolation,
+		Message:        fmt.Sprintf("duplicate key value violates unique constraint %q", constraint),
+		ConstraintName: constraint,
+	}
+}
+
+func foreignKeyViolation(constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           pgerrcode.ForeignKeyViolation,
+		Message:        fmt.Sprintf("insert or update violates foreign key constraint %q", constraint),
+		ConstraintName: constraint,
+	}
+}
+
+func checkViolation(constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           pgerrcode.CheckViolation,
+		Message:        fmt.Sprintf("new row violates check constraint %q", constraint),
+		ConstraintName: constraint,
+	}
+}
+
+// sortedOperations возвращает операции, отобранные filter, в порядке processed_at, id
+func (s *Storage) sortedOperations(filter func(op *model.Operation) bool) []*model.Operation {
+	var res []*model.Operation
+	for _, op := range s.operations {
+		if filter(op) {
+			res = append(res, op)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].ProcessedAt.Equal(res[j].ProcessedAt) {
+			return res[i].ProcessedAt.Before(res[j].ProcessedAt)
+		}
+		return res[i].ID < res[j].ID
+	})
+	return res
+}
+
+// epoch - значение to_timestamp(0), которым запросы заменяют отсутствующее время
+var epoch = time.Unix(0, 0)
@@ -0,0 +1,175 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+var log, _ = zap.NewDevelopment()
+
+func beginTx(t *testing.T, s *Storage, opts pgx.TxOptions) (context.Context, pgx.Tx) {
+	tx, err := s.BeginTx(context.Background(), opts)
+	require.NoError(t, err)
+	return context.WithValue(context.Background(), basedbhandler.TransactionKey("tx"), tx), tx
+}
+
+func assertPgCode(t *testing.T, err error, code string) {
+	var pgErr *pgconn.PgError
+	if assert.True(t, errors.As(err, &pgErr), "want postgres error %s, got %v", code, err) {
+		assert.Equal(t, code, pgErr.Code)
+	}
+}
+
+func TestStorage_Rollback(t *testing.T) {
+	s := NewStorage()
+	users, _ := NewUserRepository(s, log)
+	orders, _ := NewOrderRepository(s, log)
+	userID, err := users.Save(context.Background(), "user", "pass")
+	require.NoError(t, err)
+
+	ctx, tx := beginTx(t, s, pgx.TxOptions{})
+	_, err = users.Save(ctx, "second", "pass")
+	require.NoError(t, err)
+	require.NoError(t, users.UpdatePassword(ctx, userID, "new pass"))
+	require.NoError(t, orders.Save(ctx, &model.Order{UserID: userID, Num: "12345678903", Status: model.OrderStatusNew}))
+	require.NoError(t, tx.Rollback(ctx))
+
+	_, err = users.GetUserByLogin(context.Background(), "second")
+	assert.ErrorIs(t, err, &model.NoRowFound)
+	u, err := users.GetUserByID(context.Background(), userID)
+	require.NoError(t, err)
+	assert.Equal(t, "pass", u.Pass)
+	_, err = orders.GetByNum(context.Background(), "12345678903")
+	assert.ErrorIs(t, err, &model.NoRowFound)
+	assert.Equal(t, pgx.ErrTxClosed, tx.Commit(ctx))
+}
+
+func TestStorage_FailedTx(t *testing.T) {
+	s := NewStorage()
+	users, _ := NewUserRepository(s, log)
+	_, err := users.Save(context.Background(), "user", "pass")
+	require.NoError(t, err)
+
+	ctx, tx := beginTx(t, s, pgx.TxOptions{})
+	_, err = users.GetUserByLogin(ctx, "nobody")
+	assert.ErrorIs(t, err, &model.NoRowFound, "missing row doesn't break transaction")
+	_, err = users.Save(ctx, "second", "pass")
+	require.NoError(t, err)
+	_, err = users.Save(ctx, "user", "pass")
+	assert.ErrorIs(t, err, &model.UniqueViolation)
+	_, err = users.GetUserByLogin(ctx, "user")
+	assertPgCode(t, err, pgerrcode.InFailedSQLTransaction)
+	assert.Equal(t, pgx.ErrTxCommitRollback, tx.Commit(ctx))
+
+	_, err = users.GetUserByLogin(context.Background(), "second")
+	assert.ErrorIs(t, err, &model.NoRowFound)
+}
+
+func TestStorage_ReadOnly(t *testing.T) {
+	s := NewStorage()
+	users, _ := NewUserRepository(s, log)
+	ctx, tx := beginTx(t, s, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	_, err := users.GetUserByLogin(ctx, "user")
+	assert.ErrorIs(t, err, &model.NoRowFound)
+	_, err = users.Save(ctx, "user", "pass")
+	assertPgCode(t, err, pgerrcode.ReadOnlySQLTransaction)
+	assert.Equal(t, pgx.ErrTxCommitRollback, tx.Commit(ctx))
+}
+
+func TestStorage_LockAccount(t *testing.T) {
+	s := NewStorage()
+	users, _ := NewUserRepository(s, log)
+	balances, _ := NewBalanceRepository(s, log)
+	userID, err := users.Save(context.Background(), "user", "pass")
+	require.NoError(t, err)
+
+	ctx1, tx1 := beginTx(t, s, pgx.TxOptions{})
+	account, err := balances.LockAccount(ctx1, userID)
+	require.NoError(t, err)
+
+	locked := make(chan *model.Account)
+	go func() {
+		ctx2, tx2 := beginTx(t, s, pgx.TxOptions{})
+		defer tx2.Rollback(ctx2)
+		account, err := balances.LockAccount(ctx2, userID)
+		assert.NoError(t, err)
+		locked <- account
+	}()
+	select {
+	case <-locked:
+		t.Fatal("account is locked by another transaction")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	account.Balance = 100
+	account.Credit = 100
+	require.NoError(t, balances.SaveAccount(ctx1, account))
+	require.NoError(t, tx1.Commit(ctx1))
+	select {
+	case account = <-locked:
+		assert.Equal(t, float32(100), account.Balance, "lock waiter reads committed data")
+	case <-time.After(time.Second):
+		t.Fatal("lock is not released on commit")
+	}
+}
+
+func TestStorage_Deadlock(t *testing.T) {
+	s := NewStorage()
+	users, _ := NewUserRepository(s, log)
+	balances, _ := NewBalanceRepository(s, log)
+	first, err := users.Save(context.Background(), "first", "pass")
+	require.NoError(t, err)
+	second, err := users.Save(context.Background(), "second", "pass")
+	require.NoError(t, err)
+
+	ctx1, tx1 := beginTx(t, s, pgx.TxOptions{})
+	ctx2, tx2 := beginTx(t, s, pgx.TxOptions{})
+	_, err = balances.LockAccount(ctx1, first)
+	require.NoError(t, err)
+	_, err = balances.LockAccount(ctx2, second)
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		_, err := balances.LockAccount(ctx1, second)
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	_, err = balances.LockAccount(ctx2, first)
+	assertPgCode(t, err, pgerrcode.DeadlockDetected)
+	require.NoError(t, tx2.Rollback(ctx2))
+	assert.NoError(t, <-done)
+	require.NoError(t, tx1.Commit(ctx1))
+}
+
+func TestStorage_WithTx(t *testing.T) {
+	s := NewStorage()
+	users, _ := NewUserRepository(s, log)
+	err := s.WithTx(context.Background(), pgx.TxOptions{}, func(ctx context.Context) error {
+		if _, err := users.Save(ctx, "user", "pass"); err != nil {
+			return err
+		}
+		return errors.New("business rule violated")
+	})
+	assert.Error(t, err)
+	_, err = users.GetUserByLogin(context.Background(), "user")
+	assert.ErrorIs(t, err, &model.NoRowFound)
+
+	err = s.WithTx(context.Background(), pgx.TxOptions{}, func(ctx context.Context) error {
+		_, err := users.Save(ctx, "user", "pass")
+		return err
+	})
+	require.NoError(t, err)
+	_, err = users.GetUserByLogin(context.Background(), "user")
+	assert.NoError(t, err)
+}
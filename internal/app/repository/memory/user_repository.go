@@ -0,0 +1,222 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"go.uber.org/zap"
+	"sort"
+)
+
+type UserRepository struct {
+	s *Storage
+	l *infrastructure.Logger
+}
+
+func NewUserRepository(storage *Storage, log *infrastructure.Logger) (model.UserRepository, error) {
+	var target UserRepository
+	if storage == nil {
+		return nil, errors.New("can't init user repository")
+	}
+	target.s = storage
+	target.l = log
+	return &target, nil
+}
+
+// Save создает пользователя и его счет. Занятый логин, в том числе неактивного пользователя, - UniqueViolation
+func (r *UserRepository) Save(ctx context.Context, login string, pass string) (int, error) {
+	var userID int
+	err := r.s.write(ctx, func(tx *Tx) error {
+		s := tx.s
+		if _, ok := s.userLogins[login]; ok {
+			return &model.UniqueViolation
+		}
+		userID = s.nextID("users")
+		accountID := s.nextID("accounts")
+		s.users[userID] = &userRow{User: model.User{ID: userID, Login: login, Pass: pass}, active: true}
+		s.userLogins[login] = userID
+		s.accounts[accountID] = &model.Account{ID: accountID, UserID: userID}
+		s.accountUsers[userID] = accountID
+		tx.onRollback(func() {
+			delete(s.users, userID)
+			delete(s.userLogins, login)
+			delete(s.accounts, accountID)
+			delete(s.accountUsers, userID)
+		})
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, &model.UniqueViolation) {
+			r.l.Error("UserRepository: cannt create user", zap.Error(err))
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+func (r *UserRepository) Check(ctx context.Context, login string, pass string) (bool, error) {
+	if login == "" {
+		r.l.Info("UserRepository: empty login authorization attempt")
+		return false, errors.New("can't register empty login")
+	}
+	if pass == "" {
+		r.l.Info("UserRepository: empty pass authorization  attempt")
+		return false, errors.New("pass cannot be empty")
+	}
+	var res bool
+	err := r.s.read(ctx, func(tx *Tx) error {
+		u := tx.s.activeUserByLogin(login)
+		res = u != nil && u.Pass == pass
+		return nil
+	})
+	return res, err
+}
+
+func (r *UserRepository) GetUserByLogin(ctx context.Context, login string) (*model.User, error) {
+	var res model.User
+	err := r.s.read(ctx, func(tx *Tx) error {
+		u := tx.s.activeUserByLogin(login)
+		if u == nil {
+			return &model.NoRowFound
+		}
+		res = u.User
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (r *UserRepository) GetUserByID(ctx context.Context, userID int) (*model.User, error) {
+	var res model.User
+	err := r.s.read(ctx, func(tx *Tx) error {
+		u, ok := tx.s.users[userID]
+		if !ok || !u.active {
+			return &model.NoRowFound
+		}
+		res = u.User
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, userID int, pass string) error {
+	err := r.s.write(ctx, func(tx *Tx) error {
+		return tx.s.updateUser(ctx, tx, userID, func(u *userRow) {
+			u.Pass = pass
+		})
+	})
+	if err != nil {
+		r.l.Error("UserRepository: can't update password", zap.Int("userID", userID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *UserRepository) Deactivate(ctx context.Context, userID int) error {
+	err := r.s.write(ctx, func(tx *Tx) error {
+		return tx.s.updateUser(ctx, tx, userID, func(u *userRow) {
+			u.active = false
+		})
+	})
+	if err != nil {
+		r.l.Error("UserRepository: can't deactivate user", zap.Int("userID", userID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *UserRepository) GetRoles(ctx context.Context, userID int) ([]string, error) {
+	var roles []string
+	err := r.s.read(ctx, func(tx *Tx) error {
+		for ur := range tx.s.userRoles {
+			if ur.userID == userID {
+				roles = append(roles, ur.role)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		r.l.Error("UserRepository: can't get roles", zap.Int("userID", userID), zap.Error(err))
+		return nil, err
+	}
+	sort.Strings(roles)
+	return roles, nil
+}
+
+// AddRole не считает ошибкой уже назначенную роль, как insert ... on conflict do nothing
+func (r *UserRepository) AddRole(ctx context.Context, userID int, role string) error {
+	err := r.s.write(ctx, func(tx *Tx) error {
+		s := tx.s
+		if err := s.checkUser(userID, "user_roles_user_fk"); err != nil {
+			return err
+		}
+		key := userRole{userID: userID, role: role}
+		if s.userRoles[key] {
+			return nil
+		}
+		s.userRoles[key] = true
+		tx.onRollback(func() {
+			delete(s.userRoles, key)
+		})
+		return nil
+	})
+	if err != nil {
+		r.l.Error("UserRepository: can't add role", zap.Int("userID", userID), zap.String("role", role), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *UserRepository) RemoveRole(ctx context.Context, userID int, role string) error {
+	err := r.s.write(ctx, func(tx *Tx) error {
+		s := tx.s
+		key := userRole{userID: userID, role: role}
+		if !s.userRoles[key] {
+			return nil
+		}
+		delete(s.userRoles, key)
+		tx.onRollback(func() {
+			s.userRoles[key] = true
+		})
+		return nil
+	})
+	if err != nil {
+		r.l.Error("UserRepository: can't remove role", zap.Int("userID", userID), zap.String("role", role), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) activeUserByLogin(login string) *userRow {
+	id, ok := s.userLogins[login]
+	if !ok || !s.users[id].active {
+		return nil
+	}
+	return s.users[id]
+}
+
+// updateUser блокирует строку пользователя и изменяет ее. Отсутствующий пользователь не ошибка, как и update без строк
+func (s *Storage) updateUser(ctx context.Context, tx *Tx, userID int, update func(u *userRow)) error {
+	if _, ok := s.users[userID]; !ok {
+		return nil
+	}
+	if err := s.lockRow(ctx, tx, "users", userID); err != nil {
+		return err
+	}
+	u, ok := s.users[userID]
+	if !ok {
+		return nil
+	}
+	old := *u
+	update(u)
+	tx.onRollback(func() {
+		*u = old
+	})
+	return nil
+}
@@ -1,12 +1,56 @@
 package repository
 
 const CreateOperation = "INSERT INTO operations \n" +
-	"(id, account_id, order_id,order_num, operation_type, amount, processed_at) \n" +
-	"VALUES(nextval('seq_order'), $1, $2, $3, $4, $5, $6);"
+	"(account_id, order_id, order_num, operation_type, amount, processed_at, transfer_ref, reason, order_part, actor, approved_by) \n" +
+	"VALUES($1, nullif($2, 0), $3, $4, $5, $6, nullif($7, ''), nullif($8, ''), nullif($9, 0), nullif($10, ''), nullif($11, '')) \n" +
+	"returning id;"
 
-const GetWithdrawalByUser = "select op.order_num, op.amount, 'PROCESSED' as status, op.processed_at \n" +
+const GetWithdrawalByUser = "select op.order_num, op.amount, 'PROCESSED' as status, op.processed_at, \n" +
+	"COALESCE(op.order_part, 1), \n" +
+	"sum(op.amount) over (partition by op.order_num order by op.processed_at, op.id) as order_total \n" +
 	"from operations op, accounts acc \n" +
 	"where \n" +
 	"op.account_id = acc.id \n" +
 	"and acc.user_id  = $1 \n" +
-	"and operation_type='DEBIT'"
+	"and operation_type='DEBIT' \n" +
+	"and op.transfer_ref is null \n" +
+	"and op.reason is null \n" +
+	"order by op.processed_at, op.id"
+
+// Парные части одного заказа должны принадлежать одному счету, поэтому достаточно выбрать все списания по номеру
+const FindWithdrawalByOrder = "select id, account_id, order_num, COALESCE(order_part, 1), amount, processed_at \n" +
+	"from operations \n" +
+	"where order_num = $1 \n" +
+	"and operation_type = 'DEBIT' \n" +
+	"and transfer_ref is null \n" +
+	"and reason is null \n" +
+	"order by processed_at, id"
+
+const GetStatementByUser = "select op.operation_type, op.amount, op.order_num, COALESCE(op.transfer_ref, ''), COALESCE(u.login, ''), COALESCE(op.reason, ''), op.processed_at \n" +
+	"from operations op \n" +
+	"join accounts acc on op.account_id = acc.id \n" +
+	"left join operations cp on cp.transfer_ref = op.transfer_ref and cp.id <> op.id \n" +
+	"left join accounts cpa on cpa.id = cp.account_id \n" +
+	"left join users u on u.id = cpa.user_id \n" +
+	"where acc.user_id = $1 \n" +
+	"order by op.processed_at, op.id"
+
+const GetTransferredSum = "select COALESCE(sum(amount), 0) from operations \n" +
+	"where account_id = $1 \n" +
+	"and operation_type = 'DEBIT' \n" +
+	"and transfer_ref is not null \n" +
+	"and processed_at >= $2"
+
+const GetWithdrawnSum = "select COALESCE(sum(amount), 0) from operations \n" +
+	"where account_id = $1 \n" +
+	"and operation_type = 'DEBIT' \n" +
+	"and transfer_ref is null \n" +
+	"and reason is null \n" +
+	"and processed_at >= $2"
+
+// GetLastCreditTime учитывает любое зачисление CREDIT: начисление за заказ или входящий перевод. Ручные корректировки
+// и исправления проверки учета - операции ADJUSTMENT - не начинают период ожидания перед списанием
+const GetLastCreditTime = "select COALESCE(max(processed_at), to_timestamp(0)) from operations \n" +
+	"where account_id = $1 \n" +
+	"and operation_type = 'CREDIT' \n" +
+	"and amount >= $2"
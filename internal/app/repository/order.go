@@ -1,8 +1,8 @@
 package repository
 
 const CreateOrder = "INSERT INTO orders \n" +
-	"(id, user_id, num, status, upload_at, updated_at) \n" +
-	"VALUES(nextval('seq_order'),  $1, $2, $3, $4,$5);"
+	"(user_id, num, status, upload_at, updated_at) \n" +
+	"VALUES($1, $2, $3, $4, $5);"
 
 const UpdateOrderStatus = "UPDATE orders \n" +
 	"SET  status=$2, updated_at=$3 \n" +
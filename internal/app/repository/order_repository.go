@@ -86,16 +86,21 @@ func (r *OrderRepositoryImpl) FindByUser(ctx context.Context, userID int) ([]mod
 		r.l.Error("OrderRepository: request error", zap.String("query", FindOrdersByUser), zap.Int("userID", userID), zap.Error(err))
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var o model.Order
 		err := rows.Scan(&o.ID, &o.Num, &o.UserID, &o.Status, &o.Accrual, &o.UploadAt, &o.UpdatedAt)
 		if err != nil {
 			r.l.Error("OrderRepository: scan rows error", zap.String("query", FindOrdersByUser), zap.Int("userID", userID), zap.Error(err))
-			break
+			return nil, err
 		}
 		resArray = append(resArray, o)
 	}
+	if err = rows.Err(); err != nil {
+		r.l.Error("OrderRepository: read rows error", zap.String("query", FindOrdersByUser), zap.Int("userID", userID), zap.Error(err))
+		return nil, err
+	}
 	return resArray, nil
 }
 
@@ -127,15 +132,20 @@ func (r *OrderRepositoryImpl) FindNotProcessed(ctx context.Context) ([]model.Ord
 		r.l.Error("OrderRepository: request error", zap.String("query", FindOrderByStatuses), zap.Error(err))
 		return nil, err
 	}
+	defer rows.Close()
 	for rows.Next() {
 		var o model.Order
 		err := rows.Scan(&o.ID, &o.UserID, &o.Num, &o.Status, &o.UploadAt, &o.UpdatedAt)
 		if err != nil {
 			r.l.Error("OrderRepository: scan rows error", zap.String("query", FindOrderByStatuses), zap.Error(err))
-			break
+			return nil, err
 		}
 		resArray = append(resArray, o)
 	}
+	if err = rows.Err(); err != nil {
+		r.l.Error("OrderRepository: read rows error", zap.String("query", FindOrderByStatuses), zap.Error(err))
+		return nil, err
+	}
 
 	return resArray, nil
 }
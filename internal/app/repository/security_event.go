@@ -0,0 +1,15 @@
+package repository
+
+const CreateSecurityEvent = "INSERT INTO security_events \n" +
+	"(action, result, user_id, login, target, ip, user_agent, details, created_at) \n" +
+	"VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9) returning id;"
+
+// FindSecurityEvents - пустые значения параметров не ограничивают выборку
+const FindSecurityEvents = "select id, action, result, COALESCE(user_id, 0), login, target, ip, user_agent, details, created_at \n" +
+	"from security_events \n" +
+	"where ($1::numeric = 0 or user_id = $1) \n" +
+	"and ($2::varchar = '' or login = $2) \n" +
+	"and ($3::varchar = '' or action = $3) \n" +
+	"and ($4::timestamptz is null or created_at >= $4) \n" +
+	"and ($5::timestamptz is null or created_at < $5) \n" +
+	"order by created_at desc, id desc limit $6"
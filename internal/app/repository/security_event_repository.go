@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
+	"go.uber.org/zap"
+	"time"
+)
+
+// SecurityEventRepository пишет вне транзакции запроса: событие неудачного или отклоненного действия
+// должно сохраниться, хотя транзакция запроса откатывается
+type SecurityEventRepository struct {
+	h basedbhandler.DBHandler
+	l *infrastructure.Logger
+}
+
+func NewSecurityEventRepository(dbHandler basedbhandler.DBHandler, log *infrastructure.Logger) (model.SecurityEventRepository, error) {
+	var target SecurityEventRepository
+	if dbHandler == nil {
+		return nil, errors.New("can't init security event repository")
+	}
+	target.h = dbHandler
+	target.l = log
+	return &target, nil
+}
+
+func (r *SecurityEventRepository) Save(ctx context.Context, event *model.SecurityEvent) error {
+	var userID *int
+	if event.UserID != 0 {
+		userID = &event.UserID
+	}
+	row, err := r.h.QueryRow(basedbhandler.WithoutTransaction(ctx), CreateSecurityEvent,
+		event.Action,
+		event.Result,
+		userID,
+		event.Login,
+		event.Target,
+		event.IP,
+		event.UserAgent,
+		event.Details,
+		event.CreatedAt)
+	if err != nil {
+		r.l.Error("SecurityEventRepository: can't create security event", zap.Error(err))
+		return err
+	}
+	if err = row.Scan(&event.ID); err != nil {
+		r.l.Error("SecurityEventRepository: can't create security event", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *SecurityEventRepository) Find(ctx context.Context, filter *model.SecurityEventFilter) ([]model.SecurityEvent, error) {
+	var resArray []model.SecurityEvent
+	err := r.Stream(ctx, filter, func(event *model.SecurityEvent) error {
+		resArray = append(resArray, *event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resArray, nil
+}
+
+func (r *SecurityEventRepository) Stream(ctx context.Context, filter *model.SecurityEventFilter, fn func(event *model.SecurityEvent) error) error {
+	rows, err := r.h.Query(ctx, FindSecurityEvents,
+		filter.UserID,
+		filter.Login,
+		filter.Action,
+		nullTime(filter.From),
+		nullTime(filter.To),
+		nullLimit(filter.Limit))
+	if err != nil {
+		r.l.Error("SecurityEventRepository: request error", zap.String("query", FindSecurityEvents), zap.Error(err))
+		return err
+	}
+	err = basedbhandler.ForEachRow(rows, func(rows basedbhandler.Rows) error {
+		var e model.SecurityEvent
+		if err := rows.Scan(&e.ID, &e.Action, &e.Result, &e.UserID, &e.Login, &e.Target, &e.IP, &e.UserAgent, &e.Details, &e.CreatedAt); err != nil {
+			return err
+		}
+		return fn(&e)
+	})
+	if err != nil {
+		r.l.Error("SecurityEventRepository: read rows error", zap.String("query", FindSecurityEvents), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// nullLimit - отрицательный лимит снимает ограничение: limit null в Postgres
+func nullLimit(limit int) *int {
+	if limit < 0 {
+		return nil
+	}
+	return &limit
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
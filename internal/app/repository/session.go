@@ -0,0 +1,18 @@
+package repository
+
+const CreateSession = "INSERT INTO sessions \n" +
+	"(id, user_id, refresh_hash, created_at, refreshed_at, expires_at) \n" +
+	"VALUES($1, $2, $3, $4, $5, $6);"
+
+const GetSession = "select id, user_id, refresh_hash, COALESCE(previous_hash, ''), created_at, refreshed_at, expires_at, revoked_at \n" +
+	"from sessions where id = $1"
+
+const GetSessionByRefreshHashForUpdate = "select id, user_id, refresh_hash, COALESCE(previous_hash, ''), created_at, refreshed_at, expires_at, revoked_at \n" +
+	"from sessions where refresh_hash = $1 or previous_hash = $1 for update"
+
+const UpdateSession = "UPDATE sessions \n" +
+	"SET refresh_hash=$2, previous_hash=nullif($3, ''), refreshed_at=$4, expires_at=$5, revoked_at=$6 \n" +
+	"WHERE id=$1;"
+
+const RevokeUserSessions = "UPDATE sessions SET revoked_at=$3 \n" +
+	"WHERE user_id=$1 and id <> $2 and revoked_at is null;"
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/repository/basedbhandler"
+	"go.uber.org/zap"
+	"time"
+)
+
+type SessionRepository struct {
+	h basedbhandler.DBHandler
+	l *infrastructure.Logger
+}
+
+func NewSessionRepository(dbHandler basedbhandler.DBHandler, log *infrastructure.Logger) (model.SessionRepository, error) {
+	var target SessionRepository
+	if dbHandler == nil {
+		return nil, errors.New("can't init session repository")
+	}
+	target.h = dbHandler
+	target.l = log
+	return &target, nil
+}
+
+func (r *SessionRepository) Save(ctx context.Context, session *model.Session) error {
+	err := r.h.Execute(ctx, CreateSession,
+		session.ID,
+		session.UserID,
+		session.RefreshHash,
+		session.CreatedAt,
+		session.RefreshedAt,
+		session.ExpiresAt)
+	if err != nil {
+		r.l.Error("SessionRepository: can't create session", zap.Int("userID", session.UserID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *SessionRepository) Get(ctx context.Context, sessionID string) (*model.Session, error) {
+	return r.get(ctx, GetSession, sessionID)
+}
+
+func (r *SessionRepository) LockByRefreshHash(ctx context.Context, refreshHash string) (*model.Session, error) {
+	return r.get(ctx, GetSessionByRefreshHashForUpdate, refreshHash)
+}
+
+func (r *SessionRepository) get(ctx context.Context, query string, arg string) (*model.Session, error) {
+	row, err := r.h.QueryRow(ctx, query, arg)
+	if err != nil {
+		r.l.Error("SessionRepository: can't get session", zap.Error(err))
+		return nil, err
+	}
+	var (
+		res       model.Session
+		revokedAt *time.Time
+	)
+	err = row.Scan(&res.ID, &res.UserID, &res.RefreshHash, &res.PreviousHash, &res.CreatedAt, &res.RefreshedAt, &res.ExpiresAt,
+		&revokedAt)
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			return nil, &model.NoRowFound
+		}
+		r.l.Error("SessionRepository: can't get session", zap.Error(err))
+		return nil, err
+	}
+	if revokedAt != nil {
+		res.RevokedAt = *revokedAt
+	}
+	return &res, nil
+}
+
+func (r *SessionRepository) Update(ctx context.Context, session *model.Session) error {
+	var revokedAt *time.Time
+	if !session.RevokedAt.IsZero() {
+		revokedAt = &session.RevokedAt
+	}
+	err := r.h.Execute(ctx, UpdateSession,
+		session.ID,
+		session.RefreshHash,
+		session.PreviousHash,
+		session.RefreshedAt,
+		session.ExpiresAt,
+		revokedAt)
+	if err != nil {
+		r.l.Error("SessionRepository: can't update session", zap.String("sessionID", session.ID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *SessionRepository) RevokeByUser(ctx context.Context, userID int, exceptSessionID string, now time.Time) error {
+	err := r.h.Execute(ctx, RevokeUserSessions, userID, exceptSessionID, now)
+	if err != nil {
+		r.l.Error("SessionRepository: can't revoke user sessions", zap.Int("userID", userID), zap.Error(err))
+		return err
+	}
+	return nil
+}
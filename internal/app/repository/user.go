@@ -1,11 +1,21 @@
 package repository
 
 const CreateUser = "INSERT INTO users " +
-	"( id, login, pass) \n" +
-	"VALUES($1, $2, $3) returning id;"
+	"(login, pass) \n" +
+	"VALUES($1, $2) returning id;"
 
 const CheckUser = "select 1 from users where login = $1 and active <> 0 and pass=$2;"
 
 const GetUserByLogin = "select id, login, pass from users where active <> 0 and login=$1"
 
-const GetNextUserID = "select nextval('seq_user')"
+const GetUserByID = "select id, login, pass from users where active <> 0 and id=$1"
+
+const UpdateUserPassword = "UPDATE users SET pass=$2 WHERE id=$1;"
+
+const DeactivateUser = "UPDATE users SET active=0 WHERE id=$1;"
+
+const GetUserRoles = "select role from user_roles where user_id = $1 order by role"
+
+const AddUserRole = "INSERT INTO user_roles (user_id, role) VALUES($1, $2) ON CONFLICT DO NOTHING;"
+
+const RemoveUserRole = "DELETE FROM user_roles WHERE user_id=$1 and role=$2;"
@@ -28,24 +28,19 @@ func NewUserRepository(dbHandler basedbhandler.DBHandler, log *infrastructure.Lo
 
 func (ur *UserRepositoryImpl) Save(ctx context.Context, login string, pass string) (int, error) {
 	var userID int
-	row, err := ur.h.QueryRow(ctx, GetNextUserID)
-	if err != nil {
-		ur.l.Error("UserRepository: cannt get userID")
-		return 0, err
+	row, err := ur.h.QueryRow(ctx, CreateUser, login, pass)
+	if err == nil {
+		err = row.Scan(&userID)
 	}
-	err = row.Scan(&userID)
-	if err != nil {
-		ur.l.Error("UserRepository: cannt get userID")
-		return 0, err
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		return 0, &model.UniqueViolation
 	}
-
-	err = ur.h.Execute(ctx, CreateUser, userID, login, pass)
 	if err != nil {
 		ur.l.Error("UserRepository: cannt create user", zap.Error(err))
 		return 0, err
 	}
 	err = ur.h.Execute(ctx, CreateAccount, userID)
-	var pgErr *pgconn.PgError
 	if errors.As(err, &pgErr) {
 		if pgErr.Code == pgerrcode.UniqueViolation {
 			return 0, &model.UniqueViolation
@@ -96,3 +91,79 @@ func (ur *UserRepositoryImpl) GetUserByLogin(ctx context.Context, login string)
 	}
 	return &res, nil
 }
+
+func (ur *UserRepositoryImpl) GetUserByID(ctx context.Context, userID int) (*model.User, error) {
+	row, err := ur.h.QueryRow(ctx, GetUserByID, userID)
+	if err != nil {
+		return nil, err
+	}
+	var res model.User
+	err = row.Scan(&res.ID, &res.Login, &res.Pass)
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			return nil, &model.NoRowFound
+		}
+		ur.l.Error("UserRepository: can't get user", zap.Int("userID", userID), zap.Error(err))
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (ur *UserRepositoryImpl) UpdatePassword(ctx context.Context, userID int, pass string) error {
+	err := ur.h.Execute(ctx, UpdateUserPassword, userID, pass)
+	if err != nil {
+		ur.l.Error("UserRepository: can't update password", zap.Int("userID", userID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (ur *UserRepositoryImpl) Deactivate(ctx context.Context, userID int) error {
+	err := ur.h.Execute(ctx, DeactivateUser, userID)
+	if err != nil {
+		ur.l.Error("UserRepository: can't deactivate user", zap.Int("userID", userID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (ur *UserRepositoryImpl) GetRoles(ctx context.Context, userID int) ([]string, error) {
+	rows, err := ur.h.Query(ctx, GetUserRoles, userID)
+	if err != nil {
+		ur.l.Error("UserRepository: can't get roles", zap.Int("userID", userID), zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+	var roles []string
+	for rows.Next() {
+		var role string
+		if err = rows.Scan(&role); err != nil {
+			ur.l.Error("UserRepository: scan rows error", zap.String("query", GetUserRoles), zap.Int("userID", userID), zap.Error(err))
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	if err = rows.Err(); err != nil {
+		ur.l.Error("UserRepository: read rows error", zap.String("query", GetUserRoles), zap.Int("userID", userID), zap.Error(err))
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (ur *UserRepositoryImpl) AddRole(ctx context.Context, userID int, role string) error {
+	err := ur.h.Execute(ctx, AddUserRole, userID, role)
+	if err != nil {
+		ur.l.Error("UserRepository: can't add role", zap.Int("userID", userID), zap.String("role", role), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (ur *UserRepositoryImpl) RemoveRole(ctx context.Context, userID int, role string) error {
+	err := ur.h.Execute(ctx, RemoveUserRole, userID, role)
+	if err != nil {
+		ur.l.Error("UserRepository: can't remove role", zap.Int("userID", userID), zap.String("role", role), zap.Error(err))
+		return err
+	}
+	return nil
+}
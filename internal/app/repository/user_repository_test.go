@@ -35,9 +35,8 @@ func TestUserRepository_Save(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			postgresHandler.EXPECT().Execute(context.Background(), CreateUser, gomock.Any(), tt.args.login, tt.args.pass).Return(nil)
+			postgresHandler.EXPECT().QueryRow(context.Background(), CreateUser, tt.args.login, tt.args.pass).Return(checkRow, nil)
 			postgresHandler.EXPECT().Execute(context.Background(), CreateAccount, gomock.Any()).Return(nil)
-			postgresHandler.EXPECT().QueryRow(context.Background(), GetNextUserID).Return(checkRow, nil)
 			userID, err := target.Save(context.Background(), tt.args.login, tt.args.pass)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("UserRepository Save() error = %v, wantErr %v", err, tt.wantErr)
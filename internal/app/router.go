@@ -4,65 +4,220 @@ import (
 	chi "github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/jwtauth/v5"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
 	"github.com/portnyagin/practicum_project/internal/app/handler"
 	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
-	"github.com/portnyagin/practicum_project/internal/app/infrastructure/postgres"
+	"github.com/portnyagin/practicum_project/internal/app/model"
 	"github.com/portnyagin/practicum_project/internal/app/mymiddleware"
 )
 
 func publicRoutes(
 	r chi.Router,
 	handler *handler.AuthHandler,
-	accrual *handler.AccrualHandler,
-	postgresHandlerTx *postgres.PostgresqlHandlerTX,
+	trustProxyHeaders bool,
+	txBeginner mymiddleware.TxBeginner,
+	audit mymiddleware.SecurityAudit,
+	log *infrastructure.Logger,
+) {
+	readWrite := mymiddleware.Transactional(txBeginner, mymiddleware.ReadWrite, log)
+	noTx := mymiddleware.Transactional(txBeginner, mymiddleware.NoTx, log)
+	r.Group(func(router chi.Router) {
+		if trustProxyHeaders {
+			router.Use(middleware.RealIP)
+		}
+		router.Use(middleware.CleanPath)
+		router.Use(middleware.Logger)
+		router.Use(middleware.Recoverer)
+		router.With(mymiddleware.Audit(dto.AuditRegister, audit, log), readWrite).Post("/api/user/register", handler.Register)
+		router.With(mymiddleware.Audit(dto.AuditLogin, audit, log), readWrite).Post("/api/user/login", handler.Login)
+		// обновление выполняется в собственной транзакции SessionService: отзыв сессии при повторном предъявлении
+		// токена фиксируется, хотя клиент получает 401
+		router.With(noTx).Post("/api/user/token/refresh", handler.RefreshToken)
+		router.With(noTx).Get("/.well-known/jwks.json", handler.PublicKeys)
+	})
+}
+
+// oidcRoutes - вход через провайдер OpenID Connect. Пользователь, впервые вошедший через провайдер, создается в транзакции запроса
+func oidcRoutes(
+	r chi.Router,
+	handler *handler.OIDCHandler,
+	txBeginner mymiddleware.TxBeginner,
+	audit mymiddleware.SecurityAudit,
+	log *infrastructure.Logger,
+) {
+	readWrite := mymiddleware.Transactional(txBeginner, mymiddleware.ReadWrite, log)
+	noTx := mymiddleware.Transactional(txBeginner, mymiddleware.NoTx, log)
+	r.Group(func(router chi.Router) {
+		router.Use(middleware.CleanPath)
+		router.Use(middleware.Logger)
+		router.Use(middleware.Recoverer)
+		router.With(noTx).Get("/api/user/oidc/login", handler.Login)
+		router.With(mymiddleware.Audit(dto.AuditLoginOIDC, audit, log), readWrite).Get("/api/user/oidc/callback", handler.Callback)
+	})
+}
+
+func protectedAuthRoutes(
+	r chi.Router,
+	auth *handler.Auth,
+	sessions mymiddleware.SessionChecker,
+	csrfProtection bool,
+	txBeginner mymiddleware.TxBeginner,
+	handler *handler.AuthHandler,
+	audit mymiddleware.SecurityAudit,
 	log *infrastructure.Logger,
 ) {
+	readWrite := mymiddleware.Transactional(txBeginner, mymiddleware.ReadWrite, log)
 	r.Group(func(router chi.Router) {
 		router.Use(middleware.CleanPath)
 		router.Use(middleware.Logger)
 		router.Use(middleware.Recoverer)
-		router.Use(mymiddleware.Transactional(postgresHandlerTx, log))
-		router.Post("/api/user/register", handler.Register)
-		router.Post("/api/user/login", handler.Login)
-		router.Post("/api/accrual/process/{orderNum}", accrual.ProcessOrder)
+		router.Use(auth.Verifier)
+		router.Use(jwtauth.Authenticator)
+		if csrfProtection {
+			router.Use(mymiddleware.CSRFProtect(log))
+		}
+		router.Use(mymiddleware.ActiveSession(sessions, log))
+		router.With(mymiddleware.Audit(dto.AuditLogout, audit, log), readWrite).Post("/api/user/logout", handler.Logout)
+		router.With(mymiddleware.Audit(dto.AuditPasswordChange, audit, log), readWrite).Post("/api/user/password", handler.ChangePassword)
+		router.With(mymiddleware.Audit(dto.AuditDeactivate, audit, log), readWrite).Post("/api/user/deactivate", handler.Deactivate)
 	})
 }
 
 func protectedOrderRoutes(
 	r chi.Router,
-	tokenAuth *jwtauth.JWTAuth,
-	postgresHandlerTx *postgres.PostgresqlHandlerTX,
+	auth *handler.Auth,
+	apiKeys handler.APIKeyAuthenticator,
+	sessions mymiddleware.SessionChecker,
+	csrfProtection bool,
+	txBeginner mymiddleware.TxBeginner,
 	handler *handler.OrderHandler,
 	log *infrastructure.Logger,
 ) {
+	readWrite := mymiddleware.Transactional(txBeginner, mymiddleware.ReadWrite, log)
+	readOnly := mymiddleware.Transactional(txBeginner, mymiddleware.ReadOnly, log)
 	r.Group(func(router chi.Router) {
 		router.Use(middleware.CleanPath)
 		router.Use(middleware.Logger)
 		router.Use(middleware.Recoverer)
-		router.Use(jwtauth.Verifier(tokenAuth))
+		// группа принимает API-ключи: каждому маршруту нужен RequireScope
+		router.Use(auth.VerifierOrAPIKey(apiKeys))
 		router.Use(jwtauth.Authenticator)
-		router.Use(mymiddleware.Transactional(postgresHandlerTx, log))
-		router.Post("/api/user/orders", handler.RegisterNewOrder)
-		router.Get("/api/user/orders", handler.GetOrderList)
+		if csrfProtection {
+			router.Use(mymiddleware.CSRFProtect(log))
+		}
+		router.Use(mymiddleware.ActiveSession(sessions, log))
+		router.With(mymiddleware.RequireScope(model.ScopeOrdersWrite, log), readWrite).Post("/api/user/orders", handler.RegisterNewOrder)
+		router.With(mymiddleware.RequireScope(model.ScopeOrdersRead, log), readOnly).Get("/api/user/orders", handler.GetOrderList)
 	})
 }
 
 func protectedBalanceRoutes(
 	r chi.Router,
-	tokenAuth *jwtauth.JWTAuth,
-	postgresHandlerTx *postgres.PostgresqlHandlerTX,
+	auth *handler.Auth,
+	apiKeys handler.APIKeyAuthenticator,
+	sessions mymiddleware.SessionChecker,
+	csrfProtection bool,
+	txBeginner mymiddleware.TxBeginner,
 	handler *handler.BalanceHandler,
+	audit mymiddleware.SecurityAudit,
+	log *infrastructure.Logger,
+) {
+	readWrite := mymiddleware.Transactional(txBeginner, mymiddleware.ReadWrite, log)
+	readOnly := mymiddleware.Transactional(txBeginner, mymiddleware.ReadOnly, log)
+	r.Group(func(router chi.Router) {
+		router.Use(middleware.CleanPath)
+		router.Use(middleware.Logger)
+		router.Use(middleware.Recoverer)
+		router.Use(auth.Verifier)
+		router.Use(jwtauth.Authenticator)
+		if csrfProtection {
+			router.Use(mymiddleware.CSRFProtect(log))
+		}
+		router.Use(mymiddleware.ActiveSession(sessions, log))
+		router.With(readOnly).Get("/api/user/balance", handler.GetBalance)
+		router.With(readOnly).Get("/api/user/balance/withdrawals", handler.GetWithdrawalsList)
+		router.With(mymiddleware.Audit(dto.AuditTransfer, audit, log), readWrite).Post("/api/user/balance/transfer", handler.Transfer)
+		router.With(readOnly).Get("/api/user/balance/statement", handler.GetStatement)
+		router.With(mymiddleware.Transactional(txBeginner, mymiddleware.ReadOnlyStream, log)).Get("/api/user/balance/statement/export", handler.ExportStatement)
+	})
+	r.Group(func(router chi.Router) {
+		router.Use(middleware.CleanPath)
+		router.Use(middleware.Logger)
+		router.Use(middleware.Recoverer)
+		// группа принимает API-ключи: каждому маршруту нужен RequireScope
+		router.Use(auth.VerifierOrAPIKey(apiKeys))
+		router.Use(jwtauth.Authenticator)
+		if csrfProtection {
+			router.Use(mymiddleware.CSRFProtect(log))
+		}
+		router.Use(mymiddleware.ActiveSession(sessions, log))
+		router.With(mymiddleware.Audit(dto.AuditWithdraw, audit, log), mymiddleware.RequireScope(model.ScopeWithdraw, log), readWrite).Post("/api/user/balance/withdraw", handler.Withdraw)
+	})
+}
+
+func protectedAPIKeyRoutes(
+	r chi.Router,
+	auth *handler.Auth,
+	sessions mymiddleware.SessionChecker,
+	csrfProtection bool,
+	txBeginner mymiddleware.TxBeginner,
+	handler *handler.APIKeyHandler,
+	audit mymiddleware.SecurityAudit,
+	log *infrastructure.Logger,
+) {
+	readWrite := mymiddleware.Transactional(txBeginner, mymiddleware.ReadWrite, log)
+	readOnly := mymiddleware.Transactional(txBeginner, mymiddleware.ReadOnly, log)
+	r.Group(func(router chi.Router) {
+		router.Use(middleware.CleanPath)
+		router.Use(middleware.Logger)
+		router.Use(middleware.Recoverer)
+		router.Use(auth.Verifier)
+		router.Use(jwtauth.Authenticator)
+		if csrfProtection {
+			router.Use(mymiddleware.CSRFProtect(log))
+		}
+		router.Use(mymiddleware.ActiveSession(sessions, log))
+		router.With(mymiddleware.Audit(dto.AuditAPIKeyCreate, audit, log), readWrite).Post("/api/user/api-keys", handler.Create)
+		router.With(readOnly).Get("/api/user/api-keys", handler.GetList)
+		router.With(mymiddleware.Audit(dto.AuditAPIKeyRevoke, audit, log), readWrite).Delete("/api/user/api-keys/{keyID}", handler.Revoke)
+	})
+}
+
+func adminRoutes(
+	r chi.Router,
+	auth *handler.Auth,
+	sessions mymiddleware.SessionChecker,
+	csrfProtection bool,
+	txBeginner mymiddleware.TxBeginner,
+	handler *handler.AdminHandler,
+	accrual *handler.AccrualHandler,
+	audit mymiddleware.SecurityAudit,
 	log *infrastructure.Logger,
 ) {
+	readWrite := mymiddleware.Transactional(txBeginner, mymiddleware.ReadWrite, log)
+	readOnly := mymiddleware.Transactional(txBeginner, mymiddleware.ReadOnly, log)
 	r.Group(func(router chi.Router) {
 		router.Use(middleware.CleanPath)
 		router.Use(middleware.Logger)
 		router.Use(middleware.Recoverer)
-		router.Use(jwtauth.Verifier(tokenAuth))
+		router.Use(auth.Verifier)
 		router.Use(jwtauth.Authenticator)
-		router.Use(mymiddleware.Transactional(postgresHandlerTx, log))
-		router.Get("/api/user/balance", handler.GetBalance)
-		router.Post("/api/user/balance/withdraw", handler.Withdraw)
-		router.Get("/api/user/balance/withdrawals", handler.GetWithdrawalsList)
+		if csrfProtection {
+			router.Use(mymiddleware.CSRFProtect(log))
+		}
+		router.Use(mymiddleware.RequireRole(model.RoleAdmin, log))
+		router.Use(mymiddleware.ActiveSession(sessions, log))
+		router.With(mymiddleware.Transactional(txBeginner, mymiddleware.ReadOnlySnapshot, log)).Get("/api/admin/ledger/audit", handler.LedgerAudit)
+		router.With(mymiddleware.Audit(dto.AuditLedgerFix, audit, log), readWrite).Post("/api/admin/ledger/audit/fix", handler.LedgerFix)
+		router.With(readOnly).Get("/api/admin/adjustments", handler.GetAdjustmentList)
+		router.With(mymiddleware.Audit(dto.AuditAdjustmentCreate, audit, log), readWrite).Post("/api/admin/adjustments", handler.CreateAdjustment)
+		router.With(mymiddleware.Audit(dto.AuditAdjustmentApprove, audit, log), readWrite).Post("/api/admin/adjustments/{adjustmentID}/approve", handler.ApproveAdjustment)
+		router.With(mymiddleware.Audit(dto.AuditAdjustmentReject, audit, log), readWrite).Post("/api/admin/adjustments/{adjustmentID}/reject", handler.RejectAdjustment)
+		router.With(mymiddleware.Audit(dto.AuditUserDeactivate, audit, log), readWrite).Post("/api/admin/users/{login}/deactivate", handler.DeactivateUser)
+		router.With(mymiddleware.Audit(dto.AuditRoleGrant, audit, log), readWrite).Put("/api/admin/users/{login}/roles/{role}", handler.GrantRole)
+		router.With(mymiddleware.Audit(dto.AuditRoleRevoke, audit, log), readWrite).Delete("/api/admin/users/{login}/roles/{role}", handler.RevokeRole)
+		router.With(mymiddleware.Audit(dto.AuditAccrualProcess, audit, log), readWrite).Post("/api/admin/accrual/process/{orderNum}", accrual.ProcessOrder)
+		router.With(readOnly).Get("/api/admin/security/events", handler.GetSecurityEvents)
+		router.With(mymiddleware.Transactional(txBeginner, mymiddleware.ReadOnlyStream, log)).Get("/api/admin/security/events/export", handler.ExportSecurityEvents)
 	})
 }
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"errors"
+	"github.com/jackc/pgx/v4"
 	"github.com/portnyagin/practicum_project/internal/app/dto"
 	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
 	"github.com/portnyagin/practicum_project/internal/app/model"
@@ -14,33 +15,29 @@ type AccrualClient interface {
 	GetAccrual(ctx context.Context, orderNum string) (*dto.Accrual, error)
 }
 
-type GophermartClient interface {
-	ProcessRequest(ctx context.Context, orderNum string) bool
-}
-
 type AccrualService struct {
-	dbOrder          model.OrderRepository
-	dbBalance        model.BalanceRepository
-	accrualClient    AccrualClient
-	gophermartClient GophermartClient
-	log              *infrastructure.Logger
-	enable           bool
+	dbOrder       model.OrderRepository
+	dbBalance     model.BalanceRepository
+	tx            model.UnitOfWork
+	accrualClient AccrualClient
+	log           *infrastructure.Logger
+	enable        bool
 }
 
 func NewAccrualService(
 	orderRepo model.OrderRepository,
 	balanceRepo model.BalanceRepository,
+	tx model.UnitOfWork,
 	accrualClient AccrualClient,
-	gophermartClient GophermartClient,
 	log *infrastructure.Logger,
 	enable bool,
 ) *AccrualService {
 	var target AccrualService
 	target.dbOrder = orderRepo
 	target.dbBalance = balanceRepo
+	target.tx = tx
 	target.log = log
 	target.accrualClient = accrualClient
-	target.gophermartClient = gophermartClient
 	target.enable = enable
 	return &target
 }
@@ -61,24 +58,58 @@ func (s *AccrualService) StartProcessJob(latency time.Duration) {
 func (s *AccrualService) process(ctx context.Context) {
 	// Выполнить обработку
 	s.log.Debug("AccrualService: process. Start process job")
-	orderList, err := s.dbOrder.FindNotProcessed(ctx)
+	// фоновая обработка идет вне запроса, транзакции middleware Transactional нет
+	var orderList []model.Order
+	err := s.tx.WithTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly}, func(ctx context.Context) error {
+		var err error
+		orderList, err = s.dbOrder.FindNotProcessed(ctx)
+		return err
+	})
 	if err != nil {
 		s.log.Error("AccrualService: process. Can't get order list", zap.Error(err))
 		return
 	}
+	// заказы обрабатываются напрямую, а не через HTTP API: ручная обработка доступна только администратору.
+	// Начисление запрашивается до начала транзакции: транзакция не ждет системы начислений, а ее повтор при конфликте
+	// не запрашивает начисление снова. Каждый заказ - в своей транзакции: ошибка откатывает только его,
+	// и заказ обрабатывается повторно в следующий раз
 	for _, order := range orderList {
-		s.gophermartClient.ProcessRequest(ctx, order.Num)
+		accrual, err := s.getAccrual(ctx, order.Num)
+		if err != nil {
+			continue
+		}
+		orderNum := order.Num
+		err = s.tx.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+			return s.applyAccrual(ctx, orderNum, accrual)
+		})
+		if err != nil {
+			s.log.Error("AccrualService: process. Can't process order", zap.String("OrderNum", order.Num), zap.Error(err))
+		}
 	}
 	s.log.Debug("AccrualService: process. Process job finished")
 }
 
+// ProcessOrder запрашивает начисление по заказу и применяет его в транзакции ctx
 func (s *AccrualService) ProcessOrder(ctx context.Context, orderNum string) error {
 	s.log.Debug("AccrualService: processOrder. Request")
-	accrual, err := s.accrualClient.GetAccrual(context.Background(), orderNum)
+	accrual, err := s.getAccrual(ctx, orderNum)
 	if err != nil {
-		s.log.Error("AccrualService: processOrder. Can't get accruals from remote service", zap.Error(err))
 		return err
 	}
+	return s.applyAccrual(ctx, orderNum, accrual)
+}
+
+func (s *AccrualService) getAccrual(ctx context.Context, orderNum string) (*dto.Accrual, error) {
+	accrual, err := s.accrualClient.GetAccrual(ctx, orderNum)
+	if err != nil {
+		s.log.Error("AccrualService: processOrder. Can't get accruals from remote service", zap.String("OrderNum", orderNum), zap.Error(err))
+		return nil, err
+	}
+	return accrual, nil
+}
+
+// applyAccrual меняет статус заказа и начисляет баллы по ответу системы начислений
+func (s *AccrualService) applyAccrual(ctx context.Context, orderNum string, accrual *dto.Accrual) error {
 	order, err := s.dbOrder.LockOrder(ctx, orderNum)
 	if err != nil {
 		s.log.Error("AccrualService: processOrder. Can't lock order", zap.Error(err))
@@ -122,7 +153,10 @@ func (s *AccrualService) ProcessOrder(ctx context.Context, orderNum string) erro
 	} else if accrual.Status == model.OrderStatusProcessing || accrual.Status == model.OrderStatusRegistered || accrual.Status == model.OrderStatusInvalid {
 		order.Status = accrual.Status
 		order.UpdatedAt = time.Now().Truncate(time.Second)
-		s.dbOrder.Save(ctx, order)
+		if err = s.dbOrder.Save(ctx, order); err != nil {
+			s.log.Error("AccrualService: processOrder. Can't save order", zap.Error(err))
+			return err
+		}
 	} else {
 		s.log.Error("AccrualService: processOrder. Recieved unexpected status", zap.String("OrderNum", order.Num), zap.String("Status", accrual.Status))
 		return errors.New("recieved unexpected status")
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/service/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type txKey struct{}
+
+// accrualRecorder запоминает заказы, запрошенные в системе начислений, и отвечает accrual или ошибкой err.
+// inTx - хотя бы один запрос сделан внутри транзакции
+type accrualRecorder struct {
+	orders  []string
+	accrual *dto.Accrual
+	err     error
+	inTx    bool
+}
+
+func (a *accrualRecorder) GetAccrual(ctx context.Context, orderNum string) (*dto.Accrual, error) {
+	a.orders = append(a.orders, orderNum)
+	if ctx.Value(txKey{}) != nil {
+		a.inTx = true
+	}
+	return a.accrual, a.err
+}
+
+func TestAccrualService_process(t *testing.T) {
+	tests := []struct {
+		name       string
+		orders     []model.Order
+		err        error
+		accrualErr error
+		saveErr    error
+		wantTx     int
+		want       []string
+	}{
+		{
+			name:    "AccrualService. process. Case #1. Each order is processed in its own transaction, errors do not stop processing",
+			orders:  []model.Order{{Num: "12345678903"}, {Num: "9278923470"}},
+			saveErr: errors.New("connection refused"),
+			wantTx:  2,
+			want:    []string{"12345678903", "9278923470"},
+		},
+		{
+			name:       "AccrualService. process. Case #2. Accrual system is unavailable, transaction is not started",
+			orders:     []model.Order{{Num: "12345678903"}, {Num: "9278923470"}},
+			accrualErr: errors.New("accrual system is unavailable"),
+			want:       []string{"12345678903", "9278923470"},
+		},
+		{
+			name: "AccrualService. process. Case #3. Can't read orders",
+			err:  errors.New("connection refused"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			orders := mocks.NewMockOrderRepository(mockCtrl)
+			tx := mocks.NewMockUnitOfWork(mockCtrl)
+			accrual := &accrualRecorder{accrual: &dto.Accrual{Status: model.OrderStatusProcessing}, err: tt.accrualErr}
+			target := NewAccrualService(orders, nil, tx, accrual, log, true)
+
+			txCtx := context.WithValue(context.Background(), txKey{}, "tx")
+			tx.EXPECT().WithTx(gomock.Any(), pgx.TxOptions{AccessMode: pgx.ReadOnly}, gomock.Any()).DoAndReturn(
+				func(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context) error) error {
+					return fn(txCtx)
+				})
+			orders.EXPECT().FindNotProcessed(txCtx).Return(tt.orders, tt.err)
+			// каждый заказ обрабатывается в своей транзакции чтения и записи
+			tx.EXPECT().WithTx(gomock.Any(), pgx.TxOptions{}, gomock.Any()).DoAndReturn(
+				func(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context) error) error {
+					return fn(txCtx)
+				}).Times(tt.wantTx)
+			orders.EXPECT().LockOrder(txCtx, gomock.Any()).DoAndReturn(
+				func(ctx context.Context, orderNum string) (*model.Order, error) {
+					return &model.Order{Num: orderNum, Status: model.OrderStatusNew}, nil
+				}).Times(tt.wantTx)
+			orders.EXPECT().Save(txCtx, gomock.Any()).Return(tt.saveErr).Times(tt.wantTx)
+
+			target.process(context.Background())
+			assert.Equal(t, tt.want, accrual.orders)
+			assert.False(t, accrual.inTx, "accrual is requested outside of transaction")
+		})
+	}
+}
+
+func TestAccrualService_ProcessOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		saveErr error
+		wantErr bool
+	}{
+		{
+			name: "AccrualService. ProcessOrder. Case #1. Status saved",
+		},
+		{
+			name:    "AccrualService. ProcessOrder. Case #2. Can't save status, transaction is rolled back",
+			saveErr: errors.New("connection refused"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			orders := mocks.NewMockOrderRepository(mockCtrl)
+			accrual := &accrualRecorder{accrual: &dto.Accrual{Order: "12345678903", Status: model.OrderStatusProcessing}}
+			target := NewAccrualService(orders, nil, nil, accrual, log, true)
+
+			ctx := context.Background()
+			order := &model.Order{ID: 1, Num: "12345678903", Status: model.OrderStatusNew}
+			orders.EXPECT().LockOrder(ctx, "12345678903").Return(order, nil)
+			orders.EXPECT().Save(ctx, order).Return(tt.saveErr)
+
+			err := target.ProcessOrder(ctx, "12345678903")
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, model.OrderStatusProcessing, order.Status)
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"go.uber.org/zap"
+	"math"
+	"time"
+)
+
+type AdjustmentService struct {
+	dbAdjustment model.AdjustmentRepository
+	dbBalance    model.BalanceRepository
+	dbUser       model.UserRepository
+	log          *infrastructure.Logger
+	// approvalThreshold - корректировки с модулем суммы выше порога требуют подтверждения вторым администратором.
+	// 0 - подтверждение не требуется
+	approvalThreshold float32
+}
+
+func NewAdjustmentService(
+	adjustmentRepo model.AdjustmentRepository,
+	balanceRepo model.BalanceRepository,
+	userRepo model.UserRepository,
+	log *infrastructure.Logger,
+	approvalThreshold float32,
+) *AdjustmentService {
+	var target AdjustmentService
+	target.dbAdjustment = adjustmentRepo
+	target.dbBalance = balanceRepo
+	target.dbUser = userRepo
+	target.log = log
+	target.approvalThreshold = approvalThreshold
+	return &target
+}
+
+func (s *AdjustmentService) mapAdjustmentModelToDTO(src *model.Adjustment) *dto.Adjustment {
+	res := dto.Adjustment{
+		ID:          src.ID,
+		UserID:      src.UserID,
+		Amount:      src.Amount,
+		ReasonCode:  src.ReasonCode,
+		Comment:     src.Comment,
+		RequestedBy: src.RequestedBy,
+		ApprovedBy:  src.ApprovedBy,
+		Status:      src.Status,
+		CreatedAt:   src.CreatedAt,
+	}
+	if src.Status != model.AdjustmentStatusPending && !src.DecidedAt.IsZero() {
+		decidedAt := src.DecidedAt
+		res.DecidedAt = &decidedAt
+	}
+	return &res
+}
+
+func (s *AdjustmentService) needApproval(amount float32) bool {
+	return s.approvalThreshold > 0 && math.Abs(float64(amount)) > float64(s.approvalThreshold)
+}
+
+// Create регистрирует корректировку от имени администратора admin. Если подтверждение не требуется,
+// корректировка сразу применяется к счету, иначе остается в статусе PENDING
+func (s *AdjustmentService) Create(ctx context.Context, req *dto.AdjustmentRequest, admin string) (*dto.Adjustment, error) {
+	if req == nil || req.Login == "" || req.Amount == 0 || !model.IsAdjustmentReason(req.ReasonCode) || admin == "" {
+		s.log.Debug("AdjustmentService: Create. Validation error")
+		return nil, dto.ErrBadParam
+	}
+	user, err := s.dbUser.GetUserByLogin(ctx, req.Login)
+	if err != nil {
+		if errors.Is(err, &model.NoRowFound) {
+			s.log.Debug("AdjustmentService: Create. User not found", zap.String("login", req.Login))
+			return nil, dto.ErrNotFound
+		}
+		s.log.Error("AdjustmentService: Create. Can't get user", zap.Error(err))
+		return nil, err
+	}
+	if user == nil {
+		return nil, dto.ErrNotFound
+	}
+	now := time.Now().Truncate(time.Second)
+	adjustment := model.Adjustment{
+		UserID:      user.ID,
+		Amount:      req.Amount,
+		ReasonCode:  req.ReasonCode,
+		Comment:     req.Comment,
+		RequestedBy: admin,
+		Status:      model.AdjustmentStatusPending,
+		CreatedAt:   now,
+	}
+	if err = s.dbAdjustment.Save(ctx, &adjustment); err != nil {
+		s.log.Error("AdjustmentService: Create. Can't save adjustment", zap.Error(err))
+		return nil, err
+	}
+	if s.needApproval(adjustment.Amount) {
+		s.log.Info("AdjustmentService: adjustment is waiting for approval",
+			zap.Int("adjustmentID", adjustment.ID),
+			zap.String("admin", admin),
+			zap.Float32("amount", adjustment.Amount),
+		)
+		return s.mapAdjustmentModelToDTO(&adjustment), nil
+	}
+	if err = s.apply(ctx, &adjustment, "", now); err != nil {
+		return nil, err
+	}
+	return s.mapAdjustmentModelToDTO(&adjustment), nil
+}
+
+func (s *AdjustmentService) Approve(ctx context.Context, adjustmentID int, admin string) (*dto.Adjustment, error) {
+	adjustment, err := s.lockPending(ctx, adjustmentID)
+	if err != nil {
+		return nil, err
+	}
+	if adjustment.RequestedBy == admin {
+		s.log.Warn("AdjustmentService: Approve. Self approval attempt", zap.Int("adjustmentID", adjustmentID), zap.String("admin", admin))
+		return nil, dto.ErrSelfApproval
+	}
+	if err = s.apply(ctx, adjustment, admin, time.Now().Truncate(time.Second)); err != nil {
+		return nil, err
+	}
+	return s.mapAdjustmentModelToDTO(adjustment), nil
+}
+
+func (s *AdjustmentService) Reject(ctx context.Context, adjustmentID int, admin string) (*dto.Adjustment, error) {
+	adjustment, err := s.lockPending(ctx, adjustmentID)
+	if err != nil {
+		return nil, err
+	}
+	adjustment.Status = model.AdjustmentStatusRejected
+	adjustment.ApprovedBy = admin
+	adjustment.DecidedAt = time.Now().Truncate(time.Second)
+	if err = s.dbAdjustment.Update(ctx, adjustment); err != nil {
+		s.log.Error("AdjustmentService: Reject. Can't update adjustment", zap.Error(err))
+		return nil, err
+	}
+	s.log.Info("AdjustmentService: adjustment rejected", zap.Int("adjustmentID", adjustmentID), zap.String("admin", admin))
+	return s.mapAdjustmentModelToDTO(adjustment), nil
+}
+
+func (s *AdjustmentService) GetList(ctx context.Context, status string) ([]dto.Adjustment, error) {
+	if status == "" {
+		status = model.AdjustmentStatusPending
+	}
+	list, err := s.dbAdjustment.FindByStatus(ctx, status)
+	if err != nil {
+		s.log.Error("AdjustmentService: GetList. Can't get adjustments", zap.String("status", status), zap.Error(err))
+		return nil, err
+	}
+	var resList []dto.Adjustment
+	for i := range list {
+		resList = append(resList, *s.mapAdjustmentModelToDTO(&list[i]))
+	}
+	return resList, nil
+}
+
+func (s *AdjustmentService) lockPending(ctx context.Context, adjustmentID int) (*model.Adjustment, error) {
+	adjustment, err := s.dbAdjustment.Lock(ctx, adjustmentID)
+	if err != nil {
+		if errors.Is(err, &model.NoRowFound) {
+			return nil, dto.ErrNotFound
+		}
+		s.log.Error("AdjustmentService: Can't lock adjustment", zap.Int("adjustmentID", adjustmentID), zap.Error(err))
+		return nil, err
+	}
+	if adjustment.Status != model.AdjustmentStatusPending {
+		return nil, dto.ErrAdjustmentNotPending
+	}
+	return adjustment, nil
+}
+
+// apply создает операцию ADJUSTMENT и меняет баланс счета. approver пуст, если подтверждение не требовалось
+func (s *AdjustmentService) apply(ctx context.Context, adjustment *model.Adjustment, approver string, now time.Time) error {
+	account, err := s.dbBalance.LockAccount(ctx, adjustment.UserID)
+	if err != nil {
+		s.log.Error("AdjustmentService: apply. Can't lock account", zap.Error(err))
+		return err
+	}
+	if account.Balance+adjustment.Amount < 0 {
+		s.log.Info("AdjustmentService: apply. Not enough funds", zap.Int("adjustmentID", adjustment.ID))
+		return dto.ErrNotEnoughFunds
+	}
+	operation := model.Operation{
+		AccountID:     account.ID,
+		OperationType: model.OperationAdjustment,
+		Amount:        adjustment.Amount,
+		Reason:        adjustment.ReasonCode,
+		Actor:         adjustment.RequestedBy,
+		ApprovedBy:    approver,
+		ProcessedAt:   now,
+	}
+	if err = s.dbBalance.CreateOperation(ctx, &operation); err != nil {
+		s.log.Error("AdjustmentService: apply. Can't create operation", zap.Error(err))
+		return err
+	}
+	account.Balance += adjustment.Amount
+	if err = s.dbBalance.SaveAccount(ctx, account); err != nil {
+		s.log.Error("AdjustmentService: apply. Can't save account", zap.Error(err))
+		return err
+	}
+	adjustment.Status = model.AdjustmentStatusApplied
+	adjustment.ApprovedBy = approver
+	adjustment.OperationID = operation.ID
+	adjustment.DecidedAt = now
+	if err = s.dbAdjustment.Update(ctx, adjustment); err != nil {
+		s.log.Error("AdjustmentService: apply. Can't update adjustment", zap.Error(err))
+		return err
+	}
+	s.log.Info("AdjustmentService: adjustment applied",
+		zap.Int("adjustmentID", adjustment.ID),
+		zap.Int("userID", adjustment.UserID),
+		zap.Float32("amount", adjustment.Amount),
+		zap.String("reason", adjustment.ReasonCode),
+		zap.String("requestedBy", adjustment.RequestedBy),
+		zap.String("approvedBy", approver),
+	)
+	return nil
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/service/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAdjustmentService_Create(t *testing.T) {
+	type args struct {
+		req     *dto.AdjustmentRequest
+		balance float32
+	}
+	type wants struct {
+		error   error
+		status  string
+		balance float32
+	}
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name: "AdjustmentService. Create. Case 1. Applied immediately",
+			args: args{
+				req:     &dto.AdjustmentRequest{Login: "user", Amount: 50, ReasonCode: model.AdjustmentReasonCompensation},
+				balance: 10,
+			},
+			wants: wants{status: model.AdjustmentStatusApplied, balance: 60},
+		},
+		{
+			name: "AdjustmentService. Create. Case 2. Negative adjustment",
+			args: args{
+				req:     &dto.AdjustmentRequest{Login: "user", Amount: -10, ReasonCode: model.AdjustmentReasonFraudReversal},
+				balance: 10,
+			},
+			wants: wants{status: model.AdjustmentStatusApplied, balance: 0},
+		},
+		{
+			name: "AdjustmentService. Create. Case 3. Balance goes negative",
+			args: args{
+				req:     &dto.AdjustmentRequest{Login: "user", Amount: -20, ReasonCode: model.AdjustmentReasonFraudReversal},
+				balance: 10,
+			},
+			wants: wants{error: dto.ErrNotEnoughFunds},
+		},
+		{
+			name: "AdjustmentService. Create. Case 4. Above threshold",
+			args: args{
+				req:     &dto.AdjustmentRequest{Login: "user", Amount: 500, ReasonCode: model.AdjustmentReasonGoodwill},
+				balance: 10,
+			},
+			wants: wants{status: model.AdjustmentStatusPending, balance: 10},
+		},
+		{
+			name: "AdjustmentService. Create. Case 5. Unknown reason",
+			args: args{
+				req: &dto.AdjustmentRequest{Login: "user", Amount: 5, ReasonCode: "GIFT"},
+			},
+			wants: wants{error: dto.ErrBadParam},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			ctx := context.Background()
+			adjustmentRepository := mocks.NewMockAdjustmentRepository(mockCtrl)
+			balanceRepository := mocks.NewMockBalanceRepository(mockCtrl)
+			userRepository := mocks.NewMockUserRepository(mockCtrl)
+			target := NewAdjustmentService(adjustmentRepository, balanceRepository, userRepository, log, 100)
+
+			account := &model.Account{ID: 10, UserID: 1, Balance: tt.args.balance}
+			userRepository.EXPECT().GetUserByLogin(ctx, "user").Return(&model.User{ID: 1, Login: "user"}, nil).AnyTimes()
+			adjustmentRepository.EXPECT().Save(ctx, gomock.Any()).DoAndReturn(
+				func(ctx context.Context, a *model.Adjustment) error {
+					a.ID = 7
+					return nil
+				}).AnyTimes()
+			adjustmentRepository.EXPECT().Update(ctx, gomock.Any()).Return(nil).AnyTimes()
+			balanceRepository.EXPECT().LockAccount(ctx, 1).Return(account, nil).AnyTimes()
+			balanceRepository.EXPECT().CreateOperation(ctx, gomock.Any()).DoAndReturn(
+				func(ctx context.Context, o *model.Operation) error {
+					assert.Equal(t, model.OperationAdjustment, o.OperationType)
+					assert.Equal(t, tt.args.req.Amount, o.Amount)
+					assert.Equal(t, tt.args.req.ReasonCode, o.Reason)
+					assert.Equal(t, "admin", o.Actor)
+					assert.Empty(t, o.ApprovedBy)
+					return nil
+				}).AnyTimes()
+			balanceRepository.EXPECT().SaveAccount(ctx, account).Return(nil).AnyTimes()
+
+			res, err := target.Create(ctx, tt.args.req, "admin")
+			if tt.wants.error != nil {
+				assert.ErrorIs(t, err, tt.wants.error)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.wants.status, res.Status)
+				assert.Equal(t, 7, res.ID)
+				assert.Equal(t, tt.wants.balance, account.Balance)
+			}
+		})
+	}
+}
+
+func TestAdjustmentService_Approve(t *testing.T) {
+	type args struct {
+		adjustment *model.Adjustment
+		admin      string
+	}
+	type wants struct {
+		error  error
+		status string
+	}
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name: "AdjustmentService. Approve. Case 1. Approved by second admin",
+			args: args{
+				adjustment: &model.Adjustment{ID: 1, UserID: 1, Amount: 500, RequestedBy: "admin", Status: model.AdjustmentStatusPending},
+				admin:      "auditor",
+			},
+			wants: wants{status: model.AdjustmentStatusApplied},
+		},
+		{
+			name: "AdjustmentService. Approve. Case 2. Self approval",
+			args: args{
+				adjustment: &model.Adjustment{ID: 1, UserID: 1, Amount: 500, RequestedBy: "admin", Status: model.AdjustmentStatusPending},
+				admin:      "admin",
+			},
+			wants: wants{error: dto.ErrSelfApproval},
+		},
+		{
+			name: "AdjustmentService. Approve. Case 3. Already rejected",
+			args: args{
+				adjustment: &model.Adjustment{ID: 1, UserID: 1, Amount: 500, RequestedBy: "admin", Status: model.AdjustmentStatusRejected},
+				admin:      "auditor",
+			},
+			wants: wants{error: dto.ErrAdjustmentNotPending},
+		},
+		{
+			name: "AdjustmentService. Approve. Case 4. Not found",
+			args: args{
+				admin: "auditor",
+			},
+			wants: wants{error: dto.ErrNotFound},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			ctx := context.Background()
+			adjustmentRepository := mocks.NewMockAdjustmentRepository(mockCtrl)
+			balanceRepository := mocks.NewMockBalanceRepository(mockCtrl)
+			userRepository := mocks.NewMockUserRepository(mockCtrl)
+			target := NewAdjustmentService(adjustmentRepository, balanceRepository, userRepository, log, 100)
+
+			if tt.args.adjustment == nil {
+				adjustmentRepository.EXPECT().Lock(ctx, 1).Return(nil, &model.NoRowFound)
+			} else {
+				adjustmentRepository.EXPECT().Lock(ctx, 1).Return(tt.args.adjustment, nil)
+			}
+			adjustmentRepository.EXPECT().Update(ctx, gomock.Any()).Return(nil).AnyTimes()
+			balanceRepository.EXPECT().LockAccount(ctx, 1).Return(&model.Account{ID: 10, UserID: 1}, nil).AnyTimes()
+			// операция в учете называет и автора, и подтвердившего корректировку
+			balanceRepository.EXPECT().CreateOperation(ctx, gomock.Any()).DoAndReturn(
+				func(ctx context.Context, o *model.Operation) error {
+					assert.Equal(t, tt.args.adjustment.RequestedBy, o.Actor)
+					assert.Equal(t, tt.args.admin, o.ApprovedBy)
+					return nil
+				}).AnyTimes()
+			balanceRepository.EXPECT().SaveAccount(ctx, gomock.Any()).Return(nil).AnyTimes()
+
+			res, err := target.Approve(ctx, 1, tt.args.admin)
+			if tt.wants.error != nil {
+				assert.ErrorIs(t, err, tt.wants.error)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.wants.status, res.Status)
+				assert.Equal(t, tt.args.admin, res.ApprovedBy)
+				assert.NotNil(t, res.DecidedAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+// apiKeyPrefix - ключ имеет вид gm_<id>_<secret>. По id ключ ищется в базе, secret сверяется с хешем
+const apiKeyPrefix = "gm_"
+
+type APIKeyService struct {
+	dbAPIKey model.APIKeyRepository
+	log      *infrastructure.Logger
+}
+
+func NewAPIKeyService(apiKeyRepo model.APIKeyRepository, log *infrastructure.Logger) *APIKeyService {
+	var target APIKeyService
+	target.dbAPIKey = apiKeyRepo
+	target.log = log
+	return &target
+}
+
+func parseAPIKey(key string) (keyID string, secret string, ok bool) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(key, apiKeyPrefix), "_", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// Create выпускает ключ пользователя userID. Ключ целиком возвращается только в ответе на создание
+func (s *APIKeyService) Create(ctx context.Context, userID int, req *dto.APIKeyRequest) (*dto.APIKey, error) {
+	if req == nil {
+		return nil, dto.ErrBadParam
+	}
+	var violations []dto.Violation
+	if req.Name == "" {
+		violations = append(violations, dto.Violation{Field: "name", Code: dto.FieldRequired})
+	}
+	if len(req.Scopes) == 0 {
+		violations = append(violations, dto.Violation{Field: "scopes", Code: dto.FieldRequired})
+	}
+	for _, scope := range req.Scopes {
+		if !model.IsKnownScope(scope) {
+			violations = append(violations, dto.Violation{Field: "scopes", Code: dto.UnknownScope})
+			break
+		}
+	}
+	if violations != nil {
+		return nil, &dto.ValidationError{Violations: violations}
+	}
+	keyID, err := NewReference()
+	if err != nil {
+		s.log.Error("APIKeyService: Create. Can't generate key id", zap.Error(err))
+		return nil, err
+	}
+	secret, err := NewReference()
+	if err != nil {
+		s.log.Error("APIKeyService: Create. Can't generate key", zap.Error(err))
+		return nil, err
+	}
+	key := model.APIKey{
+		ID:        keyID,
+		UserID:    userID,
+		Name:      req.Name,
+		KeyHash:   hashToken(secret),
+		Scopes:    req.Scopes,
+		CreatedAt: time.Now(),
+	}
+	if err = s.dbAPIKey.Save(ctx, &key); err != nil {
+		s.log.Error("APIKeyService: Create. Can't save key", zap.Int("userID", userID), zap.Error(err))
+		return nil, err
+	}
+	s.log.Info("APIKeyService: api key created", zap.Int("userID", userID), zap.String("keyID", keyID), zap.Strings("scopes", req.Scopes))
+	res := toAPIKeyDTO(&key)
+	res.Key = apiKeyPrefix + keyID + "_" + secret
+	return res, nil
+}
+
+func (s *APIKeyService) GetList(ctx context.Context, userID int) ([]dto.APIKey, error) {
+	keys, err := s.dbAPIKey.ListByUser(ctx, userID)
+	if err != nil {
+		s.log.Error("APIKeyService: GetList. Can't get keys", zap.Int("userID", userID), zap.Error(err))
+		return nil, err
+	}
+	res := make([]dto.APIKey, 0, len(keys))
+	for i := range keys {
+		res = append(res, *toAPIKeyDTO(&keys[i]))
+	}
+	return res, nil
+}
+
+func (s *APIKeyService) Revoke(ctx context.Context, userID int, keyID string) error {
+	err := s.dbAPIKey.Revoke(ctx, userID, keyID, time.Now())
+	if err != nil {
+		if errors.Is(err, &model.NoRowFound) {
+			return dto.ErrNotFound
+		}
+		s.log.Error("APIKeyService: Revoke. Can't revoke key", zap.Int("userID", userID), zap.Error(err))
+		return err
+	}
+	s.log.Info("APIKeyService: api key revoked", zap.Int("userID", userID), zap.String("keyID", keyID))
+	return nil
+}
+
+// Authenticate возвращает dto.ErrUnauthorized для неизвестного, отозванного или искаженного ключа
+func (s *APIKeyService) Authenticate(ctx context.Context, key string) (*dto.APIKeyPrincipal, error) {
+	keyID, secret, ok := parseAPIKey(key)
+	if !ok {
+		return nil, dto.ErrUnauthorized
+	}
+	stored, err := s.dbAPIKey.GetActive(ctx, keyID)
+	if err != nil {
+		if errors.Is(err, &model.NoRowFound) {
+			s.log.Info("APIKeyService: Authenticate. Key not found", zap.String("keyID", keyID))
+			return nil, dto.ErrUnauthorized
+		}
+		s.log.Error("APIKeyService: Authenticate. Can't get key", zap.Error(err))
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(stored.KeyHash)) != 1 {
+		s.log.Warn("APIKeyService: Authenticate. Wrong key secret", zap.String("keyID", keyID))
+		return nil, dto.ErrUnauthorized
+	}
+	return &dto.APIKeyPrincipal{KeyID: stored.ID, UserID: stored.UserID, Login: stored.Login, Scopes: stored.Scopes}, nil
+}
+
+func toAPIKeyDTO(key *model.APIKey) *dto.APIKey {
+	res := dto.APIKey{
+		ID:        key.ID,
+		Name:      key.Name,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+	}
+	if !key.RevokedAt.IsZero() {
+		revokedAt := key.RevokedAt
+		res.RevokedAt = &revokedAt
+	}
+	return &res
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/service/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyService_CreateAuthenticate(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	ctx := context.Background()
+	apiKeyRepository := mocks.NewMockAPIKeyRepository(mockCtrl)
+	target := NewAPIKeyService(apiKeyRepository, log)
+
+	var stored model.APIKey
+	apiKeyRepository.EXPECT().Save(ctx, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, k *model.APIKey) error {
+			stored = *k
+			stored.Login = "user"
+			return nil
+		})
+	apiKeyRepository.EXPECT().GetActive(ctx, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, keyID string) (*model.APIKey, error) {
+			if keyID == stored.ID && stored.RevokedAt.IsZero() {
+				k := stored
+				return &k, nil
+			}
+			return nil, &model.NoRowFound
+		}).AnyTimes()
+	apiKeyRepository.EXPECT().Revoke(ctx, 1, gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, userID int, keyID string, now time.Time) error {
+			if keyID != stored.ID || !stored.RevokedAt.IsZero() {
+				return &model.NoRowFound
+			}
+			stored.RevokedAt = now
+			return nil
+		}).Times(2)
+
+	created, err := target.Create(ctx, 1, &dto.APIKeyRequest{Name: "import", Scopes: []string{model.ScopeOrdersWrite}})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(created.Key, "gm_"+created.ID+"_"))
+	assert.NotContains(t, created.Key, stored.KeyHash, "key must be stored hashed")
+
+	principal, err := target.Authenticate(ctx, created.Key)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, principal.UserID)
+		assert.Equal(t, "user", principal.Login)
+		assert.Equal(t, []string{model.ScopeOrdersWrite}, principal.Scopes)
+	}
+
+	_, err = target.Authenticate(ctx, created.Key+"0")
+	assert.ErrorIs(t, err, dto.ErrUnauthorized)
+	_, err = target.Authenticate(ctx, "garbage")
+	assert.ErrorIs(t, err, dto.ErrUnauthorized)
+
+	assert.NoError(t, target.Revoke(ctx, 1, created.ID))
+	_, err = target.Authenticate(ctx, created.Key)
+	assert.ErrorIs(t, err, dto.ErrUnauthorized)
+	assert.ErrorIs(t, target.Revoke(ctx, 1, created.ID), dto.ErrNotFound)
+}
+
+func TestAPIKeyService_CreateValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *dto.APIKeyRequest
+	}{
+		{
+			name: "APIKeyService. Create. Case 1. Without name",
+			req:  &dto.APIKeyRequest{Scopes: []string{model.ScopeOrdersRead}},
+		},
+		{
+			name: "APIKeyService. Create. Case 2. Without scopes",
+			req:  &dto.APIKeyRequest{Name: "import"},
+		},
+		{
+			name: "APIKeyService. Create. Case 3. Unknown scope",
+			req:  &dto.APIKeyRequest{Name: "import", Scopes: []string{model.ScopeOrdersRead, "balance:transfer"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			target := NewAPIKeyService(mocks.NewMockAPIKeyRepository(mockCtrl), log)
+			_, err := target.Create(context.Background(), 1, tt.req)
+			assert.ErrorIs(t, err, dto.ErrBadParam)
+		})
+	}
+}
@@ -7,31 +7,27 @@ import (
 	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
 	"github.com/portnyagin/practicum_project/internal/app/model"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
+	"time"
 )
 
 type AuthService struct {
-	dbUser model.UserRepository
-	log    *infrastructure.Logger
+	dbUser         model.UserRepository
+	dbSession      model.SessionRepository
+	log            *infrastructure.Logger
+	hasher         *PasswordHasher
+	passwordPolicy *PasswordPolicy
 }
 
-func NewAuthService(userRepo model.UserRepository, log *infrastructure.Logger) *AuthService {
+func NewAuthService(userRepo model.UserRepository, sessionRepo model.SessionRepository, log *infrastructure.Logger, hasher *PasswordHasher, policy *PasswordPolicy) *AuthService {
 	var target AuthService
 	target.dbUser = userRepo
+	target.dbSession = sessionRepo
 	target.log = log
+	target.hasher = hasher
+	target.passwordPolicy = policy
 	return &target
 }
 
-func (s *AuthService) hashPassword(pass string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(pass), 4)
-	return string(bytes), err
-}
-
-func (s *AuthService) checkPasswordHash(pass string, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass))
-	return err == nil
-}
-
 func (s *AuthService) Register(ctx context.Context, user *dto.User) (*dto.User, error) {
 	if user == nil {
 		s.log.Debug("AuthService: Register. got nil user")
@@ -39,15 +35,30 @@ func (s *AuthService) Register(ctx context.Context, user *dto.User) (*dto.User,
 	}
 	if (user.Login == "") || (user.Pass == "") {
 		s.log.Warn("AuthService: Register. Validation error", zap.String("user", user.Login))
-		return nil, dto.ErrBadParam
+		var violations []dto.Violation
+		if user.Login == "" {
+			violations = append(violations, dto.Violation{Field: "login", Code: dto.FieldRequired})
+		}
+		if user.Pass == "" {
+			violations = append(violations, dto.Violation{Field: "password", Code: dto.FieldRequired})
+		}
+		return nil, &dto.ValidationError{Violations: violations}
+	}
+	if err := s.passwordPolicy.Validate(user.Login, user.Pass); err != nil {
+		s.log.Info("AuthService: Register. Password policy violation", zap.String("user", user.Login), zap.Error(err))
+		return nil, err
 	}
 
-	hp, err := s.hashPassword(user.Pass)
+	hp, err := s.hasher.Hash(user.Pass)
 	if err != nil {
 		s.log.Error("AuthService: Register. Can't calculate hash", zap.String("login", user.Login), zap.Error(err))
 		return nil, err
 	}
 	user.ID, err = s.dbUser.Save(ctx, user.Login, hp)
+	if errors.Is(err, &model.UniqueViolation) {
+		s.log.Info("AuthService: Register. Login is taken", zap.String("login", user.Login))
+		return nil, dto.ErrDuplicateKey
+	}
 	if err != nil {
 		s.log.Error("AuthService: Register. Can't register user", zap.String("login", user.Login), zap.Error(err))
 		return nil, err
@@ -75,10 +86,183 @@ func (s *AuthService) Check(ctx context.Context, user *dto.User) (*dto.User, err
 			return nil, err
 		}
 	}
-	if s.checkPasswordHash(user.Pass, modelUser.Pass) {
+	if s.hasher.Verify(user.Pass, modelUser.Pass) {
 		user.ID = modelUser.ID
+		s.rehash(ctx, modelUser.ID, user.Pass, modelUser.Pass)
 		return user, nil
 	} else {
 		return nil, nil
 	}
 }
+
+// rehash пересчитывает хеш пароля, полученный со слабыми параметрами. Ошибка не мешает входу пользователя
+func (s *AuthService) rehash(ctx context.Context, userID int, pass string, hash string) {
+	if !s.hasher.NeedsRehash(hash) {
+		return
+	}
+	newHash, err := s.hasher.Hash(pass)
+	if err != nil {
+		s.log.Error("AuthService: rehash. Can't calculate hash", zap.Int("userID", userID), zap.Error(err))
+		return
+	}
+	if err = s.dbUser.UpdatePassword(ctx, userID, newHash); err != nil {
+		s.log.Error("AuthService: rehash. Can't update password", zap.Int("userID", userID), zap.Error(err))
+		return
+	}
+	s.log.Info("AuthService: password hash upgraded", zap.Int("userID", userID))
+}
+
+// ChangePassword меняет пароль после проверки старого и отзывает все сессии пользователя, кроме текущей
+func (s *AuthService) ChangePassword(ctx context.Context, userID int, sessionID string, req *dto.ChangePasswordRequest) error {
+	if req == nil {
+		s.log.Debug("AuthService: ChangePassword. got nil request")
+		return dto.ErrBadParam
+	}
+	if req.NewPass == "" {
+		return &dto.ValidationError{Violations: []dto.Violation{{Field: "new_password", Code: dto.FieldRequired}}}
+	}
+	user, err := s.checkPassword(ctx, userID, req.OldPass)
+	if err != nil {
+		return err
+	}
+	if err = s.passwordPolicy.Validate(user.Login, req.NewPass); err != nil {
+		s.log.Info("AuthService: ChangePassword. Password policy violation", zap.Int("userID", userID), zap.Error(err))
+		return err
+	}
+	hash, err := s.hasher.Hash(req.NewPass)
+	if err != nil {
+		s.log.Error("AuthService: ChangePassword. Can't calculate hash", zap.Int("userID", userID), zap.Error(err))
+		return err
+	}
+	if err = s.dbUser.UpdatePassword(ctx, userID, hash); err != nil {
+		s.log.Error("AuthService: ChangePassword. Can't update password", zap.Int("userID", userID), zap.Error(err))
+		return err
+	}
+	if err = s.dbSession.RevokeByUser(ctx, userID, sessionID, time.Now()); err != nil {
+		s.log.Error("AuthService: ChangePassword. Can't revoke sessions", zap.Int("userID", userID), zap.Error(err))
+		return err
+	}
+	s.log.Info("AuthService: password changed", zap.Int("userID", userID))
+	return nil
+}
+
+// Deactivate - пользователь удаляет свою учетную запись, подтверждая действие паролем
+func (s *AuthService) Deactivate(ctx context.Context, userID int, pass string) error {
+	if _, err := s.checkPassword(ctx, userID, pass); err != nil {
+		return err
+	}
+	return s.deactivate(ctx, userID)
+}
+
+// DeactivateByLogin - деактивация учетной записи администратором
+func (s *AuthService) DeactivateByLogin(ctx context.Context, login string) error {
+	user, err := s.dbUser.GetUserByLogin(ctx, login)
+	if err != nil {
+		if errors.Is(err, &model.NoRowFound) {
+			return dto.ErrNotFound
+		}
+		s.log.Error("AuthService: DeactivateByLogin. Can't get user", zap.String("login", login), zap.Error(err))
+		return err
+	}
+	return s.deactivate(ctx, user.ID)
+}
+
+func (s *AuthService) deactivate(ctx context.Context, userID int) error {
+	if err := s.dbUser.Deactivate(ctx, userID); err != nil {
+		s.log.Error("AuthService: Can't deactivate user", zap.Int("userID", userID), zap.Error(err))
+		return err
+	}
+	if err := s.dbSession.RevokeByUser(ctx, userID, "", time.Now()); err != nil {
+		s.log.Error("AuthService: Can't revoke sessions", zap.Int("userID", userID), zap.Error(err))
+		return err
+	}
+	s.log.Info("AuthService: user deactivated", zap.Int("userID", userID))
+	return nil
+}
+
+// GrantRole назначает роль пользователю login
+func (s *AuthService) GrantRole(ctx context.Context, login string, role string) error {
+	user, err := s.userForRole(ctx, login, role)
+	if err != nil {
+		return err
+	}
+	if err = s.dbUser.AddRole(ctx, user.ID, role); err != nil {
+		s.log.Error("AuthService: GrantRole. Can't add role", zap.String("login", login), zap.Error(err))
+		return err
+	}
+	s.log.Info("AuthService: role granted", zap.String("login", login), zap.String("role", role))
+	return nil
+}
+
+// RevokeRole снимает роль с пользователя login. Выданные токены действуют до обновления
+func (s *AuthService) RevokeRole(ctx context.Context, login string, role string) error {
+	user, err := s.userForRole(ctx, login, role)
+	if err != nil {
+		return err
+	}
+	if err = s.dbUser.RemoveRole(ctx, user.ID, role); err != nil {
+		s.log.Error("AuthService: RevokeRole. Can't remove role", zap.String("login", login), zap.Error(err))
+		return err
+	}
+	s.log.Info("AuthService: role revoked", zap.String("login", login), zap.String("role", role))
+	return nil
+}
+
+func (s *AuthService) userForRole(ctx context.Context, login string, role string) (*model.User, error) {
+	if !model.IsKnownRole(role) {
+		return nil, dto.ErrUnknownRole
+	}
+	user, err := s.dbUser.GetUserByLogin(ctx, login)
+	if err != nil {
+		if errors.Is(err, &model.NoRowFound) {
+			return nil, dto.ErrNotFound
+		}
+		s.log.Error("AuthService: Can't get user", zap.String("login", login), zap.Error(err))
+		return nil, err
+	}
+	return user, nil
+}
+
+// BootstrapAdmin назначает роль администратора пользователю user.Login, при отсутствии пользователя регистрирует его.
+// Используется для создания первого администратора
+func (s *AuthService) BootstrapAdmin(ctx context.Context, user *dto.User) (created bool, err error) {
+	if user == nil || user.Login == "" {
+		return false, dto.ErrBadParam
+	}
+	existing, err := s.dbUser.GetUserByLogin(ctx, user.Login)
+	switch {
+	case err == nil:
+		user.ID = existing.ID
+	case errors.Is(err, &model.NoRowFound):
+		if _, err = s.Register(ctx, user); err != nil {
+			return false, err
+		}
+		created = true
+	default:
+		s.log.Error("AuthService: BootstrapAdmin. Can't get user", zap.String("login", user.Login), zap.Error(err))
+		return false, err
+	}
+	if err = s.dbUser.AddRole(ctx, user.ID, model.RoleAdmin); err != nil {
+		s.log.Error("AuthService: BootstrapAdmin. Can't add role", zap.String("login", user.Login), zap.Error(err))
+		return false, err
+	}
+	s.log.Info("AuthService: administrator bootstrapped", zap.String("login", user.Login), zap.Bool("created", created))
+	return created, nil
+}
+
+// checkPassword возвращает dto.ErrWrongPassword, если пароль не совпадает с паролем активного пользователя userID
+func (s *AuthService) checkPassword(ctx context.Context, userID int, pass string) (*model.User, error) {
+	user, err := s.dbUser.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, &model.NoRowFound) {
+			return nil, dto.ErrNotFound
+		}
+		s.log.Error("AuthService: Can't get user", zap.Int("userID", userID), zap.Error(err))
+		return nil, err
+	}
+	if !s.hasher.Verify(pass, user.Pass) {
+		s.log.Info("AuthService: wrong password", zap.Int("userID", userID))
+		return nil, dto.ErrWrongPassword
+	}
+	return user, nil
+}
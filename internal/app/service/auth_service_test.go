@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/service/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAuthService_Register(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	ctx := context.Background()
+	userRepository := mocks.NewMockUserRepository(mockCtrl)
+	hasher, err := NewPasswordHasher(PasswordHashing{Algorithm: PasswordHashBcrypt, BcryptCost: 4})
+	require.NoError(t, err)
+	target := NewAuthService(userRepository, nil, log, hasher, NewPasswordPolicy(8, nil))
+
+	_, err = target.Register(ctx, &dto.User{Login: "user"})
+	assert.ErrorIs(t, err, dto.ErrBadParam)
+
+	_, err = target.Register(ctx, &dto.User{Login: "user", Pass: "123456"})
+	var validationErr *dto.ValidationError
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Len(t, validationErr.Violations, 2)
+	}
+
+	userRepository.EXPECT().Save(ctx, "user", gomock.Any()).DoAndReturn(
+		func(ctx context.Context, login string, hash string) (int, error) {
+			assert.NotEqual(t, "correct horse", hash)
+			assert.True(t, hasher.Verify("correct horse", hash))
+			return 1, nil
+		})
+	u, err := target.Register(ctx, &dto.User{Login: "user", Pass: "correct horse"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, u.ID)
+	}
+
+	userRepository.EXPECT().Save(ctx, "user", gomock.Any()).Return(0, &model.UniqueViolation)
+	_, err = target.Register(ctx, &dto.User{Login: "user", Pass: "correct horse"})
+	assert.ErrorIs(t, err, dto.ErrDuplicateKey)
+}
+
+func TestAuthService_CheckRehash(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	ctx := context.Background()
+	userRepository := mocks.NewMockUserRepository(mockCtrl)
+	weakHasher, err := NewPasswordHasher(PasswordHashing{Algorithm: PasswordHashBcrypt, BcryptCost: 4})
+	require.NoError(t, err)
+	hasher, err := NewPasswordHasher(PasswordHashing{Algorithm: PasswordHashBcrypt, BcryptCost: 5})
+	require.NoError(t, err)
+	target := NewAuthService(userRepository, nil, log, hasher, NewPasswordPolicy(8, nil))
+
+	weak, err := weakHasher.Hash("correct horse")
+	require.NoError(t, err)
+	userRepository.EXPECT().GetUserByLogin(ctx, "user").Return(&model.User{ID: 1, Login: "user", Pass: weak}, nil).Times(2)
+	userRepository.EXPECT().UpdatePassword(ctx, 1, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, userID int, hash string) error {
+			assert.False(t, hasher.NeedsRehash(hash))
+			assert.True(t, hasher.Verify("correct horse", hash))
+			return nil
+		})
+
+	u, err := target.Check(ctx, &dto.User{Login: "user", Pass: "correct horse"})
+	assert.NoError(t, err)
+	assert.NotNil(t, u)
+
+	// неверный пароль не приводит к пересчету хеша
+	u, err = target.Check(ctx, &dto.User{Login: "user", Pass: "wrong"})
+	assert.NoError(t, err)
+	assert.Nil(t, u)
+}
+
+func TestAuthService_ChangePassword(t *testing.T) {
+	type wants struct {
+		error   error
+		revoked bool
+	}
+	tests := []struct {
+		name  string
+		req   *dto.ChangePasswordRequest
+		wants wants
+	}{
+		{
+			name:  "AuthService. ChangePassword. Case 1. Positive",
+			req:   &dto.ChangePasswordRequest{OldPass: "correct horse", NewPass: "battery staple"},
+			wants: wants{revoked: true},
+		},
+		{
+			name:  "AuthService. ChangePassword. Case 2. Wrong old password",
+			req:   &dto.ChangePasswordRequest{OldPass: "wrong", NewPass: "battery staple"},
+			wants: wants{error: dto.ErrWrongPassword},
+		},
+		{
+			name:  "AuthService. ChangePassword. Case 3. Weak new password",
+			req:   &dto.ChangePasswordRequest{OldPass: "correct horse", NewPass: "short"},
+			wants: wants{error: dto.ErrBadParam},
+		},
+		{
+			name:  "AuthService. ChangePassword. Case 4. Empty new password",
+			req:   &dto.ChangePasswordRequest{OldPass: "correct horse"},
+			wants: wants{error: dto.ErrBadParam},
+		},
+	}
+	hasher, err := NewPasswordHasher(PasswordHashing{Algorithm: PasswordHashBcrypt, BcryptCost: 4})
+	require.NoError(t, err)
+	hash, err := hasher.Hash("correct horse")
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			ctx := context.Background()
+			userRepository := mocks.NewMockUserRepository(mockCtrl)
+			sessionRepository := mocks.NewMockSessionRepository(mockCtrl)
+			target := NewAuthService(userRepository, sessionRepository, log, hasher, NewPasswordPolicy(8, nil))
+
+			userRepository.EXPECT().GetUserByID(ctx, 1).Return(&model.User{ID: 1, Login: "user", Pass: hash}, nil).AnyTimes()
+			if tt.wants.revoked {
+				userRepository.EXPECT().UpdatePassword(ctx, 1, gomock.Any()).DoAndReturn(
+					func(ctx context.Context, userID int, newHash string) error {
+						assert.True(t, hasher.Verify(tt.req.NewPass, newHash))
+						return nil
+					})
+				sessionRepository.EXPECT().RevokeByUser(ctx, 1, "current", gomock.Any()).Return(nil)
+			}
+
+			err := target.ChangePassword(ctx, 1, "current", tt.req)
+			if tt.wants.error != nil {
+				assert.ErrorIs(t, err, tt.wants.error)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestAuthService_Deactivate(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	ctx := context.Background()
+	userRepository := mocks.NewMockUserRepository(mockCtrl)
+	sessionRepository := mocks.NewMockSessionRepository(mockCtrl)
+	hasher, err := NewPasswordHasher(PasswordHashing{Algorithm: PasswordHashBcrypt, BcryptCost: 4})
+	require.NoError(t, err)
+	target := NewAuthService(userRepository, sessionRepository, log, hasher, NewPasswordPolicy(8, nil))
+	hash, err := hasher.Hash("correct horse")
+	require.NoError(t, err)
+
+	userRepository.EXPECT().GetUserByID(ctx, 1).Return(&model.User{ID: 1, Login: "user", Pass: hash}, nil).Times(2)
+	assert.ErrorIs(t, target.Deactivate(ctx, 1, "wrong"), dto.ErrWrongPassword)
+
+	userRepository.EXPECT().Deactivate(ctx, 1).Return(nil)
+	sessionRepository.EXPECT().RevokeByUser(ctx, 1, "", gomock.Any()).Return(nil)
+	assert.NoError(t, target.Deactivate(ctx, 1, "correct horse"))
+
+	// администратор деактивирует пользователя по логину
+	userRepository.EXPECT().GetUserByLogin(ctx, "other").Return(&model.User{ID: 2, Login: "other"}, nil)
+	userRepository.EXPECT().Deactivate(ctx, 2).Return(nil)
+	sessionRepository.EXPECT().RevokeByUser(ctx, 2, "", gomock.Any()).Return(nil)
+	assert.NoError(t, target.DeactivateByLogin(ctx, "other"))
+
+	userRepository.EXPECT().GetUserByLogin(ctx, "missing").Return(nil, &model.NoRowFound)
+	assert.ErrorIs(t, target.DeactivateByLogin(ctx, "missing"), dto.ErrNotFound)
+}
+
+func TestAuthService_Roles(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	ctx := context.Background()
+	userRepository := mocks.NewMockUserRepository(mockCtrl)
+	hasher, err := NewPasswordHasher(PasswordHashing{Algorithm: PasswordHashBcrypt, BcryptCost: 4})
+	require.NoError(t, err)
+	target := NewAuthService(userRepository, nil, log, hasher, NewPasswordPolicy(8, nil))
+
+	assert.ErrorIs(t, target.GrantRole(ctx, "user", "root"), dto.ErrUnknownRole)
+
+	userRepository.EXPECT().GetUserByLogin(ctx, "user").Return(&model.User{ID: 1, Login: "user"}, nil).Times(2)
+	userRepository.EXPECT().AddRole(ctx, 1, model.RoleAdmin).Return(nil)
+	assert.NoError(t, target.GrantRole(ctx, "user", model.RoleAdmin))
+	userRepository.EXPECT().RemoveRole(ctx, 1, model.RoleAdmin).Return(nil)
+	assert.NoError(t, target.RevokeRole(ctx, "user", model.RoleAdmin))
+
+	userRepository.EXPECT().GetUserByLogin(ctx, "missing").Return(nil, &model.NoRowFound)
+	assert.ErrorIs(t, target.GrantRole(ctx, "missing", model.RoleAdmin), dto.ErrNotFound)
+}
+
+func TestAuthService_BootstrapAdmin(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	ctx := context.Background()
+	userRepository := mocks.NewMockUserRepository(mockCtrl)
+	hasher, err := NewPasswordHasher(PasswordHashing{Algorithm: PasswordHashBcrypt, BcryptCost: 4})
+	require.NoError(t, err)
+	target := NewAuthService(userRepository, nil, log, hasher, NewPasswordPolicy(8, nil))
+
+	// пользователь есть - пароль не нужен
+	userRepository.EXPECT().GetUserByLogin(ctx, "admin").Return(&model.User{ID: 1, Login: "admin"}, nil)
+	userRepository.EXPECT().AddRole(ctx, 1, model.RoleAdmin).Return(nil)
+	created, err := target.BootstrapAdmin(ctx, &dto.User{Login: "admin"})
+	assert.NoError(t, err)
+	assert.False(t, created)
+
+	// пользователя нет - регистрируется по общим правилам
+	userRepository.EXPECT().GetUserByLogin(ctx, "root").Return(nil, &model.NoRowFound).Times(2)
+	_, err = target.BootstrapAdmin(ctx, &dto.User{Login: "root", Pass: "short"})
+	assert.ErrorIs(t, err, dto.ErrBadParam)
+
+	userRepository.EXPECT().Save(ctx, "root", gomock.Any()).Return(2, nil)
+	userRepository.EXPECT().AddRole(ctx, 2, model.RoleAdmin).Return(nil)
+	created, err = target.BootstrapAdmin(ctx, &dto.User{Login: "root", Pass: "correct horse"})
+	assert.NoError(t, err)
+	assert.True(t, created)
+}
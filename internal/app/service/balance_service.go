@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"github.com/portnyagin/practicum_project/internal/app/dto"
 	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
 	"github.com/portnyagin/practicum_project/internal/app/model"
@@ -9,15 +10,38 @@ import (
 	"time"
 )
 
+// TransferLimits - ограничения на переводы между пользователями. Нулевое значение означает отсутствие ограничения
+type TransferLimits struct {
+	MinAmount  float32
+	MaxAmount  float32
+	DailyLimit float32
+}
+
 type BalanceService struct {
-	dbBalance model.BalanceRepository
-	log       *infrastructure.Logger
+	dbBalance      model.BalanceRepository
+	dbUser         model.UserRepository
+	log            *infrastructure.Logger
+	transferLimits TransferLimits
+	policy         *WithdrawalPolicy
+	// allowPartialWithdrawal разрешает оплачивать один заказ несколькими списаниями
+	allowPartialWithdrawal bool
 }
 
-func NewBalanceService(balanceRepo model.BalanceRepository, log *infrastructure.Logger) *BalanceService {
+func NewBalanceService(
+	balanceRepo model.BalanceRepository,
+	userRepo model.UserRepository,
+	log *infrastructure.Logger,
+	transferLimits TransferLimits,
+	policy *WithdrawalPolicy,
+	allowPartialWithdrawal bool,
+) *BalanceService {
 	var target BalanceService
 	target.dbBalance = balanceRepo
+	target.dbUser = userRepo
 	target.log = log
+	target.transferLimits = transferLimits
+	target.policy = policy
+	target.allowPartialWithdrawal = allowPartialWithdrawal
 	return &target
 }
 
@@ -27,6 +51,8 @@ func (s *BalanceService) mapWithdrawalModelToDTO(src model.Withdrawal) dto.Withd
 		Amount:      src.Amount,
 		Status:      src.Status,
 		ProcessedAt: src.ProcessedAt,
+		Part:        src.Part,
+		OrderTotal:  src.OrderTotal,
 	}
 }
 
@@ -37,6 +63,25 @@ func (s *BalanceService) mapWithdrawalListModelToDTO(src []model.Withdrawal) (re
 	return resList
 }
 
+func (s *BalanceService) mapStatementListModelToDTO(src []model.StatementEntry) (resList []dto.StatementEntry) {
+	for _, o := range src {
+		resList = append(resList, s.mapStatementModelToDTO(&o))
+	}
+	return resList
+}
+
+func (s *BalanceService) mapStatementModelToDTO(o *model.StatementEntry) dto.StatementEntry {
+	return dto.StatementEntry{
+		OperationType: o.OperationType,
+		Amount:        o.Amount,
+		OrderNum:      o.OrderNum,
+		TransferRef:   o.TransferRef,
+		Counterparty:  o.Counterparty,
+		Reason:        o.Reason,
+		ProcessedAt:   o.ProcessedAt,
+	}
+}
+
 func (s *BalanceService) GetCurrentBalance(ctx context.Context, userID int) (*dto.Balance, error) {
 	if userID == 0 {
 		s.log.Debug("BalanceService: GetCurrentBalance. got nil userID")
@@ -74,6 +119,28 @@ func (s *BalanceService) Withdraw(ctx context.Context, obj *dto.Withdraw, userID
 		s.log.Error("BalanceService: Withdraw. Unexpected error", zap.Error(err))
 		return err
 	}
+	// повторное списание по оплаченному заказу - конфликт, независимо от баланса и ограничений
+	paid, err := s.dbBalance.FindWithdrawalByOrder(ctx, obj.OrderNum)
+	if err != nil {
+		s.log.Error("BalanceService: Withdraw. Can't get order withdrawals", zap.Error(err))
+		return err
+	}
+	if len(paid) > 0 && !s.allowPartialWithdrawal {
+		s.log.Info("BalanceService: Withdraw. Order already paid", zap.String("orderNum", obj.OrderNum))
+		return dto.ErrWithdrawalDuplicate
+	}
+	for _, op := range paid {
+		if op.AccountID != account.ID {
+			s.log.Info("BalanceService: Withdraw. Order paid by another user", zap.String("orderNum", obj.OrderNum))
+			return dto.ErrWithdrawalDuplicate
+		}
+	}
+	now := time.Now().Truncate(time.Second)
+	if s.policy != nil {
+		if err = s.policy.Check(ctx, account, obj.Amount, now); err != nil {
+			return err
+		}
+	}
 
 	if account.Balance < obj.Amount {
 		s.log.Debug("BalanceService: Withdraw. In account not enough funds")
@@ -84,11 +151,15 @@ func (s *BalanceService) Withdraw(ctx context.Context, obj *dto.Withdraw, userID
 		AccountID:     account.ID,
 		Amount:        obj.Amount,
 		OrderNum:      obj.OrderNum,
+		OrderPart:     len(paid) + 1,
 		OperationType: model.OperationDebit,
-		ProcessedAt:   time.Now().Truncate(time.Second),
+		ProcessedAt:   now,
 	}
 	err = s.dbBalance.CreateOperation(ctx, &operation)
-
+	if errors.Is(err, &model.UniqueViolation) {
+		s.log.Info("BalanceService: Withdraw. Concurrent withdrawal for order", zap.String("orderNum", obj.OrderNum))
+		return dto.ErrWithdrawalDuplicate
+	}
 	if err != nil {
 		s.log.Error("BalanceService: Withdraw. Can't save operation", zap.Error(err))
 		return err
@@ -122,3 +193,168 @@ func (s *BalanceService) GetWithdrawalsList(ctx context.Context, userID int) ([]
 	resList := s.mapWithdrawalListModelToDTO(withdrawalList)
 	return resList, nil
 }
+
+func (s *BalanceService) checkTransferLimits(ctx context.Context, account *model.Account, amount float32, now time.Time) error {
+	if s.transferLimits.MinAmount > 0 && amount < s.transferLimits.MinAmount {
+		s.log.Debug("BalanceService: Transfer. Amount less than minimum", zap.Float32("amount", amount))
+		return dto.ErrTransferLimit
+	}
+	if s.transferLimits.MaxAmount > 0 && amount > s.transferLimits.MaxAmount {
+		s.log.Debug("BalanceService: Transfer. Amount greater than maximum", zap.Float32("amount", amount))
+		return dto.ErrTransferLimit
+	}
+	if s.transferLimits.DailyLimit > 0 {
+		dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		transferred, err := s.dbBalance.GetTransferredSum(ctx, account.ID, dayStart)
+		if err != nil {
+			s.log.Error("BalanceService: Transfer. Can't get transferred sum", zap.Error(err))
+			return err
+		}
+		if transferred+amount > s.transferLimits.DailyLimit {
+			s.log.Debug("BalanceService: Transfer. Daily limit exceeded", zap.Float32("transferred", transferred), zap.Float32("amount", amount))
+			return dto.ErrTransferLimit
+		}
+	}
+	return nil
+}
+
+// lockAccounts блокирует счета в порядке возрастания userID, чтобы встречные переводы не приводили к deadlock
+func (s *BalanceService) lockAccounts(ctx context.Context, senderID int, recipientID int) (sender *model.Account, recipient *model.Account, err error) {
+	firstID, secondID := senderID, recipientID
+	if firstID > secondID {
+		firstID, secondID = secondID, firstID
+	}
+	first, err := s.dbBalance.LockAccount(ctx, firstID)
+	if err != nil {
+		return nil, nil, err
+	}
+	second, err := s.dbBalance.LockAccount(ctx, secondID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if first.UserID == senderID {
+		return first, second, nil
+	}
+	return second, first, nil
+}
+
+func (s *BalanceService) Transfer(ctx context.Context, obj *dto.Transfer, userID int) error {
+	if userID == 0 {
+		s.log.Debug("BalanceService: Transfer. got nil userID")
+		return dto.ErrBadParam
+	}
+	if obj == nil || obj.Recipient == "" || obj.Amount <= 0 {
+		s.log.Debug("BalanceService: Transfer. Validation error")
+		return dto.ErrBadParam
+	}
+	recipientUser, err := s.dbUser.GetUserByLogin(ctx, obj.Recipient)
+	if err != nil {
+		if errors.Is(err, &model.NoRowFound) {
+			s.log.Debug("BalanceService: Transfer. Recipient not found", zap.String("recipient", obj.Recipient))
+			return dto.ErrRecipientNotFound
+		}
+		s.log.Error("BalanceService: Transfer. Can't get recipient", zap.Error(err))
+		return err
+	}
+	if recipientUser == nil {
+		s.log.Error("BalanceService: Transfer. Can't get recipient", zap.String("recipient", obj.Recipient))
+		return dto.ErrRecipientNotFound
+	}
+	if recipientUser.ID == userID {
+		s.log.Debug("BalanceService: Transfer. Transfer to self", zap.Int("userID", userID))
+		return dto.ErrTransferToSelf
+	}
+
+	sender, recipient, err := s.lockAccounts(ctx, userID, recipientUser.ID)
+	if err != nil {
+		s.log.Error("BalanceService: Transfer. Can't lock accounts", zap.Error(err))
+		return err
+	}
+	now := time.Now().Truncate(time.Second)
+	if err = s.checkTransferLimits(ctx, sender, obj.Amount, now); err != nil {
+		return err
+	}
+	if sender.Balance < obj.Amount {
+		s.log.Debug("BalanceService: Transfer. In account not enough funds")
+		return dto.ErrNotEnoughFunds
+	}
+
+	ref, err := NewReference()
+	if err != nil {
+		s.log.Error("BalanceService: Transfer. Can't generate transfer reference", zap.Error(err))
+		return err
+	}
+	debit := model.Operation{
+		AccountID:     sender.ID,
+		Amount:        obj.Amount,
+		OperationType: model.OperationDebit,
+		TransferRef:   ref,
+		ProcessedAt:   now,
+	}
+	credit := model.Operation{
+		AccountID:     recipient.ID,
+		Amount:        obj.Amount,
+		OperationType: model.OperationCredit,
+		TransferRef:   ref,
+		ProcessedAt:   now,
+	}
+	if err = s.dbBalance.CreateOperation(ctx, &debit); err != nil {
+		s.log.Error("BalanceService: Transfer. Can't save debit operation", zap.Error(err))
+		return err
+	}
+	if err = s.dbBalance.CreateOperation(ctx, &credit); err != nil {
+		s.log.Error("BalanceService: Transfer. Can't save credit operation", zap.Error(err))
+		return err
+	}
+	sender.Balance -= obj.Amount
+	sender.Debit += obj.Amount
+	recipient.Balance += obj.Amount
+	recipient.Credit += obj.Amount
+	if err = s.dbBalance.SaveAccount(ctx, sender); err != nil {
+		s.log.Error("BalanceService: Transfer. Can't save sender account", zap.Error(err))
+		return err
+	}
+	if err = s.dbBalance.SaveAccount(ctx, recipient); err != nil {
+		s.log.Error("BalanceService: Transfer. Can't save recipient account", zap.Error(err))
+		return err
+	}
+	s.log.Info("BalanceService: Transfer. Success", zap.String("transferRef", ref), zap.Int("from", userID), zap.Int("to", recipientUser.ID))
+	return nil
+}
+
+func (s *BalanceService) GetStatement(ctx context.Context, userID int) ([]dto.StatementEntry, error) {
+	if userID == 0 {
+		s.log.Debug("BalanceService: GetStatement. got nil userID")
+		return nil, dto.ErrBadParam
+	}
+
+	statement, err := s.dbBalance.FindStatementByUser(ctx, userID)
+	if err != nil {
+		s.log.Error("BalanceService: GetStatement. Can't get statement",
+			zap.Int("userID", userID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	return s.mapStatementListModelToDTO(statement), nil
+}
+
+// ExportStatement передает fn строки выписки по одной, не собирая выписку в памяти
+func (s *BalanceService) ExportStatement(ctx context.Context, userID int, fn func(entry *dto.StatementEntry) error) error {
+	if userID == 0 {
+		s.log.Debug("BalanceService: ExportStatement. got nil userID")
+		return dto.ErrBadParam
+	}
+	err := s.dbBalance.StreamStatementByUser(ctx, userID, func(o *model.StatementEntry) error {
+		entry := s.mapStatementModelToDTO(o)
+		return fn(&entry)
+	})
+	if err != nil {
+		s.log.Error("BalanceService: ExportStatement. Can't export statement",
+			zap.Int("userID", userID),
+			zap.Error(err),
+		)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/service/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBalanceService_Transfer(t *testing.T) {
+	type args struct {
+		userID   int
+		transfer *dto.Transfer
+		limits   TransferLimits
+	}
+	type wants struct {
+		wantErr bool
+		error   error
+	}
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name: "BalanceService. Transfer. Case 1. Empty transfer",
+			args: args{userID: 1, transfer: nil},
+			wants: wants{
+				wantErr: true,
+				error:   dto.ErrBadParam,
+			},
+		},
+		{
+			name: "BalanceService. Transfer. Case 2. Recipient not found",
+			args: args{userID: 1, transfer: &dto.Transfer{Recipient: "unknown", Amount: 10}},
+			wants: wants{
+				wantErr: true,
+				error:   dto.ErrRecipientNotFound,
+			},
+		},
+		{
+			name: "BalanceService. Transfer. Case 3. Transfer to self",
+			args: args{userID: 1, transfer: &dto.Transfer{Recipient: "sender", Amount: 10}},
+			wants: wants{
+				wantErr: true,
+				error:   dto.ErrTransferToSelf,
+			},
+		},
+		{
+			name: "BalanceService. Transfer. Case 4. Not enough funds",
+			args: args{userID: 1, transfer: &dto.Transfer{Recipient: "recipient", Amount: 1000}},
+			wants: wants{
+				wantErr: true,
+				error:   dto.ErrNotEnoughFunds,
+			},
+		},
+		{
+			name: "BalanceService. Transfer. Case 5. Maximum exceeded",
+			args: args{userID: 1, transfer: &dto.Transfer{Recipient: "recipient", Amount: 50}, limits: TransferLimits{MaxAmount: 20}},
+			wants: wants{
+				wantErr: true,
+				error:   dto.ErrTransferLimit,
+			},
+		},
+		{
+			name: "BalanceService. Transfer. Case 6. Daily limit exceeded",
+			args: args{userID: 1, transfer: &dto.Transfer{Recipient: "recipient", Amount: 50}, limits: TransferLimits{DailyLimit: 60}},
+			wants: wants{
+				wantErr: true,
+				error:   dto.ErrTransferLimit,
+			},
+		},
+		{
+			name: "BalanceService. Transfer. Case 7. Successful",
+			args: args{userID: 1, transfer: &dto.Transfer{Recipient: "recipient", Amount: 50}, limits: TransferLimits{DailyLimit: 100}},
+			wants: wants{
+				wantErr: false,
+			},
+		},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			balanceRepository := mocks.NewMockBalanceRepository(mockCtrl)
+			userRepository := mocks.NewMockUserRepository(mockCtrl)
+			target := NewBalanceService(balanceRepository, userRepository, log, tt.args.limits, nil, false)
+
+			userRepository.EXPECT().GetUserByLogin(ctx, "unknown").Return(nil, &model.NoRowFound).AnyTimes()
+			userRepository.EXPECT().GetUserByLogin(ctx, "sender").Return(&model.User{ID: 1, Login: "sender"}, nil).AnyTimes()
+			userRepository.EXPECT().GetUserByLogin(ctx, "recipient").Return(&model.User{ID: 2, Login: "recipient"}, nil).AnyTimes()
+
+			// Счета должны блокироваться в порядке возрастания userID
+			first := balanceRepository.EXPECT().LockAccount(ctx, 1).Return(&model.Account{ID: 10, UserID: 1, Balance: 100}, nil).AnyTimes()
+			balanceRepository.EXPECT().LockAccount(ctx, 2).Return(&model.Account{ID: 20, UserID: 2, Balance: 0}, nil).After(first).AnyTimes()
+			balanceRepository.EXPECT().GetTransferredSum(ctx, 10, gomock.Any()).Return(float32(20), nil).AnyTimes()
+
+			if !tt.wants.wantErr {
+				var ref string
+				balanceRepository.EXPECT().CreateOperation(ctx, gomock.Any()).DoAndReturn(
+					func(ctx context.Context, op *model.Operation) error {
+						if op.TransferRef == "" {
+							return errors.New("empty transfer ref")
+						}
+						if ref != "" && ref != op.TransferRef {
+							return errors.New("transfer refs differ")
+						}
+						ref = op.TransferRef
+						return nil
+					}).Times(2)
+				balanceRepository.EXPECT().SaveAccount(ctx, &model.Account{ID: 10, UserID: 1, Balance: 50, Debit: 50}).Return(nil)
+				balanceRepository.EXPECT().SaveAccount(ctx, &model.Account{ID: 20, UserID: 2, Balance: 50, Credit: 50}).Return(nil)
+			}
+
+			err := target.Transfer(ctx, tt.args.transfer, tt.args.userID)
+			if tt.wants.wantErr {
+				assert.ErrorIs(t, err, tt.wants.error, "Expected error is %v, got %v", tt.wants.error, err)
+			} else if err != nil {
+				t.Errorf("Transfer() error = %v, wantErr %v", err, tt.wants.wantErr)
+			}
+		})
+	}
+}
+
+func TestBalanceService_Withdraw(t *testing.T) {
+	const orderNum = "2377225624"
+	type args struct {
+		allowPartial bool
+		amount       float32
+		paid         []model.Operation
+		createErr    error
+	}
+	type wants struct {
+		wantErr bool
+		error   error
+		part    int
+	}
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name:  "BalanceService. Withdraw. Case 1. First withdrawal",
+			args:  args{},
+			wants: wants{part: 1},
+		},
+		{
+			name: "BalanceService. Withdraw. Case 2. Duplicate withdrawal",
+			args: args{
+				paid: []model.Operation{{AccountID: 10, OrderNum: orderNum, OrderPart: 1, Amount: 10}},
+			},
+			wants: wants{wantErr: true, error: dto.ErrWithdrawalDuplicate},
+		},
+		{
+			name: "BalanceService. Withdraw. Case 3. Partial withdrawal allowed",
+			args: args{
+				allowPartial: true,
+				paid:         []model.Operation{{AccountID: 10, OrderNum: orderNum, OrderPart: 1, Amount: 10}},
+			},
+			wants: wants{part: 2},
+		},
+		{
+			name: "BalanceService. Withdraw. Case 4. Order paid by another user",
+			args: args{
+				allowPartial: true,
+				paid:         []model.Operation{{AccountID: 20, OrderNum: orderNum, OrderPart: 1, Amount: 10}},
+			},
+			wants: wants{wantErr: true, error: dto.ErrWithdrawalDuplicate},
+		},
+		{
+			name: "BalanceService. Withdraw. Case 5. Concurrent withdrawal",
+			args: args{
+				createErr: &model.UniqueViolation,
+			},
+			wants: wants{wantErr: true, error: dto.ErrWithdrawalDuplicate, part: 1},
+		},
+		{
+			name: "BalanceService. Withdraw. Case 6. Duplicate withdrawal exceeding balance",
+			args: args{
+				amount: 1000,
+				paid:   []model.Operation{{AccountID: 10, OrderNum: orderNum, OrderPart: 1, Amount: 1000}},
+			},
+			wants: wants{wantErr: true, error: dto.ErrWithdrawalDuplicate},
+		},
+		{
+			name: "BalanceService. Withdraw. Case 7. Not enough funds",
+			args: args{
+				amount: 1000,
+			},
+			wants: wants{wantErr: true, error: dto.ErrNotEnoughFunds},
+		},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			balanceRepository := mocks.NewMockBalanceRepository(mockCtrl)
+			userRepository := mocks.NewMockUserRepository(mockCtrl)
+			target := NewBalanceService(balanceRepository, userRepository, log, TransferLimits{}, nil, tt.args.allowPartial)
+
+			balanceRepository.EXPECT().LockAccount(ctx, 1).Return(&model.Account{ID: 10, UserID: 1, Balance: 100}, nil)
+			balanceRepository.EXPECT().FindWithdrawalByOrder(ctx, orderNum).Return(tt.args.paid, nil)
+			balanceRepository.EXPECT().CreateOperation(ctx, gomock.Any()).DoAndReturn(
+				func(ctx context.Context, op *model.Operation) error {
+					assert.Equal(t, tt.wants.part, op.OrderPart)
+					return tt.args.createErr
+				}).MaxTimes(1)
+			balanceRepository.EXPECT().SaveAccount(ctx, gomock.Any()).Return(nil).MaxTimes(1)
+
+			amount := tt.args.amount
+			if amount == 0 {
+				amount = 10
+			}
+			err := target.Withdraw(ctx, &dto.Withdraw{OrderNum: orderNum, Amount: amount}, 1)
+			if tt.wants.wantErr {
+				assert.ErrorIs(t, err, tt.wants.error, "Expected error is %v, got %v", tt.wants.error, err)
+			} else if err != nil {
+				t.Errorf("Withdraw() error = %v, wantErr %v", err, tt.wants.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"go.uber.org/zap"
+	"time"
+)
+
+type LedgerService struct {
+	dbLedger  model.LedgerRepository
+	dbBalance model.BalanceRepository
+	log       *infrastructure.Logger
+}
+
+func NewLedgerService(ledgerRepo model.LedgerRepository, balanceRepo model.BalanceRepository, log *infrastructure.Logger) *LedgerService {
+	var target LedgerService
+	target.dbLedger = ledgerRepo
+	target.dbBalance = balanceRepo
+	target.log = log
+	return &target
+}
+
+// Check сверяет агрегаты счетов с операциями. При fix=true для каждого расхождения создается корректирующая
+// операция ADJUSTMENT: видимый пользователю баланс сохраняется, а debit/credit пересчитываются по операциям.
+// Вызывающий отвечает за транзакцию: корректировки должны фиксироваться или откатываться целиком.
+func (s *LedgerService) Check(ctx context.Context, fix bool) (*dto.LedgerReport, error) {
+	report := dto.LedgerReport{CheckedAt: time.Now().Truncate(time.Second)}
+	var err error
+
+	report.AccountsChecked, err = s.dbLedger.CountAccounts(ctx)
+	if err != nil {
+		s.log.Error("LedgerService: Check. Can't count accounts", zap.Error(err))
+		return nil, err
+	}
+	discrepancies, err := s.dbLedger.FindAccountDiscrepancies(ctx)
+	if err != nil {
+		s.log.Error("LedgerService: Check. Can't find account discrepancies", zap.Error(err))
+		return nil, err
+	}
+	for _, d := range discrepancies {
+		report.AccountMismatches = append(report.AccountMismatches, dto.AccountMismatch{
+			AccountID:        d.Account.ID,
+			UserID:           d.Account.UserID,
+			Balance:          d.Account.Balance,
+			Debit:            d.Account.Debit,
+			Credit:           d.Account.Credit,
+			LedgerDebit:      d.Ledger.Debit,
+			LedgerCredit:     d.Ledger.Credit,
+			LedgerAdjustment: d.Ledger.Adjustment,
+		})
+	}
+	orphans, err := s.dbLedger.FindOrphanOperations(ctx)
+	if err != nil {
+		s.log.Error("LedgerService: Check. Can't find orphan operations", zap.Error(err))
+		return nil, err
+	}
+	for _, o := range orphans {
+		report.OrphanOperations = append(report.OrphanOperations, dto.LedgerOperation{
+			ID:            o.ID,
+			AccountID:     o.AccountID,
+			OrderID:       o.OrderID,
+			OrderNum:      o.OrderNum,
+			OperationType: o.OperationType,
+			Amount:        o.Amount,
+			ProcessedAt:   o.ProcessedAt,
+		})
+	}
+	orders, err := s.dbLedger.FindProcessedOrdersWithoutCredit(ctx)
+	if err != nil {
+		s.log.Error("LedgerService: Check. Can't find processed orders without credit", zap.Error(err))
+		return nil, err
+	}
+	for _, o := range orders {
+		report.OrdersWithoutCredit = append(report.OrdersWithoutCredit, dto.LedgerOrder{
+			ID:     o.ID,
+			UserID: o.UserID,
+			Num:    o.Num,
+			Status: o.Status,
+		})
+	}
+
+	if !report.IsConsistent() {
+		s.log.Warn("LedgerService: Check. Ledger inconsistency found",
+			zap.Int("accountMismatches", len(report.AccountMismatches)),
+			zap.Int("orphanOperations", len(report.OrphanOperations)),
+			zap.Int("ordersWithoutCredit", len(report.OrdersWithoutCredit)),
+		)
+	}
+	if fix {
+		for _, d := range discrepancies {
+			correction, err := s.correctAccount(ctx, d.Account.UserID, report.CheckedAt)
+			if err != nil {
+				return nil, err
+			}
+			report.Corrections = append(report.Corrections, *correction)
+		}
+	}
+	return &report, nil
+}
+
+func (s *LedgerService) correctAccount(ctx context.Context, userID int, now time.Time) (*dto.LedgerCorrection, error) {
+	account, err := s.dbBalance.LockAccount(ctx, userID)
+	if err != nil {
+		s.log.Error("LedgerService: correctAccount. Can't lock account", zap.Int("userID", userID), zap.Error(err))
+		return nil, err
+	}
+	// Суммы пересчитываются после блокировки: все операции по счету создаются под этой же блокировкой
+	totals, err := s.dbLedger.GetLedgerTotals(ctx, account.ID)
+	if err != nil {
+		s.log.Error("LedgerService: correctAccount. Can't get ledger totals", zap.Int("accountID", account.ID), zap.Error(err))
+		return nil, err
+	}
+	correction := dto.LedgerCorrection{AccountID: account.ID}
+	ledgerBalance := totals.Credit - totals.Debit + totals.Adjustment
+	diff := account.Balance - ledgerBalance
+	if model.LedgerMismatch(account.Balance, ledgerBalance) {
+		operation := model.Operation{
+			AccountID:     account.ID,
+			OperationType: model.OperationAdjustment,
+			Amount:        diff,
+			Reason:        model.OperationReasonAuditCorrection,
+			ProcessedAt:   now,
+		}
+		if err = s.dbBalance.CreateOperation(ctx, &operation); err != nil {
+			s.log.Error("LedgerService: correctAccount. Can't create operation", zap.Int("accountID", account.ID), zap.Error(err))
+			return nil, err
+		}
+		correction.OperationType = operation.OperationType
+		correction.Amount = operation.Amount
+	}
+	account.Credit = totals.Credit
+	account.Debit = totals.Debit
+	if err = s.dbBalance.SaveAccount(ctx, account); err != nil {
+		s.log.Error("LedgerService: correctAccount. Can't save account", zap.Int("accountID", account.ID), zap.Error(err))
+		return nil, err
+	}
+	s.log.Info("LedgerService: account corrected",
+		zap.Int("accountID", account.ID),
+		zap.String("operationType", correction.OperationType),
+		zap.Float32("amount", correction.Amount),
+	)
+	return &correction, nil
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/service/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLedgerService_Check(t *testing.T) {
+	type args struct {
+		fix           bool
+		discrepancies []model.AccountDiscrepancy
+		ledger        model.LedgerTotals
+		orphans       []model.Operation
+	}
+	type wants struct {
+		consistent     bool
+		repairable     bool
+		savedAccount   *model.Account
+		adjustment     float32
+		operationCount int
+	}
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name:  "LedgerService. Check. Case 1. Consistent ledger",
+			args:  args{fix: true},
+			wants: wants{consistent: true, repairable: true},
+		},
+		{
+			name: "LedgerService. Check. Case 2. Report only",
+			args: args{
+				fix: false,
+				discrepancies: []model.AccountDiscrepancy{
+					{Account: model.Account{ID: 10, UserID: 1, Balance: 100, Credit: 100}, Ledger: model.LedgerTotals{Credit: 80}},
+				},
+			},
+			wants: wants{consistent: false, repairable: true},
+		},
+		{
+			name: "LedgerService. Check. Case 3. Balance greater than ledger",
+			args: args{
+				fix: true,
+				discrepancies: []model.AccountDiscrepancy{
+					{Account: model.Account{ID: 10, UserID: 1, Balance: 100, Credit: 100}, Ledger: model.LedgerTotals{Credit: 80}},
+				},
+				ledger: model.LedgerTotals{Credit: 80},
+			},
+			wants: wants{
+				consistent:     false,
+				repairable:     true,
+				savedAccount:   &model.Account{ID: 10, UserID: 1, Balance: 100, Credit: 80},
+				adjustment:     20,
+				operationCount: 1,
+			},
+		},
+		{
+			name: "LedgerService. Check. Case 4. Balance less than ledger",
+			args: args{
+				fix: true,
+				discrepancies: []model.AccountDiscrepancy{
+					{Account: model.Account{ID: 10, UserID: 1, Balance: 50, Credit: 100, Debit: 30}, Ledger: model.LedgerTotals{Credit: 100, Debit: 30}},
+				},
+				ledger: model.LedgerTotals{Credit: 100, Debit: 30},
+			},
+			wants: wants{
+				consistent:     false,
+				repairable:     true,
+				savedAccount:   &model.Account{ID: 10, UserID: 1, Balance: 50, Credit: 100, Debit: 30},
+				adjustment:     -20,
+				operationCount: 1,
+			},
+		},
+		{
+			name: "LedgerService. Check. Case 5. Only aggregates differ",
+			args: args{
+				fix: true,
+				discrepancies: []model.AccountDiscrepancy{
+					{Account: model.Account{ID: 10, UserID: 1, Balance: 80, Credit: 90, Debit: 20}, Ledger: model.LedgerTotals{Credit: 100, Debit: 30, Adjustment: 10}},
+				},
+				ledger: model.LedgerTotals{Credit: 100, Debit: 30, Adjustment: 10},
+			},
+			wants: wants{
+				consistent:     false,
+				repairable:     true,
+				savedAccount:   &model.Account{ID: 10, UserID: 1, Balance: 80, Credit: 100, Debit: 30},
+				operationCount: 0,
+			},
+		},
+		{
+			name: "LedgerService. Check. Case 6. Orphan operation is not repaired",
+			args: args{
+				fix:     true,
+				orphans: []model.Operation{{ID: 5, AccountID: 99, OperationType: model.OperationCredit, Amount: 10}},
+			},
+			wants: wants{consistent: false, repairable: false},
+		},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			ledgerRepository := mocks.NewMockLedgerRepository(mockCtrl)
+			balanceRepository := mocks.NewMockBalanceRepository(mockCtrl)
+			target := NewLedgerService(ledgerRepository, balanceRepository, log)
+
+			ledgerRepository.EXPECT().CountAccounts(ctx).Return(1, nil)
+			ledgerRepository.EXPECT().FindAccountDiscrepancies(ctx).Return(tt.args.discrepancies, nil)
+			ledgerRepository.EXPECT().FindOrphanOperations(ctx).Return(tt.args.orphans, nil)
+			ledgerRepository.EXPECT().FindProcessedOrdersWithoutCredit(ctx).Return(nil, nil)
+			if tt.args.fix && len(tt.args.discrepancies) > 0 {
+				account := tt.args.discrepancies[0].Account
+				balanceRepository.EXPECT().LockAccount(ctx, account.UserID).Return(&account, nil)
+				ledger := tt.args.ledger
+				ledgerRepository.EXPECT().GetLedgerTotals(ctx, account.ID).Return(&ledger, nil)
+				balanceRepository.EXPECT().CreateOperation(ctx, gomock.Any()).DoAndReturn(
+					func(ctx context.Context, op *model.Operation) error {
+						assert.Equal(t, model.OperationAdjustment, op.OperationType)
+						assert.Equal(t, tt.wants.adjustment, op.Amount)
+						assert.Equal(t, model.OperationReasonAuditCorrection, op.Reason)
+						return nil
+					}).Times(tt.wants.operationCount)
+				balanceRepository.EXPECT().SaveAccount(ctx, tt.wants.savedAccount).Return(nil)
+			}
+
+			report, err := target.Check(ctx, tt.args.fix)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wants.consistent, report.IsConsistent())
+			assert.Equal(t, tt.wants.repairable, report.IsRepairable())
+			if tt.args.fix {
+				assert.Len(t, report.Corrections, len(tt.args.discrepancies))
+			} else {
+				assert.Empty(t, report.Corrections)
+			}
+		})
+	}
+}
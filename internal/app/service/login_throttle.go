@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+// LoginThrottleRules - ограничения на неудачные попытки входа. Нулевое значение правила означает, что оно не применяется
+type LoginThrottleRules struct {
+	// MaxFailures - число неудач подряд по одному логину, после которого логин блокируется на Lockout
+	MaxFailures int
+	// IPMaxFailures - то же для адреса клиента, по всем логинам
+	IPMaxFailures int
+	Lockout       time.Duration
+	// FailureWindow - неудачи старше этого срока не учитываются
+	FailureWindow time.Duration
+	// После k-й неудачи следующая попытка по логину разрешена через DelayBase * 2^(k-1), но не больше DelayMax
+	DelayBase time.Duration
+	DelayMax  time.Duration
+}
+
+type LoginThrottle struct {
+	dbAttempt model.LoginAttemptRepository
+	audit     *SecurityAuditLog
+	rules     LoginThrottleRules
+	log       *infrastructure.Logger
+}
+
+func NewLoginThrottle(attemptRepo model.LoginAttemptRepository, audit *SecurityAuditLog, rules LoginThrottleRules, log *infrastructure.Logger) *LoginThrottle {
+	var target LoginThrottle
+	target.dbAttempt = attemptRepo
+	target.audit = audit
+	target.rules = rules
+	target.log = log
+	return &target
+}
+
+func loginKey(login string) string {
+	return "login:" + strings.ToLower(login)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// delay - пауза перед следующей попыткой после failures неудач
+func (t *LoginThrottle) delay(failures int) time.Duration {
+	if t.rules.DelayBase <= 0 || failures <= 0 {
+		return 0
+	}
+	d := t.rules.DelayBase
+	// не больше 30 удвоений, чтобы не переполнить time.Duration
+	for i := 1; i < failures && i <= 30 && (t.rules.DelayMax <= 0 || d < t.rules.DelayMax); i++ {
+		d *= 2
+	}
+	if t.rules.DelayMax > 0 && d > t.rules.DelayMax {
+		return t.rules.DelayMax
+	}
+	return d
+}
+
+// Check возвращает *dto.LockoutError, если попытку входа нужно отклонить без проверки пароля
+func (t *LoginThrottle) Check(ctx context.Context, login string, ip string) error {
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, key := range []string{loginKey(login), ipKey(ip)} {
+		attempt, err := t.dbAttempt.Get(ctx, key)
+		if err != nil {
+			if errors.Is(err, &model.NoRowFound) {
+				continue
+			}
+			t.log.Error("LoginThrottle: Check. Can't get login attempts", zap.String("key", key), zap.Error(err))
+			return err
+		}
+		if t.rules.FailureWindow > 0 && attempt.LastFailureAt.Before(now.Add(-t.rules.FailureWindow)) {
+			continue
+		}
+		wait := attempt.LockedUntil.Sub(now)
+		if key == loginKey(login) {
+			if d := attempt.LastFailureAt.Add(t.delay(attempt.Failures)).Sub(now); d > wait {
+				wait = d
+			}
+		}
+		if wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	if retryAfter > 0 {
+		t.log.Warn("LoginThrottle: login attempt rejected",
+			zap.String("login", login),
+			zap.String("ip", ip),
+			zap.Duration("retryAfter", retryAfter),
+		)
+		return &dto.LockoutError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Failure учитывает неудачную попытку и блокирует логин или адрес при превышении лимита
+func (t *LoginThrottle) Failure(ctx context.Context, login string, ip string) error {
+	now := time.Now()
+	windowStart := time.Time{}
+	if t.rules.FailureWindow > 0 {
+		windowStart = now.Add(-t.rules.FailureWindow)
+	}
+	limits := []struct {
+		key         string
+		maxFailures int
+	}{
+		{key: loginKey(login), maxFailures: t.rules.MaxFailures},
+		{key: ipKey(ip), maxFailures: t.rules.IPMaxFailures},
+	}
+	for _, limit := range limits {
+		attempt, err := t.dbAttempt.RegisterFailure(ctx, limit.key, now, windowStart)
+		if err != nil {
+			t.log.Error("LoginThrottle: Failure. Can't register failure", zap.String("key", limit.key), zap.Error(err))
+			return err
+		}
+		if limit.maxFailures <= 0 || attempt.Failures < limit.maxFailures || t.rules.Lockout <= 0 {
+			continue
+		}
+		lockedUntil := now.Add(t.rules.Lockout)
+		if err = t.dbAttempt.LockUntil(ctx, limit.key, lockedUntil); err != nil {
+			t.log.Error("LoginThrottle: Failure. Can't lock", zap.String("key", limit.key), zap.Error(err))
+			return err
+		}
+		t.audit.Record(ctx, &dto.SecurityEvent{
+			Action:    dto.AuditLoginLockout,
+			Result:    dto.AuditFailure,
+			Login:     login,
+			IP:        ip,
+			Details:   fmt.Sprintf("%s locked until %s after %d failures", limit.key, lockedUntil.Format(time.RFC3339), attempt.Failures),
+			CreatedAt: now,
+		})
+	}
+	return nil
+}
+
+// Success сбрасывает счетчик неудач по логину. Счетчик по адресу не сбрасывается: иначе перебор по многим логинам
+// с одного адреса можно чередовать с успешным входом в свою учетную запись
+func (t *LoginThrottle) Success(ctx context.Context, login string) error {
+	if err := t.dbAttempt.Reset(ctx, loginKey(login)); err != nil {
+		t.log.Error("LoginThrottle: Success. Can't reset login attempts", zap.String("login", login), zap.Error(err))
+		return err
+	}
+	return nil
+}
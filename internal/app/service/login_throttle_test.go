@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/service/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// newLoginAttemptStorage - хранилище попыток входа в памяти поверх мока репозитория
+func newLoginAttemptStorage(repo *mocks.MockLoginAttemptRepository) map[string]*model.LoginAttempt {
+	stored := make(map[string]*model.LoginAttempt)
+	repo.EXPECT().Get(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, key string) (*model.LoginAttempt, error) {
+			if a, ok := stored[key]; ok {
+				res := *a
+				return &res, nil
+			}
+			return nil, &model.NoRowFound
+		}).AnyTimes()
+	repo.EXPECT().RegisterFailure(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, key string, now time.Time, windowStart time.Time) (*model.LoginAttempt, error) {
+			a, ok := stored[key]
+			if !ok {
+				a = &model.LoginAttempt{Key: key}
+				stored[key] = a
+			}
+			if a.LastFailureAt.Before(windowStart) {
+				a.Failures = 0
+			}
+			a.Failures++
+			a.LastFailureAt = now
+			res := *a
+			return &res, nil
+		}).AnyTimes()
+	repo.EXPECT().LockUntil(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, key string, until time.Time) error {
+			stored[key].LockedUntil = until
+			return nil
+		}).AnyTimes()
+	repo.EXPECT().Reset(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, key string) error {
+			delete(stored, key)
+			return nil
+		}).AnyTimes()
+	return stored
+}
+
+func TestLoginThrottle_Lockout(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	ctx := context.Background()
+	repo := mocks.NewMockLoginAttemptRepository(mockCtrl)
+	stored := newLoginAttemptStorage(repo)
+	events := mocks.NewMockSecurityEventRepository(mockCtrl)
+	var lockouts []string
+	events.EXPECT().Save(ctx, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, e *model.SecurityEvent) error {
+			assert.Equal(t, dto.AuditLoginLockout, e.Action)
+			assert.Equal(t, dto.AuditFailure, e.Result)
+			lockouts = append(lockouts, e.Login+"@"+e.IP)
+			return nil
+		}).AnyTimes()
+	target := NewLoginThrottle(repo, NewSecurityAuditLog(events, log), LoginThrottleRules{
+		MaxFailures:   3,
+		IPMaxFailures: 5,
+		Lockout:       time.Hour,
+		FailureWindow: time.Hour,
+	}, log)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, target.Check(ctx, "User", "10.0.0.1"))
+		require.NoError(t, target.Failure(ctx, "User", "10.0.0.1"))
+	}
+	require.NoError(t, target.Check(ctx, "user", "10.0.0.1"))
+	require.NoError(t, target.Success(ctx, "user"))
+	assert.NotContains(t, stored, "login:user")
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, target.Failure(ctx, "user", "10.0.0.2"))
+	}
+	err := target.Check(ctx, "user", "10.0.0.3")
+	var lockoutErr *dto.LockoutError
+	if assert.ErrorAs(t, err, &lockoutErr) {
+		assert.ErrorIs(t, err, dto.ErrTooManyAttempts)
+		assert.InDelta(t, time.Hour.Seconds(), lockoutErr.RetryAfter.Seconds(), 5)
+	}
+	assert.NoError(t, target.Check(ctx, "other", "10.0.0.3"), "other logins are not locked")
+
+	// перебор логинов с одного адреса блокирует адрес
+	assert.NoError(t, target.Failure(ctx, "first", "10.0.0.1"))
+	assert.NoError(t, target.Failure(ctx, "second", "10.0.0.1"))
+	assert.NoError(t, target.Failure(ctx, "third", "10.0.0.1"))
+	assert.ErrorIs(t, target.Check(ctx, "fourth", "10.0.0.1"), dto.ErrTooManyAttempts)
+	assert.NoError(t, target.Check(ctx, "fourth", "10.0.0.4"))
+	assert.Equal(t, []string{"user@10.0.0.2", "third@10.0.0.1"}, lockouts)
+
+	// блокировка истекла
+	stored["login:user"].LockedUntil = time.Now().Add(-time.Second)
+	stored["login:user"].LastFailureAt = time.Now().Add(-2 * time.Hour)
+	assert.NoError(t, target.Check(ctx, "user", "10.0.0.3"))
+}
+
+func TestLoginThrottle_Delay(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	ctx := context.Background()
+	repo := mocks.NewMockLoginAttemptRepository(mockCtrl)
+	newLoginAttemptStorage(repo)
+	target := NewLoginThrottle(repo, NewSecurityAuditLog(nil, log), LoginThrottleRules{
+		DelayBase: time.Second,
+		DelayMax:  10 * time.Second,
+	}, log)
+
+	assert.Equal(t, time.Duration(0), target.delay(0))
+	assert.Equal(t, time.Second, target.delay(1))
+	assert.Equal(t, 2*time.Second, target.delay(2))
+	assert.Equal(t, 8*time.Second, target.delay(4))
+	assert.Equal(t, 10*time.Second, target.delay(5))
+	assert.Equal(t, 10*time.Second, target.delay(1000))
+
+	require.NoError(t, target.Failure(ctx, "user", "10.0.0.1"))
+	require.NoError(t, target.Failure(ctx, "user", "10.0.0.1"))
+	var lockoutErr *dto.LockoutError
+	if assert.ErrorAs(t, target.Check(ctx, "user", "10.0.0.1"), &lockoutErr) {
+		assert.InDelta(t, 2, lockoutErr.RetryAfter.Seconds(), 0.5)
+	}
+}
@@ -0,0 +1,94 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/portnyagin/practicum_project/internal/app/model (interfaces: AdjustmentRepository)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	model "github.com/portnyagin/practicum_project/internal/app/model"
+)
+
+// MockAdjustmentRepository is a mock of AdjustmentRepository interface.
+type MockAdjustmentRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockAdjustmentRepositoryMockRecorder
+}
+
+// MockAdjustmentRepositoryMockRecorder is the mock recorder for MockAdjustmentRepository.
+type MockAdjustmentRepositoryMockRecorder struct {
+	mock *MockAdjustmentRepository
+}
+
+// NewMockAdjustmentRepository creates a new mock instance.
+func NewMockAdjustmentRepository(ctrl *gomock.Controller) *MockAdjustmentRepository {
+	mock := &MockAdjustmentRepository{ctrl: ctrl}
+	mock.recorder = &MockAdjustmentRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAdjustmentRepository) EXPECT() *MockAdjustmentRepositoryMockRecorder {
+	return m.recorder
+}
+
+// FindByStatus mocks base method.
+func (m *MockAdjustmentRepository) FindByStatus(arg0 context.Context, arg1 string) ([]model.Adjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByStatus", arg0, arg1)
+	ret0, _ := ret[0].([]model.Adjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByStatus indicates an expected call of FindByStatus.
+func (mr *MockAdjustmentRepositoryMockRecorder) FindByStatus(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByStatus", reflect.TypeOf((*MockAdjustmentRepository)(nil).FindByStatus), arg0, arg1)
+}
+
+// Lock mocks base method.
+func (m *MockAdjustmentRepository) Lock(arg0 context.Context, arg1 int) (*model.Adjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lock", arg0, arg1)
+	ret0, _ := ret[0].(*model.Adjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Lock indicates an expected call of Lock.
+func (mr *MockAdjustmentRepositoryMockRecorder) Lock(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lock", reflect.TypeOf((*MockAdjustmentRepository)(nil).Lock), arg0, arg1)
+}
+
+// Save mocks base method.
+func (m *MockAdjustmentRepository) Save(arg0 context.Context, arg1 *model.Adjustment) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockAdjustmentRepositoryMockRecorder) Save(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockAdjustmentRepository)(nil).Save), arg0, arg1)
+}
+
+// Update mocks base method.
+func (m *MockAdjustmentRepository) Update(arg0 context.Context, arg1 *model.Adjustment) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockAdjustmentRepositoryMockRecorder) Update(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockAdjustmentRepository)(nil).Update), arg0, arg1)
+}
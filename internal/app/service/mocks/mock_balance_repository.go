@@ -7,6 +7,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	model "github.com/portnyagin/practicum_project/internal/app/model"
@@ -49,6 +50,36 @@ func (mr *MockBalanceRepositoryMockRecorder) CreateOperation(arg0, arg1 interfac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOperation", reflect.TypeOf((*MockBalanceRepository)(nil).CreateOperation), arg0, arg1)
 }
 
+// FindStatementByUser mocks base method.
+func (m *MockBalanceRepository) FindStatementByUser(arg0 context.Context, arg1 int) ([]model.StatementEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindStatementByUser", arg0, arg1)
+	ret0, _ := ret[0].([]model.StatementEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindStatementByUser indicates an expected call of FindStatementByUser.
+func (mr *MockBalanceRepositoryMockRecorder) FindStatementByUser(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindStatementByUser", reflect.TypeOf((*MockBalanceRepository)(nil).FindStatementByUser), arg0, arg1)
+}
+
+// FindWithdrawalByOrder mocks base method.
+func (m *MockBalanceRepository) FindWithdrawalByOrder(arg0 context.Context, arg1 string) ([]model.Operation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindWithdrawalByOrder", arg0, arg1)
+	ret0, _ := ret[0].([]model.Operation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindWithdrawalByOrder indicates an expected call of FindWithdrawalByOrder.
+func (mr *MockBalanceRepositoryMockRecorder) FindWithdrawalByOrder(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindWithdrawalByOrder", reflect.TypeOf((*MockBalanceRepository)(nil).FindWithdrawalByOrder), arg0, arg1)
+}
+
 // FindWithdrawalByUser mocks base method.
 func (m *MockBalanceRepository) FindWithdrawalByUser(arg0 context.Context, arg1 int) ([]model.Withdrawal, error) {
 	m.ctrl.T.Helper()
@@ -79,6 +110,51 @@ func (mr *MockBalanceRepositoryMockRecorder) GetAccount(arg0, arg1 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccount", reflect.TypeOf((*MockBalanceRepository)(nil).GetAccount), arg0, arg1)
 }
 
+// GetLastCreditTime mocks base method.
+func (m *MockBalanceRepository) GetLastCreditTime(arg0 context.Context, arg1 int, arg2 float32) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLastCreditTime", arg0, arg1, arg2)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLastCreditTime indicates an expected call of GetLastCreditTime.
+func (mr *MockBalanceRepositoryMockRecorder) GetLastCreditTime(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLastCreditTime", reflect.TypeOf((*MockBalanceRepository)(nil).GetLastCreditTime), arg0, arg1, arg2)
+}
+
+// GetTransferredSum mocks base method.
+func (m *MockBalanceRepository) GetTransferredSum(arg0 context.Context, arg1 int, arg2 time.Time) (float32, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferredSum", arg0, arg1, arg2)
+	ret0, _ := ret[0].(float32)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferredSum indicates an expected call of GetTransferredSum.
+func (mr *MockBalanceRepositoryMockRecorder) GetTransferredSum(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferredSum", reflect.TypeOf((*MockBalanceRepository)(nil).GetTransferredSum), arg0, arg1, arg2)
+}
+
+// GetWithdrawnSum mocks base method.
+func (m *MockBalanceRepository) GetWithdrawnSum(arg0 context.Context, arg1 int, arg2 time.Time) (float32, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithdrawnSum", arg0, arg1, arg2)
+	ret0, _ := ret[0].(float32)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWithdrawnSum indicates an expected call of GetWithdrawnSum.
+func (mr *MockBalanceRepositoryMockRecorder) GetWithdrawnSum(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawnSum", reflect.TypeOf((*MockBalanceRepository)(nil).GetWithdrawnSum), arg0, arg1, arg2)
+}
+
 // LockAccount mocks base method.
 func (m *MockBalanceRepository) LockAccount(arg0 context.Context, arg1 int) (*model.Account, error) {
 	m.ctrl.T.Helper()
@@ -107,3 +183,17 @@ func (mr *MockBalanceRepositoryMockRecorder) SaveAccount(arg0, arg1 interface{})
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAccount", reflect.TypeOf((*MockBalanceRepository)(nil).SaveAccount), arg0, arg1)
 }
+
+// StreamStatementByUser mocks base method.
+func (m *MockBalanceRepository) StreamStatementByUser(arg0 context.Context, arg1 int, arg2 func(*model.StatementEntry) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamStatementByUser", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamStatementByUser indicates an expected call of StreamStatementByUser.
+func (mr *MockBalanceRepositoryMockRecorder) StreamStatementByUser(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamStatementByUser", reflect.TypeOf((*MockBalanceRepository)(nil).StreamStatementByUser), arg0, arg1, arg2)
+}
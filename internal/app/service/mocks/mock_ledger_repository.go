@@ -0,0 +1,111 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/portnyagin/practicum_project/internal/app/model (interfaces: LedgerRepository)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	model "github.com/portnyagin/practicum_project/internal/app/model"
+)
+
+// MockLedgerRepository is a mock of LedgerRepository interface.
+type MockLedgerRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockLedgerRepositoryMockRecorder
+}
+
+// MockLedgerRepositoryMockRecorder is the mock recorder for MockLedgerRepository.
+type MockLedgerRepositoryMockRecorder struct {
+	mock *MockLedgerRepository
+}
+
+// NewMockLedgerRepository creates a new mock instance.
+func NewMockLedgerRepository(ctrl *gomock.Controller) *MockLedgerRepository {
+	mock := &MockLedgerRepository{ctrl: ctrl}
+	mock.recorder = &MockLedgerRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLedgerRepository) EXPECT() *MockLedgerRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CountAccounts mocks base method.
+func (m *MockLedgerRepository) CountAccounts(arg0 context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountAccounts", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountAccounts indicates an expected call of CountAccounts.
+func (mr *MockLedgerRepositoryMockRecorder) CountAccounts(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountAccounts", reflect.TypeOf((*MockLedgerRepository)(nil).CountAccounts), arg0)
+}
+
+// FindAccountDiscrepancies mocks base method.
+func (m *MockLedgerRepository) FindAccountDiscrepancies(arg0 context.Context) ([]model.AccountDiscrepancy, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAccountDiscrepancies", arg0)
+	ret0, _ := ret[0].([]model.AccountDiscrepancy)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAccountDiscrepancies indicates an expected call of FindAccountDiscrepancies.
+func (mr *MockLedgerRepositoryMockRecorder) FindAccountDiscrepancies(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAccountDiscrepancies", reflect.TypeOf((*MockLedgerRepository)(nil).FindAccountDiscrepancies), arg0)
+}
+
+// FindOrphanOperations mocks base method.
+func (m *MockLedgerRepository) FindOrphanOperations(arg0 context.Context) ([]model.Operation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOrphanOperations", arg0)
+	ret0, _ := ret[0].([]model.Operation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOrphanOperations indicates an expected call of FindOrphanOperations.
+func (mr *MockLedgerRepositoryMockRecorder) FindOrphanOperations(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOrphanOperations", reflect.TypeOf((*MockLedgerRepository)(nil).FindOrphanOperations), arg0)
+}
+
+// FindProcessedOrdersWithoutCredit mocks base method.
+func (m *MockLedgerRepository) FindProcessedOrdersWithoutCredit(arg0 context.Context) ([]model.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindProcessedOrdersWithoutCredit", arg0)
+	ret0, _ := ret[0].([]model.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindProcessedOrdersWithoutCredit indicates an expected call of FindProcessedOrdersWithoutCredit.
+func (mr *MockLedgerRepositoryMockRecorder) FindProcessedOrdersWithoutCredit(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindProcessedOrdersWithoutCredit", reflect.TypeOf((*MockLedgerRepository)(nil).FindProcessedOrdersWithoutCredit), arg0)
+}
+
+// GetLedgerTotals mocks base method.
+func (m *MockLedgerRepository) GetLedgerTotals(arg0 context.Context, arg1 int) (*model.LedgerTotals, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLedgerTotals", arg0, arg1)
+	ret0, _ := ret[0].(*model.LedgerTotals)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLedgerTotals indicates an expected call of GetLedgerTotals.
+func (mr *MockLedgerRepositoryMockRecorder) GetLedgerTotals(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLedgerTotals", reflect.TypeOf((*MockLedgerRepository)(nil).GetLedgerTotals), arg0, arg1)
+}
@@ -0,0 +1,66 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/portnyagin/practicum_project/internal/app/service (interfaces: OIDCProvider)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	dto "github.com/portnyagin/practicum_project/internal/app/dto"
+)
+
+// MockOIDCProvider is a mock of OIDCProvider interface.
+type MockOIDCProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockOIDCProviderMockRecorder
+}
+
+// MockOIDCProviderMockRecorder is the mock recorder for MockOIDCProvider.
+type MockOIDCProviderMockRecorder struct {
+	mock *MockOIDCProvider
+}
+
+// NewMockOIDCProvider creates a new mock instance.
+func NewMockOIDCProvider(ctrl *gomock.Controller) *MockOIDCProvider {
+	mock := &MockOIDCProvider{ctrl: ctrl}
+	mock.recorder = &MockOIDCProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOIDCProvider) EXPECT() *MockOIDCProviderMockRecorder {
+	return m.recorder
+}
+
+// AuthCodeURL mocks base method.
+func (m *MockOIDCProvider) AuthCodeURL(arg0 context.Context, arg1, arg2, arg3 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthCodeURL", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuthCodeURL indicates an expected call of AuthCodeURL.
+func (mr *MockOIDCProviderMockRecorder) AuthCodeURL(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthCodeURL", reflect.TypeOf((*MockOIDCProvider)(nil).AuthCodeURL), arg0, arg1, arg2, arg3)
+}
+
+// Exchange mocks base method.
+func (m *MockOIDCProvider) Exchange(arg0 context.Context, arg1, arg2 string) (*dto.OIDCIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Exchange", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*dto.OIDCIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Exchange indicates an expected call of Exchange.
+func (mr *MockOIDCProviderMockRecorder) Exchange(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exchange", reflect.TypeOf((*MockOIDCProvider)(nil).Exchange), arg0, arg1, arg2)
+}
@@ -0,0 +1,79 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/portnyagin/practicum_project/internal/app/model (interfaces: SecurityEventRepository)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	model "github.com/portnyagin/practicum_project/internal/app/model"
+)
+
+// MockSecurityEventRepository is a mock of SecurityEventRepository interface.
+type MockSecurityEventRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockSecurityEventRepositoryMockRecorder
+}
+
+// MockSecurityEventRepositoryMockRecorder is the mock recorder for MockSecurityEventRepository.
+type MockSecurityEventRepositoryMockRecorder struct {
+	mock *MockSecurityEventRepository
+}
+
+// NewMockSecurityEventRepository creates a new mock instance.
+func NewMockSecurityEventRepository(ctrl *gomock.Controller) *MockSecurityEventRepository {
+	mock := &MockSecurityEventRepository{ctrl: ctrl}
+	mock.recorder = &MockSecurityEventRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSecurityEventRepository) EXPECT() *MockSecurityEventRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Find mocks base method.
+func (m *MockSecurityEventRepository) Find(arg0 context.Context, arg1 *model.SecurityEventFilter) ([]model.SecurityEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Find", arg0, arg1)
+	ret0, _ := ret[0].([]model.SecurityEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Find indicates an expected call of Find.
+func (mr *MockSecurityEventRepositoryMockRecorder) Find(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockSecurityEventRepository)(nil).Find), arg0, arg1)
+}
+
+// Save mocks base method.
+func (m *MockSecurityEventRepository) Save(arg0 context.Context, arg1 *model.SecurityEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockSecurityEventRepositoryMockRecorder) Save(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockSecurityEventRepository)(nil).Save), arg0, arg1)
+}
+
+// Stream mocks base method.
+func (m *MockSecurityEventRepository) Stream(arg0 context.Context, arg1 *model.SecurityEventFilter, arg2 func(*model.SecurityEvent) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stream", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Stream indicates an expected call of Stream.
+func (mr *MockSecurityEventRepositoryMockRecorder) Stream(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stream", reflect.TypeOf((*MockSecurityEventRepository)(nil).Stream), arg0, arg1, arg2)
+}
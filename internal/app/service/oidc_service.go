@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+//go:generate mockgen -destination=mocks/mock_oidc_provider.go -package=mocks . OIDCProvider
+type OIDCProvider interface {
+	AuthCodeURL(ctx context.Context, state string, nonce string, codeChallenge string) (string, error)
+	Exchange(ctx context.Context, code string, verifier string) (*dto.OIDCIdentity, error)
+}
+
+// oidcNoPassword - хеш пароля пользователя, созданного при входе через провайдер. Ни один алгоритм PasswordHasher
+// не дает такого значения, поэтому войти по паролю нельзя
+const oidcNoPassword = "!"
+
+// oidcMaxLoginAttempts - число вариантов логина нового пользователя, если предложенный провайдером логин занят
+const oidcMaxLoginAttempts = 10
+
+type OIDCService struct {
+	provider   OIDCProvider
+	dbUser     model.UserRepository
+	dbIdentity model.IdentityRepository
+	log        *infrastructure.Logger
+}
+
+func NewOIDCService(provider OIDCProvider, userRepo model.UserRepository, identityRepo model.IdentityRepository, log *infrastructure.Logger) *OIDCService {
+	var target OIDCService
+	target.provider = provider
+	target.dbUser = userRepo
+	target.dbIdentity = identityRepo
+	target.log = log
+	return &target
+}
+
+// Begin начинает вход через провайдер: генерирует state, nonce и PKCE verifier и возвращает адрес страницы входа
+func (s *OIDCService) Begin(ctx context.Context) (*dto.OIDCAuthRequest, error) {
+	var (
+		req dto.OIDCAuthRequest
+		err error
+	)
+	if req.State, err = NewReference(); err != nil {
+		s.log.Error("OIDCService: Begin. Can't generate state", zap.Error(err))
+		return nil, err
+	}
+	if req.Nonce, err = NewReference(); err != nil {
+		s.log.Error("OIDCService: Begin. Can't generate nonce", zap.Error(err))
+		return nil, err
+	}
+	if req.Verifier, err = newCodeVerifier(); err != nil {
+		s.log.Error("OIDCService: Begin. Can't generate code verifier", zap.Error(err))
+		return nil, err
+	}
+	req.URL, err = s.provider.AuthCodeURL(ctx, req.State, req.Nonce, codeChallenge(req.Verifier))
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// Complete обменивает код на id_token и возвращает привязанного к учетной записи провайдера пользователя.
+// При первом входе пользователь создается вместе со счетом. Существующий пользователь с тем же логином или email
+// автоматически не привязывается: иначе владелец учетной записи провайдера получил бы чужой аккаунт
+func (s *OIDCService) Complete(ctx context.Context, code string, verifier string, nonce string) (*dto.User, error) {
+	if code == "" || verifier == "" || nonce == "" {
+		return nil, dto.ErrBadParam
+	}
+	identity, err := s.provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(identity.Nonce), []byte(nonce)) != 1 {
+		s.log.Info("OIDCService: Complete. Nonce mismatch", zap.String("subject", identity.Subject))
+		return nil, dto.ErrUnauthorized
+	}
+
+	link, err := s.dbIdentity.Get(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		user, err := s.dbUser.GetUserByID(ctx, link.UserID)
+		if err != nil {
+			if errors.Is(err, &model.NoRowFound) {
+				s.log.Info("OIDCService: Complete. Linked user is deactivated", zap.Int("userID", link.UserID))
+				return nil, dto.ErrUnauthorized
+			}
+			s.log.Error("OIDCService: Complete. Can't get user", zap.Int("userID", link.UserID), zap.Error(err))
+			return nil, err
+		}
+		return &dto.User{ID: user.ID, Login: user.Login}, nil
+	}
+	if !errors.Is(err, &model.NoRowFound) {
+		s.log.Error("OIDCService: Complete. Can't get identity", zap.String("subject", identity.Subject), zap.Error(err))
+		return nil, err
+	}
+	return s.register(ctx, identity)
+}
+
+// register создает пользователя так же, как UserRepository.Save при обычной регистрации, и привязывает к нему учетную запись провайдера
+func (s *OIDCService) register(ctx context.Context, identity *dto.OIDCIdentity) (*dto.User, error) {
+	login, err := s.freeLogin(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := s.dbUser.Save(ctx, login, oidcNoPassword)
+	if err != nil {
+		if errors.Is(err, &model.UniqueViolation) {
+			return nil, dto.ErrDuplicateKey
+		}
+		s.log.Error("OIDCService: Can't create user", zap.String("login", login), zap.Error(err))
+		return nil, err
+	}
+	err = s.dbIdentity.Save(ctx, &model.Identity{
+		Issuer:    identity.Issuer,
+		Subject:   identity.Subject,
+		UserID:    userID,
+		Email:     identity.Email,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		if errors.Is(err, &model.UniqueViolation) {
+			// параллельный первый вход той же учетной записи
+			return nil, dto.ErrDuplicateKey
+		}
+		s.log.Error("OIDCService: Can't link identity", zap.Int("userID", userID), zap.Error(err))
+		return nil, err
+	}
+	s.log.Info("OIDCService: user registered by provider", zap.String("login", login), zap.String("issuer", identity.Issuer))
+	return &dto.User{ID: userID, Login: login}, nil
+}
+
+// freeLogin подбирает логин нового пользователя: preferred_username, email, иначе производный от subject.
+// Если логин занят, добавляется числовой суффикс
+func (s *OIDCService) freeLogin(ctx context.Context, identity *dto.OIDCIdentity) (string, error) {
+	base := strings.TrimSpace(identity.PreferredUsername)
+	if base == "" && identity.EmailVerified {
+		base = strings.TrimSpace(identity.Email)
+	}
+	if base == "" {
+		base = "oidc_" + identity.Subject
+	}
+	login := base
+	for i := 1; i <= oidcMaxLoginAttempts; i++ {
+		_, err := s.dbUser.GetUserByLogin(ctx, login)
+		if errors.Is(err, &model.NoRowFound) {
+			return login, nil
+		}
+		if err != nil {
+			s.log.Error("OIDCService: Can't check login", zap.String("login", login), zap.Error(err))
+			return "", err
+		}
+		login = fmt.Sprintf("%s_%d", base, i+1)
+	}
+	s.log.Warn("OIDCService: no free login for identity", zap.String("login", base))
+	return "", dto.ErrDuplicateKey
+}
+
+// newCodeVerifier - PKCE verifier из 43 символов base64url (RFC 7636)
+func newCodeVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func codeChallenge(verifier string) string {
+	h := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
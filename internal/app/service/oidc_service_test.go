@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/service/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestOIDCService_Begin(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	ctx := context.Background()
+	provider := mocks.NewMockOIDCProvider(mockCtrl)
+	target := NewOIDCService(provider, nil, nil, log)
+
+	var challenge string
+	provider.EXPECT().AuthCodeURL(ctx, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, state string, nonce string, codeChallenge string) (string, error) {
+			challenge = codeChallenge
+			return "http://idp/authorize?state=" + state, nil
+		})
+
+	req, err := target.Begin(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "http://idp/authorize?state="+req.State, req.URL)
+	assert.NotEmpty(t, req.Nonce)
+	assert.NotEqual(t, req.State, req.Nonce)
+	assert.Len(t, req.Verifier, 43)
+	assert.Equal(t, codeChallenge(req.Verifier), challenge)
+	assert.NotEqual(t, req.Verifier, challenge, "verifier must not be sent to provider")
+}
+
+func TestOIDCService_Complete(t *testing.T) {
+	identity := dto.OIDCIdentity{
+		Issuer:            "http://idp",
+		Subject:           "subject-1",
+		Email:             "user@example.com",
+		EmailVerified:     true,
+		PreferredUsername: "user",
+		Nonce:             "nonce-1",
+	}
+	type wants struct {
+		err  error
+		user *dto.User
+	}
+	tests := []struct {
+		name    string
+		nonce   string
+		prepare func(users *mocks.MockUserRepository, identities *mocks.MockIdentityRepository)
+		wants   wants
+	}{
+		{
+			name:  "OIDCService. Complete. Case #1. Linked user",
+			nonce: "nonce-1",
+			prepare: func(users *mocks.MockUserRepository, identities *mocks.MockIdentityRepository) {
+				identities.EXPECT().Get(gomock.Any(), "http://idp", "subject-1").Return(&model.Identity{UserID: 7}, nil)
+				users.EXPECT().GetUserByID(gomock.Any(), 7).Return(&model.User{ID: 7, Login: "local"}, nil)
+			},
+			wants: wants{user: &dto.User{ID: 7, Login: "local"}},
+		},
+		{
+			name:  "OIDCService. Complete. Case #2. First login, preferred username is taken",
+			nonce: "nonce-1",
+			prepare: func(users *mocks.MockUserRepository, identities *mocks.MockIdentityRepository) {
+				identities.EXPECT().Get(gomock.Any(), "http://idp", "subject-1").Return(nil, &model.NoRowFound)
+				users.EXPECT().GetUserByLogin(gomock.Any(), "user").Return(&model.User{ID: 1, Login: "user"}, nil)
+				users.EXPECT().GetUserByLogin(gomock.Any(), "user_2").Return(nil, &model.NoRowFound)
+				users.EXPECT().Save(gomock.Any(), "user_2", oidcNoPassword).Return(8, nil)
+				identities.EXPECT().Save(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, i *model.Identity) error {
+						assert.Equal(t, model.Identity{Issuer: "http://idp", Subject: "subject-1", UserID: 8, Email: "user@example.com", CreatedAt: i.CreatedAt}, *i)
+						return nil
+					})
+			},
+			wants: wants{user: &dto.User{ID: 8, Login: "user_2"}},
+		},
+		{
+			name:  "OIDCService. Complete. Case #3. Nonce mismatch",
+			nonce: "nonce-2",
+			wants: wants{err: dto.ErrUnauthorized},
+		},
+		{
+			name:  "OIDCService. Complete. Case #4. Linked user is deactivated",
+			nonce: "nonce-1",
+			prepare: func(users *mocks.MockUserRepository, identities *mocks.MockIdentityRepository) {
+				identities.EXPECT().Get(gomock.Any(), "http://idp", "subject-1").Return(&model.Identity{UserID: 7}, nil)
+				users.EXPECT().GetUserByID(gomock.Any(), 7).Return(nil, &model.NoRowFound)
+			},
+			wants: wants{err: dto.ErrUnauthorized},
+		},
+		{
+			name:  "OIDCService. Complete. Case #5. Concurrent first login",
+			nonce: "nonce-1",
+			prepare: func(users *mocks.MockUserRepository, identities *mocks.MockIdentityRepository) {
+				identities.EXPECT().Get(gomock.Any(), "http://idp", "subject-1").Return(nil, &model.NoRowFound)
+				users.EXPECT().GetUserByLogin(gomock.Any(), "user").Return(nil, &model.NoRowFound)
+				users.EXPECT().Save(gomock.Any(), "user", oidcNoPassword).Return(8, nil)
+				identities.EXPECT().Save(gomock.Any(), gomock.Any()).Return(&model.UniqueViolation)
+			},
+			wants: wants{err: dto.ErrDuplicateKey},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			provider := mocks.NewMockOIDCProvider(mockCtrl)
+			users := mocks.NewMockUserRepository(mockCtrl)
+			identities := mocks.NewMockIdentityRepository(mockCtrl)
+			target := NewOIDCService(provider, users, identities, log)
+			i := identity
+			provider.EXPECT().Exchange(gomock.Any(), "code", "verifier").Return(&i, nil)
+			if tt.prepare != nil {
+				tt.prepare(users, identities)
+			}
+
+			user, err := target.Complete(context.Background(), "code", "verifier", tt.nonce)
+			if tt.wants.err != nil {
+				assert.ErrorIs(t, err, tt.wants.err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.wants.user, user)
+			}
+		})
+	}
+}
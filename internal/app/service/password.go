@@ -0,0 +1,132 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+const (
+	PasswordHashBcrypt   = "bcrypt"
+	PasswordHashArgon2id = "argon2id"
+)
+
+// PasswordHashing - параметры хеширования паролей. Хеши со слабыми параметрами пересчитываются при входе пользователя
+type PasswordHashing struct {
+	Algorithm     string
+	BcryptCost    int
+	Argon2Time    uint32
+	Argon2Memory  uint32 // KiB
+	Argon2Threads uint8
+}
+
+type PasswordHasher struct {
+	params PasswordHashing
+}
+
+func NewPasswordHasher(params PasswordHashing) (*PasswordHasher, error) {
+	switch params.Algorithm {
+	case PasswordHashBcrypt:
+		if params.BcryptCost < bcrypt.MinCost || params.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case PasswordHashArgon2id:
+		if params.Argon2Time == 0 || params.Argon2Memory == 0 || params.Argon2Threads == 0 {
+			return nil, fmt.Errorf("argon2id parameters must be positive")
+		}
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %s", params.Algorithm)
+	}
+	return &PasswordHasher{params: params}, nil
+}
+
+func (h *PasswordHasher) Hash(pass string) (string, error) {
+	if h.params.Algorithm == PasswordHashArgon2id {
+		return h.hashArgon2id(pass)
+	}
+	b, err := bcrypt.GenerateFromPassword([]byte(pass), h.params.BcryptCost)
+	return string(b), err
+}
+
+// Verify проверяет пароль по хешу любого поддерживаемого алгоритма, независимо от текущей настройки
+func (h *PasswordHasher) Verify(pass string, hash string) bool {
+	if strings.HasPrefix(hash, "$"+PasswordHashArgon2id+"$") {
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false
+		}
+		actual := argon2.IDKey([]byte(pass), salt, params.Argon2Time, params.Argon2Memory, params.Argon2Threads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(actual, key) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass)) == nil
+}
+
+// NeedsRehash - хеш получен другим алгоритмом или с параметрами слабее текущих
+func (h *PasswordHasher) NeedsRehash(hash string) bool {
+	if strings.HasPrefix(hash, "$"+PasswordHashArgon2id+"$") {
+		if h.params.Algorithm != PasswordHashArgon2id {
+			return true
+		}
+		params, _, _, err := decodeArgon2id(hash)
+		if err != nil {
+			return true
+		}
+		return params.Argon2Time < h.params.Argon2Time ||
+			params.Argon2Memory < h.params.Argon2Memory ||
+			params.Argon2Threads < h.params.Argon2Threads
+	}
+	if h.params.Algorithm != PasswordHashBcrypt {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost < h.params.BcryptCost
+}
+
+const argon2KeyLen = 32
+
+// hashArgon2id кодирует хеш в формате PHC: $argon2id$v=19$m=65536,t=1,p=4$<salt>$<key>
+func (h *PasswordHasher) hashArgon2id(pass string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(pass), salt, h.params.Argon2Time, h.params.Argon2Memory, h.params.Argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		PasswordHashArgon2id,
+		argon2.Version,
+		h.params.Argon2Memory,
+		h.params.Argon2Time,
+		h.params.Argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func decodeArgon2id(hash string) (params PasswordHashing, salt []byte, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash")
+	}
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, err
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Argon2Memory, &params.Argon2Time, &params.Argon2Threads); err != nil {
+		return params, nil, nil, err
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, err
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return params, nil, nil, err
+	}
+	params.Algorithm = PasswordHashArgon2id
+	return params, salt, key, nil
+}
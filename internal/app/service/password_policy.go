@@ -0,0 +1,67 @@
+package service
+
+import (
+	"bufio"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultBannedPasswords - самые распространенные пароли, запрещенные всегда
+var defaultBannedPasswords = []string{
+	"123456", "12345678", "123456789", "1234567890", "111111", "000000", "123123", "654321",
+	"password", "password1", "qwerty", "qwerty123", "qwertyuiop", "abc123", "iloveyou", "admin", "letmein", "welcome",
+}
+
+type PasswordPolicy struct {
+	minLength int
+	banned    map[string]struct{}
+}
+
+func NewPasswordPolicy(minLength int, banned []string) *PasswordPolicy {
+	var target PasswordPolicy
+	target.minLength = minLength
+	target.banned = make(map[string]struct{}, len(defaultBannedPasswords)+len(banned))
+	for _, list := range [][]string{defaultBannedPasswords, banned} {
+		for _, p := range list {
+			if p = strings.TrimSpace(p); p != "" {
+				target.banned[strings.ToLower(p)] = struct{}{}
+			}
+		}
+	}
+	return &target
+}
+
+// LoadBannedPasswords читает список запрещенных паролей: по одному паролю в строке
+func LoadBannedPasswords(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var res []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		res = append(res, scanner.Text())
+	}
+	return res, scanner.Err()
+}
+
+// Validate возвращает *dto.ValidationError со всеми нарушениями политики
+func (p *PasswordPolicy) Validate(login string, pass string) error {
+	var violations []dto.Violation
+	if utf8.RuneCountInString(pass) < p.minLength {
+		violations = append(violations, dto.Violation{Field: "password", Code: dto.PasswordTooShort})
+	}
+	if _, ok := p.banned[strings.ToLower(pass)]; ok {
+		violations = append(violations, dto.Violation{Field: "password", Code: dto.PasswordBanned})
+	}
+	if login != "" && strings.EqualFold(login, pass) {
+		violations = append(violations, dto.Violation{Field: "password", Code: dto.PasswordEqualsLogin})
+	}
+	if len(violations) > 0 {
+		return &dto.ValidationError{Violations: violations}
+	}
+	return nil
+}
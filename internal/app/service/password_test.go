@@ -0,0 +1,79 @@
+package service
+
+import (
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPasswordHasher(t *testing.T) {
+	bcrypt4, err := NewPasswordHasher(PasswordHashing{Algorithm: PasswordHashBcrypt, BcryptCost: 4})
+	require.NoError(t, err)
+	bcrypt6, err := NewPasswordHasher(PasswordHashing{Algorithm: PasswordHashBcrypt, BcryptCost: 6})
+	require.NoError(t, err)
+	argonLight, err := NewPasswordHasher(PasswordHashing{Algorithm: PasswordHashArgon2id, Argon2Time: 1, Argon2Memory: 1024, Argon2Threads: 1})
+	require.NoError(t, err)
+	argonHeavy, err := NewPasswordHasher(PasswordHashing{Algorithm: PasswordHashArgon2id, Argon2Time: 2, Argon2Memory: 1024, Argon2Threads: 1})
+	require.NoError(t, err)
+
+	weak, err := bcrypt4.Hash("secret pass")
+	require.NoError(t, err)
+	argonHash, err := argonLight.Hash("secret pass")
+	require.NoError(t, err)
+
+	for _, h := range []*PasswordHasher{bcrypt4, bcrypt6, argonLight, argonHeavy} {
+		assert.True(t, h.Verify("secret pass", weak))
+		assert.True(t, h.Verify("secret pass", argonHash))
+		assert.False(t, h.Verify("other pass", weak))
+		assert.False(t, h.Verify("other pass", argonHash))
+	}
+
+	assert.False(t, bcrypt4.NeedsRehash(weak))
+	assert.True(t, bcrypt6.NeedsRehash(weak), "weaker bcrypt cost")
+	assert.True(t, argonLight.NeedsRehash(weak), "algorithm changed")
+	assert.True(t, bcrypt4.NeedsRehash(argonHash), "algorithm changed")
+	assert.False(t, argonLight.NeedsRehash(argonHash))
+	assert.True(t, argonHeavy.NeedsRehash(argonHash), "weaker argon2id parameters")
+
+	_, err = NewPasswordHasher(PasswordHashing{Algorithm: PasswordHashBcrypt, BcryptCost: 1})
+	assert.Error(t, err)
+	_, err = NewPasswordHasher(PasswordHashing{Algorithm: "md5"})
+	assert.Error(t, err)
+}
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		login string
+		pass  string
+		codes []string
+	}{
+		{name: "PasswordPolicy. Case 1. Valid", login: "user", pass: "correct horse", codes: nil},
+		{name: "PasswordPolicy. Case 2. Too short", login: "user", pass: "short", codes: []string{dto.PasswordTooShort}},
+		{name: "PasswordPolicy. Case 3. Banned by default", login: "user", pass: "Password1", codes: []string{dto.PasswordBanned}},
+		{name: "PasswordPolicy. Case 4. Banned by list", login: "user", pass: "gophermart", codes: []string{dto.PasswordBanned}},
+		{name: "PasswordPolicy. Case 5. Equals login", login: "long_login", pass: "Long_Login", codes: []string{dto.PasswordEqualsLogin}},
+		{name: "PasswordPolicy. Case 6. Several violations", login: "qwerty", pass: "qwerty", codes: []string{dto.PasswordTooShort, dto.PasswordBanned, dto.PasswordEqualsLogin}},
+	}
+	policy := NewPasswordPolicy(8, []string{"GopherMart", " "})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.login, tt.pass)
+			if tt.codes == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, dto.ErrBadParam)
+			var validationErr *dto.ValidationError
+			if assert.ErrorAs(t, err, &validationErr) {
+				var codes []string
+				for _, v := range validationErr.Violations {
+					assert.Equal(t, "password", v.Field)
+					codes = append(codes, v.Code)
+				}
+				assert.Equal(t, tt.codes, codes)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	SecurityEventDefaultLimit = 100
+	SecurityEventMaxLimit     = 1000
+)
+
+// SecurityAuditLog - журнал событий безопасности. События сохраняются в базе и дублируются в лог сервиса
+type SecurityAuditLog struct {
+	dbEvent model.SecurityEventRepository
+	log     *infrastructure.Logger
+}
+
+func NewSecurityAuditLog(eventRepo model.SecurityEventRepository, log *infrastructure.Logger) *SecurityAuditLog {
+	var target SecurityAuditLog
+	target.dbEvent = eventRepo
+	target.log = log
+	return &target
+}
+
+// Record сохраняет событие. Ошибка записи журнала не прерывает действие пользователя и только попадает в лог
+func (a *SecurityAuditLog) Record(ctx context.Context, event *dto.SecurityEvent) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	if event.Result == "" {
+		event.Result = dto.AuditSuccess
+	}
+	fields := []zap.Field{
+		zap.String("action", event.Action),
+		zap.String("result", event.Result),
+		zap.Int("userID", event.UserID),
+		zap.String("login", event.Login),
+		zap.String("target", event.Target),
+		zap.String("ip", event.IP),
+		zap.String("details", event.Details),
+		zap.Time("createdAt", event.CreatedAt),
+	}
+	if event.Result == dto.AuditSuccess {
+		a.log.Info("security event", fields...)
+	} else {
+		a.log.Warn("security event", fields...)
+	}
+	e := model.SecurityEvent{
+		Action:    event.Action,
+		Result:    event.Result,
+		UserID:    event.UserID,
+		Login:     event.Login,
+		Target:    event.Target,
+		IP:        event.IP,
+		UserAgent: event.UserAgent,
+		Details:   event.Details,
+		CreatedAt: event.CreatedAt,
+	}
+	if err := a.dbEvent.Save(ctx, &e); err != nil {
+		a.log.Error("SecurityAuditLog: Can't save security event", zap.String("action", event.Action), zap.Error(err))
+		return
+	}
+	event.ID = e.ID
+}
+
+// Find возвращает события журнала от новых к старым, не больше SecurityEventMaxLimit за запрос
+func (a *SecurityAuditLog) Find(ctx context.Context, filter *dto.SecurityEventFilter) ([]dto.SecurityEvent, error) {
+	f, err := securityEventFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	if f.Limit == 0 {
+		f.Limit = SecurityEventDefaultLimit
+	}
+	if f.Limit > SecurityEventMaxLimit {
+		f.Limit = SecurityEventMaxLimit
+	}
+	events, err := a.dbEvent.Find(ctx, f)
+	if err != nil {
+		a.log.Error("SecurityAuditLog: Find. Can't get security events", zap.Error(err))
+		return nil, err
+	}
+	var res []dto.SecurityEvent
+	for _, e := range events {
+		res = append(res, mapSecurityEventModelToDTO(&e))
+	}
+	return res, nil
+}
+
+// Export передает fn события журнала по одной от новых к старым. Лимит 0 выгружает все события по фильтру
+func (a *SecurityAuditLog) Export(ctx context.Context, filter *dto.SecurityEventFilter, fn func(event *dto.SecurityEvent) error) error {
+	f, err := securityEventFilter(filter)
+	if err != nil {
+		return err
+	}
+	if f.Limit == 0 {
+		f.Limit = -1
+	}
+	err = a.dbEvent.Stream(ctx, f, func(e *model.SecurityEvent) error {
+		event := mapSecurityEventModelToDTO(e)
+		return fn(&event)
+	})
+	if err != nil {
+		a.log.Error("SecurityAuditLog: Export. Can't export security events", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func securityEventFilter(filter *dto.SecurityEventFilter) (*model.SecurityEventFilter, error) {
+	if filter == nil {
+		return nil, dto.ErrBadParam
+	}
+	if filter.UserID < 0 || filter.Limit < 0 || (!filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To)) {
+		return nil, dto.ErrBadParam
+	}
+	return &model.SecurityEventFilter{
+		UserID: filter.UserID,
+		Login:  filter.Login,
+		Action: filter.Action,
+		From:   filter.From,
+		To:     filter.To,
+		Limit:  filter.Limit,
+	}, nil
+}
+
+func mapSecurityEventModelToDTO(e *model.SecurityEvent) dto.SecurityEvent {
+	return dto.SecurityEvent{
+		ID:        e.ID,
+		Action:    e.Action,
+		Result:    e.Result,
+		UserID:    e.UserID,
+		Login:     e.Login,
+		Target:    e.Target,
+		IP:        e.IP,
+		UserAgent: e.UserAgent,
+		Details:   e.Details,
+		CreatedAt: e.CreatedAt,
+	}
+}
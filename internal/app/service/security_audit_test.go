@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/service/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSecurityAuditLog_Record(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	ctx := context.Background()
+	events := mocks.NewMockSecurityEventRepository(mockCtrl)
+	target := NewSecurityAuditLog(events, log)
+
+	events.EXPECT().Save(ctx, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, e *model.SecurityEvent) error {
+			assert.Equal(t, dto.AuditLogin, e.Action)
+			assert.Equal(t, dto.AuditSuccess, e.Result, "result defaults to success")
+			assert.False(t, e.CreatedAt.IsZero())
+			e.ID = 42
+			return nil
+		})
+	event := dto.SecurityEvent{Action: dto.AuditLogin, UserID: 1, Login: "user"}
+	target.Record(ctx, &event)
+	assert.Equal(t, int64(42), event.ID)
+
+	// ошибка записи журнала не возвращается вызывающей стороне
+	events.EXPECT().Save(ctx, gomock.Any()).Return(errors.New("db is down"))
+	target.Record(ctx, &dto.SecurityEvent{Action: dto.AuditLogin, Result: dto.AuditFailure})
+}
+
+func TestSecurityAuditLog_Find(t *testing.T) {
+	from := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		filter    *dto.SecurityEventFilter
+		wantLimit int
+		wantErr   error
+	}{
+		{
+			name:      "SecurityAuditLog. Find. Case #1. Default limit",
+			filter:    &dto.SecurityEventFilter{UserID: 7, Action: dto.AuditLogin, From: from},
+			wantLimit: SecurityEventDefaultLimit,
+		},
+		{
+			name:      "SecurityAuditLog. Find. Case #2. Limit above maximum",
+			filter:    &dto.SecurityEventFilter{Limit: 100000},
+			wantLimit: SecurityEventMaxLimit,
+		},
+		{
+			name:    "SecurityAuditLog. Find. Case #3. Empty time range",
+			filter:  &dto.SecurityEventFilter{From: from, To: from},
+			wantErr: dto.ErrBadParam,
+		},
+		{
+			name:    "SecurityAuditLog. Find. Case #4. Negative limit",
+			filter:  &dto.SecurityEventFilter{Limit: -1},
+			wantErr: dto.ErrBadParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			events := mocks.NewMockSecurityEventRepository(mockCtrl)
+			target := NewSecurityAuditLog(events, log)
+			if tt.wantErr == nil {
+				events.EXPECT().Find(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, f *model.SecurityEventFilter) ([]model.SecurityEvent, error) {
+						assert.Equal(t, tt.wantLimit, f.Limit)
+						assert.Equal(t, tt.filter.UserID, f.UserID)
+						assert.Equal(t, tt.filter.Action, f.Action)
+						assert.Equal(t, tt.filter.From, f.From)
+						return []model.SecurityEvent{{ID: 1, Action: dto.AuditLogin, UserID: 7}}, nil
+					})
+			}
+			res, err := target.Find(context.Background(), tt.filter)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, []dto.SecurityEvent{{ID: 1, Action: dto.AuditLogin, UserID: 7}}, res)
+			}
+		})
+	}
+}
+
+func TestSecurityAuditLog_Export(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    *dto.SecurityEventFilter
+		wantLimit int
+		wantErr   error
+	}{
+		{
+			name:      "SecurityAuditLog. Export. Case #1. No limit",
+			filter:    &dto.SecurityEventFilter{Action: dto.AuditLogin},
+			wantLimit: -1,
+		},
+		{
+			name:      "SecurityAuditLog. Export. Case #2. Limit above maximum is kept",
+			filter:    &dto.SecurityEventFilter{Limit: 100000},
+			wantLimit: 100000,
+		},
+		{
+			name:    "SecurityAuditLog. Export. Case #3. Negative limit",
+			filter:  &dto.SecurityEventFilter{Limit: -1},
+			wantErr: dto.ErrBadParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			events := mocks.NewMockSecurityEventRepository(mockCtrl)
+			target := NewSecurityAuditLog(events, log)
+			if tt.wantErr == nil {
+				events.EXPECT().Stream(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, f *model.SecurityEventFilter, fn func(event *model.SecurityEvent) error) error {
+						assert.Equal(t, tt.wantLimit, f.Limit)
+						assert.Equal(t, tt.filter.Action, f.Action)
+						for _, e := range []model.SecurityEvent{{ID: 2, Action: dto.AuditLogin}, {ID: 1, Action: dto.AuditLogin}} {
+							if err := fn(&e); err != nil {
+								return err
+							}
+						}
+						return nil
+					})
+			}
+			var res []int64
+			err := target.Export(context.Background(), tt.filter, func(event *dto.SecurityEvent) error {
+				res = append(res, event.ID)
+				return nil
+			})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, []int64{2, 1}, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"go.uber.org/zap"
+	"time"
+)
+
+type SessionService struct {
+	dbSession model.SessionRepository
+	dbUser    model.UserRepository
+	tx        model.UnitOfWork
+	log       *infrastructure.Logger
+	// refreshTTL - время жизни refresh-токена. Каждое обновление продлевает сессию на этот срок
+	refreshTTL time.Duration
+}
+
+func NewSessionService(sessionRepo model.SessionRepository, userRepo model.UserRepository, tx model.UnitOfWork, log *infrastructure.Logger, refreshTTL time.Duration) *SessionService {
+	var target SessionService
+	target.dbSession = sessionRepo
+	target.dbUser = userRepo
+	target.tx = tx
+	target.log = log
+	target.refreshTTL = refreshTTL
+	return &target
+}
+
+// hashToken - хеш случайного токена (refresh-токена, API-ключа) для хранения в базе.
+// Токены длинные и случайные, поэтому медленный хеш паролей не нужен
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// Create открывает новую сессию пользователя и выдает первый refresh-токен
+func (s *SessionService) Create(ctx context.Context, userID int) (*dto.Session, error) {
+	sessionID, err := NewReference()
+	if err != nil {
+		s.log.Error("SessionService: Create. Can't generate session id", zap.Error(err))
+		return nil, err
+	}
+	refreshToken, err := NewReference()
+	if err != nil {
+		s.log.Error("SessionService: Create. Can't generate refresh token", zap.Error(err))
+		return nil, err
+	}
+	now := time.Now()
+	session := model.Session{
+		ID:          sessionID,
+		UserID:      userID,
+		RefreshHash: hashToken(refreshToken),
+		CreatedAt:   now,
+		RefreshedAt: now,
+		ExpiresAt:   now.Add(s.refreshTTL),
+	}
+	if err = s.dbSession.Save(ctx, &session); err != nil {
+		s.log.Error("SessionService: Create. Can't save session", zap.Int("userID", userID), zap.Error(err))
+		return nil, err
+	}
+	roles, err := s.roles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.Session{ID: session.ID, UserID: userID, Roles: roles, RefreshToken: refreshToken, ExpiresAt: session.ExpiresAt}, nil
+}
+
+// roles возвращает роли пользователя для access-токена. Изменение ролей вступает в силу при обновлении токена
+func (s *SessionService) roles(ctx context.Context, userID int) ([]string, error) {
+	roles, err := s.dbUser.GetRoles(ctx, userID)
+	if err != nil {
+		s.log.Error("SessionService: Can't get user roles", zap.Int("userID", userID), zap.Error(err))
+		return nil, err
+	}
+	return append([]string{model.RoleUser}, roles...), nil
+}
+
+// Refresh заменяет refresh-токен на новый. Повторное предъявление уже замененного токена означает его утечку:
+// сессия отзывается целиком. Обновление выполняется в собственной транзакции, маршрут работает без транзакции запроса:
+// отзыв должен быть зафиксирован, хотя клиент получает ошибку, а ответ с ошибкой откатывает транзакцию запроса
+func (s *SessionService) Refresh(ctx context.Context, refreshToken string) (*dto.Session, error) {
+	if refreshToken == "" {
+		return nil, dto.ErrBadParam
+	}
+	var (
+		res     *dto.Session
+		revoked bool
+	)
+	err := s.tx.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		var err error
+		res, revoked, err = s.refresh(ctx, refreshToken)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, dto.ErrSessionExpired
+	}
+	return res, nil
+}
+
+// refresh заменяет refresh-токен в транзакции ctx. revoked - сессия отозвана из-за повторного предъявления токена:
+// транзакцию нужно зафиксировать, а клиенту ответить ошибкой
+func (s *SessionService) refresh(ctx context.Context, refreshToken string) (*dto.Session, bool, error) {
+	hash := hashToken(refreshToken)
+	session, err := s.dbSession.LockByRefreshHash(ctx, hash)
+	if err != nil {
+		if errors.Is(err, &model.NoRowFound) {
+			s.log.Info("SessionService: Refresh. Unknown refresh token")
+			return nil, false, dto.ErrSessionExpired
+		}
+		s.log.Error("SessionService: Refresh. Can't get session", zap.Error(err))
+		return nil, false, err
+	}
+	now := time.Now()
+	if !session.IsActive(now) {
+		s.log.Info("SessionService: Refresh. Session is not active", zap.String("sessionID", session.ID))
+		return nil, false, dto.ErrSessionExpired
+	}
+	if session.RefreshHash != hash {
+		s.log.Warn("SessionService: Refresh. Refresh token reuse detected, session revoked",
+			zap.String("sessionID", session.ID),
+			zap.Int("userID", session.UserID),
+		)
+		if err = s.revoke(ctx, session, now); err != nil {
+			return nil, false, err
+		}
+		return nil, true, nil
+	}
+	user, err := s.dbUser.GetUserByID(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, &model.NoRowFound) {
+			s.log.Info("SessionService: Refresh. User not found", zap.Int("userID", session.UserID))
+			return nil, false, dto.ErrSessionExpired
+		}
+		s.log.Error("SessionService: Refresh. Can't get user", zap.Error(err))
+		return nil, false, err
+	}
+	newToken, err := NewReference()
+	if err != nil {
+		s.log.Error("SessionService: Refresh. Can't generate refresh token", zap.Error(err))
+		return nil, false, err
+	}
+	roles, err := s.roles(ctx, user.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	session.PreviousHash = session.RefreshHash
+	session.RefreshHash = hashToken(newToken)
+	session.RefreshedAt = now
+	session.ExpiresAt = now.Add(s.refreshTTL)
+	if err = s.dbSession.Update(ctx, session); err != nil {
+		s.log.Error("SessionService: Refresh. Can't update session", zap.Error(err))
+		return nil, false, err
+	}
+	return &dto.Session{
+		ID:           session.ID,
+		UserID:       user.ID,
+		Login:        user.Login,
+		Roles:        roles,
+		RefreshToken: newToken,
+		ExpiresAt:    session.ExpiresAt,
+	}, false, nil
+}
+
+func (s *SessionService) Revoke(ctx context.Context, sessionID string) error {
+	session, err := s.dbSession.Get(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, &model.NoRowFound) {
+			return dto.ErrNotFound
+		}
+		s.log.Error("SessionService: Revoke. Can't get session", zap.String("sessionID", sessionID), zap.Error(err))
+		return err
+	}
+	if !session.RevokedAt.IsZero() {
+		return nil
+	}
+	return s.revoke(ctx, session, time.Now())
+}
+
+func (s *SessionService) revoke(ctx context.Context, session *model.Session, now time.Time) error {
+	session.RevokedAt = now
+	if err := s.dbSession.Update(ctx, session); err != nil {
+		s.log.Error("SessionService: Can't revoke session", zap.String("sessionID", session.ID), zap.Error(err))
+		return err
+	}
+	s.log.Info("SessionService: session revoked", zap.String("sessionID", session.ID), zap.Int("userID", session.UserID))
+	return nil
+}
+
+// IsActive проверяет, что сессия access-токена не отозвана и не истекла, а пользователь не деактивирован
+func (s *SessionService) IsActive(ctx context.Context, sessionID string) (bool, error) {
+	if sessionID == "" {
+		return false, nil
+	}
+	session, err := s.dbSession.Get(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, &model.NoRowFound) {
+			return false, nil
+		}
+		s.log.Error("SessionService: IsActive. Can't get session", zap.String("sessionID", sessionID), zap.Error(err))
+		return false, err
+	}
+	if !session.IsActive(time.Now()) {
+		return false, nil
+	}
+	if _, err = s.dbUser.GetUserByID(ctx, session.UserID); err != nil {
+		if errors.Is(err, &model.NoRowFound) {
+			s.log.Info("SessionService: IsActive. User is deactivated", zap.Int("userID", session.UserID))
+			return false, nil
+		}
+		s.log.Error("SessionService: IsActive. Can't get user", zap.Int("userID", session.UserID), zap.Error(err))
+		return false, err
+	}
+	return true, nil
+}
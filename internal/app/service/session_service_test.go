@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/service/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSessionService_Refresh(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	ctx := context.Background()
+	sessionRepository := mocks.NewMockSessionRepository(mockCtrl)
+	userRepository := mocks.NewMockUserRepository(mockCtrl)
+	tx := mocks.NewMockUnitOfWork(mockCtrl)
+	target := NewSessionService(sessionRepository, userRepository, tx, log, time.Hour)
+
+	// хранилище из одной сессии. Транзакция, завершившаяся ошибкой, откатывает изменения сессии
+	var stored model.Session
+	tx.EXPECT().WithTx(ctx, pgx.TxOptions{}, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context) error) error {
+			saved := stored
+			err := fn(ctx)
+			if err != nil {
+				stored = saved
+			}
+			return err
+		}).AnyTimes()
+	sessionRepository.EXPECT().Save(ctx, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, s *model.Session) error {
+			stored = *s
+			return nil
+		})
+	sessionRepository.EXPECT().LockByRefreshHash(ctx, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, hash string) (*model.Session, error) {
+			if hash == stored.RefreshHash || hash == stored.PreviousHash {
+				s := stored
+				return &s, nil
+			}
+			return nil, &model.NoRowFound
+		}).AnyTimes()
+	sessionRepository.EXPECT().Update(ctx, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, s *model.Session) error {
+			stored = *s
+			return nil
+		}).AnyTimes()
+	userRepository.EXPECT().GetUserByID(ctx, 1).Return(&model.User{ID: 1, Login: "user"}, nil).AnyTimes()
+	userRepository.EXPECT().GetRoles(ctx, 1).Return([]string{model.RoleAdmin}, nil).AnyTimes()
+
+	created, err := target.Create(ctx, 1)
+	require.NoError(t, err)
+	assert.NotEqual(t, created.RefreshToken, stored.RefreshHash, "refresh token must be stored hashed")
+	assert.Equal(t, []string{model.RoleUser, model.RoleAdmin}, created.Roles)
+
+	refreshed, err := target.Refresh(ctx, created.RefreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, refreshed.ID)
+	assert.Equal(t, "user", refreshed.Login)
+	assert.NotEqual(t, created.RefreshToken, refreshed.RefreshToken, "refresh token must rotate")
+
+	_, err = target.Refresh(ctx, "unknown")
+	assert.ErrorIs(t, err, dto.ErrSessionExpired)
+
+	// повторное использование замененного токена отзывает сессию, отзыв фиксируется вместе с транзакцией
+	_, err = target.Refresh(ctx, created.RefreshToken)
+	assert.ErrorIs(t, err, dto.ErrSessionExpired)
+	assert.False(t, stored.RevokedAt.IsZero())
+
+	_, err = target.Refresh(ctx, refreshed.RefreshToken)
+	assert.ErrorIs(t, err, dto.ErrSessionExpired, "current refresh token of revoked session is rejected")
+}
+
+func TestSessionService_IsActive(t *testing.T) {
+	type args struct {
+		session     *model.Session
+		err         error
+		deactivated bool
+	}
+	tests := []struct {
+		name   string
+		args   args
+		active bool
+	}{
+		{
+			name:   "SessionService. IsActive. Case 1. Active",
+			args:   args{session: &model.Session{ID: "s", ExpiresAt: time.Now().Add(time.Hour)}},
+			active: true,
+		},
+		{
+			name:   "SessionService. IsActive. Case 2. Expired",
+			args:   args{session: &model.Session{ID: "s", ExpiresAt: time.Now().Add(-time.Minute)}},
+			active: false,
+		},
+		{
+			name:   "SessionService. IsActive. Case 3. Revoked",
+			args:   args{session: &model.Session{ID: "s", ExpiresAt: time.Now().Add(time.Hour), RevokedAt: time.Now()}},
+			active: false,
+		},
+		{
+			name:   "SessionService. IsActive. Case 4. Not found",
+			args:   args{err: &model.NoRowFound},
+			active: false,
+		},
+		{
+			name:   "SessionService. IsActive. Case 5. User deactivated",
+			args:   args{session: &model.Session{ID: "s", UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}, deactivated: true},
+			active: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			ctx := context.Background()
+			sessionRepository := mocks.NewMockSessionRepository(mockCtrl)
+			userRepository := mocks.NewMockUserRepository(mockCtrl)
+			target := NewSessionService(sessionRepository, userRepository, nil, log, time.Hour)
+			sessionRepository.EXPECT().Get(ctx, "s").Return(tt.args.session, tt.args.err)
+			if tt.args.deactivated {
+				userRepository.EXPECT().GetUserByID(ctx, gomock.Any()).Return(nil, &model.NoRowFound)
+			} else {
+				userRepository.EXPECT().GetUserByID(ctx, gomock.Any()).Return(&model.User{ID: 1}, nil).AnyTimes()
+			}
+
+			active, err := target.IsActive(ctx, "s")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.active, active)
+		})
+	}
+}
@@ -1,5 +1,19 @@
 package service
 
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// NewReference генерирует случайный идентификатор для связывания парных операций
+func NewReference() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 func CheckOrderNum(orderNum string) bool {
 	var (
 		number int
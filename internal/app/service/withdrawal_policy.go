@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"go.uber.org/zap"
+	"time"
+)
+
+// WithdrawalRules - правила списания баллов. Нулевое значение правила означает, что оно не применяется
+type WithdrawalRules struct {
+	MinAmount     float32
+	MaxAmount     float32
+	DailyLimit    float32
+	MonthlyLimit  float32
+	LargeAccrual  float32
+	CoolingPeriod time.Duration
+}
+
+type WithdrawalPolicy struct {
+	dbBalance model.BalanceRepository
+	rules     WithdrawalRules
+	log       *infrastructure.Logger
+}
+
+func NewWithdrawalPolicy(balanceRepo model.BalanceRepository, rules WithdrawalRules, log *infrastructure.Logger) *WithdrawalPolicy {
+	var target WithdrawalPolicy
+	target.dbBalance = balanceRepo
+	target.rules = rules
+	target.log = log
+	return &target
+}
+
+// Check возвращает *dto.PolicyError, если списание нарушает одно из правил
+func (p *WithdrawalPolicy) Check(ctx context.Context, account *model.Account, amount float32, now time.Time) error {
+	code, err := p.evaluate(ctx, account, amount, now)
+	if err != nil {
+		p.log.Error("WithdrawalPolicy: Check. Can't evaluate rules", zap.Error(err))
+		return err
+	}
+	if code != "" {
+		p.log.Warn("WithdrawalPolicy: withdrawal rejected",
+			zap.String("code", code),
+			zap.Int("userID", account.UserID),
+			zap.Int("accountID", account.ID),
+			zap.Float32("amount", amount),
+		)
+		return &dto.PolicyError{Code: code}
+	}
+	return nil
+}
+
+func (p *WithdrawalPolicy) evaluate(ctx context.Context, account *model.Account, amount float32, now time.Time) (string, error) {
+	if p.rules.MinAmount > 0 && amount < p.rules.MinAmount {
+		return dto.PolicyAmountTooSmall, nil
+	}
+	if p.rules.MaxAmount > 0 && amount > p.rules.MaxAmount {
+		return dto.PolicyAmountTooLarge, nil
+	}
+	if p.rules.DailyLimit > 0 {
+		dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		withdrawn, err := p.dbBalance.GetWithdrawnSum(ctx, account.ID, dayStart)
+		if err != nil {
+			return "", err
+		}
+		if withdrawn+amount > p.rules.DailyLimit {
+			return dto.PolicyDailyLimitExceeded, nil
+		}
+	}
+	if p.rules.MonthlyLimit > 0 {
+		monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		withdrawn, err := p.dbBalance.GetWithdrawnSum(ctx, account.ID, monthStart)
+		if err != nil {
+			return "", err
+		}
+		if withdrawn+amount > p.rules.MonthlyLimit {
+			return dto.PolicyMonthlyLimitExceeded, nil
+		}
+	}
+	// Крупным считается любое зачисление (начисление за заказ или входящий перевод) не меньше LargeAccrual
+	if p.rules.LargeAccrual > 0 && p.rules.CoolingPeriod > 0 {
+		lastCredit, err := p.dbBalance.GetLastCreditTime(ctx, account.ID, p.rules.LargeAccrual)
+		if err != nil {
+			return "", err
+		}
+		if now.Sub(lastCredit) < p.rules.CoolingPeriod {
+			return dto.PolicyCoolingPeriodActive, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/portnyagin/practicum_project/internal/app/dto"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/service/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestWithdrawalPolicy_Check(t *testing.T) {
+	now := time.Date(2021, 11, 15, 12, 0, 0, 0, time.UTC)
+	type args struct {
+		rules      WithdrawalRules
+		amount     float32
+		daySum     float32
+		monthSum   float32
+		lastCredit time.Time
+	}
+	tests := []struct {
+		name     string
+		args     args
+		wantCode string
+	}{
+		{
+			name:     "WithdrawalPolicy. Check. Case 1. No rules",
+			args:     args{amount: 100},
+			wantCode: "",
+		},
+		{
+			name:     "WithdrawalPolicy. Check. Case 2. Amount too small",
+			args:     args{rules: WithdrawalRules{MinAmount: 10}, amount: 5},
+			wantCode: dto.PolicyAmountTooSmall,
+		},
+		{
+			name:     "WithdrawalPolicy. Check. Case 3. Amount too large",
+			args:     args{rules: WithdrawalRules{MaxAmount: 10}, amount: 50},
+			wantCode: dto.PolicyAmountTooLarge,
+		},
+		{
+			name:     "WithdrawalPolicy. Check. Case 4. Daily limit exceeded",
+			args:     args{rules: WithdrawalRules{DailyLimit: 100}, amount: 50, daySum: 60},
+			wantCode: dto.PolicyDailyLimitExceeded,
+		},
+		{
+			name:     "WithdrawalPolicy. Check. Case 5. Monthly limit exceeded",
+			args:     args{rules: WithdrawalRules{DailyLimit: 100, MonthlyLimit: 500}, amount: 50, daySum: 10, monthSum: 460},
+			wantCode: dto.PolicyMonthlyLimitExceeded,
+		},
+		{
+			name: "WithdrawalPolicy. Check. Case 6. Cooling period",
+			args: args{rules: WithdrawalRules{LargeAccrual: 1000, CoolingPeriod: 24 * time.Hour}, amount: 50,
+				lastCredit: now.Add(-time.Hour)},
+			wantCode: dto.PolicyCoolingPeriodActive,
+		},
+		{
+			name: "WithdrawalPolicy. Check. Case 7. Cooling period is over",
+			args: args{rules: WithdrawalRules{LargeAccrual: 1000, CoolingPeriod: 24 * time.Hour}, amount: 50,
+				lastCredit: now.Add(-25 * time.Hour)},
+			wantCode: "",
+		},
+	}
+	ctx := context.Background()
+	account := &model.Account{ID: 10, UserID: 1, Balance: 1000}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			balanceRepository := mocks.NewMockBalanceRepository(mockCtrl)
+			balanceRepository.EXPECT().GetWithdrawnSum(ctx, 10, time.Date(2021, 11, 15, 0, 0, 0, 0, time.UTC)).Return(tt.args.daySum, nil).AnyTimes()
+			balanceRepository.EXPECT().GetWithdrawnSum(ctx, 10, time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)).Return(tt.args.monthSum, nil).AnyTimes()
+			balanceRepository.EXPECT().GetLastCreditTime(ctx, 10, tt.args.rules.LargeAccrual).Return(tt.args.lastCredit, nil).AnyTimes()
+			target := NewWithdrawalPolicy(balanceRepository, tt.args.rules, log)
+
+			err := target.Check(ctx, account, tt.args.amount, now)
+			if tt.wantCode == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, dto.ErrWithdrawalRejected)
+			assert.Equal(t, tt.wantCode, err.(*dto.PolicyError).Code)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	cfg "github.com/portnyagin/practicum_project/internal/app/config"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure"
+	"github.com/portnyagin/practicum_project/internal/app/infrastructure/postgres"
+	"github.com/portnyagin/practicum_project/internal/app/model"
+	"github.com/portnyagin/practicum_project/internal/app/mymiddleware"
+	"github.com/portnyagin/practicum_project/internal/app/repository"
+	"github.com/portnyagin/practicum_project/internal/app/repository/memory"
+)
+
+// txHandler - источник транзакций хранилища: для обработчиков запросов и для фоновых задач
+type txHandler interface {
+	mymiddleware.TxBeginner
+	model.UnitOfWork
+}
+
+// repositories - репозитории хранилища, выбранного настройкой Storage
+type repositories struct {
+	tx            txHandler
+	user          model.UserRepository
+	order         model.OrderRepository
+	balance       model.BalanceRepository
+	ledger        model.LedgerRepository
+	adjustment    model.AdjustmentRepository
+	session       model.SessionRepository
+	apiKey        model.APIKeyRepository
+	loginAttempt  model.LoginAttemptRepository
+	identity      model.IdentityRepository
+	securityEvent model.SecurityEventRepository
+}
+
+func newRepositories(ctx context.Context, config *cfg.AppConfig, logger *infrastructure.Logger) (*repositories, error) {
+	switch config.Storage {
+	case cfg.StoragePostgres:
+		return newPostgresRepositories(ctx, config, logger)
+	case cfg.StorageMemory:
+		logger.Warn("data is stored in memory and will be lost on shutdown")
+		return newMemoryRepositories(logger)
+	}
+	return nil, fmt.Errorf("unknown storage %s", config.Storage)
+}
+
+func newPostgresRepositories(ctx context.Context, config *cfg.AppConfig, logger *infrastructure.Logger) (*repositories, error) {
+	postgresHandlerTx, err := postgres.NewPostgresqlHandlerTXWithConfig(ctx, config.DatabaseDSN, postgres.PoolConfig{
+		MaxConns:                        config.DBMaxConns,
+		MinConns:                        config.DBMinConns,
+		MaxConnLifetime:                 config.DBMaxConnLifetime,
+		MaxConnIdleTime:                 config.DBMaxConnIdleTime,
+		StatementTimeout:                config.DBStatementTimeout,
+		LockTimeout:                     config.DBLockTimeout,
+		IdleInTransactionSessionTimeout: config.DBIdleInTransactionTimeout,
+		SlowQueryThreshold:              config.DBSlowQueryThreshold,
+		ReplicaDataSource:               config.DatabaseReplicaDSN,
+		ReplicaReadAfterWrite:           config.ReplicaReadAfterWrite,
+	}, logger)
+	if err != nil {
+		return nil, fmt.Errorf("can't create postgres handler: %w", err)
+	}
+	publishPoolStats(postgresHandlerTx)
+
+	if config.Reinit {
+		if err = reinitDatabase(ctx, config, postgresHandlerTx, logger); err != nil {
+			return nil, fmt.Errorf("can't reinit database: %w", err)
+		}
+	}
+	if err = repository.InitDatabase(ctx, postgresHandlerTx, logger); err != nil {
+		return nil, fmt.Errorf("can't init database structure: %w", err)
+	}
+	return postgresRepositories(postgresHandlerTx, logger)
+}
+
+func postgresRepositories(h *postgres.PostgresqlHandlerTX, logger *infrastructure.Logger) (*repositories, error) {
+	var (
+		target repositories
+		err    error
+	)
+	target.tx = h
+	if target.user, err = repository.NewUserRepository(h, logger); err != nil {
+		return nil, fmt.Errorf("can't init user repository: %w", err)
+	}
+	if target.order, err = repository.NewOrderRepository(h, logger); err != nil {
+		return nil, fmt.Errorf("can't init order repository: %w", err)
+	}
+	if target.balance, err = repository.NewBalanceRepository(h, logger); err != nil {
+		return nil, fmt.Errorf("can't init balance repository: %w", err)
+	}
+	if target.ledger, err = repository.NewLedgerRepository(h, logger); err != nil {
+		return nil, fmt.Errorf("can't init ledger repository: %w", err)
+	}
+	if target.adjustment, err = repository.NewAdjustmentRepository(h, logger); err != nil {
+		return nil, fmt.Errorf("can't init adjustment repository: %w", err)
+	}
+	if target.session, err = repository.NewSessionRepository(h, logger); err != nil {
+		return nil, fmt.Errorf("can't init session repository: %w", err)
+	}
+	if target.apiKey, err = repository.NewAPIKeyRepository(h, logger); err != nil {
+		return nil, fmt.Errorf("can't init api key repository: %w", err)
+	}
+	if target.loginAttempt, err = repository.NewLoginAttemptRepository(h, logger); err != nil {
+		return nil, fmt.Errorf("can't init login attempt repository: %w", err)
+	}
+	if target.identity, err = repository.NewIdentityRepository(h, logger); err != nil {
+		return nil, fmt.Errorf("can't init identity repository: %w", err)
+	}
+	if target.securityEvent, err = repository.NewSecurityEventRepository(h, logger); err != nil {
+		return nil, fmt.Errorf("can't init security event repository: %w", err)
+	}
+	return &target, nil
+}
+
+func newMemoryRepositories(logger *infrastructure.Logger) (*repositories, error) {
+	var (
+		target repositories
+		err    error
+	)
+	storage := memory.NewStorage()
+	target.tx = storage
+	if target.user, err = memory.NewUserRepository(storage, logger); err != nil {
+		return nil, fmt.Errorf("can't init user repository: %w", err)
+	}
+	if target.order, err = memory.NewOrderRepository(storage, logger); err != nil {
+		return nil, fmt.Errorf("can't init order repository: %w", err)
+	}
+	if target.balance, err = memory.NewBalanceRepository(storage, logger); err != nil {
+		return nil, fmt.Errorf("can't init balance repository: %w", err)
+	}
+	if target.ledger, err = memory.NewLedgerRepository(storage, logger); err != nil {
+		return nil, fmt.Errorf("can't init ledger repository: %w", err)
+	}
+	if target.adjustment, err = memory.NewAdjustmentRepository(storage, logger); err != nil {
+		return nil, fmt.Errorf("can't init adjustment repository: %w", err)
+	}
+	if target.session, err = memory.NewSessionRepository(storage, logger); err != nil {
+		return nil, fmt.Errorf("can't init session repository: %w", err)
+	}
+	if target.apiKey, err = memory.NewAPIKeyRepository(storage, logger); err != nil {
+		return nil, fmt.Errorf("can't init api key repository: %w", err)
+	}
+	if target.loginAttempt, err = memory.NewLoginAttemptRepository(storage, logger); err != nil {
+		return nil, fmt.Errorf("can't init login attempt repository: %w", err)
+	}
+	if target.identity, err = memory.NewIdentityRepository(storage, logger); err != nil {
+		return nil, fmt.Errorf("can't init identity repository: %w", err)
+	}
+	if target.securityEvent, err = memory.NewSecurityEventRepository(storage, logger); err != nil {
+		return nil, fmt.Errorf("can't init security event repository: %w", err)
+	}
+	return &target, nil
+}